@@ -0,0 +1,42 @@
+package csv
+
+import (
+	"reflect"
+	"sync"
+	"sync/atomic"
+)
+
+var (
+	// fieldCache maps a struct type to its *cachedFields
+	fieldCache sync.Map
+	// fieldCacheGen is bumped whenever cached metadata may have become stale
+	fieldCacheGen atomic.Uint64
+)
+
+// cachedFields is the result of collecting the fields of a type. It is shared between goroutines and must not be modified.
+type cachedFields struct {
+	gen    uint64
+	fields []fieldInfo
+	err    error
+}
+
+// cachedCollectFields is like collectFields but computes the fields of each type only once
+func cachedCollectFields(t reflect.Type) ([]fieldInfo, error) {
+	gen := fieldCacheGen.Load()
+	if v, ok := fieldCache.Load(t); ok {
+		if entry := v.(*cachedFields); entry.gen == gen {
+			return entry.fields, entry.err
+		}
+	}
+
+	fields, err := collectFields(t)
+	// An entry computed from names registered meanwhile carries the old generation and is recomputed on next use
+	fieldCache.Store(t, &cachedFields{gen: gen, fields: fields, err: err})
+	return fields, err
+}
+
+// invalidateFieldCache discards all cached metadata
+func invalidateFieldCache() {
+	fieldCacheGen.Add(1)
+	fieldCache.Clear()
+}
@@ -0,0 +1,69 @@
+package csv
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+)
+
+// TestConcurrentMarshalUnmarshal is meant to be run with -race, it shares the metadata cache between goroutines
+func TestConcurrentMarshalUnmarshal(t *testing.T) {
+	var wg sync.WaitGroup
+	for i := 0; i < 32; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			for j := 0; j < 20; j++ {
+				var err error
+				switch (i + j) % 4 {
+				case 0:
+					err = VerifyRoundTrip([]Ticket{{Name: fmt.Sprint(i), UserID: "U", Ticket: j, RecordID: "R", Source: "S"}})
+				case 1:
+					err = VerifyRoundTrip([]*RecordWithOmitempty{{Name: "n", Age: j}})
+				case 2:
+					err = VerifyRoundTrip([]Contact{{Name: "n", Home: Address{City: "c"}, Work: &Address{Street: "s"}}})
+				case 3:
+					err = VerifyRoundTrip([]SizedInts{{I8: int8(j)}})
+				}
+				if err != nil {
+					t.Error(err)
+					return
+				}
+			}
+		}(i)
+	}
+	wg.Wait()
+}
+
+func TestFieldCache_Invalidation(t *testing.T) {
+	defer RegisterFieldNames(GeneratedUser{}, nil)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 32; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			if i%8 == 0 {
+				RegisterFieldNames(GeneratedUser{}, map[string]string{"UserID": fmt.Sprint("id", i)})
+				return
+			}
+			// Marshal and Unmarshal may see different names while registrations run,
+			// so only check for races and panics here
+			_ = VerifyRoundTrip([]GeneratedUser{{UserID: "U", Age: i}})
+		}(i)
+	}
+	wg.Wait()
+
+	// Once registrations settle, every call sees the latest names
+	if err := VerifyRoundTrip([]GeneratedUser{{UserID: "U", Age: 1}}); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+	RegisterFieldNames(GeneratedUser{}, map[string]string{"UserID": "final"})
+	data, err := Marshal(GeneratedUser{})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if expected := "final,DisplayName,age\n,,0\n"; string(data) != expected {
+		t.Errorf("unexpected result: got %q, want %q", string(data), expected)
+	}
+}
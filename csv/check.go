@@ -0,0 +1,27 @@
+package csv
+
+import (
+	"errors"
+	"reflect"
+)
+
+// Check reports whether every field of the record type of v can be encoded and decoded, before any data
+// is processed. v may be a struct, a struct pointer or a slice of them, and is typically a zero value.
+// All unsupported fields are reported as *UnsupportedTypeError values joined together.
+func Check(v any) error {
+	t, err := structType(reflect.TypeOf(v))
+	if err != nil {
+		return err
+	}
+	fields, err := structFields(t)
+	if err != nil {
+		return err
+	}
+	var errs []error
+	for _, field := range fields {
+		if !field.supported {
+			errs = append(errs, unsupportedTypeError(t, field))
+		}
+	}
+	return errors.Join(errs...)
+}
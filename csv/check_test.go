@@ -0,0 +1,58 @@
+package csv
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+type OrderMeta struct {
+	Source   string `csv:"source"`
+	Callback func() `csv:"callback"`
+}
+
+type Order struct {
+	ID int `csv:"id"`
+	*OrderMeta
+	Amount complex128 `csv:"amount"`
+}
+
+func TestCheck(t *testing.T) {
+	if err := Check([]Ticket{}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	err := Check(&Order{})
+	expected := "field Order.OrderMeta.Callback (column \"callback\") has unsupported type func()\n" +
+		"field Order.Amount (column \"amount\") has unsupported type complex128"
+	if err == nil || err.Error() != expected {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	var typeErr *UnsupportedTypeError
+	if !errors.As(err, &typeErr) || typeErr.Column != "callback" {
+		t.Errorf("unexpected error: %#v", err)
+	}
+
+	if err := Check(1); err == nil {
+		t.Fatalf("expected error for non-struct")
+	}
+}
+
+func TestMarshal_UnsupportedFieldPath(t *testing.T) {
+	_, err := Marshal([]Order{{ID: 1}})
+	if err == nil || err.Error() != `field Order.OrderMeta.Callback (column "callback") has unsupported type func()` {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
+
+func TestUnmarshal_UnsupportedFieldPath(t *testing.T) {
+	var orders []Order
+	err := Unmarshal([]byte("id,amount\n1,2\n"), &orders)
+	if err == nil || !strings.HasSuffix(err.Error(), `field Order.Amount (column "amount") has unsupported type complex128`) {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	var rowErr *RowError
+	if !errors.As(err, &rowErr) || rowErr.Row != 1 || rowErr.Column != "amount" {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
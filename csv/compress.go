@@ -0,0 +1,26 @@
+package csv
+
+import "bytes"
+
+// Compression selects how the CSV stream is compressed
+type Compression int
+
+const (
+	// NoCompression reads and writes plain CSV
+	NoCompression Compression = iota
+	// Gzip reads and writes gzip compressed CSV, such as .csv.gz files
+	Gzip
+)
+
+// gzipMagic is the header every gzip stream starts with
+var gzipMagic = []byte{0x1f, 0x8b}
+
+// isGzip reports whether data starts with the gzip magic bytes
+func isGzip(data []byte) bool {
+	return bytes.HasPrefix(data, gzipMagic)
+}
+
+// MarshalGzip is like MarshalWithOptions but returns gzip compressed CSV data
+func MarshalGzip(v interface{}, opts ...Option) ([]byte, error) {
+	return MarshalWithOptions(v, append(opts[:len(opts):len(opts)], WithCompression(Gzip))...)
+}
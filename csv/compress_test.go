@@ -0,0 +1,135 @@
+package csv
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestMarshalGzip(t *testing.T) {
+	tickets := []Ticket{
+		{Name: "Alice", UserID: "U001", Ticket: 1, RecordID: "R001", Source: "S001"},
+		{Name: "Bob", UserID: "U002", Ticket: 2, RecordID: "R002", Source: "S002"},
+	}
+
+	data, err := MarshalGzip(tickets)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !isGzip(data) {
+		t.Fatalf("expected gzip data")
+	}
+
+	// Unmarshal detects the compression by itself
+	var decoded []Ticket
+	if err := Unmarshal(data, &decoded); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !reflect.DeepEqual(decoded, tickets) {
+		t.Errorf("unexpected result: got %+v, want %+v", decoded, tickets)
+	}
+
+	stats, err := UnmarshalWithStats(data, &decoded)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if stats.RowsDecoded != 2 {
+		t.Errorf("unexpected stats: %+v", stats)
+	}
+}
+
+func TestMarshalGzip_CallerOptions(t *testing.T) {
+	// spare capacity in opts must not be written to by MarshalGzip
+	opts := make([]Option, 1, 2)
+	opts[0] = WithDelimiter(';')
+	if _, err := MarshalGzip([]Ticket{{Name: "Alice"}}, opts...); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if spare := opts[:2][1]; spare != nil {
+		t.Errorf("MarshalGzip wrote %v into the caller's slice", spare)
+	}
+}
+
+func TestDecoder_GzipInvalid(t *testing.T) {
+	dec := NewDecoder(strings.NewReader("name\nAlice\n"), Options{Compression: Gzip})
+	var s Simple
+	if err := dec.Decode(&s); err == nil {
+		t.Fatalf("expected error for plain data with gzip compression")
+	}
+}
+
+func TestEncoderDecoder_GzipStreaming(t *testing.T) {
+	const rows = 100000
+
+	opts := Default()
+	opts.Compression = Gzip
+
+	// The payload is produced and consumed through a pipe, so it is never held in memory as a whole
+	pr, pw := io.Pipe()
+	go func() {
+		enc := NewEncoder(pw, Ticket{}, opts)
+		for i := 0; i < rows; i++ {
+			ticket := Ticket{Name: fmt.Sprintf("name-%d", i), UserID: "U001", Ticket: i, RecordID: "R001", Source: strings.Repeat("s", 20)}
+			if err := enc.WriteRecord(ticket); err != nil {
+				pw.CloseWithError(err)
+				return
+			}
+		}
+		pw.CloseWithError(enc.Close())
+	}()
+
+	counter := &countingReader{r: pr}
+	dec := NewDecoder(counter, opts)
+	var ticket Ticket
+	n := 0
+	for {
+		err := dec.Decode(&ticket)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if ticket.Ticket != n || ticket.Name != fmt.Sprintf("name-%d", n) {
+			t.Fatalf("unexpected record %d: %+v", n, ticket)
+		}
+		n++
+	}
+
+	if n != rows {
+		t.Errorf("expected %d rows, got %d", rows, n)
+	}
+	// The decompressed payload is several MB while far fewer compressed bytes are read
+	if dec.InputOffset() < 4<<20 || counter.n >= dec.InputOffset() {
+		t.Errorf("unexpected sizes: %d compressed, %d decompressed", counter.n, dec.InputOffset())
+	}
+}
+
+func TestMarshalTo_Gzip(t *testing.T) {
+	b := &bytes.Buffer{}
+	if err := MarshalTo(b, Simple{Name: "Alice"}, Options{Compression: Gzip}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	var decoded Simple
+	if err := UnmarshalFrom(b, &decoded, Options{Compression: Gzip}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if decoded.Name != "Alice" {
+		t.Errorf("unexpected result: %+v", decoded)
+	}
+}
+
+type countingReader struct {
+	r io.Reader
+	n int64
+}
+
+func (c *countingReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	c.n += int64(n)
+	return n, err
+}
@@ -0,0 +1,272 @@
+package csv
+
+import (
+	"compress/gzip"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"reflect"
+	"strings"
+)
+
+// Decoder reads CSV records from an input stream and decodes them into structs one at a time,
+// so memory stays proportional to a single record rather than to the whole input.
+//
+// A Decoder is not safe for concurrent use.
+type Decoder struct {
+	r   *csv.Reader
+	src io.Reader
+	opt Options
+	// base is the byte offset of the underlying reader where the current csv.Reader started
+	base   int64
+	header []string
+	// trimTrailing is set when the header ended with an empty name that was dropped by TrimTrailingDelimiter
+	trimTrailing bool
+	// row is the number of data rows read so far, line the input line where the last record starts
+	row  int
+	line int
+	// endLine is the input line where the last record ends, used to count skipped blank lines
+	endLine int
+	stats   Stats
+	// bind caches the column lookup for the last decoded type
+	bind    *binding
+	started bool
+	err     error
+}
+
+// Validator is implemented by record types that check themselves after decoding.
+// Unmarshal and Decoder call Validate on every decoded record unless Options.SkipValidation is set.
+type Validator interface {
+	Validate() error
+}
+
+// NewDecoder returns a Decoder reading from r.
+//
+// When Options.StartOffset is set, decoding resumes at that byte offset, which should be a value previously
+// returned by InputOffset. The header is then taken from Options.Header, or, if that is empty, read from the
+// beginning of r, which requires r to be an io.ReadSeeker.
+//
+// With Options.Compression set to Gzip, r is decompressed on the fly and offsets refer to the decompressed data.
+func NewDecoder(r io.Reader, opts ...Option) *Decoder {
+	opt, err := mergeOptions(opts)
+	// Invalid options make every call fail, as if starting had failed
+	return &Decoder{src: r, opt: opt, started: err != nil, err: err}
+}
+
+// start reads the header and positions the reader at the first record to decode
+func (d *Decoder) start() error {
+	if d.started {
+		return d.err
+	}
+	d.started = true
+
+	if d.opt.Compression == Gzip {
+		gz, err := gzip.NewReader(d.src)
+		if err != nil {
+			d.err = err
+			return d.err
+		}
+		d.src = gz
+	}
+
+	if len(d.opt.Header) > 0 {
+		d.header = append([]string(nil), d.opt.Header...)
+	}
+
+	if d.opt.StartOffset > 0 {
+		if d.header == nil && !d.opt.NoHeader {
+			seeker, ok := d.src.(io.ReadSeeker)
+			if !ok {
+				d.err = errors.New("StartOffset without Header requires an io.ReadSeeker")
+				return d.err
+			}
+			if _, err := seeker.Seek(0, io.SeekStart); err != nil {
+				d.err = err
+				return d.err
+			}
+			if d.header, d.err = d.newReader().Read(); d.err != nil {
+				return d.err
+			}
+		}
+		if d.err = d.skipTo(d.opt.StartOffset); d.err != nil {
+			return d.err
+		}
+	}
+
+	d.r = d.newReader()
+	if d.header == nil && !d.opt.NoHeader {
+		record, err := d.r.Read()
+		if err != nil {
+			d.err = err
+			return d.err
+		}
+		d.header = append([]string(nil), record...)
+		d.track(record)
+	}
+	if d.opt.MaxColumns > 0 && len(d.header) > d.opt.MaxColumns {
+		d.err = fmt.Errorf("header has %d columns, more than the maximum of %d", len(d.header), d.opt.MaxColumns)
+		return d.err
+	}
+	if last := len(d.header) - 1; d.opt.TrimTrailingDelimiter && last >= 0 && d.header[last] == "" {
+		d.header = d.header[:last]
+		d.trimTrailing = true
+	}
+	d.stats.Columns = d.header
+	return nil
+}
+
+// skipTo moves the underlying reader to the given absolute byte offset
+func (d *Decoder) skipTo(offset int64) error {
+	if seeker, ok := d.src.(io.Seeker); ok {
+		if _, err := seeker.Seek(offset, io.SeekStart); err != nil {
+			return err
+		}
+	} else if _, err := io.CopyN(io.Discard, d.src, offset); err != nil {
+		return fmt.Errorf("skip to offset %d: %w", offset, err)
+	}
+	d.base = offset
+	return nil
+}
+
+func (d *Decoder) newReader() *csv.Reader {
+	r := csv.NewReader(newCRReader(d.src, d.opt.MaxRecordBytes))
+	r.Comma = d.opt.comma()
+	r.ReuseRecord = true
+	return r
+}
+
+// Header returns the column names, reading the header line if it has not been read yet.
+// With Options.NoHeader and no Options.Header it returns nil, the columns then depend on the decoded type.
+func (d *Decoder) Header() ([]string, error) {
+	if err := d.start(); err != nil {
+		return nil, err
+	}
+	return d.header, nil
+}
+
+// Decode reads the next record and stores it into v, which must be a pointer to a struct.
+// It returns io.EOF when there are no more records.
+func (d *Decoder) Decode(v interface{}) error {
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Ptr || rv.IsNil() || rv.Elem().Kind() != reflect.Struct {
+		return errors.New("v must be a non-nil pointer to a struct")
+	}
+	return d.decode(rv.Elem())
+}
+
+// decode reads the next record into the struct value rv
+func (d *Decoder) decode(rv reflect.Value) error {
+	if err := d.start(); err != nil {
+		return err
+	}
+	if err := d.bindTo(rv.Type()); err != nil {
+		return err
+	}
+
+	record, err := d.read()
+	if err != nil {
+		return err
+	}
+	if err := d.bind.decode(rv, record, d.opt); err != nil {
+		if rowErr, ok := err.(*RowError); ok {
+			rowErr.Row = d.row
+		}
+		return err
+	}
+	d.stats.RowsDecoded++
+	return nil
+}
+
+// read reads the next data record, the returned slice is reused by the next call
+func (d *Decoder) read() ([]string, error) {
+	if err := d.start(); err != nil {
+		return nil, err
+	}
+	record, err := d.r.Read()
+	if err != nil {
+		return nil, err
+	}
+	d.row++
+	d.track(record)
+	if d.opt.MaxColumns > 0 && len(record) > d.opt.MaxColumns {
+		return nil, &RowError{Row: d.row, Err: fmt.Errorf("record has %d columns, more than the maximum of %d", len(record), d.opt.MaxColumns)}
+	}
+	if last := len(record) - 1; d.trimTrailing && last >= 0 && record[last] == "" {
+		record = record[:last]
+	}
+	return record, nil
+}
+
+// bindTo prepares the column lookup for the struct type typ, see newBinding
+func (d *Decoder) bindTo(typ reflect.Type) error {
+	if d.bind != nil && d.bind.typ == typ {
+		return nil
+	}
+	header := d.header
+	if header == nil && d.opt.NoHeader {
+		// Without any header the columns follow the fields of the record type
+		fields, err := cachedCollectFields(typ)
+		if err != nil {
+			return err
+		}
+		header = headerOf(fields)
+	}
+	b, err := newBinding(typ, header, d.opt)
+	if err != nil {
+		return err
+	}
+	d.stats.Columns = header
+	d.bind = b
+	d.stats.UnmatchedFields = b.unmatched
+	return nil
+}
+
+// track updates the line bookkeeping after a record has been read
+func (d *Decoder) track(record []string) {
+	d.line, _ = d.r.FieldPos(0)
+	// The CSV reader silently drops blank lines, they show up as gaps between records
+	d.stats.RowsSkipped += d.line - d.endLine - 1
+	last := len(record) - 1
+	endLine, _ := d.r.FieldPos(last)
+	d.endLine = endLine + strings.Count(record[last], "\n")
+}
+
+// Stats describes how much input a Decoder has consumed
+type Stats struct {
+	// RowsDecoded is the number of data rows successfully decoded
+	RowsDecoded int
+	// RowsSkipped is the number of input rows that were not decoded, such as blank lines
+	RowsSkipped int
+	// BytesRead is the number of input bytes consumed, see Decoder.InputOffset
+	BytesRead int64
+	// Columns is the header of the input
+	Columns []string
+	// UnmatchedFields are the column names of the struct fields that have no column in the input,
+	// they are left at their zero value or set to the value of their default tag
+	UnmatchedFields []string
+}
+
+// Stats returns a snapshot of the decoding statistics so far
+func (d *Decoder) Stats() Stats {
+	stats := d.stats
+	stats.BytesRead = d.InputOffset()
+	return stats
+}
+
+// Line returns the input line number where the most recently decoded record starts, counted from the
+// position where reading began. A quoted field spanning several lines makes a record cover more than one
+// line, so the next record's line number may advance by more than one.
+func (d *Decoder) Line() int {
+	return d.line
+}
+
+// InputOffset returns the byte offset of the end of the most recently read record in the underlying reader.
+// The offset always falls on a record boundary, even after quoted multi-line fields, so it can be stored as a
+// checkpoint and later passed back as Options.StartOffset.
+func (d *Decoder) InputOffset() int64 {
+	if d.r == nil {
+		return d.base
+	}
+	return d.base + d.r.InputOffset()
+}
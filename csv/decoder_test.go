@@ -0,0 +1,449 @@
+package csv
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestDecoder_Decode(t *testing.T) {
+	dec := NewDecoder(strings.NewReader(`name,user_id,ticket,record_id,source
+Alice,U001,1,R001,S001
+Bob,U002,2,R002,S002
+`))
+
+	var got []Ticket
+	for {
+		var ticket Ticket
+		err := dec.Decode(&ticket)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		got = append(got, ticket)
+	}
+
+	expected := []Ticket{
+		{Name: "Alice", UserID: "U001", Ticket: 1, RecordID: "R001", Source: "S001"},
+		{Name: "Bob", UserID: "U002", Ticket: 2, RecordID: "R002", Source: "S002"},
+	}
+	if !reflect.DeepEqual(got, expected) {
+		t.Errorf("unexpected result: got %+v, want %+v", got, expected)
+	}
+}
+
+func TestDecoder_InvalidTarget(t *testing.T) {
+	dec := NewDecoder(strings.NewReader("name\nAlice\n"))
+	var s Simple
+	if err := dec.Decode(s); err == nil {
+		t.Fatalf("expected error for non-pointer target")
+	}
+}
+
+func TestDecoder_LineAndOffset(t *testing.T) {
+	data := "name\nAlice\n\"Bob\nSmith\"\nCarol\n"
+	dec := NewDecoder(strings.NewReader(data))
+
+	var s Simple
+	wantLines := []int{2, 3, 5}
+	wantOffsets := []int64{11, 23, 29}
+	for i := range wantLines {
+		if err := dec.Decode(&s); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if dec.Line() != wantLines[i] {
+			t.Errorf("record %d: expected line %d, got %d", i, wantLines[i], dec.Line())
+		}
+		if dec.InputOffset() != wantOffsets[i] {
+			t.Errorf("record %d: expected offset %d, got %d", i, wantOffsets[i], dec.InputOffset())
+		}
+	}
+}
+
+func TestDecoder_ResumeFromOffset(t *testing.T) {
+	data := "name\nAlice\n\"Bob\nSmith\"\nCarol\n"
+
+	dec := NewDecoder(strings.NewReader(data))
+	var s Simple
+	if err := dec.Decode(&s); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	checkpoint := dec.InputOffset()
+
+	// The header is read from the beginning of the seekable reader
+	resumed := NewDecoder(strings.NewReader(data), Options{StartOffset: checkpoint})
+	if err := resumed.Decode(&s); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if s.Name != "Bob\nSmith" {
+		t.Errorf("unexpected record: %+v", s)
+	}
+	if err := resumed.Decode(&s); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if s.Name != "Carol" || resumed.InputOffset() != int64(len(data)) {
+		t.Errorf("unexpected record %+v at offset %d", s, resumed.InputOffset())
+	}
+	if err := resumed.Decode(&s); err != io.EOF {
+		t.Errorf("expected io.EOF, got %v", err)
+	}
+}
+
+func TestDecoder_ResumeWithHeader(t *testing.T) {
+	data := "name\nAlice\nBob\n"
+
+	// A plain io.Reader can resume when the header is supplied
+	dec := NewDecoder(io.MultiReader(strings.NewReader(data)), Options{Header: []string{"name"}, StartOffset: 11})
+	var s Simple
+	if err := dec.Decode(&s); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if s.Name != "Bob" {
+		t.Errorf("unexpected record: %+v", s)
+	}
+
+	// Without a header a plain io.Reader cannot resume
+	dec = NewDecoder(io.MultiReader(strings.NewReader(data)), Options{StartOffset: 11})
+	if err := dec.Decode(&s); err == nil {
+		t.Fatalf("expected error when resuming a non-seekable reader without header")
+	}
+}
+
+func TestDecoder_SuppliedHeader(t *testing.T) {
+	dec := NewDecoder(bytes.NewReader([]byte("Alice\n")), Options{Header: []string{"name"}})
+	header, err := dec.Header()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !reflect.DeepEqual(header, []string{"name"}) {
+		t.Errorf("unexpected header: %v", header)
+	}
+	if dec.Line() != 0 {
+		t.Errorf("expected line 0 before decoding, got %d", dec.Line())
+	}
+
+	var s Simple
+	if err := dec.Decode(&s); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if s.Name != "Alice" || dec.Line() != 1 {
+		t.Errorf("unexpected record %+v at line %d", s, dec.Line())
+	}
+}
+
+type ValidatedRecord struct {
+	Name string `csv:"name"`
+	Age  int    `csv:"age"`
+}
+
+func (r *ValidatedRecord) Validate() error {
+	if r.Age < 0 {
+		return errors.New("age must not be negative")
+	}
+	return nil
+}
+
+func TestDecoder_Validate(t *testing.T) {
+	dec := NewDecoder(strings.NewReader("name,age\nAlice,30\nBob,-1\n"))
+
+	var r ValidatedRecord
+	if err := dec.Decode(&r); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	err := dec.Decode(&r)
+	var rowErr *RowError
+	if !errors.As(err, &rowErr) {
+		t.Fatalf("expected *RowError, got %T: %v", err, err)
+	}
+	if rowErr.Row != 2 || rowErr.Column != "" {
+		t.Errorf("unexpected position: row %d column %q", rowErr.Row, rowErr.Column)
+	}
+}
+
+func TestUnmarshal_Validate(t *testing.T) {
+	data := []byte("name,age\nAlice,30\nBob,-1\n")
+
+	var records []ValidatedRecord
+	err := Unmarshal(data, &records)
+	if err == nil || err.Error() != "row 2: age must not be negative" {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	// Pointer elements are validated as well
+	var ptrs []*ValidatedRecord
+	if err := Unmarshal(data, &ptrs); err == nil {
+		t.Fatalf("expected validation error")
+	}
+
+	opts := Default()
+	opts.SkipValidation = true
+	records = nil
+	if err := UnmarshalWithOptions(data, &records, opts); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(records) != 2 || records[1].Age != -1 {
+		t.Errorf("unexpected result: %+v", records)
+	}
+}
+
+func TestUnmarshalFrom(t *testing.T) {
+	r := io.MultiReader(strings.NewReader("name,user_id,ticket,record_id,source\n"), strings.NewReader("Alice,U001,1,R001,S001\n"))
+
+	var tickets []Ticket
+	if err := UnmarshalFrom(r, &tickets); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	expected := []Ticket{{Name: "Alice", UserID: "U001", Ticket: 1, RecordID: "R001", Source: "S001"}}
+	if !reflect.DeepEqual(tickets, expected) {
+		t.Errorf("unexpected result: got %+v, want %+v", tickets, expected)
+	}
+
+	if err := UnmarshalFrom(strings.NewReader(""), &tickets); err == nil {
+		t.Fatalf("expected error for empty input")
+	}
+}
+
+func TestUnmarshalWithStats(t *testing.T) {
+	data := []byte("name,age\n\nAlice,30\n\n\n\"Bob\n\",40\nCarol,50\n")
+
+	var records []ValidatedRecord
+	stats, err := UnmarshalWithStats(data, &records)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	expected := Stats{RowsDecoded: 3, RowsSkipped: 3, BytesRead: int64(len(data)), Columns: []string{"name", "age"}}
+	if !reflect.DeepEqual(stats, expected) {
+		t.Errorf("unexpected stats: got %+v, want %+v", stats, expected)
+	}
+	if len(records) != 3 {
+		t.Errorf("expected 3 records, got %d", len(records))
+	}
+}
+
+func TestUnmarshalWithStats_Error(t *testing.T) {
+	var records []ValidatedRecord
+	stats, err := UnmarshalWithStats([]byte("name,age\nAlice,30\nBob,x\n"), &records)
+	if err == nil {
+		t.Fatalf("expected error")
+	}
+	if stats.RowsDecoded != 1 {
+		t.Errorf("expected 1 decoded row before the error, got %d", stats.RowsDecoded)
+	}
+}
+
+func TestDecoder_Stats(t *testing.T) {
+	dec := NewDecoder(strings.NewReader("name\nAlice\n\nBob\n"))
+
+	var s Simple
+	if err := dec.Decode(&s); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if stats := dec.Stats(); stats.RowsDecoded != 1 || stats.RowsSkipped != 0 || stats.BytesRead != 11 {
+		t.Errorf("unexpected stats: %+v", stats)
+	}
+
+	if err := dec.Decode(&s); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if stats := dec.Stats(); stats.RowsDecoded != 2 || stats.RowsSkipped != 1 || stats.BytesRead != 16 {
+		t.Errorf("unexpected stats: %+v", stats)
+	}
+}
+
+func TestUnmarshal_LineEndingsAndTrailingDelimiter(t *testing.T) {
+	expected := []Ticket{
+		{Name: "Alice", UserID: "U001", Ticket: 1, RecordID: "R001", Source: "S001"},
+		{Name: "Bob", UserID: "U002", Ticket: 2, RecordID: "R002", Source: "S002"},
+	}
+	inputs := map[string]string{
+		"LF":            "name,user_id,ticket,record_id,source\nAlice,U001,1,R001,S001\nBob,U002,2,R002,S002\n",
+		"CRLF":          "name,user_id,ticket,record_id,source\r\nAlice,U001,1,R001,S001\r\nBob,U002,2,R002,S002\r\n",
+		"CR":            "name,user_id,ticket,record_id,source\rAlice,U001,1,R001,S001\rBob,U002,2,R002,S002\r",
+		"TrailingComma": "name,user_id,ticket,record_id,source,\nAlice,U001,1,R001,S001,\nBob,U002,2,R002,S002,\n",
+		"CRTrailing":    "name,user_id,ticket,record_id,source,\rAlice,U001,1,R001,S001,\rBob,U002,2,R002,S002,\r",
+	}
+
+	opt := Default()
+	opt.TrimTrailingDelimiter = true
+	for name, input := range inputs {
+		var records []Ticket
+		if err := UnmarshalWithOptions([]byte(input), &records, opt); err != nil {
+			t.Fatalf("%s: unexpected error: %v", name, err)
+		}
+		if !reflect.DeepEqual(records, expected) {
+			t.Errorf("%s: unexpected result: got %+v, want %+v", name, records, expected)
+		}
+	}
+}
+
+func TestDecoder_TrimTrailingDelimiterStats(t *testing.T) {
+	opt := Default()
+	opt.TrimTrailingDelimiter = true
+	var records []Simple
+	stats, err := UnmarshalWithStats([]byte("name,\nAlice,\n"), &records, opt)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !reflect.DeepEqual(stats.Columns, []string{"name"}) {
+		t.Errorf("unexpected columns: %q", stats.Columns)
+	}
+}
+
+func TestDecoder_QuotedCarriageReturn(t *testing.T) {
+	data := "name\r\"Alice\rSmith\"\r\"Bob \"\"B\"\"\"\r"
+	dec := NewDecoder(strings.NewReader(data))
+
+	var s Simple
+	if err := dec.Decode(&s); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if s.Name != "Alice\rSmith" {
+		t.Errorf("unexpected result: %q", s.Name)
+	}
+	if err := dec.Decode(&s); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if s.Name != `Bob "B"` {
+		t.Errorf("unexpected result: %q", s.Name)
+	}
+	if offset := dec.InputOffset(); offset != int64(len(data)) {
+		t.Errorf("unexpected offset: got %d, want %d", offset, len(data))
+	}
+}
+
+type PriceRow struct {
+	Item   string  `csv:"item"`
+	Price  float64 `csv:"price"`
+	Status int     `csv:"status"`
+}
+
+func TestUnmarshal_Transforms(t *testing.T) {
+	opt := Default()
+	opt.Transforms = map[string]func(string) (string, error){
+		"price": func(s string) (string, error) {
+			return strings.NewReplacer("$", "", ",", "").Replace(s), nil
+		},
+		"status": func(s string) (string, error) {
+			switch s {
+			case "A":
+				return "1", nil
+			case "I":
+				return "0", nil
+			}
+			return "", fmt.Errorf("unknown status code %q", s)
+		},
+	}
+
+	var records []PriceRow
+	data := "item,price,status\nbook,\"$1,234.56\",A\npen,$2,I\n"
+	if err := UnmarshalWithOptions([]byte(data), &records, opt); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	expected := []PriceRow{{Item: "book", Price: 1234.56, Status: 1}, {Item: "pen", Price: 2}}
+	if !reflect.DeepEqual(records, expected) {
+		t.Errorf("unexpected result: got %+v, want %+v", records, expected)
+	}
+
+	err := UnmarshalWithOptions([]byte("item,price,status\nbook,$1,X\n"), &records, opt)
+	var rowErr *RowError
+	if !errors.As(err, &rowErr) || rowErr.Row != 1 || rowErr.Column != "status" {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err.Error() != `row 1, column "status": unknown status code "X"` {
+		t.Errorf("unexpected error message: %v", err)
+	}
+}
+
+func TestUnmarshal_MaxColumns(t *testing.T) {
+	opt := Default()
+	opt.MaxColumns = 2
+
+	var records []Ticket
+	err := UnmarshalWithOptions([]byte("name,user_id,ticket\nAlice,U001,1\n"), &records, opt)
+	if err == nil || err.Error() != "header has 3 columns, more than the maximum of 2" {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	opt.Header = []string{"name", "user_id"}
+	err = UnmarshalWithOptions([]byte("Alice,U001,extra\n"), &records, opt)
+	var rowErr *RowError
+	if !errors.As(err, &rowErr) || rowErr.Row != 1 {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
+
+func TestUnmarshal_MaxRecordBytes(t *testing.T) {
+	opt := Default()
+	opt.MaxRecordBytes = 16
+
+	var records []Simple
+	// Records up to the limit are fine, quoted line breaks count towards it
+	if err := UnmarshalWithOptions([]byte("name\r\n\"012345678\n1234\"\n"), &records, opt); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	data := "name\nAlice\n" + strings.Repeat("x", 1<<20) + "\n"
+	err := UnmarshalWithOptions([]byte(data), &records, opt)
+	if err == nil || !strings.Contains(err.Error(), "record exceeds 16 bytes") {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
+
+type OptionalColumns struct {
+	Name    string `csv:"name,required"`
+	Country string `csv:"country,default=CN"`
+	Retries int    `csv:"retries,default=3"`
+	Note    string `csv:"note"`
+}
+
+func TestUnmarshal_MissingColumns(t *testing.T) {
+	var records []OptionalColumns
+	stats, err := UnmarshalWithStats([]byte("name,retries\nAlice,5\nBob,\n"), &records)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	// A missing column takes the default value, a present but empty cell does not
+	expected := []OptionalColumns{{Name: "Alice", Country: "CN", Retries: 5}, {Name: "Bob", Country: "CN"}}
+	if !reflect.DeepEqual(records, expected) {
+		t.Errorf("unexpected result: got %+v, want %+v", records, expected)
+	}
+	if !reflect.DeepEqual(stats.UnmatchedFields, []string{"country", "note"}) {
+		t.Errorf("unexpected unmatched fields: %q", stats.UnmatchedFields)
+	}
+}
+
+func TestUnmarshal_MissingRequiredColumns(t *testing.T) {
+	var records []OptionalColumns
+	err := Unmarshal([]byte("country,note\n"), &records)
+	if err == nil || err.Error() != "missing required columns: name" {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	dec := NewDecoder(strings.NewReader("note\nhello\n"))
+	var record OptionalColumns
+	if err := dec.Decode(&record); err == nil || err.Error() != "missing required columns: name" {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
+
+func TestUnmarshal_InvalidDefault(t *testing.T) {
+	type badDefault struct {
+		Name  string `csv:"name"`
+		Count int    `csv:"count,default=many"`
+	}
+	var records []badDefault
+	err := Unmarshal([]byte("name\nAlice\n"), &records)
+	var rowErr *RowError
+	if !errors.As(err, &rowErr) || rowErr.Row != 1 || rowErr.Column != "count" {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
@@ -0,0 +1,10 @@
+// Package csv converts between CSV data and Go structs using `csv` struct tags.
+//
+// # Concurrency
+//
+// The package level functions, such as Marshal, Unmarshal and RegisterFieldNames, are safe for concurrent use.
+// The struct metadata they share is cached per type and rebuilt when field names are registered.
+// An Encoder or a Decoder is not safe for concurrent use, guard it with a mutex or use one per goroutine.
+// Options values passed in are only read, but the functions in Options.Transforms are called from
+// whichever goroutine is decoding and must be safe for concurrent use when the Options are shared.
+package csv
@@ -2,11 +2,13 @@ package csv
 
 import (
 	"bytes"
-	"encoding/csv"
+	"compress/gzip"
 	"errors"
 	"fmt"
+	"io"
 	"reflect"
 	"strconv"
+	"strings"
 	"time"
 )
 
@@ -15,75 +17,166 @@ type fieldInfo struct {
 	name      string
 	indexPath []int
 	omitempty bool
+	// goPath is the dotted Go field path such as "Home.Street", used in error messages
+	goPath string
+	// quote overrides when the cells of the column are quoted
+	quote quoteMode
+	// supported reports whether the field type can be converted to and from a CSV cell
+	supported bool
+	// inlined marks fields reached through an inline (prefix) flattened struct field
+	inlined bool
+	// required fields must have a column when decoding
+	required bool
+	// defaultValue is decoded into the field when the input has no column for it
+	defaultValue string
+	hasDefault   bool
 }
 
 // collectFields recursively collects all fields from a struct type, including embedded structs
-func collectFields(t reflect.Type) []fieldInfo {
+// and struct fields flattened with the inline or prefix= tag options.
+// Columns produced by flattening must not collide with any other column.
+func collectFields(t reflect.Type) ([]fieldInfo, error) {
 	var fields []fieldInfo
-	collectFieldsRecursive(t, nil, &fields)
-	return fields
+	collectFieldsRecursive(t, nil, "", "", false, &fields)
+
+	seen := make(map[string]fieldInfo, len(fields))
+	for _, field := range fields {
+		if prev, ok := seen[field.name]; ok && (prev.inlined || field.inlined) {
+			return nil, fmt.Errorf("type %s has duplicate column %q from fields %s and %s", t, field.name, prev.goPath, field.goPath)
+		}
+		seen[field.name] = field
+	}
+	return fields, nil
 }
 
 // collectFieldsRecursive is a helper function that recursively collects fields
-func collectFieldsRecursive(t reflect.Type, indexPath []int, fields *[]fieldInfo) {
+func collectFieldsRecursive(t reflect.Type, indexPath []int, prefix, goPath string, inlined bool, fields *[]fieldInfo) {
 	for i := 0; i < t.NumField(); i++ {
 		field := t.Field(i)
 		// Create a new slice to avoid shared memory issues
 		currentPath := make([]int, len(indexPath), len(indexPath)+1)
 		copy(currentPath, indexPath)
 		currentPath = append(currentPath, i)
+		currentGoPath := field.Name
+		if goPath != "" {
+			currentGoPath = goPath + "." + field.Name
+		}
 
 		// If this is an anonymous (embedded) struct field, recurse into it
 		// Handle both direct struct embedding and pointer-to-struct embedding
 		fieldType := field.Type
-		if field.Anonymous {
-			// Dereference pointer types for embedded fields
-			if fieldType.Kind() == reflect.Ptr {
-				fieldType = fieldType.Elem()
-			}
-			if fieldType.Kind() == reflect.Struct {
-				collectFieldsRecursive(fieldType, currentPath, fields)
-				continue
-			}
+		if fieldType.Kind() == reflect.Ptr {
+			// Dereference pointer types for embedded and inline fields
+			fieldType = fieldType.Elem()
+		}
+		if field.Anonymous && fieldType.Kind() == reflect.Struct {
+			collectFieldsRecursive(fieldType, currentPath, prefix, currentGoPath, inlined, fields)
+			continue
 		}
 
-		// Regular field - add it to the list
+		// Regular field - add it to the list, unless it is unexported or explicitly skipped
 		tag := field.Tag.Get("csv")
-		var fieldName string
-		var omitempty bool
-		
-		if tag == "" {
+		if !field.IsExported() || tag == "-" {
+			continue
+		}
+		fieldName, opts := parseTag(tag)
+		if fieldName == "" {
 			fieldName = field.Name
-		} else {
-			// Parse tag: "name,omitempty" or just "name"
-			parts := splitTag(tag)
-			fieldName = parts[0]
-			for _, opt := range parts[1:] {
-				if opt == "omitempty" {
-					omitempty = true
-				}
+			if name, ok := registeredFieldName(t, field.Name); ok {
+				fieldName = name
 			}
 		}
-		
-		*fields = append(*fields, fieldInfo{
-			name:      fieldName,
-			indexPath: currentPath,
-			omitempty: omitempty,
-		})
+
+		info := fieldInfo{
+			name:    prefix + fieldName,
+			goPath:  currentGoPath,
+			inlined: inlined,
+		}
+		var inline bool
+		childPrefix := ""
+		for _, opt := range opts {
+			switch {
+			case opt == "omitempty":
+				info.omitempty = true
+			case opt == "quote":
+				info.quote = quoteAlways
+			case opt == "noquote":
+				info.quote = quoteNever
+			case opt == "required":
+				info.required = true
+			case strings.HasPrefix(opt, "default="):
+				info.defaultValue = strings.TrimPrefix(opt, "default=")
+				info.hasDefault = true
+			case opt == "inline":
+				inline = true
+			case strings.HasPrefix(opt, "prefix="):
+				inline = true
+				childPrefix = strings.TrimPrefix(opt, "prefix=")
+			}
+		}
+
+		// Struct fields tagged inline are flattened like embedded structs, optionally prefixing their columns
+		if inline && fieldType.Kind() == reflect.Struct && fieldType != reflect.TypeOf(time.Time{}) {
+			collectFieldsRecursive(fieldType, currentPath, prefix+childPrefix, currentGoPath, true, fields)
+			continue
+		}
+
+		info.indexPath = currentPath
+		info.supported = isSupportedType(field.Type)
+		*fields = append(*fields, info)
 	}
 }
 
-// splitTag splits a struct tag into name and options
-func splitTag(tag string) []string {
-	// Split by comma, but trim spaces
-	var parts []string
-	for _, part := range splitCSVTag(tag) {
-		part = trimSpace(part)
-		if part != "" {
-			parts = append(parts, part)
+// parseTag parses a csv struct tag of the form "name,opt1,opt2" into the column name and options
+func parseTag(tag string) (string, []string) {
+	parts := splitCSVTag(tag)
+	name := trimSpace(parts[0])
+	var opts []string
+	for _, part := range parts[1:] {
+		if part = trimSpace(part); part != "" {
+			opts = append(opts, part)
 		}
 	}
-	return parts
+	return name, opts
+}
+
+// structFields collects the fields of t and checks that at least one of them can be encoded
+func structFields(t reflect.Type) ([]fieldInfo, error) {
+	fields, err := cachedCollectFields(t)
+	if err != nil {
+		return nil, err
+	}
+	if err = checkFields(t, fields); err != nil {
+		return nil, err
+	}
+	return fields, nil
+}
+
+// checkFields reports an error when none of the collected fields of t can be encoded
+func checkFields(t reflect.Type, fields []fieldInfo) error {
+	for _, field := range fields {
+		if field.supported {
+			return nil
+		}
+	}
+	return fmt.Errorf("type %s has no csv-encodable fields", t)
+}
+
+// isSupportedType reports whether values of t can be converted to and from a CSV cell
+func isSupportedType(t reflect.Type) bool {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	switch t.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr,
+		reflect.Float32, reflect.Float64, reflect.Bool, reflect.String:
+		return true
+	case reflect.Struct:
+		return t == reflect.TypeOf(time.Time{})
+	default:
+		return false
+	}
 }
 
 // splitCSVTag splits a tag by comma
@@ -129,6 +222,7 @@ func isZeroValue(v reflect.Value) bool {
 	case reflect.String:
 		return v.String() == ""
 	case reflect.Ptr, reflect.Interface:
+		// Only nil counts as empty, a pointer to a zero value is a deliberately set value
 		return v.IsNil()
 	case reflect.Struct:
 		// For time.Time, check if it's the zero time
@@ -141,12 +235,14 @@ func isZeroValue(v reflect.Value) bool {
 	}
 }
 
-// getFieldByIndexPath retrieves a field value using the index path
+// getFieldByIndexPath retrieves a field value using the index path for decoding.
+// Nil embedded struct pointers along the path are initialized, the field itself is returned as is,
+// so a pointer field is left for decodeValue to handle.
 func getFieldByIndexPath(v reflect.Value, indexPath []int) reflect.Value {
-	for _, idx := range indexPath {
+	for i, idx := range indexPath {
 		v = v.Field(idx)
-		// Dereference pointer fields if needed
-		if v.Kind() == reflect.Ptr {
+		// Dereference embedded pointer fields if needed
+		if i < len(indexPath)-1 && v.Kind() == reflect.Ptr {
 			if v.IsNil() {
 				// Initialize nil pointer for struct fields
 				v.Set(reflect.New(v.Type().Elem()))
@@ -157,16 +253,72 @@ func getFieldByIndexPath(v reflect.Value, indexPath []int) reflect.Value {
 	return v
 }
 
+// lookupFieldByIndexPath retrieves a field value using the index path for encoding without modifying v.
+// It reports false when a nil embedded struct pointer along the path hides the field.
+func lookupFieldByIndexPath(v reflect.Value, indexPath []int) (reflect.Value, bool) {
+	for i, idx := range indexPath {
+		v = v.Field(idx)
+		if i < len(indexPath)-1 && v.Kind() == reflect.Ptr {
+			if v.IsNil() {
+				return reflect.Value{}, false
+			}
+			v = v.Elem()
+		}
+	}
+	return v, true
+}
+
 func Marshal(v interface{}) ([]byte, error) {
+	return MarshalWithOptions(v)
+}
+
+// MarshalWithOptions is like Marshal but accepts Options to tune encoding
+func MarshalWithOptions(v interface{}, opts ...Option) ([]byte, error) {
+	b := &bytes.Buffer{}
+	if err := MarshalTo(b, v, opts...); err != nil {
+		return nil, err
+	}
+	return b.Bytes(), nil
+}
+
+// marshalChunkSize is the number of records MarshalTo writes between flushes
+const marshalChunkSize = 1024
+
+// MarshalTo is like MarshalWithOptions but writes the CSV data to w. Records are converted and written one at a
+// time and flushed every marshalChunkSize rows, so memory does not grow with the size of v.
+// On error, the records before the failing one may already have been written to w.
+//
+// v may also be a RowProvider or a func() (any, bool), records are then pulled one at a time until it is exhausted.
+func MarshalTo(w io.Writer, v interface{}, opts ...Option) error {
+	opt, err := mergeOptions(opts)
+	if err != nil {
+		return err
+	}
+	if opt.Compression == Gzip {
+		gz := gzip.NewWriter(w)
+		if err := marshalTo(gz, v, opt); err != nil {
+			return err
+		}
+		return gz.Close()
+	}
+	return marshalTo(w, v, opt)
+}
+
+// marshalTo writes v as plain CSV data to w
+func marshalTo(w io.Writer, v interface{}, opt Options) error {
+	if p, ok := asRowProvider(v); ok {
+		return marshalRows(w, p, opt)
+	}
+
 	rv := reflect.ValueOf(v)
 	var sliceValue reflect.Value
 	var sliceType reflect.Type
 
 	switch {
 	case !rv.IsValid():
-		return nil, errors.New("v is nil")
+		return errors.New("v is nil")
 	case rv.Kind() == reflect.Ptr && rv.IsNil():
-		return nil, errors.New("v is nil")
+		return errors.New("v is nil")
 	case rv.Kind() == reflect.Slice:
 		sliceValue = rv
 		sliceType = rv.Type().Elem()
@@ -182,23 +334,26 @@ func Marshal(v interface{}) ([]byte, error) {
 		sliceValue = reflect.Append(sliceValue, rv)
 		sliceType = rv.Type()
 	default:
-		return nil, errors.New("v must be a struct, a struct pointer or a slice of struct")
+		return errors.New("v must be a struct, a struct pointer or a slice of struct")
 	}
 	if sliceType.Kind() == reflect.Ptr {
 		sliceType = sliceType.Elem()
 	}
 	if sliceType.Kind() != reflect.Struct {
-		return nil, errors.New("element must be a struct")
+		return errors.New("element must be a struct")
 	}
 
 	// Collect all fields including embedded struct fields
-	fields := collectFields(sliceType)
-	
+	fields, err := structFields(sliceType)
+	if err != nil {
+		return err
+	}
+
 	// First pass: check which omitempty fields are empty across ALL records
 	columnsToInclude := make([]bool, len(fields))
 	for i, fieldInfo := range fields {
-		if !fieldInfo.omitempty {
-			// Non-omitempty fields are always included
+		if !fieldInfo.omitempty || opt.StableColumns {
+			// Non-omitempty fields are always included, as are all fields with stable columns
 			columnsToInclude[i] = true
 			continue
 		}
@@ -213,8 +368,8 @@ func Marshal(v interface{}) ([]byte, error) {
 				}
 				rvElem = rvElem.Elem()
 			}
-			field := getFieldByIndexPath(rvElem, fieldInfo.indexPath)
-			if !isZeroValue(field) {
+			field, ok := lookupFieldByIndexPath(rvElem, fieldInfo.indexPath)
+			if ok && !isZeroValue(field) {
 				hasNonZeroValue = true
 				break
 			}
@@ -232,75 +387,173 @@ func Marshal(v interface{}) ([]byte, error) {
 		}
 	}
 
-	var records [][]string
-	records = append(records, headers)
+	writer := newRowWriter(w, includedFields, opt)
+	if err := writer.WriteHeader(headers); err != nil {
+		return err
+	}
 	for i := 0; i < sliceValue.Len(); i++ {
-		var record []string
 		rvElem := sliceValue.Index(i)
 		if rvElem.Kind() == reflect.Ptr {
 			if rvElem.IsNil() {
-				return nil, errors.New("slice element is nil")
+				return errors.New("slice element is nil")
 			}
 			rvElem = rvElem.Elem()
 		}
-		for _, fieldInfo := range includedFields {
-			field := getFieldByIndexPath(rvElem, fieldInfo.indexPath)
-			var value string
-			
-			switch field.Kind() {
-			case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
-				value = strconv.FormatInt(field.Int(), 10)
-			case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
-				value = strconv.FormatUint(field.Uint(), 10)
-			case reflect.Float32, reflect.Float64:
-				value = strconv.FormatFloat(field.Float(), 'f', -1, 64)
-			case reflect.Bool:
-				value = strconv.FormatBool(field.Bool())
-			case reflect.String:
-				value = field.String()
-			case reflect.Struct:
-				if field.Type() == reflect.TypeOf(time.Time{}) {
-					t := field.Interface().(time.Time)
-					b, err := t.MarshalText()
-					if err != nil {
-						return nil, err
-					}
-					value = string(b)
-				} else {
-					return nil, fmt.Errorf("unsupported struct type: %s", field.Type())
-				}
-			default:
-				return nil, fmt.Errorf("unsupported field type: %s", field.Type())
+		record, err := encodeRecord(rvElem, includedFields, opt)
+		if err != nil {
+			return err
+		}
+		if err = writer.Write(record); err != nil {
+			return err
+		}
+		if (i+1)%marshalChunkSize == 0 {
+			writer.Flush()
+			if err = writer.Error(); err != nil {
+				return err
 			}
-			record = append(record, value)
 		}
-		records = append(records, record)
 	}
 
-	b := &bytes.Buffer{}
-	writer := csv.NewWriter(b)
-	if err := writer.WriteAll(records); err != nil {
-		return nil, err
+	writer.Flush()
+	return writer.Error()
+}
+
+// encodeRecord converts the given fields of a struct value into a CSV record
+func encodeRecord(rv reflect.Value, fields []fieldInfo, opt Options) ([]string, error) {
+	record := make([]string, 0, len(fields))
+	for _, fieldInfo := range fields {
+		if !fieldInfo.supported {
+			return nil, unsupportedTypeError(rv.Type(), fieldInfo)
+		}
+		field, ok := lookupFieldByIndexPath(rv, fieldInfo.indexPath)
+		if !ok {
+			// The field lives in a nil embedded struct pointer
+			record = append(record, "")
+			continue
+		}
+		if opt.StableColumns && fieldInfo.omitempty && isZeroValue(field) {
+			record = append(record, "")
+			continue
+		}
+		value, err := encodeValue(field, opt)
+		if err != nil {
+			return nil, err
+		}
+		record = append(record, value)
 	}
+	return record, nil
+}
 
-	return b.Bytes(), nil
+// encodeValue formats a field value as a CSV cell according to the field's kind
+// A nil pointer is written as an empty cell, a non-nil pointer as the value it points to.
+func encodeValue(field reflect.Value, opt Options) (string, error) {
+	switch field.Kind() {
+	case reflect.Ptr:
+		if field.IsNil() {
+			return "", nil
+		}
+		return encodeValue(field.Elem(), opt)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return strconv.FormatInt(field.Int(), 10), nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return strconv.FormatUint(field.Uint(), 10), nil
+	case reflect.Float32, reflect.Float64:
+		return strconv.FormatFloat(field.Float(), 'f', -1, 64), nil
+	case reflect.Bool:
+		return strconv.FormatBool(field.Bool()), nil
+	case reflect.String:
+		return field.String(), nil
+	case reflect.Struct:
+		if field.Type() == reflect.TypeOf(time.Time{}) {
+			t := field.Interface().(time.Time)
+			if t.IsZero() && !opt.ZeroTimeAsTimestamp {
+				return "", nil
+			}
+			b, err := t.MarshalText()
+			if err != nil {
+				return "", err
+			}
+			return string(b), nil
+		}
+		return "", fmt.Errorf("unsupported struct type: %s", field.Type())
+	default:
+		return "", fmt.Errorf("unsupported field type: %s", field.Type())
+	}
+}
+
+// headerOf returns the column names of the given fields
+func headerOf(fields []fieldInfo) []string {
+	header := make([]string, 0, len(fields))
+	for _, field := range fields {
+		header = append(header, field.name)
+	}
+	return header
 }
 
 func Unmarshal(data []byte, v interface{}) error {
+	return UnmarshalWithOptions(data, v)
+}
+
+// UnmarshalWithOptions is like Unmarshal but accepts Options to tune decoding
+func UnmarshalWithOptions(data []byte, v interface{}, opts ...Option) error {
+	return UnmarshalFrom(bytes.NewReader(data), v, detectCompression(data, opts)...)
+}
+
+// UnmarshalFrom is like UnmarshalWithOptions but reads the CSV data from r through a Decoder,
+// so memory stays proportional to the decoded structs rather than to the raw input.
+func UnmarshalFrom(r io.Reader, v interface{}, opts ...Option) error {
+	return unmarshalFrom(NewDecoder(r, opts...), v)
+}
+
+// UnmarshalWithStats is like UnmarshalWithOptions but also reports Stats about the decoded data
+func UnmarshalWithStats(data []byte, v interface{}, opts ...Option) (Stats, error) {
+	dec := NewDecoder(bytes.NewReader(data), detectCompression(data, opts)...)
+	err := unmarshalFrom(dec, v)
+	return dec.Stats(), err
+}
+
+// detectCompression returns the options to decode data with, switching to gzip when data is compressed
+func detectCompression(data []byte, opts []Option) []Option {
+	if isGzip(data) {
+		return append(opts[:len(opts):len(opts)], WithCompression(Gzip))
+	}
+	return opts
+}
+
+// unmarshalFrom decodes every record of dec into v
+func unmarshalFrom(dec *Decoder, v interface{}) error {
+	opt := dec.opt
 	rv := reflect.ValueOf(v)
 	var sliceValue reflect.Value
 	var sliceType reflect.Type
 	var singleStruct bool
+	// arrayValue is valid when decoding into a fixed size array, rows are collected into sliceValue first
+	var arrayValue reflect.Value
 
 	if rv.Kind() == reflect.Ptr && rv.Elem().Kind() == reflect.Slice {
 		sliceValue = rv.Elem()
 		sliceType = rv.Elem().Type().Elem()
+	} else if rv.Kind() == reflect.Ptr && rv.Elem().Kind() == reflect.Array {
+		arrayValue = rv.Elem()
+		sliceType = arrayValue.Type().Elem()
+		sliceValue = reflect.New(reflect.SliceOf(sliceType)).Elem()
+		sliceValue.Set(reflect.MakeSlice(sliceValue.Type(), 0, arrayValue.Len()))
 	} else if rv.Kind() == reflect.Ptr && rv.Elem().Kind() == reflect.Struct {
 		sliceValue = reflect.New(reflect.SliceOf(rv.Type().Elem())).Elem()
 		sliceType = rv.Elem().Type()
 		singleStruct = true
 	} else {
-		return errors.New("v must be a pointer to a struct or a slice of struct")
+		return errors.New("v must be a pointer to a struct, a slice of struct or an array of struct")
+	}
+
+	if isMapElem(sliceType) && !singleStruct && !arrayValue.IsValid() {
+		if err := unmarshalMaps(dec, sliceValue); err != nil {
+			if err == io.EOF {
+				return errors.New("no records found")
+			}
+			return err
+		}
+		return nil
 	}
 
 	var isPtr bool
@@ -311,91 +564,49 @@ func Unmarshal(data []byte, v interface{}) error {
 	if sliceType.Kind() != reflect.Struct {
 		return errors.New("element must be a struct")
 	}
-
-	reader := csv.NewReader(bytes.NewReader(data))
-	records, err := reader.ReadAll()
-	if err != nil {
+	if _, err := structFields(sliceType); err != nil {
 		return err
 	}
-	if len(records) == 0 {
-		return errors.New("no records found")
-	}
 
-	headers := records[0]
-
-	// Collect all fields including embedded struct fields
-	fields := collectFields(sliceType)
-	fieldMap := make(map[string][]int)
-	for _, field := range fields {
-		fieldMap[field.name] = field.indexPath
+	if _, err := dec.Header(); err != nil {
+		if err == io.EOF {
+			return errors.New("no records found")
+		}
+		return err
 	}
 
-	for _, record := range records[1:] {
-		newValue := reflect.New(sliceType)
-		limit := len(headers)
-		if len(record) < limit {
-			limit = len(record)
-		}
-		for i := 0; i < limit; i++ {
-			value := record[i]
-			header := headers[i]
-			if indexPath, ok := fieldMap[header]; ok {
-				field := getFieldByIndexPath(newValue.Elem(), indexPath)
-				switch field.Kind() {
-				case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
-					var intValue int64
-					if value != "" {
-						if intValue, err = strconv.ParseInt(value, 10, 64); err != nil {
-							return err
-						}
-					}
-					field.SetInt(intValue)
-				case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
-					var uintValue uint64
-					if value != "" {
-						if uintValue, err = strconv.ParseUint(value, 10, 64); err != nil {
-							return err
-						}
-					}
-					field.SetUint(uintValue)
-				case reflect.Float32, reflect.Float64:
-					var floatValue float64
-					if value != "" {
-						if floatValue, err = strconv.ParseFloat(value, 64); err != nil {
-							return err
-						}
-					}
-					field.SetFloat(floatValue)
-				case reflect.Bool:
-					var boolValue bool
-					if value != "" {
-						if boolValue, err = strconv.ParseBool(value); err != nil {
-							return err
-						}
-					}
-					field.SetBool(boolValue)
-				case reflect.String:
-					field.SetString(value)
-				case reflect.Struct:
-					if field.Type() == reflect.TypeOf(time.Time{}) {
-						var t time.Time
-						if err := t.UnmarshalText([]byte(value)); err != nil {
-							return err
-						}
-						field.Set(reflect.ValueOf(t))
-					} else {
-						return fmt.Errorf("unsupported struct type: %s", field.Type())
-					}
-				default:
-					return fmt.Errorf("unsupported field type: %s", field.Type())
-				}
+	for {
+		if arrayValue.IsValid() && sliceValue.Len() >= arrayValue.Len() {
+			// Only probe whether another row exists, it cannot be stored
+			if err := dec.decode(reflect.New(sliceType).Elem()); err == io.EOF {
+				break
+			} else if err != nil {
+				return err
 			}
+			return fmt.Errorf("data has more than %d rows, the length of %s", arrayValue.Len(), arrayValue.Type())
+		}
+		newValue := reflect.New(sliceType)
+		if err := dec.decode(newValue.Elem()); err == io.EOF {
+			break
+		} else if err != nil {
+			return err
 		}
 		if isPtr {
 			sliceValue.Set(reflect.Append(sliceValue, newValue))
 		} else {
 			sliceValue.Set(reflect.Append(sliceValue, newValue.Elem()))
 		}
+		if singleStruct {
+			break
+		}
+	}
+
+	if arrayValue.IsValid() {
+		if opt.StrictArrayLength && sliceValue.Len() != arrayValue.Len() {
+			return fmt.Errorf("data has %d rows, want exactly %d for %s", sliceValue.Len(), arrayValue.Len(), arrayValue.Type())
+		}
+		arrayValue.SetZero()
+		reflect.Copy(arrayValue, sliceValue)
 	}
 
 	if singleStruct {
@@ -407,3 +618,89 @@ func Unmarshal(data []byte, v interface{}) error {
 
 	return nil
 }
+
+// decodeValue parses value and stores it into field according to the field's kind
+// An empty cell leaves a pointer field nil, any other value is decoded into a newly allocated pointer.
+func decodeValue(field reflect.Value, value string, opt Options) error {
+	switch field.Kind() {
+	case reflect.Ptr:
+		if value == "" {
+			field.SetZero()
+			return nil
+		}
+		elem := reflect.New(field.Type().Elem())
+		if err := decodeValue(elem.Elem(), value, opt); err != nil {
+			return err
+		}
+		field.Set(elem)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		var intValue int64
+		if value != "" {
+			var err error
+			// Parse with the field's own bit size so out-of-range values are rejected instead of truncated
+			if intValue, err = strconv.ParseInt(value, opt.intBase(), field.Type().Bits()); err != nil {
+				return err
+			}
+		}
+		field.SetInt(intValue)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		var uintValue uint64
+		if value != "" {
+			var err error
+			if uintValue, err = parseUint(value, opt.intBase(), field.Type().Bits()); err != nil {
+				return err
+			}
+		}
+		field.SetUint(uintValue)
+	case reflect.Float32, reflect.Float64:
+		var floatValue float64
+		if value != "" {
+			var err error
+			if floatValue, err = strconv.ParseFloat(value, field.Type().Bits()); err != nil {
+				return err
+			}
+		}
+		field.SetFloat(floatValue)
+	case reflect.Bool:
+		var boolValue bool
+		if value != "" {
+			var err error
+			if boolValue, err = strconv.ParseBool(value); err != nil {
+				return err
+			}
+		}
+		field.SetBool(boolValue)
+	case reflect.String:
+		field.SetString(value)
+	case reflect.Struct:
+		if field.Type() == reflect.TypeOf(time.Time{}) {
+			// An empty cell decodes to the zero time, mirroring how Marshal writes it
+			var t time.Time
+			if value != "" {
+				if err := t.UnmarshalText([]byte(value)); err != nil {
+					return err
+				}
+			}
+			field.Set(reflect.ValueOf(t))
+		} else {
+			return fmt.Errorf("unsupported struct type: %s", field.Type())
+		}
+	default:
+		return fmt.Errorf("unsupported field type: %s", field.Type())
+	}
+	return nil
+}
+
+// parseUint is like strconv.ParseUint but also accepts a leading "+" and a negative zero such as "-0",
+// which ParseInt allows for signed fields
+func parseUint(s string, base int, bitSize int) (uint64, error) {
+	if len(s) > 1 && s[0] == '+' {
+		return strconv.ParseUint(s[1:], base, bitSize)
+	}
+	if len(s) > 1 && s[0] == '-' {
+		if n, err := strconv.ParseUint(s[1:], base, bitSize); err == nil && n == 0 {
+			return 0, nil
+		}
+	}
+	return strconv.ParseUint(s, base, bitSize)
+}
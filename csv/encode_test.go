@@ -1,8 +1,12 @@
 package csv
 
 import (
+	"bytes"
+	"errors"
 	"reflect"
+	"strconv"
 	"testing"
+	"time"
 )
 
 type Ticket struct {
@@ -466,3 +470,544 @@ Charlie,30,charlie@example.com
 		t.Errorf("unexpected result:\ngot:\n%+v\nwant:\n%+v", records, expected)
 	}
 }
+
+type SizedInts struct {
+	I8  int8   `csv:"i8"`
+	I16 int16  `csv:"i16"`
+	U8  uint8  `csv:"u8"`
+	U16 uint16 `csv:"u16"`
+}
+
+func TestUnmarshal_IntBoundaries(t *testing.T) {
+	data := []byte(`i8,i16,u8,u16
+127,32767,255,65535
+-128,-32768,0,0
+`)
+
+	var records []SizedInts
+	if err := Unmarshal(data, &records); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	expected := []SizedInts{
+		{I8: 127, I16: 32767, U8: 255, U16: 65535},
+		{I8: -128, I16: -32768, U8: 0, U16: 0},
+	}
+	if !reflect.DeepEqual(records, expected) {
+		t.Errorf("unexpected result: got %+v, want %+v", records, expected)
+	}
+}
+
+func TestUnmarshal_IntOverflow(t *testing.T) {
+	tests := []struct {
+		name   string
+		data   string
+		row    int
+		column string
+	}{
+		{name: "int8 overflow", data: "i8,u8\n1,1\n128,1\n", row: 2, column: "i8"},
+		{name: "int8 underflow", data: "i8,u8\n-129,1\n", row: 1, column: "i8"},
+		{name: "uint8 overflow", data: "i8,u8\n1,300\n", row: 1, column: "u8"},
+		{name: "uint8 negative", data: "i8,u8\n1,-1\n", row: 1, column: "u8"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var records []SizedInts
+			err := Unmarshal([]byte(tt.data), &records)
+			if err == nil {
+				t.Fatalf("expected error, got records %+v", records)
+			}
+
+			var rowErr *RowError
+			if !errors.As(err, &rowErr) {
+				t.Fatalf("expected *RowError, got %T: %v", err, err)
+			}
+			if rowErr.Row != tt.row || rowErr.Column != tt.column {
+				t.Errorf("unexpected position: got row %d column %q, want row %d column %q", rowErr.Row, rowErr.Column, tt.row, tt.column)
+			}
+			if !errors.Is(err, strconv.ErrRange) && !errors.Is(err, strconv.ErrSyntax) {
+				t.Errorf("expected strconv error, got %v", err)
+			}
+		})
+	}
+}
+
+func TestUnmarshal_Array(t *testing.T) {
+	data := []byte(`name
+Alice
+Bob
+`)
+
+	arr := [3]Simple{{Name: "stale"}, {Name: "stale"}, {Name: "stale"}}
+	if err := Unmarshal(data, &arr); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	expected := [3]Simple{{Name: "Alice"}, {Name: "Bob"}, {}}
+	if arr != expected {
+		t.Errorf("unexpected result: got %+v, want %+v", arr, expected)
+	}
+}
+
+func TestUnmarshal_ArrayOfPointers(t *testing.T) {
+	data := []byte(`name
+Alice
+`)
+
+	var arr [1]*Simple
+	if err := Unmarshal(data, &arr); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if arr[0] == nil || arr[0].Name != "Alice" {
+		t.Errorf("unexpected result: %+v", arr[0])
+	}
+}
+
+func TestUnmarshal_ArrayTooManyRows(t *testing.T) {
+	data := []byte(`name
+Alice
+Bob
+`)
+
+	var arr [1]Simple
+	if err := Unmarshal(data, &arr); err == nil {
+		t.Fatalf("expected error when data has more rows than the array length")
+	}
+}
+
+func TestUnmarshal_ArrayStrictLength(t *testing.T) {
+	data := []byte(`name
+Alice
+`)
+
+	var arr [2]Simple
+	if err := UnmarshalWithOptions(data, &arr, Options{StrictArrayLength: true}); err == nil {
+		t.Fatalf("expected error when data has fewer rows than the array length")
+	}
+
+	var exact [1]Simple
+	if err := UnmarshalWithOptions(data, &exact, Options{StrictArrayLength: true}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
+
+type Event struct {
+	Name string    `csv:"name"`
+	At   time.Time `csv:"at"`
+}
+
+func TestMarshal_ZeroTime(t *testing.T) {
+	at := time.Date(2024, 5, 1, 8, 30, 0, 0, time.UTC)
+	events := []Event{{Name: "start", At: at}, {Name: "pending"}}
+
+	data, err := Marshal(events)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	expected := `name,at
+start,2024-05-01T08:30:00Z
+pending,
+`
+	if string(data) != expected {
+		t.Errorf("unexpected result: got %v, want %v", string(data), expected)
+	}
+
+	var decoded []Event
+	if err := Unmarshal(data, &decoded); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !reflect.DeepEqual(decoded, events) {
+		t.Errorf("round trip mismatch: got %+v, want %+v", decoded, events)
+	}
+	if !decoded[1].At.IsZero() {
+		t.Errorf("expected zero time after round trip, got %v", decoded[1].At)
+	}
+}
+
+func TestMarshal_ZeroTimeAsTimestamp(t *testing.T) {
+	data, err := MarshalWithOptions(Event{Name: "pending"}, Options{ZeroTimeAsTimestamp: true})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	expected := `name,at
+pending,0001-01-01T00:00:00Z
+`
+	if string(data) != expected {
+		t.Errorf("unexpected result: got %v, want %v", string(data), expected)
+	}
+
+	var decoded Event
+	if err := Unmarshal(data, &decoded); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !decoded.At.IsZero() {
+		t.Errorf("expected zero time after round trip, got %v", decoded.At)
+	}
+}
+
+type ConfigRow struct {
+	Name  string `csv:"name"`
+	Value int64  `csv:"value"`
+	Mask  uint16 `csv:"mask"`
+}
+
+func TestUnmarshal_IntBase(t *testing.T) {
+	data := []byte(`name,value,mask
+grouped,1_000,0x1F
+hex,-0x10,0b101
+octal,0o17,0
+plus,+5,+7
+zero,-0,-0
+`)
+
+	opts := Default()
+	opts.IntBase = IntBaseAuto
+	var rows []ConfigRow
+	if err := UnmarshalWithOptions(data, &rows, opts); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	expected := []ConfigRow{
+		{Name: "grouped", Value: 1000, Mask: 31},
+		{Name: "hex", Value: -16, Mask: 5},
+		{Name: "octal", Value: 15, Mask: 0},
+		{Name: "plus", Value: 5, Mask: 7},
+		{Name: "zero", Value: 0, Mask: 0},
+	}
+	if !reflect.DeepEqual(rows, expected) {
+		t.Errorf("unexpected result:\ngot:\n%+v\nwant:\n%+v", rows, expected)
+	}
+}
+
+func TestUnmarshal_IntBaseDefault(t *testing.T) {
+	// Base 10 stays the default, so prefixed and grouped values are rejected
+	for _, data := range []string{"name,value,mask\nx,1_000,0\n", "name,value,mask\nx,0x1F,0\n"} {
+		var rows []ConfigRow
+		if err := Unmarshal([]byte(data), &rows); err == nil {
+			t.Errorf("expected error for %q", data)
+		}
+	}
+
+	// Leading zeros keep their decimal meaning and signs are accepted
+	var rows []ConfigRow
+	if err := Unmarshal([]byte("name,value,mask\nx,010,+8\ny,-0,0\n"), &rows); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if rows[0].Value != 10 || rows[0].Mask != 8 || rows[1].Value != 0 {
+		t.Errorf("unexpected result: %+v", rows)
+	}
+}
+
+func TestUnmarshal_IntBaseZeroValue(t *testing.T) {
+	// An Options literal that leaves out IntBase keeps parsing base 10
+	for _, opts := range []Options{{Delimiter: ','}, {StrictArrayLength: true}} {
+		var rows []ConfigRow
+		if err := UnmarshalWithOptions([]byte("name,value,mask\nx,010,08\n"), &rows, opts); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if rows[0].Value != 10 || rows[0].Mask != 8 {
+			t.Errorf("unexpected result: %+v", rows)
+		}
+	}
+}
+
+func TestUnmarshal_IntBaseSixteen(t *testing.T) {
+	opts := Default()
+	opts.IntBase = 16
+	var rows []ConfigRow
+	if err := UnmarshalWithOptions([]byte("name,value,mask\nx,ff,FFFF\n"), &rows, opts); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if rows[0].Value != 255 || rows[0].Mask != 65535 {
+		t.Errorf("unexpected result: %+v", rows)
+	}
+}
+
+type PointerRecord struct {
+	Name string     `csv:"name"`
+	N    *int       `csv:"n,omitempty"`
+	S    *string    `csv:"s,omitempty"`
+	At   *time.Time `csv:"at,omitempty"`
+}
+
+func TestMarshal_OmitemptyPointers(t *testing.T) {
+	zeroInt, zeroString, zeroTime := 0, "", time.Time{}
+	one, str, at := 1, "x", time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name     string
+		records  []PointerRecord
+		expected string
+	}{
+		{
+			name:     "all nil omits the columns",
+			records:  []PointerRecord{{Name: "a"}, {Name: "b"}},
+			expected: "name\na\nb\n",
+		},
+		{
+			name:     "pointer to zero keeps the columns",
+			records:  []PointerRecord{{Name: "a", N: &zeroInt, S: &zeroString, At: &zeroTime}},
+			expected: "name,n,s,at\na,0,,\n",
+		},
+		{
+			name:     "nil renders empty next to set values",
+			records:  []PointerRecord{{Name: "a", N: &one, S: &str, At: &at}, {Name: "b"}},
+			expected: "name,n,s,at\na,1,x,2024-05-01T00:00:00Z\nb,,,\n",
+		},
+		{
+			name:     "nil and zero are distinct cells",
+			records:  []PointerRecord{{Name: "a", N: &zeroInt}, {Name: "b"}},
+			expected: "name,n\na,0\nb,\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data, err := Marshal(tt.records)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if string(data) != tt.expected {
+				t.Errorf("unexpected result:\ngot:\n%v\nwant:\n%v", string(data), tt.expected)
+			}
+		})
+	}
+}
+
+func TestUnmarshal_Pointers(t *testing.T) {
+	data := []byte("name,n,s,at\na,0,x,2024-05-01T00:00:00Z\nb,,,\n")
+
+	var records []PointerRecord
+	if err := Unmarshal(data, &records); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if records[0].N == nil || *records[0].N != 0 {
+		t.Errorf("expected pointer to 0, got %v", records[0].N)
+	}
+	if records[0].S == nil || *records[0].S != "x" {
+		t.Errorf("expected pointer to x, got %v", records[0].S)
+	}
+	if records[0].At == nil || !records[0].At.Equal(time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC)) {
+		t.Errorf("unexpected time: %v", records[0].At)
+	}
+	if records[1].N != nil || records[1].S != nil || records[1].At != nil {
+		t.Errorf("expected nil pointers for empty cells, got %+v", records[1])
+	}
+}
+
+func TestMarshal_NilEmbeddedPointer(t *testing.T) {
+	// A nil embedded pointer renders empty cells and is not allocated on the caller's value
+	records := []PtrExtendedRecord{{Extra: "E1"}}
+
+	data, err := Marshal(records)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	expected := "id,name,extra\n,,E1\n"
+	if string(data) != expected {
+		t.Errorf("unexpected result: got %q, want %q", string(data), expected)
+	}
+	if records[0].PtrBaseRecord != nil {
+		t.Errorf("expected embedded pointer to stay nil")
+	}
+}
+
+// countingWriter records the size of every write it receives
+type countingWriter struct {
+	bytes.Buffer
+	writes []int
+}
+
+func (w *countingWriter) Write(p []byte) (int, error) {
+	w.writes = append(w.writes, len(p))
+	return w.Buffer.Write(p)
+}
+
+func TestMarshalTo(t *testing.T) {
+	tickets := make([]Ticket, 3*marshalChunkSize+10)
+	for i := range tickets {
+		tickets[i] = Ticket{Name: "Alice", UserID: "U001", Ticket: i, RecordID: "R001", Source: "S001"}
+	}
+
+	w := &countingWriter{}
+	if err := MarshalTo(w, tickets); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	expected, err := Marshal(tickets)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !bytes.Equal(w.Bytes(), expected) {
+		t.Errorf("MarshalTo output differs from Marshal")
+	}
+
+	// Data reaches the writer in several flushes rather than in one piece at the end
+	if len(w.writes) < 4 {
+		t.Errorf("expected at least 4 writes, got %d", len(w.writes))
+	}
+}
+
+func TestMarshalTo_Error(t *testing.T) {
+	if err := MarshalTo(&bytes.Buffer{}, nil); err == nil {
+		t.Fatalf("expected error for nil value")
+	}
+	if err := MarshalTo(failingWriter{}, []Simple{{Name: "Alice"}}); err == nil {
+		t.Fatalf("expected write error")
+	}
+}
+
+type NoVisibleFields struct {
+	Skipped  string `csv:"-"`
+	internal int
+	Callback func()
+}
+
+type MixedVisibility struct {
+	Name     string `csv:"name"`
+	Secret   string `csv:"-"`
+	internal int
+}
+
+func TestMarshal_NoVisibleFields(t *testing.T) {
+	_, err := Marshal([]NoVisibleFields{{Skipped: "x", internal: 1}})
+	if err == nil || err.Error() != "type csv.NoVisibleFields has no csv-encodable fields" {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
+
+func TestUnmarshal_NoVisibleFields(t *testing.T) {
+	var records []NoVisibleFields
+	err := Unmarshal([]byte("Skipped\nx\n"), &records)
+	if err == nil || err.Error() != "type csv.NoVisibleFields has no csv-encodable fields" {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
+
+func TestMarshal_SkippedFields(t *testing.T) {
+	data, err := Marshal(MixedVisibility{Name: "Alice", Secret: "s", internal: 1})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if expected := "name\nAlice\n"; string(data) != expected {
+		t.Errorf("unexpected result: got %q, want %q", string(data), expected)
+	}
+
+	var decoded MixedVisibility
+	if err := Unmarshal([]byte("name,Secret,internal\nAlice,s,1\n"), &decoded); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if decoded != (MixedVisibility{Name: "Alice"}) {
+		t.Errorf("unexpected result: %+v", decoded)
+	}
+}
+
+type Address struct {
+	Street string `csv:"street"`
+	City   string `csv:"city"`
+}
+
+type Contact struct {
+	Name string   `csv:"name"`
+	Home Address  `csv:",prefix=home_"`
+	Work *Address `csv:",prefix=work_"`
+}
+
+type AmbiguousContact struct {
+	Name string  `csv:"name"`
+	Home Address `csv:",inline"`
+	Work Address `csv:",prefix=work_"`
+	City string  `csv:"city"`
+}
+
+type DuplicateAddresses struct {
+	Home Address `csv:",inline"`
+	Work Address `csv:",inline"`
+}
+
+func TestMarshal_PrefixedInlineStructs(t *testing.T) {
+	contacts := []Contact{
+		{Name: "Alice", Home: Address{Street: "Main St", City: "Springfield"}, Work: &Address{Street: "Elm St", City: "Shelbyville"}},
+		{Name: "Bob", Home: Address{Street: "Oak St", City: "Ogdenville"}},
+	}
+	data, err := Marshal(contacts)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	expected := `name,home_street,home_city,work_street,work_city
+Alice,Main St,Springfield,Elm St,Shelbyville
+Bob,Oak St,Ogdenville,,
+`
+	if string(data) != expected {
+		t.Errorf("unexpected result: got %v, want %v", string(data), expected)
+	}
+
+	var decoded []Contact
+	if err := Unmarshal(data, &decoded); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(decoded) != 2 || decoded[0].Home != contacts[0].Home || decoded[0].Work == nil || *decoded[0].Work != *contacts[0].Work {
+		t.Errorf("unexpected result: %+v", decoded)
+	}
+}
+
+func TestMarshal_DuplicateInlineColumns(t *testing.T) {
+	expected := `type csv.AmbiguousContact has duplicate column "city" from fields Home.City and City`
+
+	_, err := Marshal([]AmbiguousContact{{Name: "Alice"}})
+	if err == nil || err.Error() != expected {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	var decoded []AmbiguousContact
+	err = Unmarshal([]byte("name,street,city\nAlice,Main St,Springfield\n"), &decoded)
+	if err == nil || err.Error() != expected {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if err := NewEncoder(&bytes.Buffer{}, AmbiguousContact{}).Error(); err == nil || err.Error() != expected {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	_, err = Marshal([]DuplicateAddresses{{}})
+	if err == nil || err.Error() != `type csv.DuplicateAddresses has duplicate column "street" from fields Home.Street and Work.Street` {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
+
+func TestMarshal_StableColumns(t *testing.T) {
+	opt := Default()
+	opt.StableColumns = true
+
+	batches := [][]RecordWithOmitempty{
+		{{Name: "Alice"}},
+		{{Name: "Bob", Age: 30, Email: "bob@example.com", Active: true, Score: 1.5}},
+	}
+	expected := []string{
+		"name,age,email,active,score\nAlice,,,,\n",
+		"name,age,email,active,score\nBob,30,bob@example.com,true,1.5\n",
+	}
+	for i, batch := range batches {
+		data, err := MarshalWithOptions(batch, opt)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if string(data) != expected[i] {
+			t.Errorf("unexpected result: got %q, want %q", string(data), expected[i])
+		}
+
+		var decoded []RecordWithOmitempty
+		if err := Unmarshal(data, &decoded); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if !reflect.DeepEqual(decoded, batch) {
+			t.Errorf("unexpected result: got %+v, want %+v", decoded, batch)
+		}
+	}
+}
@@ -0,0 +1,160 @@
+package csv
+
+import (
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"reflect"
+)
+
+// Encoder writes structs as CSV records to an output stream.
+//
+// The columns are fixed by the record type, so unlike Marshal the omitempty columns are always written.
+// Write errors are sticky: once a write fails, every later call returns the same error, as with csv.Writer.
+// An Encoder is not safe for concurrent use, guard it with a mutex when records come from several goroutines.
+type Encoder struct {
+	w             *rowWriter
+	gz            *gzip.Writer
+	typ           reflect.Type
+	fields        []fieldInfo
+	opt           Options
+	headerWritten bool
+	err           error
+}
+
+// NewEncoder returns an Encoder writing to w. sample determines the record type and may be a struct,
+// a struct pointer or a slice of them, typically the zero value of the type to be written.
+// With Options.Compression set to Gzip the output is compressed, call Close to complete the stream.
+func NewEncoder(w io.Writer, sample interface{}, opts ...Option) *Encoder {
+	e := &Encoder{}
+	e.opt, e.err = mergeOptions(opts)
+	if e.opt.Compression == Gzip {
+		e.gz = gzip.NewWriter(w)
+		w = e.gz
+	}
+	if e.err == nil {
+		e.typ, e.err = structType(reflect.TypeOf(sample))
+	}
+	if e.err == nil {
+		e.fields, e.err = structFields(e.typ)
+	}
+	e.w = newRowWriter(w, e.fields, e.opt)
+	return e
+}
+
+// WriteHeader writes the header line. It is idempotent, only the first call writes anything.
+func (e *Encoder) WriteHeader() error {
+	if e.err != nil {
+		return e.err
+	}
+	if e.headerWritten {
+		return nil
+	}
+	if err := e.w.WriteHeader(headerOf(e.fields)); err != nil {
+		e.err = err
+		return err
+	}
+	e.headerWritten = true
+	return nil
+}
+
+// WriteRecord writes a single struct or struct pointer of the encoder's record type, the header is written first if needed.
+// The record is buffered, call Flush to make sure it reaches the underlying writer.
+func (e *Encoder) WriteRecord(v interface{}) error {
+	if err := e.WriteHeader(); err != nil {
+		return err
+	}
+
+	rv := reflect.ValueOf(v)
+	if rv.Kind() == reflect.Ptr {
+		if rv.IsNil() {
+			return errors.New("record is nil")
+		}
+		rv = rv.Elem()
+	}
+	if !rv.IsValid() || rv.Type() != e.typ {
+		return fmt.Errorf("record must be a %s, got %T", e.typ, v)
+	}
+
+	record, err := encodeRecord(rv, e.fields, e.opt)
+	if err != nil {
+		return err
+	}
+	if err = e.w.Write(record); err != nil {
+		e.err = err
+		return err
+	}
+	return nil
+}
+
+// Encode writes a struct, a struct pointer or every element of a slice of them
+func (e *Encoder) Encode(v interface{}) error {
+	rv := reflect.ValueOf(v)
+	if rv.Kind() == reflect.Ptr && !rv.IsNil() && rv.Elem().Kind() == reflect.Slice {
+		rv = rv.Elem()
+	}
+	if rv.Kind() != reflect.Slice {
+		return e.WriteRecord(v)
+	}
+
+	if err := e.WriteHeader(); err != nil {
+		return err
+	}
+	for i := 0; i < rv.Len(); i++ {
+		if err := e.WriteRecord(rv.Index(i).Interface()); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Flush writes any buffered data to the underlying writer
+func (e *Encoder) Flush() error {
+	if e.err != nil {
+		return e.err
+	}
+	e.w.Flush()
+	if err := e.w.Error(); err != nil {
+		e.err = err
+		return err
+	}
+	if e.gz != nil {
+		e.err = e.gz.Flush()
+	}
+	return e.err
+}
+
+// Close flushes any buffered data and completes the compressed stream if compression is enabled.
+// It does not close the underlying writer.
+func (e *Encoder) Close() error {
+	if err := e.Flush(); err != nil {
+		return err
+	}
+	if e.gz != nil {
+		e.err = e.gz.Close()
+	}
+	return e.err
+}
+
+// Error reports any error that has occurred during a previous write or flush
+func (e *Encoder) Error() error {
+	if e.err != nil {
+		return e.err
+	}
+	return e.w.Error()
+}
+
+// structType unwraps pointers, slices and arrays to the underlying struct type
+func structType(t reflect.Type) (reflect.Type, error) {
+	if t == nil {
+		return nil, errors.New("v is nil")
+	}
+	for t.Kind() == reflect.Ptr || t.Kind() == reflect.Slice || t.Kind() == reflect.Array {
+		t = t.Elem()
+	}
+	if t.Kind() != reflect.Struct {
+		return nil, errors.New("element must be a struct")
+	}
+	return t, nil
+}
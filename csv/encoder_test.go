@@ -0,0 +1,142 @@
+package csv
+
+import (
+	"bytes"
+	"errors"
+	"sync"
+	"testing"
+)
+
+func TestEncoder_WriteRecord(t *testing.T) {
+	b := &bytes.Buffer{}
+	enc := NewEncoder(b, Ticket{})
+
+	// WriteRecord before WriteHeader writes the header exactly once
+	if err := enc.WriteRecord(Ticket{Name: "Alice", UserID: "U001", Ticket: 1, RecordID: "R001", Source: "S001"}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := enc.WriteHeader(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := enc.WriteRecord(&Ticket{Name: "Bob", UserID: "U002", Ticket: 2, RecordID: "R002", Source: "S002"}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := enc.Flush(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	expected := `name,user_id,ticket,record_id,source
+Alice,U001,1,R001,S001
+Bob,U002,2,R002,S002
+`
+	if b.String() != expected {
+		t.Errorf("unexpected result: got %v, want %v", b.String(), expected)
+	}
+}
+
+func TestEncoder_HeaderOnly(t *testing.T) {
+	b := &bytes.Buffer{}
+	enc := NewEncoder(b, []*Simple(nil))
+	if err := enc.WriteHeader(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := enc.WriteHeader(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := enc.Flush(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if b.String() != "name\n" {
+		t.Errorf("unexpected result: %q", b.String())
+	}
+}
+
+func TestEncoder_OmitemptyColumnsAlwaysWritten(t *testing.T) {
+	b := &bytes.Buffer{}
+	enc := NewEncoder(b, RecordWithOmitempty{})
+	if err := enc.Encode([]RecordWithOmitempty{{Name: "Alice"}}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := enc.Flush(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	expected := `name,age,email,active,score
+Alice,0,,false,0
+`
+	if b.String() != expected {
+		t.Errorf("unexpected result: got %v, want %v", b.String(), expected)
+	}
+}
+
+func TestEncoder_TypeMismatch(t *testing.T) {
+	enc := NewEncoder(&bytes.Buffer{}, Simple{})
+	if err := enc.WriteRecord(Ticket{}); err == nil {
+		t.Fatalf("expected error for mismatched record type")
+	}
+	if err := enc.WriteRecord((*Simple)(nil)); err == nil {
+		t.Fatalf("expected error for nil record")
+	}
+}
+
+func TestEncoder_InvalidSample(t *testing.T) {
+	enc := NewEncoder(&bytes.Buffer{}, 1)
+	if err := enc.WriteHeader(); err == nil {
+		t.Fatalf("expected error for non-struct sample")
+	}
+	if enc.Error() == nil {
+		t.Fatalf("expected sticky error")
+	}
+}
+
+type failingWriter struct{}
+
+func (failingWriter) Write([]byte) (int, error) {
+	return 0, errors.New("disk full")
+}
+
+func TestEncoder_StickyError(t *testing.T) {
+	enc := NewEncoder(failingWriter{}, Simple{})
+	if err := enc.WriteRecord(Simple{Name: "Alice"}); err != nil {
+		t.Fatalf("unexpected error before flush: %v", err)
+	}
+	if err := enc.Flush(); err == nil {
+		t.Fatalf("expected flush error")
+	}
+	if err := enc.WriteRecord(Simple{Name: "Bob"}); err == nil {
+		t.Fatalf("expected sticky error after failed flush")
+	}
+	if enc.Error() == nil {
+		t.Fatalf("expected Error to report the failure")
+	}
+}
+
+func TestEncoder_ConcurrentWithMutex(t *testing.T) {
+	b := &bytes.Buffer{}
+	enc := NewEncoder(b, Simple{})
+
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 10; j++ {
+				mu.Lock()
+				if err := enc.WriteRecord(Simple{Name: "x"}); err != nil {
+					t.Error(err)
+				}
+				mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+	if err := enc.Flush(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if got := bytes.Count(b.Bytes(), []byte("\n")); got != 81 {
+		t.Errorf("expected 81 lines, got %d", got)
+	}
+}
@@ -0,0 +1,60 @@
+package csv
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+)
+
+// RowError describes a failure while decoding a specific data row.
+type RowError struct {
+	// Row is the 1-based data row number, the header line is not counted.
+	// It is 0 for errors from DecodeRecord, which has no notion of rows.
+	Row int
+	// Column is the header name of the offending cell, empty if the error is not column specific
+	Column string
+	Err    error
+}
+
+func (e *RowError) Error() string {
+	if e.Row == 0 {
+		if e.Column == "" {
+			return e.Err.Error()
+		}
+		return fmt.Sprintf("column %q: %v", e.Column, e.Err)
+	}
+	if e.Column == "" {
+		return fmt.Sprintf("row %d: %v", e.Row, e.Err)
+	}
+	return fmt.Sprintf("row %d, column %q: %v", e.Row, e.Column, e.Err)
+}
+
+func (e *RowError) Unwrap() error {
+	return e.Err
+}
+
+// UnsupportedTypeError reports a struct field whose type cannot be converted to or from a CSV cell,
+// such as a chan, func or complex field.
+type UnsupportedTypeError struct {
+	// Field is the Go field path including the struct name, such as "Order.Meta.Callback"
+	Field string
+	// Column is the column name of the field
+	Column string
+	Type   reflect.Type
+}
+
+func (e *UnsupportedTypeError) Error() string {
+	return fmt.Sprintf("field %s (column %q) has unsupported type %s", e.Field, e.Column, e.Type)
+}
+
+// unsupportedTypeError returns the error for the unsupported field of the struct type t
+func unsupportedTypeError(t reflect.Type, field fieldInfo) error {
+	return &UnsupportedTypeError{
+		Field:  t.Name() + "." + field.goPath,
+		Column: field.name,
+		Type:   t.FieldByIndex(field.indexPath).Type,
+	}
+}
+
+// ErrStop can be returned by a per-row callback to stop reading early, the caller then reports no error
+var ErrStop = errors.New("csv: stop")
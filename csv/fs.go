@@ -0,0 +1,77 @@
+package csv
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"reflect"
+	"slices"
+)
+
+// UnmarshalFS opens the named file in fsys, such as an embed.FS, and streams it into v like UnmarshalFrom
+func UnmarshalFS(fsys fs.FS, name string, v interface{}, opts ...Option) error {
+	f, err := fsys.Open(name)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	return UnmarshalFrom(f, v, opts...)
+}
+
+// UnmarshalFSGlob decodes the rows of every file in fsys matching pattern, in lexical order, and appends them to v,
+// which must be a pointer to a slice. All files must have the same header as the first one.
+func UnmarshalFSGlob(fsys fs.FS, pattern string, v interface{}, opts ...Option) error {
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Ptr || rv.IsNil() || rv.Elem().Kind() != reflect.Slice {
+		return errors.New("v must be a pointer to a slice of struct")
+	}
+
+	names, err := fs.Glob(fsys, pattern)
+	if err != nil {
+		return err
+	}
+	if len(names) == 0 {
+		return fmt.Errorf("no files match pattern %q", pattern)
+	}
+
+	var header []string
+	var first string
+	for _, name := range names {
+		rows := reflect.New(rv.Elem().Type())
+		err := unmarshalFSFile(fsys, name, rows.Interface(), func(h []string) error {
+			if header == nil {
+				header, first = append([]string(nil), h...), name
+			} else if !slices.Equal(h, header) {
+				return fmt.Errorf("header %v does not match header %v of %s", h, header, first)
+			}
+			return nil
+		}, opts)
+		if err != nil {
+			return fmt.Errorf("%s: %w", name, err)
+		}
+		rv.Elem().Set(reflect.AppendSlice(rv.Elem(), rows.Elem()))
+	}
+	return nil
+}
+
+// unmarshalFSFile decodes the named file into v after checkHeader has accepted its header
+func unmarshalFSFile(fsys fs.FS, name string, v interface{}, checkHeader func([]string) error, opts []Option) error {
+	f, err := fsys.Open(name)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	dec := NewDecoder(f, opts...)
+	header, err := dec.Header()
+	if err == nil {
+		err = checkHeader(header)
+	}
+	// Leave empty files to unmarshalFrom so they fail the same way as with UnmarshalFS
+	if err != nil && !errors.Is(err, io.EOF) {
+		return err
+	}
+	return unmarshalFrom(dec, v)
+}
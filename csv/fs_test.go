@@ -0,0 +1,72 @@
+package csv
+
+import (
+	"strings"
+	"testing"
+	"testing/fstest"
+)
+
+func TestUnmarshalFS(t *testing.T) {
+	fsys := fstest.MapFS{
+		"data/users.csv": {Data: []byte("name\nAlice\nBob\n")},
+	}
+
+	var records []Simple
+	if err := UnmarshalFS(fsys, "data/users.csv", &records); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(records) != 2 || records[0].Name != "Alice" || records[1].Name != "Bob" {
+		t.Errorf("unexpected result: %+v", records)
+	}
+
+	if err := UnmarshalFS(fsys, "data/missing.csv", &records); err == nil {
+		t.Fatalf("expected error for missing file")
+	}
+}
+
+func TestUnmarshalFSGlob(t *testing.T) {
+	fsys := fstest.MapFS{
+		"data/b.csv":  {Data: []byte("name\nCarol\n")},
+		"data/a.csv":  {Data: []byte("name\nAlice\nBob\n")},
+		"data/c.txt":  {Data: []byte("ignored")},
+		"other/d.csv": {Data: []byte("name\nDave\n")},
+	}
+
+	var records []*Simple
+	if err := UnmarshalFSGlob(fsys, "data/*.csv", &records); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	var names []string
+	for _, record := range records {
+		names = append(names, record.Name)
+	}
+	if got := strings.Join(names, ","); got != "Alice,Bob,Carol" {
+		t.Errorf("unexpected result: got %v, want %v", got, "Alice,Bob,Carol")
+	}
+}
+
+func TestUnmarshalFSGlob_HeaderMismatch(t *testing.T) {
+	fsys := fstest.MapFS{
+		"a.csv": {Data: []byte("name,user_id\nAlice,U001\n")},
+		"b.csv": {Data: []byte("user_id,name\nU002,Bob\n")},
+	}
+
+	var records []Ticket
+	err := UnmarshalFSGlob(fsys, "*.csv", &records)
+	if err == nil || !strings.HasPrefix(err.Error(), "b.csv: header [user_id name] does not match header [name user_id] of a.csv") {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
+
+func TestUnmarshalFSGlob_Errors(t *testing.T) {
+	fsys := fstest.MapFS{"a.csv": {Data: []byte("name\nAlice\n")}}
+
+	var records []Simple
+	if err := UnmarshalFSGlob(fsys, "*.json", &records); err == nil || err.Error() != `no files match pattern "*.json"` {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	var record Simple
+	if err := UnmarshalFSGlob(fsys, "*.csv", &record); err == nil {
+		t.Fatalf("expected error for non-slice target")
+	}
+}
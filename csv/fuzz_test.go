@@ -0,0 +1,68 @@
+package csv
+
+import (
+	"bytes"
+	"io"
+	"testing"
+	"time"
+)
+
+type FuzzMeta struct {
+	Source string  `csv:"source"`
+	Weight float32 `csv:"weight"`
+}
+
+type FuzzRecord struct {
+	*FuzzMeta
+	Name    string     `csv:"name"`
+	Count   int8       `csv:"count"`
+	Total   uint64     `csv:"total"`
+	Ratio   float64    `csv:"ratio"`
+	Active  bool       `csv:"active"`
+	At      time.Time  `csv:"at"`
+	Updated *time.Time `csv:"updated,omitempty"`
+	Home    *Address   `csv:",prefix=home_"`
+}
+
+func FuzzUnmarshal(f *testing.F) {
+	f.Add([]byte("name,count,total,ratio,active,at,source,weight\nAlice,1,2,0.5,true,2024-01-02T03:04:05Z,S,1.5\n"))
+	f.Add([]byte("name,updated,home_city\n\"multi\nline\",2024-01-02T03:04:05Z,X\r\n"))
+	f.Add([]byte("name,\rBob,\r"))
+	f.Add([]byte("count\n-129\n"))
+	f.Add([]byte("\"unterminated\n"))
+	f.Add([]byte{0x1f, 0x8b, 0x08})
+
+	f.Fuzz(func(t *testing.T, data []byte) {
+		opt := Default()
+		opt.TrimTrailingDelimiter = true
+		opt.MaxColumns = 64
+		opt.MaxRecordBytes = 1 << 12
+
+		var records []FuzzRecord
+		_ = UnmarshalWithOptions(data, &records, opt)
+		var array [2]*FuzzRecord
+		_ = UnmarshalWithOptions(data, &array, opt)
+		var record FuzzRecord
+		_ = Unmarshal(data, &record)
+	})
+}
+
+func FuzzCRReader(f *testing.F) {
+	f.Add([]byte("a,b\rc,d\r"))
+	f.Add([]byte("a\r\nb\r\n"))
+	f.Add([]byte("\"x\ry\"\r\"\"\"\"\r"))
+
+	f.Fuzz(func(t *testing.T, data []byte) {
+		out, err := io.ReadAll(newCRReader(bytes.NewReader(data), 0))
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		// Offsets must keep referring to the original input
+		if len(out) != len(data) {
+			t.Fatalf("length changed: got %d, want %d", len(out), len(data))
+		}
+		if bytes.Count(out, []byte("\r")) > bytes.Count(data, []byte("\r")) {
+			t.Fatalf("carriage returns added: %q", out)
+		}
+	})
+}
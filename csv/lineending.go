@@ -0,0 +1,64 @@
+package csv
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+)
+
+// crReader turns lone "\r" line endings, as written by some legacy exports, into "\n".
+// encoding/csv only understands "\n" and "\r\n" and would otherwise read the whole input as a single record.
+// Carriage returns inside quoted fields are left alone, and every byte is replaced in place
+// so that offsets such as Decoder.InputOffset still refer to the original input.
+//
+// It also enforces Options.MaxRecordBytes before encoding/csv buffers an oversized record.
+type crReader struct {
+	r      *bufio.Reader
+	quoted bool
+	// maxRecord is the limit of bytes per record, 0 means unlimited
+	maxRecord   int64
+	recordBytes int64
+	err         error
+}
+
+func newCRReader(r io.Reader, maxRecord int64) *crReader {
+	return &crReader{r: bufio.NewReader(r), maxRecord: maxRecord}
+}
+
+func (c *crReader) Read(p []byte) (int, error) {
+	if c.err != nil {
+		return 0, c.err
+	}
+	n, err := c.r.Read(p)
+	for i := 0; i < n; i++ {
+		switch p[i] {
+		case '"':
+			// An escaped quote toggles twice, so only field delimiting quotes change the state
+			c.quoted = !c.quoted
+		case '\r':
+			if c.quoted {
+				break
+			}
+			var next byte
+			if i+1 < n {
+				next = p[i+1]
+			} else if b, _ := c.r.Peek(1); len(b) > 0 {
+				next = b[0]
+			}
+			if next != '\n' {
+				p[i] = '\n'
+			}
+		}
+
+		if p[i] == '\n' && !c.quoted {
+			c.recordBytes = 0
+			continue
+		}
+		c.recordBytes++
+		if c.maxRecord > 0 && c.recordBytes > c.maxRecord {
+			c.err = fmt.Errorf("record exceeds %d bytes", c.maxRecord)
+			return i, c.err
+		}
+	}
+	return n, err
+}
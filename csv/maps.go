@@ -0,0 +1,83 @@
+package csv
+
+import (
+	"errors"
+	"io"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var (
+	mapType       = reflect.TypeOf(map[string]any(nil))
+	interfaceType = reflect.TypeOf((*any)(nil)).Elem()
+)
+
+// isMapElem reports whether a slice element type is decoded as a map of column name to cell value,
+// which is the case for map[string]any and any
+func isMapElem(t reflect.Type) bool {
+	return t == mapType || t == interfaceType
+}
+
+// unmarshalMaps decodes every record of dec as a map[string]any appended to sliceValue
+func unmarshalMaps(dec *Decoder, sliceValue reflect.Value) error {
+	header, err := dec.Header()
+	if err != nil {
+		return err
+	}
+	if header == nil {
+		return errors.New("decoding into maps with NoHeader requires Options.Header")
+	}
+	for {
+		record, err := dec.read()
+		if err != nil {
+			if err == io.EOF {
+				break
+			}
+			return err
+		}
+		row := make(map[string]any, len(header))
+		for i, name := range header {
+			var value string
+			if i < len(record) {
+				value = record[i]
+			}
+			if transform, ok := dec.opt.Transforms[name]; ok {
+				if value, err = transform(value); err != nil {
+					return &RowError{Row: dec.row, Column: name, Err: err}
+				}
+			}
+			if dec.opt.NoTypeInference {
+				row[name] = value
+			} else {
+				row[name] = inferValue(value)
+			}
+		}
+		sliceValue.Set(reflect.Append(sliceValue, reflect.ValueOf(row)))
+		dec.stats.RowsDecoded++
+	}
+	return nil
+}
+
+// inferValue converts a cell to the first matching type of int64, float64, bool ("true" or "false" in any case)
+// and time.Time (RFC 3339), and keeps it as a string otherwise
+func inferValue(value string) any {
+	if i, err := strconv.ParseInt(value, 10, 64); err == nil {
+		return i
+	}
+	// ParseFloat also accepts words like "NaN" and "Inf", which are more likely text
+	if f, err := strconv.ParseFloat(value, 64); err == nil && strings.ContainsAny(value, "0123456789") {
+		return f
+	}
+	if strings.EqualFold(value, "true") {
+		return true
+	}
+	if strings.EqualFold(value, "false") {
+		return false
+	}
+	if t, err := time.Parse(time.RFC3339Nano, value); err == nil {
+		return t
+	}
+	return value
+}
@@ -0,0 +1,60 @@
+package csv
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+	"time"
+)
+
+const mixedData = `id,price,active,at,name,note
+1,9.5,TRUE,2024-01-02T03:04:05Z,Alice,
+-2,1e3,false,2024-01-02,NaN,x
+`
+
+func TestUnmarshal_Maps(t *testing.T) {
+	var rows []map[string]any
+	if err := Unmarshal([]byte(mixedData), &rows); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	expected := []map[string]any{
+		{"id": int64(1), "price": 9.5, "active": true, "at": time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC), "name": "Alice", "note": ""},
+		{"id": int64(-2), "price": 1000.0, "active": false, "at": "2024-01-02", "name": "NaN", "note": "x"},
+	}
+	if !reflect.DeepEqual(rows, expected) {
+		t.Errorf("unexpected result: got %v, want %v", rows, expected)
+	}
+}
+
+func TestUnmarshal_AnySlice(t *testing.T) {
+	opt := Default()
+	opt.NoTypeInference = true
+
+	var rows []any
+	if err := UnmarshalWithOptions([]byte(mixedData), &rows, opt); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(rows) != 2 {
+		t.Fatalf("expected 2 rows, got %d", len(rows))
+	}
+	row, ok := rows[0].(map[string]any)
+	if !ok {
+		t.Fatalf("unexpected row type %T", rows[0])
+	}
+	if row["id"] != "1" || row["active"] != "TRUE" {
+		t.Errorf("unexpected result: %v", row)
+	}
+}
+
+func TestUnmarshal_InterfaceElement(t *testing.T) {
+	var rows []fmt.Stringer
+	if err := Unmarshal([]byte(mixedData), &rows); err == nil || err.Error() != "element must be a struct" {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	var empty []map[string]any
+	if err := Unmarshal(nil, &empty); err == nil || err.Error() != "no records found" {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
@@ -0,0 +1,239 @@
+package csv
+
+import (
+	"errors"
+	"fmt"
+	"unicode/utf8"
+)
+
+// Options controls optional behaviour of the codec. The zero value is the default configuration.
+//
+// Options is itself an Option: passing an Options value replaces all settings made before it,
+// while the With functions change a single setting.
+type Options struct {
+	// Encoding and decoding
+
+	// Delimiter separates the cells of a record, ',' when zero
+	Delimiter rune
+	// NoHeader writes no header line and reads data rows only. When decoding, the columns are taken from Header,
+	// or if that is empty, from the fields of the record type in declaration order.
+	NoHeader bool
+	// Compression wraps the encoded output or the decoded input, Unmarshal detects gzip data by itself
+	Compression Compression
+	// ZeroTimeAsTimestamp restores the old behaviour of writing a zero time.Time as "0001-01-01T00:00:00Z",
+	// by default it is written as an empty cell
+	ZeroTimeAsTimestamp bool
+
+	// Encoding only
+
+	// StableColumns makes Marshal always write the omitempty columns so the header only depends on the type,
+	// zero values of omitempty fields are then written as empty cells
+	StableColumns bool
+	// QuoteAll makes Marshal and Encoder quote every data cell, columns tagged quote or noquote keep their own quoting
+	QuoteAll bool
+
+	// Decoding only
+
+	// Header supplies the column names for decoding, the input is then expected to contain data rows only
+	Header []string
+	// RequireAllColumns makes decoding fail unless every struct field has a column, as if all were tagged required
+	RequireAllColumns bool
+	// StrictArrayLength makes Unmarshal into an array fail unless the data has exactly as many rows as the array length,
+	// by default missing rows leave the remaining elements zeroed
+	StrictArrayLength bool
+	// StartOffset makes a Decoder resume at this byte offset, a value previously returned by Decoder.InputOffset
+	StartOffset int64
+	// IntBase is the base used to parse integer fields, 10 when zero. IntBaseAuto detects the base from
+	// prefixes such as "0x", "0o" and "0b" and allows Go style underscore grouping like "1_000".
+	IntBase int
+	// SkipValidation disables calling Validate on decoded records that implement Validator
+	SkipValidation bool
+	// TrimTrailingDelimiter drops the final empty cell of every record when the header ends with an empty name,
+	// for producers that terminate each row with a delimiter like "a,b,"
+	TrimTrailingDelimiter bool
+	// Transforms rewrites raw cell strings before they are converted, keyed by header name.
+	// Use it to strip decorations such as currency symbols or to map legacy codes.
+	Transforms map[string]func(string) (string, error)
+	// NoTypeInference keeps every cell as a string when unmarshaling into []map[string]any or []any,
+	// instead of inferring int64, float64, bool and time.Time values
+	NoTypeInference bool
+	// MaxColumns rejects input whose header or records have more cells, 0 means unlimited
+	MaxColumns int
+	// MaxRecordBytes rejects input with a record longer than this many bytes, line breaks excluded, 0 means unlimited.
+	// Together with MaxColumns it bounds the memory an untrusted file can make the decoder allocate.
+	MaxRecordBytes int64
+}
+
+// IntBaseAuto makes Options.IntBase detect the base of each integer from its prefix
+const IntBaseAuto = -1
+
+// Default returns the default Options
+func Default() Options {
+	return Options{IntBase: 10}
+}
+
+// Validate reports invalid settings and contradictory combinations of settings
+func (o Options) Validate() error {
+	var errs []error
+	if o.Delimiter != 0 && (o.Delimiter == '"' || o.Delimiter == '\r' || o.Delimiter == '\n' ||
+		!utf8.ValidRune(o.Delimiter) || o.Delimiter == utf8.RuneError) {
+		errs = append(errs, fmt.Errorf("invalid delimiter %q", o.Delimiter))
+	}
+	if o.IntBase != 0 && o.IntBase != IntBaseAuto && (o.IntBase < 2 || o.IntBase > 36) {
+		errs = append(errs, fmt.Errorf("invalid IntBase %d", o.IntBase))
+	}
+	if o.Compression != NoCompression && o.Compression != Gzip {
+		errs = append(errs, fmt.Errorf("invalid Compression %d", o.Compression))
+	}
+	if o.StartOffset < 0 || o.MaxColumns < 0 || o.MaxRecordBytes < 0 {
+		errs = append(errs, errors.New("StartOffset, MaxColumns and MaxRecordBytes must not be negative"))
+	}
+	if o.NoHeader && o.RequireAllColumns && len(o.Header) == 0 {
+		errs = append(errs, errors.New("RequireAllColumns cannot be checked with NoHeader and no Header"))
+	}
+	if o.NoHeader && o.TrimTrailingDelimiter && len(o.Header) == 0 {
+		errs = append(errs, errors.New("TrimTrailingDelimiter needs a header, which NoHeader disables"))
+	}
+	return errors.Join(errs...)
+}
+
+// intBase returns the base to pass to strconv, where 0 means detecting it from the prefix
+func (o Options) intBase() int {
+	switch o.IntBase {
+	case 0:
+		return 10
+	case IntBaseAuto:
+		return 0
+	}
+	return o.IntBase
+}
+
+// comma returns the delimiter to use
+func (o Options) comma() rune {
+	if o.Delimiter == 0 {
+		return ','
+	}
+	return o.Delimiter
+}
+
+// Option configures Marshal, Unmarshal, Encoder and Decoder. It is implemented by Options and returned by the With functions.
+type Option interface {
+	apply(*Options)
+}
+
+func (o Options) apply(dst *Options) {
+	*dst = o
+}
+
+type optionFunc func(*Options)
+
+func (f optionFunc) apply(dst *Options) {
+	f(dst)
+}
+
+// mergeOptions applies opts to Default() in order and validates the result
+func mergeOptions(opts []Option) (Options, error) {
+	opt := Default()
+	for _, o := range opts {
+		if o != nil {
+			o.apply(&opt)
+		}
+	}
+	if err := opt.Validate(); err != nil {
+		return opt, fmt.Errorf("invalid options: %w", err)
+	}
+	return opt, nil
+}
+
+// WithDelimiter sets Options.Delimiter
+func WithDelimiter(delimiter rune) Option {
+	return optionFunc(func(o *Options) { o.Delimiter = delimiter })
+}
+
+// WithNoHeader sets Options.NoHeader
+func WithNoHeader() Option {
+	return optionFunc(func(o *Options) { o.NoHeader = true })
+}
+
+// WithCompression sets Options.Compression
+func WithCompression(compression Compression) Option {
+	return optionFunc(func(o *Options) { o.Compression = compression })
+}
+
+// WithZeroTimeAsTimestamp sets Options.ZeroTimeAsTimestamp
+func WithZeroTimeAsTimestamp() Option {
+	return optionFunc(func(o *Options) { o.ZeroTimeAsTimestamp = true })
+}
+
+// WithStableColumns sets Options.StableColumns
+func WithStableColumns() Option {
+	return optionFunc(func(o *Options) { o.StableColumns = true })
+}
+
+// WithQuoteAll sets Options.QuoteAll
+func WithQuoteAll() Option {
+	return optionFunc(func(o *Options) { o.QuoteAll = true })
+}
+
+// WithHeader sets Options.Header
+func WithHeader(names ...string) Option {
+	return optionFunc(func(o *Options) { o.Header = names })
+}
+
+// WithRequireAllColumns sets Options.RequireAllColumns
+func WithRequireAllColumns() Option {
+	return optionFunc(func(o *Options) { o.RequireAllColumns = true })
+}
+
+// WithStrictArrayLength sets Options.StrictArrayLength
+func WithStrictArrayLength() Option {
+	return optionFunc(func(o *Options) { o.StrictArrayLength = true })
+}
+
+// WithStartOffset sets Options.StartOffset
+func WithStartOffset(offset int64) Option {
+	return optionFunc(func(o *Options) { o.StartOffset = offset })
+}
+
+// WithIntBase sets Options.IntBase
+func WithIntBase(base int) Option {
+	return optionFunc(func(o *Options) { o.IntBase = base })
+}
+
+// WithSkipValidation sets Options.SkipValidation
+func WithSkipValidation() Option {
+	return optionFunc(func(o *Options) { o.SkipValidation = true })
+}
+
+// WithTrimTrailingDelimiter sets Options.TrimTrailingDelimiter
+func WithTrimTrailingDelimiter() Option {
+	return optionFunc(func(o *Options) { o.TrimTrailingDelimiter = true })
+}
+
+// WithTransform adds the transform of a column to Options.Transforms
+func WithTransform(column string, transform func(string) (string, error)) Option {
+	return optionFunc(func(o *Options) {
+		// Copy the map so that Options values shared by the caller are not modified
+		transforms := make(map[string]func(string) (string, error), len(o.Transforms)+1)
+		for k, v := range o.Transforms {
+			transforms[k] = v
+		}
+		transforms[column] = transform
+		o.Transforms = transforms
+	})
+}
+
+// WithNoTypeInference sets Options.NoTypeInference
+func WithNoTypeInference() Option {
+	return optionFunc(func(o *Options) { o.NoTypeInference = true })
+}
+
+// WithMaxColumns sets Options.MaxColumns
+func WithMaxColumns(n int) Option {
+	return optionFunc(func(o *Options) { o.MaxColumns = n })
+}
+
+// WithMaxRecordBytes sets Options.MaxRecordBytes
+func WithMaxRecordBytes(n int64) Option {
+	return optionFunc(func(o *Options) { o.MaxRecordBytes = n })
+}
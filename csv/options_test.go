@@ -0,0 +1,121 @@
+package csv
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestOptions_DelimiterAndNoHeader(t *testing.T) {
+	records := []Ticket{
+		{Name: "Alice", UserID: "U001", Ticket: 1, RecordID: "R;001", Source: "S001"},
+		{Name: "Bob", UserID: "U002", Ticket: 2, RecordID: "R002", Source: "S002"},
+	}
+
+	data, err := MarshalWithOptions(records, WithDelimiter(';'), WithNoHeader())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	expected := "Alice;U001;1;\"R;001\";S001\nBob;U002;2;R002;S002\n"
+	if string(data) != expected {
+		t.Errorf("unexpected result: got %q, want %q", string(data), expected)
+	}
+
+	// Without a header the columns follow the field order
+	var decoded []Ticket
+	if err := UnmarshalWithOptions(data, &decoded, WithDelimiter(';'), WithNoHeader()); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !reflect.DeepEqual(decoded, records) {
+		t.Errorf("unexpected result: got %+v, want %+v", decoded, records)
+	}
+
+	var simple []Simple
+	if err := UnmarshalWithOptions([]byte("Alice;x\n"), &simple, WithDelimiter(';'), WithNoHeader(), WithHeader("name", "other")); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(simple) != 1 || simple[0].Name != "Alice" {
+		t.Errorf("unexpected result: %+v", simple)
+	}
+}
+
+func TestOptions_QuoteAllWithDelimiter(t *testing.T) {
+	b := &bytes.Buffer{}
+	enc := NewEncoder(b, QuotedRecord{}, WithDelimiter('\t'), WithQuoteAll())
+	if err := enc.Encode([]QuotedRecord{{ID: 1, Description: "a\tb", Price: 2, Note: "n"}}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := enc.Flush(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if expected := "id\tdescription\tprice\tnote\n1\t\"a\tb\"\t2\t\"n\"\n"; b.String() != expected {
+		t.Errorf("unexpected result: got %q, want %q", b.String(), expected)
+	}
+}
+
+func TestOptions_Order(t *testing.T) {
+	// An Options value replaces everything before it, later functional options change single settings
+	opt, err := mergeOptions([]Option{WithQuoteAll(), Options{StableColumns: true}, WithIntBase(16)})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if opt.QuoteAll || !opt.StableColumns || opt.IntBase != 16 {
+		t.Errorf("unexpected options: %+v", opt)
+	}
+
+	opt, err = mergeOptions(nil)
+	if err != nil || !reflect.DeepEqual(opt, Default()) {
+		t.Errorf("unexpected options: %+v, %v", opt, err)
+	}
+}
+
+func TestOptions_Validate(t *testing.T) {
+	tests := []struct {
+		opt  Options
+		want string
+	}{
+		{Options{NoHeader: true, RequireAllColumns: true}, "RequireAllColumns cannot be checked with NoHeader and no Header"},
+		{Options{NoHeader: true, TrimTrailingDelimiter: true}, "TrimTrailingDelimiter needs a header"},
+		{Options{Delimiter: '"'}, "invalid delimiter"},
+		{Options{IntBase: 1}, "invalid IntBase 1"},
+		{Options{MaxColumns: -1}, "must not be negative"},
+		{Options{Compression: 7}, "invalid Compression 7"},
+	}
+	for _, tt := range tests {
+		if err := tt.opt.Validate(); err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("Validate(%+v) = %v, want error containing %q", tt.opt, err, tt.want)
+		}
+	}
+
+	if err := Default().Validate(); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+	if err := (Options{NoHeader: true, RequireAllColumns: true, Header: []string{"name"}}).Validate(); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
+
+func TestOptions_InvalidRejected(t *testing.T) {
+	var records []Simple
+	if err := UnmarshalWithOptions([]byte("name\nAlice\n"), &records, WithDelimiter('\n')); err == nil || !strings.HasPrefix(err.Error(), "invalid options: ") {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, err := MarshalWithOptions(records, WithNoHeader(), WithRequireAllColumns()); err == nil {
+		t.Fatalf("expected error for contradictory options")
+	}
+	if err := NewEncoder(&bytes.Buffer{}, Simple{}, WithIntBase(99)).WriteHeader(); err == nil {
+		t.Fatalf("expected error for invalid options")
+	}
+	if err := NewDecoder(strings.NewReader("name\n"), WithMaxColumns(-1)).Decode(&Simple{}); err == nil {
+		t.Fatalf("expected error for invalid options")
+	}
+}
+
+func TestOptions_RequireAllColumns(t *testing.T) {
+	var records []Ticket
+	err := UnmarshalWithOptions([]byte("name,user_id\nAlice,U001\n"), &records, WithRequireAllColumns())
+	if err == nil || err.Error() != "missing required columns: ticket, record_id, source" {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
@@ -0,0 +1,94 @@
+package csv
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"reflect"
+)
+
+// RowProvider yields records one at a time, such as rows from a database cursor.
+// Next returns the next struct or struct pointer and false once the provider is exhausted.
+type RowProvider interface {
+	Next() (any, bool)
+}
+
+// RowProviderFunc adapts a function to a RowProvider
+type RowProviderFunc func() (any, bool)
+
+// Next calls f
+func (f RowProviderFunc) Next() (any, bool) {
+	return f()
+}
+
+// asRowProvider reports whether v is a RowProvider or a function that can act as one
+func asRowProvider(v interface{}) (RowProvider, bool) {
+	switch p := v.(type) {
+	case RowProvider:
+		return p, true
+	case func() (any, bool):
+		return RowProviderFunc(p), true
+	}
+	return nil, false
+}
+
+// marshalRows writes the records yielded by p to w. Every record must have the type of the first one.
+// Since the records are not known in advance, the omitempty columns are always written, as with Encoder.
+func marshalRows(w io.Writer, p RowProvider, opt Options) error {
+	first, ok := p.Next()
+	if !ok {
+		return errors.New("row provider yielded no rows")
+	}
+	typ := reflect.TypeOf(first)
+	if typ == nil {
+		return errors.New("row 1 is nil")
+	}
+	elemType := typ
+	if elemType.Kind() == reflect.Ptr {
+		elemType = elemType.Elem()
+	}
+	if elemType.Kind() != reflect.Struct {
+		return fmt.Errorf("row must be a struct or a struct pointer, got %s", typ)
+	}
+	fields, err := structFields(elemType)
+	if err != nil {
+		return err
+	}
+
+	writer := newRowWriter(w, fields, opt)
+	if err := writer.WriteHeader(headerOf(fields)); err != nil {
+		return err
+	}
+	for row, v := 1, first; ; row++ {
+		rv := reflect.ValueOf(v)
+		if !rv.IsValid() || rv.Type() != typ {
+			return fmt.Errorf("row %d is %T, want %s", row, v, typ)
+		}
+		if rv.Kind() == reflect.Ptr {
+			if rv.IsNil() {
+				return fmt.Errorf("row %d is nil", row)
+			}
+			rv = rv.Elem()
+		}
+		record, err := encodeRecord(rv, fields, opt)
+		if err != nil {
+			return err
+		}
+		if err = writer.Write(record); err != nil {
+			return err
+		}
+		if row%marshalChunkSize == 0 {
+			writer.Flush()
+			if err = writer.Error(); err != nil {
+				return err
+			}
+		}
+
+		if v, ok = p.Next(); !ok {
+			break
+		}
+	}
+
+	writer.Flush()
+	return writer.Error()
+}
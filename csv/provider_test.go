@@ -0,0 +1,78 @@
+package csv
+
+import (
+	"bytes"
+	"testing"
+)
+
+// cursor mimics a database cursor yielding records one at a time
+type cursor struct {
+	rows []Simple
+	pos  int
+}
+
+func (c *cursor) Next() (any, bool) {
+	if c.pos >= len(c.rows) {
+		return nil, false
+	}
+	c.pos++
+	return &c.rows[c.pos-1], true
+}
+
+func TestMarshal_RowProvider(t *testing.T) {
+	data, err := Marshal(&cursor{rows: []Simple{{Name: "Alice"}, {Name: "Bob"}}})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if expected := "name\nAlice\nBob\n"; string(data) != expected {
+		t.Errorf("unexpected result: got %q, want %q", string(data), expected)
+	}
+}
+
+func TestMarshalTo_RowProviderFunc(t *testing.T) {
+	n := 0
+	next := func() (any, bool) {
+		if n == 3 {
+			return nil, false
+		}
+		n++
+		return RecordWithOmitempty{Name: "x", Age: n}, true
+	}
+
+	b := &bytes.Buffer{}
+	if err := MarshalTo(b, next); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	// omitempty columns cannot be dropped without seeing every record first
+	expected := `name,age,email,active,score
+x,1,,false,0
+x,2,,false,0
+x,3,,false,0
+`
+	if b.String() != expected {
+		t.Errorf("unexpected result: got %v, want %v", b.String(), expected)
+	}
+}
+
+func TestMarshal_RowProviderErrors(t *testing.T) {
+	if _, err := Marshal(RowProviderFunc(func() (any, bool) { return nil, false })); err == nil || err.Error() != "row provider yielded no rows" {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	rows := []any{Simple{Name: "Alice"}, Ticket{Name: "Bob"}}
+	i := 0
+	mixed := RowProviderFunc(func() (any, bool) {
+		if i == len(rows) {
+			return nil, false
+		}
+		i++
+		return rows[i-1], true
+	})
+	if _, err := Marshal(mixed); err == nil || err.Error() != "row 2 is csv.Ticket, want csv.Simple" {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if _, err := Marshal(RowProviderFunc(func() (any, bool) { return 1, true })); err == nil {
+		t.Fatalf("expected error for non-struct rows")
+	}
+}
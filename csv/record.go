@@ -0,0 +1,155 @@
+package csv
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+// EncodeRecord converts a struct or struct pointer into a header and a single CSV record without any framing,
+// for callers that transport the cells themselves. As with Encoder, omitempty columns are always included.
+func EncodeRecord(v any, opts ...Option) (header []string, record []string, err error) {
+	rv := reflect.ValueOf(v)
+	if rv.Kind() == reflect.Ptr {
+		if rv.IsNil() {
+			return nil, nil, errors.New("v is nil")
+		}
+		rv = rv.Elem()
+	}
+	if rv.Kind() != reflect.Struct {
+		return nil, nil, errors.New("v must be a struct or a struct pointer")
+	}
+
+	opt, err := mergeOptions(opts)
+	if err != nil {
+		return nil, nil, err
+	}
+	fields, err := structFields(rv.Type())
+	if err != nil {
+		return nil, nil, err
+	}
+	record, err = encodeRecord(rv, fields, opt)
+	if err != nil {
+		return nil, nil, err
+	}
+	return headerOf(fields), record, nil
+}
+
+// Header returns the column names that Encoder and EncodeRecord write for the record type of v,
+// which may be a struct, a struct pointer or a slice of them. The omitempty columns are always included.
+func Header(v any) ([]string, error) {
+	t, err := structType(reflect.TypeOf(v))
+	if err != nil {
+		return nil, err
+	}
+	fields, err := structFields(t)
+	if err != nil {
+		return nil, err
+	}
+	return headerOf(fields), nil
+}
+
+// DecodeRecord stores a single CSV record with the given header into v, which must be a pointer to a struct.
+// It applies the same conversions, defaults, transforms and validation as Unmarshal. Errors about a cell
+// are *RowError values with Row set to 0.
+func DecodeRecord(header, record []string, v any, opts ...Option) error {
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Ptr || rv.IsNil() || rv.Elem().Kind() != reflect.Struct {
+		return errors.New("v must be a non-nil pointer to a struct")
+	}
+	opt, err := mergeOptions(opts)
+	if err != nil {
+		return err
+	}
+	b, err := newBinding(rv.Elem().Type(), header, opt)
+	if err != nil {
+		return err
+	}
+	return b.decode(rv.Elem(), record, opt)
+}
+
+// binding maps the columns of a header to the fields of a struct type
+type binding struct {
+	typ      reflect.Type
+	header   []string
+	fieldMap map[string]fieldInfo
+	// defaults are the fields without a column that have a default value
+	defaults []fieldInfo
+	// unmatched are the column names of the fields without a column
+	unmatched []string
+}
+
+// newBinding prepares the column lookup of typ for header. Struct fields without a matching column
+// get their default value if they have one, and fail the binding if they are required.
+func newBinding(typ reflect.Type, header []string, opt Options) (*binding, error) {
+	fields, err := cachedCollectFields(typ)
+	if err != nil {
+		return nil, err
+	}
+
+	inHeader := make(map[string]bool, len(header))
+	for _, name := range header {
+		inHeader[name] = true
+	}
+	b := &binding{typ: typ, header: header, fieldMap: make(map[string]fieldInfo)}
+	var missing []string
+	for _, field := range fields {
+		b.fieldMap[field.name] = field
+		if inHeader[field.name] {
+			continue
+		}
+		b.unmatched = append(b.unmatched, field.name)
+		if field.required || opt.RequireAllColumns {
+			missing = append(missing, field.name)
+		} else if field.hasDefault {
+			b.defaults = append(b.defaults, field)
+		}
+	}
+	if len(missing) > 0 {
+		return nil, fmt.Errorf("missing required columns: %s", strings.Join(missing, ", "))
+	}
+	return b, nil
+}
+
+// decode converts record into the struct value rv. Errors are *RowError values without a row number.
+func (b *binding) decode(rv reflect.Value, record []string, opt Options) error {
+	for _, field := range b.defaults {
+		if err := decodeValue(getFieldByIndexPath(rv, field.indexPath), field.defaultValue, opt); err != nil {
+			return &RowError{Column: field.name, Err: err}
+		}
+	}
+
+	limit := len(b.header)
+	if len(record) < limit {
+		limit = len(record)
+	}
+	for i := 0; i < limit; i++ {
+		field, ok := b.fieldMap[b.header[i]]
+		if !ok {
+			continue
+		}
+		if !field.supported {
+			return &RowError{Column: field.name, Err: unsupportedTypeError(b.typ, field)}
+		}
+		value := record[i]
+		if transform, ok := opt.Transforms[b.header[i]]; ok {
+			var err error
+			if value, err = transform(value); err != nil {
+				return &RowError{Column: b.header[i], Err: err}
+			}
+		}
+		if err := decodeValue(getFieldByIndexPath(rv, field.indexPath), value, opt); err != nil {
+			return &RowError{Column: b.header[i], Err: err}
+		}
+	}
+
+	if !opt.SkipValidation && rv.CanAddr() {
+		if validator, ok := rv.Addr().Interface().(Validator); ok {
+			if err := validator.Validate(); err != nil {
+				return &RowError{Err: err}
+			}
+		}
+	}
+	return nil
+}
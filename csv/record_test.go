@@ -0,0 +1,76 @@
+package csv
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestEncodeRecord(t *testing.T) {
+	header, record, err := EncodeRecord(&RecordWithOmitempty{Name: "Alice", Age: 30})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if expected := []string{"name", "age", "email", "active", "score"}; !reflect.DeepEqual(header, expected) {
+		t.Errorf("unexpected header: got %q, want %q", header, expected)
+	}
+	if expected := []string{"Alice", "30", "", "false", "0"}; !reflect.DeepEqual(record, expected) {
+		t.Errorf("unexpected record: got %q, want %q", record, expected)
+	}
+
+	if _, _, err := EncodeRecord([]Simple{}); err == nil {
+		t.Fatalf("expected error for slice")
+	}
+}
+
+func TestDecodeRecord(t *testing.T) {
+	original := Ticket{Name: "Alice", UserID: "U001", Ticket: 7, RecordID: "R001", Source: "S001"}
+	header, record, err := EncodeRecord(original)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	var decoded Ticket
+	if err := DecodeRecord(header, record, &decoded); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if decoded != original {
+		t.Errorf("unexpected result: got %+v, want %+v", decoded, original)
+	}
+}
+
+func TestDecodeRecord_Errors(t *testing.T) {
+	var ticket Ticket
+	err := DecodeRecord([]string{"name", "ticket"}, []string{"Alice", "x"}, &ticket)
+	var rowErr *RowError
+	if !errors.As(err, &rowErr) || rowErr.Row != 0 || rowErr.Column != "ticket" {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	var validated ValidatedRecord
+	if err := DecodeRecord([]string{"name", "age"}, []string{"Bob", "-1"}, &validated); err == nil {
+		t.Fatalf("expected validation error")
+	}
+
+	var optional OptionalColumns
+	if err := DecodeRecord([]string{"note"}, []string{"n"}, &optional); err == nil || err.Error() != "missing required columns: name" {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if err := DecodeRecord(nil, nil, ticket); err == nil {
+		t.Fatalf("expected error for non-pointer target")
+	}
+}
+
+func TestHeader(t *testing.T) {
+	header, err := Header([]*RecordWithOmitempty{})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if expected := []string{"name", "age", "email", "active", "score"}; !reflect.DeepEqual(header, expected) {
+		t.Errorf("unexpected header: got %q, want %q", header, expected)
+	}
+	if _, err := Header("x"); err == nil {
+		t.Fatalf("expected error for non-struct")
+	}
+}
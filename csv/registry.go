@@ -0,0 +1,60 @@
+package csv
+
+import (
+	"reflect"
+	"sync"
+	"sync/atomic"
+)
+
+var (
+	// fieldNamesMu serializes writers, readers load fieldNames without locking
+	fieldNamesMu sync.Mutex
+	// fieldNames maps a struct type to overrides of Go field name -> column name.
+	// The map is copied on every registration and never modified once published.
+	fieldNames atomic.Pointer[map[reflect.Type]map[string]string]
+)
+
+// RegisterFieldNames sets the column names of a struct type whose fields carry no csv tags, such as
+// types from generated packages. names maps Go field names to column names and is consulted only for
+// fields without a csv tag. Registering the same type again replaces the previous names, a nil map
+// removes the registration. It is safe for concurrent use.
+func RegisterFieldNames(sample any, names map[string]string) {
+	t := reflect.TypeOf(sample)
+	for t != nil && t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if t == nil || t.Kind() != reflect.Struct {
+		panic("csv: RegisterFieldNames requires a struct or struct pointer sample")
+	}
+
+	fieldNamesMu.Lock()
+	defer fieldNamesMu.Unlock()
+	updated := map[reflect.Type]map[string]string{}
+	if current := fieldNames.Load(); current != nil {
+		for k, v := range *current {
+			updated[k] = v
+		}
+	}
+	if names == nil {
+		delete(updated, t)
+	} else {
+		copied := make(map[string]string, len(names))
+		for k, v := range names {
+			copied[k] = v
+		}
+		updated[t] = copied
+	}
+	fieldNames.Store(&updated)
+	// Registered names may be used by any type embedding t, so drop all cached metadata
+	invalidateFieldCache()
+}
+
+// registeredFieldName returns the registered column name of a field of t
+func registeredFieldName(t reflect.Type, field string) (string, bool) {
+	current := fieldNames.Load()
+	if current == nil {
+		return "", false
+	}
+	name, ok := (*current)[t][field]
+	return name, ok
+}
@@ -0,0 +1,115 @@
+package csv
+
+import (
+	"reflect"
+	"sync"
+	"testing"
+)
+
+// GeneratedUser mimics a struct from a generated package without csv tags
+type GeneratedUser struct {
+	UserID      string
+	DisplayName string
+	Age         int `csv:"age"`
+}
+
+type GeneratedWrapper struct {
+	GeneratedUser
+	Note string
+}
+
+func TestRegisterFieldNames(t *testing.T) {
+	RegisterFieldNames(GeneratedUser{}, map[string]string{"UserID": "user_id", "DisplayName": "name", "Age": "ignored"})
+	defer RegisterFieldNames(GeneratedUser{}, nil)
+
+	users := []GeneratedUser{{UserID: "U001", DisplayName: "Alice", Age: 30}}
+	data, err := Marshal(users)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	// Tagged fields keep their tag name
+	expected := `user_id,name,age
+U001,Alice,30
+`
+	if string(data) != expected {
+		t.Errorf("unexpected result: got %v, want %v", string(data), expected)
+	}
+
+	var decoded []GeneratedUser
+	if err := Unmarshal(data, &decoded); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !reflect.DeepEqual(decoded, users) {
+		t.Errorf("unexpected result: got %+v, want %+v", decoded, users)
+	}
+}
+
+func TestRegisterFieldNames_Embedded(t *testing.T) {
+	RegisterFieldNames(&GeneratedUser{}, map[string]string{"UserID": "user_id"})
+	defer RegisterFieldNames(GeneratedUser{}, nil)
+
+	data, err := Marshal(GeneratedWrapper{GeneratedUser: GeneratedUser{UserID: "U001"}, Note: "n"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	expected := `user_id,DisplayName,age,Note
+U001,,0,n
+`
+	if string(data) != expected {
+		t.Errorf("unexpected result: got %v, want %v", string(data), expected)
+	}
+}
+
+func TestRegisterFieldNames_Override(t *testing.T) {
+	RegisterFieldNames(GeneratedUser{}, map[string]string{"UserID": "first"})
+	RegisterFieldNames(GeneratedUser{}, map[string]string{"UserID": "second"})
+
+	data, err := Marshal(GeneratedUser{})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if expected := "second,DisplayName,age\n,,0\n"; string(data) != expected {
+		t.Errorf("unexpected result: got %q, want %q", string(data), expected)
+	}
+
+	// A nil map restores the default Go field names
+	RegisterFieldNames(GeneratedUser{}, nil)
+	data, err = Marshal(GeneratedUser{})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if expected := "UserID,DisplayName,age\n,,0\n"; string(data) != expected {
+		t.Errorf("unexpected result: got %q, want %q", string(data), expected)
+	}
+}
+
+func TestRegisterFieldNames_Concurrent(t *testing.T) {
+	defer RegisterFieldNames(GeneratedUser{}, nil)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 16; i++ {
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			RegisterFieldNames(GeneratedUser{}, map[string]string{"UserID": "user_id"})
+		}()
+		go func() {
+			defer wg.Done()
+			if _, err := Marshal(GeneratedUser{}); err != nil {
+				t.Error(err)
+			}
+		}()
+	}
+	wg.Wait()
+}
+
+func TestRegisterFieldNames_NonStruct(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Fatalf("expected panic for non-struct sample")
+		}
+	}()
+	RegisterFieldNames(1, map[string]string{})
+}
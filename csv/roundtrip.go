@@ -0,0 +1,99 @@
+package csv
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"reflect"
+	"strconv"
+	"time"
+)
+
+// VerifyRoundTrip marshals v, a slice of structs or struct pointers, unmarshals the result into a fresh slice
+// and compares both field by field. It returns an error describing the first row and field that differ,
+// so Unmarshal(Marshal(v)) == v can be asserted for any type in tests.
+func VerifyRoundTrip(v any, opts ...Option) error {
+	rv := reflect.ValueOf(v)
+	if rv.Kind() == reflect.Ptr && !rv.IsNil() {
+		rv = rv.Elem()
+	}
+	if rv.Kind() != reflect.Slice {
+		return errors.New("v must be a slice of struct")
+	}
+	t, err := structType(rv.Type())
+	if err != nil {
+		return err
+	}
+	fields, err := structFields(t)
+	if err != nil {
+		return err
+	}
+
+	data, err := MarshalWithOptions(rv.Interface(), opts...)
+	if err != nil {
+		return fmt.Errorf("marshal: %w", err)
+	}
+	decoded := reflect.New(rv.Type())
+	if err = UnmarshalWithOptions(data, decoded.Interface(), opts...); err != nil {
+		return fmt.Errorf("unmarshal: %w", err)
+	}
+	if decoded.Elem().Len() != rv.Len() {
+		return fmt.Errorf("got %d rows, want %d", decoded.Elem().Len(), rv.Len())
+	}
+
+	for i := 0; i < rv.Len(); i++ {
+		want, got := reflect.Indirect(rv.Index(i)), reflect.Indirect(decoded.Elem().Index(i))
+		for _, field := range fields {
+			wantField := fieldOrZero(want, field, t)
+			gotField := fieldOrZero(got, field, t)
+			if !equalCells(gotField, wantField) {
+				return fmt.Errorf("row %d, field %s (column %q): got %v, want %v",
+					i+1, field.goPath, field.name, formatCell(gotField), formatCell(wantField))
+			}
+		}
+	}
+	return nil
+}
+
+// fieldOrZero returns the field of the struct value rv, or its zero value if it lives in a nil embedded pointer
+func fieldOrZero(rv reflect.Value, field fieldInfo, t reflect.Type) reflect.Value {
+	if value, ok := lookupFieldByIndexPath(rv, field.indexPath); ok {
+		return value
+	}
+	return reflect.Zero(t.FieldByIndex(field.indexPath).Type)
+}
+
+// equalCells compares two field values the way they round trip through a CSV cell
+func equalCells(a, b reflect.Value) bool {
+	if a.Kind() == reflect.Ptr {
+		if a.IsNil() || b.IsNil() {
+			return a.IsNil() == b.IsNil()
+		}
+		return equalCells(a.Elem(), b.Elem())
+	}
+	switch a.Kind() {
+	case reflect.Float32, reflect.Float64:
+		if math.IsNaN(a.Float()) && math.IsNaN(b.Float()) {
+			return true
+		}
+	case reflect.Struct:
+		if at, ok := a.Interface().(time.Time); ok {
+			return at.Equal(b.Interface().(time.Time))
+		}
+	}
+	return reflect.DeepEqual(a.Interface(), b.Interface())
+}
+
+// formatCell formats a field value for error messages, showing the value behind a pointer
+func formatCell(v reflect.Value) string {
+	if v.Kind() == reflect.Ptr {
+		if v.IsNil() {
+			return "nil"
+		}
+		return "&" + formatCell(v.Elem())
+	}
+	if v.Kind() == reflect.String {
+		return strconv.Quote(v.String())
+	}
+	return fmt.Sprintf("%v", v.Interface())
+}
@@ -0,0 +1,55 @@
+package csv
+
+import (
+	"math"
+	"testing"
+	"time"
+)
+
+type RoundTripRecord struct {
+	*FuzzMeta
+	Name    string     `csv:"name"`
+	Score   float32    `csv:"score"`
+	Ratio   float64    `csv:"ratio"`
+	At      time.Time  `csv:"at"`
+	Updated *time.Time `csv:"updated,omitempty"`
+	Home    Address    `csv:",prefix=home_"`
+	Secret  string     `csv:"-"`
+}
+
+func TestVerifyRoundTrip(t *testing.T) {
+	now := time.Now()
+	records := []RoundTripRecord{
+		{Name: "Alice", Score: 0.1, Ratio: math.NaN(), At: now, Updated: &now, Home: Address{City: "x"}},
+		{FuzzMeta: &FuzzMeta{Source: "S001"}, Name: "Bob, \"Jr\"\nII", At: now.UTC()},
+	}
+	if err := VerifyRoundTrip(records); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := VerifyRoundTrip(&[]*Ticket{{Name: "Alice", Ticket: 1}}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
+
+type LossyRecord struct {
+	Name    string `csv:"name"`
+	Retries int    `csv:"retries,omitempty,default=3"`
+}
+
+func TestVerifyRoundTrip_Mismatch(t *testing.T) {
+	// The omitted column is decoded with its default value
+	err := VerifyRoundTrip([]LossyRecord{{Name: "Alice"}})
+	if err == nil || err.Error() != `row 1, field Retries (column "retries"): got 3, want 0` {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	opt := Default()
+	opt.StableColumns = true
+	if err := VerifyRoundTrip([]LossyRecord{{Name: "Alice"}}, opt); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if err := VerifyRoundTrip(Ticket{}); err == nil {
+		t.Fatalf("expected error for non-slice")
+	}
+}
@@ -0,0 +1,147 @@
+package csv
+
+import (
+	"bytes"
+	"encoding/csv"
+	"strings"
+	"unicode/utf8"
+
+	"golang.org/x/text/width"
+)
+
+// ellipsis marks a truncated cell in FormatTable
+const ellipsis = "…"
+
+// ColumnWidths parses CSV data and returns the display width of the widest cell in each column,
+// header included. Wide characters such as CJK count as two columns.
+func ColumnWidths(data []byte) ([]int, error) {
+	records, err := readTable(data)
+	if err != nil {
+		return nil, err
+	}
+	return columnWidths(records, 0), nil
+}
+
+// FormatTable parses CSV data and renders it as an aligned ASCII table for logs and debugging.
+// Cells wider than maxWidth display columns are truncated with "…", maxWidth <= 0 disables truncation.
+func FormatTable(data []byte, maxWidth int) (string, error) {
+	records, err := readTable(data)
+	if err != nil {
+		return "", err
+	}
+	if len(records) == 0 {
+		return "", nil
+	}
+
+	widths := columnWidths(records, maxWidth)
+	sb := &strings.Builder{}
+	writeTableBorder(sb, widths)
+	for i, record := range records {
+		sb.WriteString("|")
+		for j, w := range widths {
+			var cell string
+			if j < len(record) {
+				cell = truncateCell(record[j], maxWidth)
+			}
+			sb.WriteString(" ")
+			sb.WriteString(cell)
+			sb.WriteString(strings.Repeat(" ", w-displayWidth(cell)))
+			sb.WriteString(" |")
+		}
+		sb.WriteString("\n")
+		// Separate the header from the data rows
+		if i == 0 {
+			writeTableBorder(sb, widths)
+		}
+	}
+	if len(records) > 1 {
+		writeTableBorder(sb, widths)
+	}
+	return sb.String(), nil
+}
+
+// FormatStructsTable encodes v like Marshal and renders the result with FormatTable without truncation
+func FormatStructsTable(v interface{}) (string, error) {
+	data, err := Marshal(v)
+	if err != nil {
+		return "", err
+	}
+	return FormatTable(data, 0)
+}
+
+// readTable reads all records, allowing rows of different lengths
+func readTable(data []byte) ([][]string, error) {
+	reader := csv.NewReader(bytes.NewReader(data))
+	reader.FieldsPerRecord = -1
+	return reader.ReadAll()
+}
+
+// columnWidths computes the display width of each column, capped at maxWidth when it is positive
+func columnWidths(records [][]string, maxWidth int) []int {
+	var widths []int
+	for _, record := range records {
+		for i, cell := range record {
+			w := displayWidth(truncateCell(cell, maxWidth))
+			if i >= len(widths) {
+				widths = append(widths, w)
+			} else if w > widths[i] {
+				widths[i] = w
+			}
+		}
+	}
+	return widths
+}
+
+func writeTableBorder(sb *strings.Builder, widths []int) {
+	sb.WriteString("+")
+	for _, w := range widths {
+		sb.WriteString(strings.Repeat("-", w+2))
+		sb.WriteString("+")
+	}
+	sb.WriteString("\n")
+}
+
+// truncateCell shortens s to at most maxWidth display columns, ending with an ellipsis when cut.
+// Line breaks are shown as spaces so that every record stays on one line.
+func truncateCell(s string, maxWidth int) string {
+	s = strings.NewReplacer("\r\n", " ", "\n", " ", "\r", " ", "\t", " ").Replace(s)
+	if maxWidth <= 0 || displayWidth(s) <= maxWidth {
+		return s
+	}
+
+	limit := maxWidth - displayWidth(ellipsis)
+	sb := &strings.Builder{}
+	w := 0
+	for _, r := range s {
+		rw := runeWidth(r)
+		if w+rw > limit {
+			break
+		}
+		sb.WriteRune(r)
+		w += rw
+	}
+	sb.WriteString(ellipsis)
+	return sb.String()
+}
+
+// displayWidth returns the number of terminal columns s occupies
+func displayWidth(s string) int {
+	w := 0
+	for _, r := range s {
+		w += runeWidth(r)
+	}
+	return w
+}
+
+// runeWidth returns 2 for East Asian wide and fullwidth runes and 1 otherwise
+func runeWidth(r rune) int {
+	if r == utf8.RuneError {
+		return 1
+	}
+	switch width.LookupRune(r).Kind() {
+	case width.EastAsianWide, width.EastAsianFullwidth:
+		return 2
+	default:
+		return 1
+	}
+}
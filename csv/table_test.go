@@ -0,0 +1,84 @@
+package csv
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestColumnWidths(t *testing.T) {
+	widths, err := ColumnWidths([]byte("name,city\nAlice,北京\nBob,NYC\n"))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if expected := []int{5, 4}; !reflect.DeepEqual(widths, expected) {
+		t.Errorf("unexpected widths: got %v, want %v", widths, expected)
+	}
+}
+
+func TestFormatTable(t *testing.T) {
+	table, err := FormatTable([]byte("name,city\nAlice,北京\nBob,New York\n"), 0)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	expected := `+-------+----------+
+| name  | city     |
++-------+----------+
+| Alice | 北京     |
+| Bob   | New York |
++-------+----------+
+`
+	if table != expected {
+		t.Errorf("unexpected result:\ngot:\n%v\nwant:\n%v", table, expected)
+	}
+}
+
+func TestFormatTable_Truncate(t *testing.T) {
+	table, err := FormatTable([]byte("name,note\nAlice,\"a very long\nnote\"\nBob,中文说明很长\n"), 6)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	expected := `+-------+--------+
+| name  | note   |
++-------+--------+
+| Alice | a ver… |
+| Bob   | 中文…  |
++-------+--------+
+`
+	if table != expected {
+		t.Errorf("unexpected result:\ngot:\n%v\nwant:\n%v", table, expected)
+	}
+}
+
+func TestFormatTable_HeaderOnlyAndEmpty(t *testing.T) {
+	table, err := FormatTable([]byte("name\n"), 0)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if expected := "+------+\n| name |\n+------+\n"; table != expected {
+		t.Errorf("unexpected result:\ngot:\n%v\nwant:\n%v", table, expected)
+	}
+
+	table, err = FormatTable(nil, 0)
+	if err != nil || table != "" {
+		t.Errorf("unexpected result %q, error %v", table, err)
+	}
+}
+
+func TestFormatStructsTable(t *testing.T) {
+	table, err := FormatStructsTable([]ExtendedRecord{{BaseRecord: BaseRecord{ID: 1, Name: "Alice"}, Extra: "E1"}})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	expected := `+----+-------+-------+
+| id | name  | extra |
++----+-------+-------+
+| 1  | Alice | E1    |
++----+-------+-------+
+`
+	if table != expected {
+		t.Errorf("unexpected result:\ngot:\n%v\nwant:\n%v", table, expected)
+	}
+}
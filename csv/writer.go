@@ -0,0 +1,136 @@
+package csv
+
+import (
+	"bufio"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// quoteMode controls when a column's cells are quoted
+type quoteMode int
+
+const (
+	// quoteAuto quotes a cell only when it contains special characters, as csv.Writer does
+	quoteAuto quoteMode = iota
+	// quoteAlways quotes every cell, set with the quote tag option
+	quoteAlways
+	// quoteNever never quotes a cell, set with the noquote tag option
+	quoteNever
+)
+
+// rowWriter writes CSV records like csv.Writer, but serializes the rows itself when some columns
+// have their own quoting or Options.QuoteAll is set, which csv.Writer cannot express
+type rowWriter struct {
+	w *csv.Writer
+	// bw and quotes are set when the rows are serialized by rowWriter itself
+	bw       *bufio.Writer
+	quotes   []quoteMode
+	comma    rune
+	noHeader bool
+	err      error
+}
+
+// newRowWriter returns a rowWriter for records of the given fields
+func newRowWriter(w io.Writer, fields []fieldInfo, opt Options) *rowWriter {
+	custom := opt.QuoteAll
+	quotes := make([]quoteMode, len(fields))
+	for i, field := range fields {
+		quotes[i] = field.quote
+		if quotes[i] == quoteAuto && opt.QuoteAll {
+			quotes[i] = quoteAlways
+		}
+		custom = custom || quotes[i] != quoteAuto
+	}
+	if !custom {
+		cw := csv.NewWriter(w)
+		cw.Comma = opt.comma()
+		return &rowWriter{w: cw, noHeader: opt.NoHeader}
+	}
+	return &rowWriter{bw: bufio.NewWriter(w), quotes: quotes, comma: opt.comma(), noHeader: opt.NoHeader}
+}
+
+// WriteHeader writes the header line, whose names are only quoted when needed.
+// It writes nothing with Options.NoHeader.
+func (r *rowWriter) WriteHeader(header []string) error {
+	if r.noHeader {
+		return nil
+	}
+	if r.bw == nil {
+		return r.w.Write(header)
+	}
+	return r.write(header, nil)
+}
+
+// Write writes a record with the quoting of its columns
+func (r *rowWriter) Write(record []string) error {
+	if r.bw == nil {
+		return r.w.Write(record)
+	}
+	return r.write(record, r.quotes)
+}
+
+func (r *rowWriter) write(record []string, quotes []quoteMode) error {
+	if r.err != nil {
+		return r.err
+	}
+	for i, cell := range record {
+		if i > 0 {
+			r.bw.WriteRune(r.comma)
+		}
+		mode := quoteAuto
+		if i < len(quotes) {
+			mode = quotes[i]
+		}
+		quoted := needsQuotes(cell, r.comma)
+		switch mode {
+		case quoteAlways:
+			quoted = true
+		case quoteNever:
+			if quoted {
+				return fmt.Errorf("value %q of a noquote column needs quoting", cell)
+			}
+		}
+		if !quoted {
+			r.bw.WriteString(cell)
+			continue
+		}
+		r.bw.WriteByte('"')
+		r.bw.WriteString(strings.ReplaceAll(cell, `"`, `""`))
+		r.bw.WriteByte('"')
+	}
+	_, r.err = r.bw.WriteString("\n")
+	return r.err
+}
+
+// Flush writes any buffered data to the underlying writer
+func (r *rowWriter) Flush() {
+	if r.bw == nil {
+		r.w.Flush()
+	} else if r.err == nil {
+		r.err = r.bw.Flush()
+	}
+}
+
+// Error reports any error that has occurred during a previous write or flush
+func (r *rowWriter) Error() error {
+	if r.bw == nil {
+		return r.w.Error()
+	}
+	return r.err
+}
+
+// needsQuotes reports whether a cell must be quoted, following the rules of csv.Writer
+func needsQuotes(cell string, comma rune) bool {
+	if cell == "" {
+		return false
+	}
+	if cell == `\.` || strings.ContainsRune(cell, comma) || strings.ContainsAny(cell, "\"\r\n") {
+		return true
+	}
+	r, _ := utf8.DecodeRuneInString(cell)
+	return unicode.IsSpace(r)
+}
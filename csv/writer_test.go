@@ -0,0 +1,99 @@
+package csv
+
+import (
+	"bufio"
+	"bytes"
+	stdcsv "encoding/csv"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+type QuotedRecord struct {
+	ID          int     `csv:"id,noquote"`
+	Description string  `csv:"description,quote"`
+	Price       float64 `csv:"price,noquote"`
+	Note        string  `csv:"note"`
+}
+
+func TestMarshal_QuoteTags(t *testing.T) {
+	records := []QuotedRecord{
+		{ID: 1, Description: "plain", Price: 9.5, Note: "a,b"},
+		{ID: 2, Description: `say "hi"`, Price: 10, Note: ""},
+	}
+	data, err := Marshal(records)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	expected := `id,description,price,note
+1,"plain",9.5,"a,b"
+2,"say ""hi""",10,
+`
+	if string(data) != expected {
+		t.Errorf("unexpected result: got %v, want %v", string(data), expected)
+	}
+
+	var decoded []QuotedRecord
+	if err := Unmarshal(data, &decoded); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !reflect.DeepEqual(decoded, records) {
+		t.Errorf("unexpected result: got %+v, want %+v", decoded, records)
+	}
+}
+
+func TestMarshal_QuoteAll(t *testing.T) {
+	opt := Default()
+	opt.QuoteAll = true
+
+	data, err := MarshalWithOptions([]QuotedRecord{{ID: 1, Description: "d", Price: 2, Note: "n"}}, opt)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	// The noquote tag wins over QuoteAll, the header is never force quoted
+	if expected := "id,description,price,note\n1,\"d\",2,\"n\"\n"; string(data) != expected {
+		t.Errorf("unexpected result: got %q, want %q", string(data), expected)
+	}
+
+	data, err = MarshalWithOptions([]Simple{{Name: "Alice"}, {Name: ""}}, opt)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if expected := "name\n\"Alice\"\n\"\"\n"; string(data) != expected {
+		t.Errorf("unexpected result: got %q, want %q", string(data), expected)
+	}
+}
+
+func TestMarshal_NoquoteNeedsQuoting(t *testing.T) {
+	type badNoquote struct {
+		Name string `csv:"name,noquote"`
+	}
+	if _, err := Marshal([]badNoquote{{Name: "a,b"}}); err == nil {
+		t.Fatalf("expected error for noquote value containing a delimiter")
+	}
+}
+
+func TestRowWriter_MatchesCSVWriter(t *testing.T) {
+	cells := []string{"", "plain", " leading", "a,b", "line\nbreak", "cr\rx", `q"uote`, `\.`, "tab\t", "é"}
+
+	expected := &bytes.Buffer{}
+	w := stdcsv.NewWriter(expected)
+	if err := w.Write(cells); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	w.Flush()
+
+	got := &bytes.Buffer{}
+	r := &rowWriter{bw: bufio.NewWriter(got), comma: ','}
+	if err := r.Write(cells); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	r.Flush()
+
+	if got.String() != expected.String() {
+		t.Errorf("unexpected result: got %q, want %q", got.String(), expected.String())
+	}
+	if !strings.HasSuffix(got.String(), "\n") {
+		t.Errorf("missing line terminator: %q", got.String())
+	}
+}
@@ -0,0 +1,123 @@
+package fs
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"strings"
+	"unicode/utf8"
+
+	"golang.org/x/text/encoding"
+	"golang.org/x/text/encoding/htmlindex"
+	"golang.org/x/text/encoding/ianaindex"
+	"golang.org/x/text/encoding/unicode"
+	"golang.org/x/text/transform"
+)
+
+// WithCharset 读取时先将文件内容从字符集 name（如 gbk、gb18030、latin1、shift_jis）转换为 UTF-8 再解码，
+// 写入时将序列化后的 UTF-8 内容转换为该字符集，适合与使用旧编码的系统交换文件
+func WithCharset(name string) Option {
+	return func(o *options) {
+		o.charset = name
+	}
+}
+
+// WithCharsetDetect 读取时自动判断字符集：有 BOM 时按 BOM（UTF-8、UTF-16）转换，内容是合法的 UTF-8 时不转换，
+// 否则按 fallback 字符集转换。只影响读取，写入仍按 WithCharset
+func WithCharsetDetect(fallback string) Option {
+	return func(o *options) {
+		o.charsetDetect = true
+		o.charsetFallback = fallback
+	}
+}
+
+// lookupCharset 按 IANA 或 WHATWG 的名称查找字符集，UTF-8 返回 nil 表示无需转换
+func lookupCharset(name string) (encoding.Encoding, error) {
+	switch strings.ToLower(strings.ReplaceAll(name, "_", "-")) {
+	case "", "utf-8", "utf8":
+		return nil, nil
+	}
+	if enc, err := ianaindex.IANA.Encoding(name); err == nil && enc != nil {
+		return enc, nil
+	}
+	enc, err := htmlindex.Get(name)
+	if err != nil {
+		return nil, fmt.Errorf("unknown charset %q", name)
+	}
+	return enc, nil
+}
+
+// readEncoding 返回读取内容 head 时应使用的字符集，head 为文件开头的部分或全部内容，nil 表示无需转换
+func (o *options) readEncoding(head []byte) (encoding.Encoding, error) {
+	if !o.charsetDetect {
+		return lookupCharset(o.charset)
+	}
+	switch {
+	case bytes.HasPrefix(head, []byte{0xEF, 0xBB, 0xBF}):
+		return unicode.UTF8BOM, nil
+	case bytes.HasPrefix(head, []byte{0xFF, 0xFE}):
+		return unicode.UTF16(unicode.LittleEndian, unicode.ExpectBOM), nil
+	case bytes.HasPrefix(head, []byte{0xFE, 0xFF}):
+		return unicode.UTF16(unicode.BigEndian, unicode.ExpectBOM), nil
+	case utf8.Valid(trimPartialRune(head)):
+		return nil, nil
+	}
+	return lookupCharset(o.charsetFallback)
+}
+
+// trimPartialRune 去掉 data 末尾被截断的不完整字符
+func trimPartialRune(data []byte) []byte {
+	for i := len(data) - 1; i >= 0 && i >= len(data)-utf8.UTFMax; i-- {
+		if utf8.RuneStart(data[i]) {
+			if !utf8.FullRune(data[i:]) {
+				return data[:i]
+			}
+			break
+		}
+	}
+	return data
+}
+
+// decodeCharset 按 WithCharset 或 WithCharsetDetect 将 data 转换为 UTF-8
+func (o *options) decodeCharset(data []byte) ([]byte, error) {
+	enc, err := o.readEncoding(data)
+	if err != nil || enc == nil {
+		return data, err
+	}
+	out, err := enc.NewDecoder().Bytes(data)
+	if err != nil {
+		return nil, fmt.Errorf("decode charset: %w", err)
+	}
+	return out, nil
+}
+
+// charsetReader 与 decodeCharset 相同，但以流的方式转换 r，自动判断时只检查开头的 4KB
+func (o *options) charsetReader(r io.Reader) (io.Reader, error) {
+	if o.charset == "" && !o.charsetDetect {
+		return r, nil
+	}
+	br := bufio.NewReaderSize(r, 4096)
+	head, err := br.Peek(4096)
+	if err != nil && err != io.EOF && err != bufio.ErrBufferFull {
+		return nil, err
+	}
+	enc, err := o.readEncoding(head)
+	if err != nil || enc == nil {
+		return br, err
+	}
+	return transform.NewReader(br, enc.NewDecoder()), nil
+}
+
+// encodeCharset 按 WithCharset 将 UTF-8 的 data 转换为目标字符集，无法表示的字符返回错误
+func (o *options) encodeCharset(data []byte) ([]byte, error) {
+	enc, err := lookupCharset(o.charset)
+	if err != nil || enc == nil {
+		return data, err
+	}
+	out, err := enc.NewEncoder().Bytes(data)
+	if err != nil {
+		return nil, fmt.Errorf("encode charset %s: %w", o.charset, err)
+	}
+	return out, nil
+}
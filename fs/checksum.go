@@ -0,0 +1,109 @@
+package fs
+
+import (
+	"bytes"
+	"crypto"
+	_ "crypto/md5"
+	_ "crypto/sha1"
+	_ "crypto/sha256"
+	_ "crypto/sha512"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// WithChecksum 写入成功后在同一目录下生成校验文件 文件名.算法，如 report.json.sha256
+// 内容为十六进制摘要、两个空格和文件名，与 sha256sum 等工具兼容，可用 sha256sum -c 校验
+func WithChecksum(algo crypto.Hash) Option {
+	return func(o *options) {
+		o.checksum = algo
+	}
+}
+
+// WithRequireChecksum 使 ReadFileVerified 在校验文件不存在时返回 ErrChecksumMissing，默认跳过校验
+func WithRequireChecksum() Option {
+	return func(o *options) {
+		o.requireChecksum = true
+	}
+}
+
+// checksumExt 返回 algo 对应的校验文件后缀名，如 crypto.SHA256 对应 .sha256
+func checksumExt(algo crypto.Hash) string {
+	return "." + strings.ToLower(strings.ReplaceAll(algo.String(), "-", ""))
+}
+
+// checksumOf 计算 r 的十六进制摘要
+func checksumOf(algo crypto.Hash, r io.Reader) (string, error) {
+	if !algo.Available() {
+		return "", fmt.Errorf("checksum algorithm %s is not available", algo)
+	}
+	h := algo.New()
+	if _, err := io.Copy(h, r); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// writeChecksum 计算 filename 的摘要并以原子方式写入校验文件
+func (o *options) writeChecksum(filename string) error {
+	f, err := os.Open(filename)
+	if err != nil {
+		return fmt.Errorf("checksum file: %w", err)
+	}
+	sum, err := checksumOf(o.checksum, f)
+	f.Close()
+	if err != nil {
+		return fmt.Errorf("checksum file: %w", err)
+	}
+
+	line := sum + "  " + filepath.Base(filename) + "\n"
+	if err = o.writeAtomic(filename+checksumExt(o.checksum), []byte(line)); err != nil {
+		return fmt.Errorf("write checksum file: %w", err)
+	}
+	return nil
+}
+
+// ReadFileVerified 与 ReadFile 相同，但先用同一目录下的校验文件（默认 文件名.sha256，算法可通过 WithChecksum 指定）校验文件内容
+// 摘要不一致时不解码，返回 ErrChecksumMismatch；校验文件不存在时默认直接读取，配合 WithRequireChecksum 则返回 ErrChecksumMissing
+func ReadFileVerified(path string, out any, opts ...Option) error {
+	o := newOptions(opts)
+	algo := o.checksum
+	if algo == 0 {
+		algo = crypto.SHA256
+	}
+
+	filename, err := GetLatestFileByName(path, o.listOpts...)
+	if err != nil {
+		return err
+	}
+	data, err := o.readAll(osFS{}, filename)
+	if err != nil {
+		return err
+	}
+
+	sidecar := filename + checksumExt(algo)
+	want, err := os.ReadFile(sidecar)
+	switch {
+	case err == nil:
+		got, err := checksumOf(algo, bytes.NewReader(data))
+		if err != nil {
+			return err
+		}
+		fields := strings.Fields(string(want))
+		if len(fields) == 0 || !strings.EqualFold(fields[0], got) {
+			return fmt.Errorf("%s: %w", filename, ErrChecksumMismatch)
+		}
+	case errors.Is(err, os.ErrNotExist):
+		if o.requireChecksum {
+			return fmt.Errorf("%s: %w", sidecar, ErrChecksumMissing)
+		}
+	default:
+		return fmt.Errorf("read checksum file: %w", err)
+	}
+
+	return o.decodeBytes(filename, data, out)
+}
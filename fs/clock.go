@@ -0,0 +1,26 @@
+package fs
+
+import (
+	"sync/atomic"
+	"time"
+)
+
+// clock 保存 SetClock 设置的时间函数
+var clock atomic.Pointer[func() time.Time]
+
+// SetClock 设置 TimestampFileName 等函数获取当前时间的方式，便于测试中固定文件名；传入 nil 恢复为 time.Now
+func SetClock(now func() time.Time) {
+	if now == nil {
+		clock.Store(nil)
+		return
+	}
+	clock.Store(&now)
+}
+
+// now 返回 SetClock 设置的当前时间
+func now() time.Time {
+	if f := clock.Load(); f != nil {
+		return (*f)()
+	}
+	return time.Now()
+}
@@ -0,0 +1,52 @@
+package fs
+
+import (
+	"context"
+	"io"
+	iofs "io/fs"
+	"time"
+)
+
+// withContext 设置读写使用的 context，供 ReadFileCtx 等函数使用
+func withContext(ctx context.Context) Option {
+	return func(o *options) {
+		o.ctx = ctx
+	}
+}
+
+// ctxReader 在每次读取前检查 ctx，使大文件或慢速文件系统上的读取可以在块之间取消
+type ctxReader struct {
+	ctx context.Context
+	r   io.Reader
+}
+
+func (c *ctxReader) Read(b []byte) (int, error) {
+	if err := c.ctx.Err(); err != nil {
+		return 0, err
+	}
+	return c.r.Read(b)
+}
+
+// fileReader 按 o 的设置包装打开的文件 f，报告读取进度并在 ctx 结束后停止读取
+func (o *options) fileReader(f iofs.File) io.Reader {
+	var r io.Reader = f
+	if o.progress != nil {
+		r = o.progressReader(f, statSize(f))
+	}
+	if o.ctx.Done() != nil {
+		r = &ctxReader{ctx: o.ctx, r: r}
+	}
+	return r
+}
+
+// sleepCtx 等待 d 或 ctx 结束，ctx 先结束时返回 ctx.Err()
+func sleepCtx(ctx context.Context, d time.Duration) error {
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-t.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
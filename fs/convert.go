@@ -0,0 +1,185 @@
+package fs
+
+import (
+	"bytes"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"maps"
+	"reflect"
+	"slices"
+	"strconv"
+)
+
+// ConvertFile 读取与 src 匹配的最新文件并按 dst 的后缀名写入，例如将 YAML 转为 JSON、将 CSV 转为 NDJSON
+// 表格格式（CSV、NDJSON）解码为对象列表，文档格式（JSON、YAML、TOML）解码为 any；dst 的 .gz 和时间戳的处理同 WriteFile
+// 数据结构无法用目标格式表示时（例如嵌套的对象写入 CSV、列表写入 TOML）返回说明原因的错误；XML 没有通用的结构，不支持转换
+func ConvertFile(src, dst string, opts ...Option) error {
+	_, err := ConvertFilePath(src, dst, opts...)
+	return err
+}
+
+// ConvertFilePath 与 ConvertFile 相同，但返回替换时间戳后的文件名
+func ConvertFilePath(src, dst string, opts ...Option) (string, error) {
+	filename, err := GetLatestFileByName(src)
+	if err != nil {
+		return "", fmt.Errorf("get latest file: %w", err)
+	}
+
+	var data any
+	var columns []string
+	switch ext, _ := formatExt(filename); {
+	case ext == ".csv":
+		data, columns, err = readCSVTable(filename)
+	case isNDJSON(ext):
+		var rows []any
+		err = ReadFile(filename, &rows)
+		data = rows
+	case ext == ".xml":
+		return "", fmt.Errorf("%w: cannot convert from %s", ErrUnsupportedFormat, ext)
+	default:
+		err = ReadFile(filename, &data)
+	}
+	if err != nil {
+		return "", fmt.Errorf("%s: %w", filename, err)
+	}
+
+	o := newOptions(opts)
+	switch ext, _ := o.formatExt(dst); {
+	case ext == ".csv":
+		opts = append(opts, WithMarshal(func(v any) ([]byte, error) {
+			return marshalCSVTable(v, columns)
+		}))
+	case isNDJSON(ext):
+		if _, ok := data.([]any); !ok {
+			return "", fmt.Errorf("cannot convert %s to %s: NDJSON needs a list of records, got %s", filename, ext, describeShape(data))
+		}
+	case ext == ".toml":
+		if _, ok := data.(map[string]any); !ok {
+			return "", fmt.Errorf("cannot convert %s to %s: TOML needs a table at the top level, got %s", filename, ext, describeShape(data))
+		}
+	case ext == ".xml":
+		return "", fmt.Errorf("%w: cannot convert to %s", ErrUnsupportedFormat, ext)
+	}
+
+	written, err := WriteFilePath(dst, data, opts...)
+	if err != nil {
+		return "", fmt.Errorf("cannot convert %s to %s: %w", filename, dst, err)
+	}
+	return written, nil
+}
+
+// readCSVTable 读取 CSV 文件为以表头为键的对象列表，同时返回表头以保持列的顺序
+func readCSVTable(filename string) ([]any, []string, error) {
+	cr, closeFile, err := openCSVFile(filename)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer closeFile()
+
+	header, err := cr.Read()
+	if err != nil {
+		return nil, nil, fmt.Errorf("read header: %w", err)
+	}
+	header = slices.Clone(header)
+	var rows []any
+	for {
+		record, err := cr.Read()
+		if err == io.EOF {
+			return rows, header, nil
+		}
+		if err != nil {
+			return nil, nil, err
+		}
+		row := make(map[string]any, len(header))
+		for i, col := range header {
+			if i < len(record) {
+				row[col] = record[i]
+			} else {
+				row[col] = ""
+			}
+		}
+		rows = append(rows, row)
+	}
+}
+
+// marshalCSVTable 将对象列表序列化为 CSV，columns 为空时使用所有对象的键按字母排序
+// 对象的值只能是字符串、数字、布尔值或 null，嵌套的对象和列表无法表示为单元格
+func marshalCSVTable(v any, columns []string) ([]byte, error) {
+	list, ok := v.([]any)
+	if !ok {
+		return nil, fmt.Errorf("CSV needs a list of records, got %s", describeShape(v))
+	}
+	rows := make([]map[string]any, len(list))
+	keys := map[string]bool{}
+	for i, item := range list {
+		row, ok := item.(map[string]any)
+		if !ok {
+			return nil, fmt.Errorf("CSV needs a list of records, record %d is %s", i+1, describeShape(item))
+		}
+		rows[i] = row
+		for k := range row {
+			keys[k] = true
+		}
+	}
+	if len(columns) == 0 {
+		columns = slices.Sorted(maps.Keys(keys))
+	}
+
+	var buf bytes.Buffer
+	w := csv.NewWriter(&buf)
+	if err := w.Write(columns); err != nil {
+		return nil, err
+	}
+	record := make([]string, len(columns))
+	for i, row := range rows {
+		for j, col := range columns {
+			cell, err := csvCell(row[col])
+			if err != nil {
+				return nil, fmt.Errorf("record %d field %q: %w", i+1, col, err)
+			}
+			record[j] = cell
+		}
+		if err := w.Write(record); err != nil {
+			return nil, err
+		}
+	}
+	w.Flush()
+	return buf.Bytes(), w.Error()
+}
+
+// csvCell 将标量转换为单元格的文本
+func csvCell(v any) (string, error) {
+	switch v := v.(type) {
+	case nil:
+		return "", nil
+	case string:
+		return v, nil
+	case bool:
+		return strconv.FormatBool(v), nil
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64), nil
+	case float32:
+		return strconv.FormatFloat(float64(v), 'f', -1, 32), nil
+	case int, int8, int16, int32, int64, uint, uint8, uint16, uint32, uint64:
+		return fmt.Sprint(v), nil
+	case fmt.Stringer:
+		return v.String(), nil
+	default:
+		return "", fmt.Errorf("%s cannot be written to a CSV cell, flatten it first", describeShape(v))
+	}
+}
+
+// describeShape 描述 v 的结构，用于错误信息
+func describeShape(v any) string {
+	switch reflect.ValueOf(v).Kind() {
+	case reflect.Map:
+		return "an object"
+	case reflect.Slice, reflect.Array:
+		return "a list"
+	case reflect.Invalid:
+		return "null"
+	default:
+		return fmt.Sprintf("a %T value", v)
+	}
+}
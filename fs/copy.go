@@ -0,0 +1,99 @@
+package fs
+
+import (
+	"fmt"
+	"os"
+)
+
+// rename 重命名文件，测试中可以替换以模拟跨设备移动
+var rename = os.Rename
+
+// CopyFile 将与 src 匹配的最新文件复制到 dstPattern（其中的 * 会替换为时间戳），返回实际写入的文件名
+// 复制以流式方式写入同一目录下的临时文件后再重命名，读者不会看到复制了一半的文件；默认创建缺失的父目录，
+// 目标文件的权限与源文件相同，可通过 WithFileMode 覆盖；WithChecksum、WithLatestSymlink 等选项与 SaveFile 相同
+func CopyFile(src, dstPattern string, opts ...Option) (string, error) {
+	filename, err := GetLatestFileByName(src)
+	if err != nil {
+		return "", fmt.Errorf("get latest file: %w", err)
+	}
+	o := newOptions(opts)
+	var dst string
+	err = o.lockWrite(dstPattern, func() error {
+		if dst, err = timestampFileName(dstPattern); err != nil {
+			return err
+		}
+		return o.copyFile(filename, dst)
+	})
+	if err != nil {
+		return "", err
+	}
+	return dst, nil
+}
+
+// copyFile 将 src 以原子方式复制为 dst
+func (o *options) copyFile(src, dst string) error {
+	f, err := os.Open(src)
+	if err != nil {
+		return fmt.Errorf("copy file: %w", err)
+	}
+	defer f.Close()
+	if !o.fileModeSet {
+		info, err := f.Stat()
+		if err != nil {
+			return fmt.Errorf("copy file: %w", err)
+		}
+		o.fileMode, o.fileModeSet = info.Mode().Perm(), true
+	}
+
+	o.atomic = true
+	if err = o.writeNamed(dst, f); err != nil {
+		return fmt.Errorf("copy file: %w", err)
+	}
+	return nil
+}
+
+// MoveFile 将与 src 匹配的最新文件移动到 dstPattern（其中的 * 会替换为时间戳），返回实际写入的文件名
+// 同一文件系统内直接重命名；跨设备时改为按 CopyFile 复制后删除源文件，默认创建缺失的父目录
+func MoveFile(src, dstPattern string, opts ...Option) (string, error) {
+	filename, err := GetLatestFileByName(src)
+	if err != nil {
+		return "", fmt.Errorf("get latest file: %w", err)
+	}
+	o := newOptions(opts)
+	var dst string
+	err = o.lockWrite(dstPattern, func() error {
+		dst, err = o.moveFile(filename, dstPattern)
+		return err
+	})
+	return dst, err
+}
+
+// moveFile 将 src 移动到替换时间戳后的 dstPattern，返回实际写入的文件名
+func (o *options) moveFile(filename, dstPattern string) (string, error) {
+	dst, err := timestampFileName(dstPattern)
+	if err != nil {
+		return "", err
+	}
+
+	if err = o.createParentDirs(dst); err != nil {
+		return "", err
+	}
+	err = rename(filename, dst)
+	switch {
+	case err == nil:
+		if err = o.afterWrite(dst); err != nil {
+			return dst, err
+		}
+		return dst, nil
+	case !crossDevice(err):
+		return "", fmt.Errorf("move file: %w", err)
+	}
+
+	if err = o.copyFile(filename, dst); err != nil {
+		return "", err
+	}
+	if err = os.Remove(filename); err != nil {
+		return dst, fmt.Errorf("remove source file: %w", err)
+	}
+	return dst, nil
+}
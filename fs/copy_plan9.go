@@ -0,0 +1,8 @@
+//go:build plan9
+
+package fs
+
+// crossDevice 在 Plan 9 上无法区分跨设备错误，重命名失败时总是改为复制后删除
+func crossDevice(error) bool {
+	return true
+}
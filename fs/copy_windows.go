@@ -0,0 +1,14 @@
+//go:build windows
+
+package fs
+
+import (
+	"errors"
+
+	"golang.org/x/sys/windows"
+)
+
+// crossDevice 判断重命名失败是否因为源文件和目标在不同的卷上
+func crossDevice(err error) bool {
+	return errors.Is(err, windows.ERROR_NOT_SAME_DEVICE)
+}
@@ -0,0 +1,13 @@
+//go:build !plan9 && !windows
+
+package fs
+
+import (
+	"errors"
+	"syscall"
+)
+
+// crossDevice 判断重命名失败是否因为源文件和目标在不同的文件系统上
+func crossDevice(err error) bool {
+	return errors.Is(err, syscall.EXDEV)
+}
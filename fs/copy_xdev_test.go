@@ -0,0 +1,48 @@
+//go:build !plan9
+
+package fs
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+	"syscall"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// crossDeviceErr 返回当前平台重命名跨设备时的错误，Windows 上为 ERROR_NOT_SAME_DEVICE
+func crossDeviceErr() error {
+	if runtime.GOOS == "windows" {
+		return syscall.Errno(17)
+	}
+	return syscall.EXDEV
+}
+
+func TestMoveFile_CrossDevice(t *testing.T) {
+	dir := t.TempDir()
+	src := filepath.Join(dir, "src", "report.json")
+	assert.NoError(t, SaveFileWith(src, `{"count":1}`, WithFileMode(0o600)))
+
+	// 跨设备时复制后删除源文件
+	rename = func(string, string) error { return &os.LinkError{Op: "rename", Err: crossDeviceErr()} }
+	defer func() { rename = os.Rename }()
+	moved, err := MoveFile(src, filepath.Join(dir, "other", "report.json"))
+	assert.NoError(t, err)
+	assert.NoFileExists(t, src)
+	var state CounterState
+	assert.NoError(t, ReadFile(moved, &state))
+	assert.Equal(t, 1, state.Count)
+	if runtime.GOOS != "windows" {
+		info, err := os.Stat(moved)
+		assert.NoError(t, err)
+		assert.Equal(t, os.FileMode(0o600), info.Mode().Perm())
+	}
+
+	// 其他错误直接返回，不复制
+	rename = func(string, string) error { return &os.LinkError{Op: "rename", Err: syscall.EACCES} }
+	_, err = MoveFile(moved, filepath.Join(dir, "other2", "report.json"))
+	assert.ErrorIs(t, err, syscall.EACCES)
+	assert.FileExists(t, moved)
+}
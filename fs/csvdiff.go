@@ -0,0 +1,174 @@
+package fs
+
+import (
+	"fmt"
+	"slices"
+)
+
+// Diff 是 DiffCSVFiles 比较两个 CSV 文件的结果，行均为原始的单元格，列顺序同 Header
+type Diff struct {
+	Header     []string
+	KeyColumns []string
+	// Removed 是只在 A 中的行，按 A 中的顺序排列
+	Removed [][]string
+	// Added 是只在 B 中的行，按 B 中的顺序排列
+	Added [][]string
+	// Changed 是键相同但其他单元格不同的行，按 B 中的顺序排列
+	Changed []RowChange
+}
+
+// RowChange 描述键相同的一行在 A、B 之间的变化
+type RowChange struct {
+	// Key 是键列的值，顺序同 Diff.KeyColumns
+	Key []string
+	// Row 是该行在 B 中的内容
+	Row     []string
+	Changes []CellChange
+}
+
+// CellChange 描述一个单元格的变化
+type CellChange struct {
+	Column string
+	Old    string
+	New    string
+}
+
+// Empty 判断两个文件是否没有差异
+func (d Diff) Empty() bool {
+	return len(d.Removed) == 0 && len(d.Added) == 0 && len(d.Changed) == 0
+}
+
+// DiffCSVFiles 按 keyColumns 比较与 pathA、pathB 匹配的最新 CSV 文件，返回只在 A 中、只在 B 中以及非键单元格有变化的行
+// 两个文件的表头须相同，否则在比较前返回 ErrHeaderMismatch；keyColumns 按表头名称区分大小写匹配，同一文件中的键须唯一
+// A 会完整读入内存，B 逐行读取
+func DiffCSVFiles(pathA, pathB string, keyColumns []string) (Diff, error) {
+	if len(keyColumns) == 0 {
+		return Diff{}, fmt.Errorf("keyColumns must not be empty")
+	}
+	fileA, err := GetLatestFileByName(pathA)
+	if err != nil {
+		return Diff{}, err
+	}
+	fileB, err := GetLatestFileByName(pathB)
+	if err != nil {
+		return Diff{}, err
+	}
+	headerA, err := readRawCSVHeader(fileA)
+	if err != nil {
+		return Diff{}, err
+	}
+	headerB, err := readRawCSVHeader(fileB)
+	if err != nil {
+		return Diff{}, err
+	}
+	if !slices.Equal(headerA, headerB) {
+		return Diff{}, fmt.Errorf("%s: %w: header %q does not match header %q of %s", fileB, ErrHeaderMismatch, headerB, headerA, fileA)
+	}
+	index := make([]int, len(keyColumns))
+	for i, col := range keyColumns {
+		if index[i] = slices.Index(headerA, col); index[i] < 0 {
+			return Diff{}, fmt.Errorf("%s: key column %q not found in header %q", fileA, col, headerA)
+		}
+	}
+	keyOf := func(record []string) []string {
+		key := make([]string, len(index))
+		for i, j := range index {
+			key[i] = cell(record, j)
+		}
+		return key
+	}
+
+	// rowsA 按 A 中的顺序保存各行，byKey 记录键对应的行号，B 中出现的键会被删除
+	var rowsA [][]string
+	byKey := map[string]int{}
+	if err = eachCSVRecord(fileA, func(row int, record []string) error {
+		key := joinKey(keyOf(record))
+		if _, ok := byKey[key]; ok {
+			return fmt.Errorf("%s: duplicate key %q", fileA, keyOf(record))
+		}
+		byKey[key] = row
+		rowsA = append(rowsA, slices.Clone(record))
+		return nil
+	}); err != nil {
+		return Diff{}, err
+	}
+
+	d := Diff{Header: headerA, KeyColumns: keyColumns}
+	seenB := map[string]bool{}
+	if err = eachCSVRecord(fileB, func(_ int, record []string) error {
+		key := keyOf(record)
+		k := joinKey(key)
+		if seenB[k] {
+			return fmt.Errorf("%s: duplicate key %q", fileB, key)
+		}
+		seenB[k] = true
+
+		row, ok := byKey[k]
+		if !ok {
+			d.Added = append(d.Added, slices.Clone(record))
+			return nil
+		}
+		delete(byKey, k)
+		var changes []CellChange
+		for i, col := range headerA {
+			if slices.Contains(index, i) {
+				continue
+			}
+			if old, cur := cell(rowsA[row], i), cell(record, i); old != cur {
+				changes = append(changes, CellChange{Column: col, Old: old, New: cur})
+			}
+		}
+		if len(changes) > 0 {
+			d.Changed = append(d.Changed, RowChange{Key: key, Row: slices.Clone(record), Changes: changes})
+		}
+		return nil
+	}); err != nil {
+		return Diff{}, err
+	}
+
+	for _, record := range rowsA {
+		if _, ok := byKey[joinKey(keyOf(record))]; ok {
+			d.Removed = append(d.Removed, record)
+		}
+	}
+	return d, nil
+}
+
+// WriteCSV 将差异写入 CSV 文件，列为 change、原表头的各列以及 column、old、new
+// 新增和删除的行各占一行，change 为 added 或 removed；有变化的行每个变化的单元格占一行，change 为 changed，其余列为 B 中的内容
+// path 的时间戳、.gz 等处理同 WriteFile，文件以原子方式写入
+func (d Diff) WriteCSV(path string, opts ...Option) error {
+	header := append(append([]string{"change"}, d.Header...), "column", "old", "new")
+	row := func(change string, record []string, c CellChange) []string {
+		out := make([]string, 0, len(header))
+		out = append(out, change)
+		for i := range d.Header {
+			out = append(out, cell(record, i))
+		}
+		return append(out, c.Column, c.Old, c.New)
+	}
+	filename, err := timestampFileName(path)
+	if err != nil {
+		return err
+	}
+	return newOptions(opts).writeCSVAtomic(filename, header, func(w *csvFileWriter) error {
+		for _, record := range d.Removed {
+			if err := w.Write(row("removed", record, CellChange{})); err != nil {
+				return err
+			}
+		}
+		for _, record := range d.Added {
+			if err := w.Write(row("added", record, CellChange{})); err != nil {
+				return err
+			}
+		}
+		for _, change := range d.Changed {
+			for _, c := range change.Changes {
+				if err := w.Write(row("changed", change.Row, c)); err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	})
+}
@@ -0,0 +1,491 @@
+package fs
+
+import (
+	"compress/gzip"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand/v2"
+	"os"
+	"path/filepath"
+	"reflect"
+	"runtime"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/gookit/goutil/fsutil"
+)
+
+// partPlaceholder 是 SplitCSVFile 目标文件名中分片序号的占位符
+const partPlaceholder = "{part}"
+
+// SplitCSVFile 将与 srcPattern 匹配的最新 CSV 文件按每 rowsPerFile 行拆分为多个文件，每个文件都带有原表头，返回创建的文件
+// dstTemplate 中的 {part} 替换为从 1 开始的分片序号，其余占位符同 WriteFile；源文件或目标文件以 .gz 结尾时自动解压或压缩
+// 逐行读取，内存占用与文件大小无关，带引号的多行字段不会被拆开；源文件只有表头时创建一个只有表头的文件
+func SplitCSVFile(srcPattern, dstTemplate string, rowsPerFile int, opts ...Option) (parts []string, err error) {
+	if rowsPerFile <= 0 {
+		return nil, errors.New("rowsPerFile must be positive")
+	}
+	if !strings.Contains(dstTemplate, partPlaceholder) {
+		return nil, fmt.Errorf("dstTemplate %q has no %s placeholder", dstTemplate, partPlaceholder)
+	}
+
+	src, err := GetLatestFileByName(srcPattern)
+	if err != nil {
+		return nil, err
+	}
+	cr, closeSrc, err := openCSVFile(src)
+	if err != nil {
+		return nil, err
+	}
+	defer closeSrc()
+	header, err := cr.Read()
+	if err != nil {
+		return nil, fmt.Errorf("%s: read header: %w", src, err)
+	}
+	header = append([]string(nil), header...)
+
+	o := newOptions(opts)
+	var w *csvFileWriter
+	defer func() {
+		if w != nil {
+			if cerr := w.Close(); err == nil {
+				err = cerr
+			}
+		}
+	}()
+
+	rows := 0
+	for {
+		record, err := cr.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return parts, fmt.Errorf("%s: %w", src, err)
+		}
+		if w == nil || rows == rowsPerFile {
+			if w != nil {
+				if err = w.Close(); err != nil {
+					w = nil
+					return parts, err
+				}
+			}
+			name, err := timestampFileName(strings.ReplaceAll(dstTemplate, partPlaceholder, strconv.Itoa(len(parts)+1)))
+			if err != nil {
+				return parts, err
+			}
+			if w, err = o.createCSVFile(name, name, header); err != nil {
+				return parts, err
+			}
+			parts = append(parts, w.name)
+			rows = 0
+		}
+		if err = w.Write(record); err != nil {
+			return parts, err
+		}
+		rows++
+	}
+
+	if w == nil {
+		name, err := timestampFileName(strings.ReplaceAll(dstTemplate, partPlaceholder, "1"))
+		if err != nil {
+			return nil, err
+		}
+		if w, err = o.createCSVFile(name, name, header); err != nil {
+			return nil, err
+		}
+		parts = append(parts, w.name)
+	}
+	return parts, nil
+}
+
+// MergeCSVFiles 按文件名的自然顺序将与 srcPattern 匹配的所有 CSV 文件合并写入 dst，表头只写一次，dst 本身不参与合并
+// 各文件的表头须与第一个文件一致，否则返回包含文件名的 ErrHeaderMismatch；配合 WithUnionHeaders 则合并所有列，缺少的列留空
+// 逐行处理并以原子方式写入 dst，源文件或 dst 以 .gz 结尾时自动解压或压缩
+func MergeCSVFiles(srcPattern, dst string, opts ...Option) (err error) {
+	o := newOptions(opts)
+	if dst, err = timestampFileName(dst); err != nil {
+		return err
+	}
+	entries, err := ListFiles(srcPattern, WithExcludeDirs())
+	if err != nil {
+		return err
+	}
+	var files []string
+	for _, entry := range entries {
+		if filepath.Clean(entry.Path) != filepath.Clean(dst) {
+			files = append(files, entry.Path)
+		}
+	}
+	if len(files) == 0 {
+		return ErrNoMatch
+	}
+
+	headers := make([][]string, len(files))
+	for i, file := range files {
+		if headers[i], err = readRawCSVHeader(file); err != nil {
+			return err
+		}
+	}
+	header := headers[0]
+	if o.unionHeaders {
+		for _, h := range headers[1:] {
+			for _, col := range h {
+				if !slices.Contains(header, col) {
+					header = append(header, col)
+				}
+			}
+		}
+	} else {
+		for i, h := range headers[1:] {
+			if !slices.Equal(h, header) {
+				return fmt.Errorf("%s: %w: header %q does not match header %q of %s", files[i+1], ErrHeaderMismatch, h, header, files[0])
+			}
+		}
+	}
+
+	return o.writeCSVAtomic(dst, header, func(w *csvFileWriter) error {
+		for i, file := range files {
+			if err := copyCSVRows(w, file, header, headers[i]); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// writeCSVAtomic 先将表头和 write 写入的记录写入同一目录下的临时文件，成功后再重命名为 dst，dst 以 .gz 结尾时压缩
+func (o *options) writeCSVAtomic(dst string, header []string, write func(w *csvFileWriter) error) (err error) {
+	tmp := filepath.Join(filepath.Dir(dst), fmt.Sprintf(".%s.tmp%d", filepath.Base(dst), rand.Uint32()))
+	w, err := o.createCSVFile(tmp, dst, header)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if w != nil {
+			w.Close()
+		}
+		if err != nil {
+			os.Remove(tmp)
+		}
+	}()
+
+	if err = write(w); err != nil {
+		return err
+	}
+	err, w = w.Close(), nil
+	if err != nil {
+		return err
+	}
+	if err = os.Rename(tmp, dst); err != nil {
+		return fmt.Errorf("rename file: %w", err)
+	}
+	return nil
+}
+
+// DedupeCSVFile 逐行读取与 srcPattern 匹配的最新 CSV 文件，每组 keyColumns 的值只保留第一行，以原子方式写入 dst，返回保留和丢弃的行数
+// keyColumns 按表头名称区分大小写匹配，为空时以整行作为键，表头中缺少某个键列时在写入前返回错误
+// 配合 WithKeepLast 则保留每组的最后一行，此时需读取源文件两遍。保留的行维持原来的顺序，源文件或 dst 以 .gz 结尾时自动解压或压缩
+func DedupeCSVFile(srcPattern, dst string, keyColumns []string, opts ...Option) (kept, dropped int, err error) {
+	o := newOptions(opts)
+	src, err := GetLatestFileByName(srcPattern)
+	if err != nil {
+		return 0, 0, err
+	}
+	header, err := readRawCSVHeader(src)
+	if err != nil {
+		return 0, 0, err
+	}
+	index := make([]int, len(keyColumns))
+	for i, col := range keyColumns {
+		if index[i] = slices.Index(header, col); index[i] < 0 {
+			return 0, 0, fmt.Errorf("%s: key column %q not found in header %q", src, col, header)
+		}
+	}
+	keyOf := func(record []string) string {
+		if len(index) == 0 {
+			return joinKey(record)
+		}
+		key := make([]string, len(index))
+		for i, j := range index {
+			key[i] = cell(record, j)
+		}
+		return joinKey(key)
+	}
+
+	// keep 判断第 row 行（从 0 开始）是否保留
+	var keep func(row int, key string) bool
+	if o.keepLast {
+		last := map[string]int{}
+		if err = eachCSVRecord(src, func(row int, record []string) error {
+			last[keyOf(record)] = row
+			return nil
+		}); err != nil {
+			return 0, 0, err
+		}
+		keep = func(row int, key string) bool {
+			return last[key] == row
+		}
+	} else {
+		seen := map[string]bool{}
+		keep = func(_ int, key string) bool {
+			if seen[key] {
+				return false
+			}
+			seen[key] = true
+			return true
+		}
+	}
+
+	if dst, err = timestampFileName(dst); err != nil {
+		return 0, 0, err
+	}
+	err = o.writeCSVAtomic(dst, header, func(w *csvFileWriter) error {
+		return eachCSVRecord(src, func(row int, record []string) error {
+			if !keep(row, keyOf(record)) {
+				dropped++
+				return nil
+			}
+			kept++
+			return w.Write(record)
+		})
+	})
+	if err != nil {
+		return 0, 0, err
+	}
+	return kept, dropped, nil
+}
+
+// eachCSVRecord 对 filename 中表头以外的每条记录调用 fn，row 从 0 开始，record 在下次调用时会被复用
+func eachCSVRecord(filename string, fn func(row int, record []string) error) error {
+	cr, closeFile, err := openCSVFile(filename)
+	if err != nil {
+		return err
+	}
+	defer closeFile()
+	cr.ReuseRecord = true
+	if _, err = cr.Read(); err != nil {
+		return fmt.Errorf("%s: read header: %w", filename, err)
+	}
+	for row := 0; ; row++ {
+		record, err := cr.Read()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("%s: %w", filename, err)
+		}
+		if err = fn(row, record); err != nil {
+			return err
+		}
+	}
+}
+
+// readRawCSVHeader 读取 filename 的第一行
+func readRawCSVHeader(filename string) ([]string, error) {
+	cr, closeFile, err := openCSVFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer closeFile()
+	header, err := cr.Read()
+	if err != nil {
+		return nil, fmt.Errorf("%s: read header: %w", filename, err)
+	}
+	return append([]string(nil), header...), nil
+}
+
+// copyCSVRows 将 filename 中表头以外的记录按 header 的列顺序写入 w，fileHeader 是 filename 的表头
+func copyCSVRows(w *csvFileWriter, filename string, header, fileHeader []string) error {
+	cr, closeFile, err := openCSVFile(filename)
+	if err != nil {
+		return err
+	}
+	defer closeFile()
+	if _, err = cr.Read(); err != nil {
+		return fmt.Errorf("%s: read header: %w", filename, err)
+	}
+
+	// index[i] 是 header 第 i 列在 fileHeader 中的位置，-1 表示该文件没有这一列
+	var index []int
+	if !slices.Equal(header, fileHeader) {
+		index = make([]int, len(header))
+		for i, col := range header {
+			index[i] = slices.Index(fileHeader, col)
+		}
+	}
+	row := make([]string, len(header))
+	for {
+		record, err := cr.Read()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("%s: %w", filename, err)
+		}
+		if index != nil {
+			for i, j := range index {
+				row[i] = ""
+				if j >= 0 && j < len(record) {
+					row[i] = record[j]
+				}
+			}
+			record = row
+		}
+		if err = w.Write(record); err != nil {
+			return err
+		}
+	}
+}
+
+// joinKey 将键列的值拼接为 map 的键，以长度作前缀避免不同的值拼接后相同
+func joinKey(key []string) string {
+	var b strings.Builder
+	for _, v := range key {
+		fmt.Fprintf(&b, "%d:%s", len(v), v)
+	}
+	return b.String()
+}
+
+// cell 返回 record 的第 i 个单元格，缺少时为空
+func cell(record []string, i int) string {
+	if i < len(record) {
+		return record[i]
+	}
+	return ""
+}
+
+// csvFileWriter 将原始记录写入 CSV 文件，文件名以 .gz 结尾时压缩
+type csvFileWriter struct {
+	name string
+	f    *os.File
+	gz   *gzip.Writer
+	*csv.Writer
+}
+
+// openCSVFile 打开 filename 并返回逐行读取原始记录的 Reader，文件以 gzip 压缩时自动解压
+func openCSVFile(filename string) (*csv.Reader, func() error, error) {
+	f, err := os.Open(filename)
+	if err != nil {
+		return nil, nil, fmt.Errorf("read file: %w", err)
+	}
+	r, err := gunzipReader(f)
+	if err != nil {
+		f.Close()
+		return nil, nil, fmt.Errorf("read file: %w", err)
+	}
+	cr := csv.NewReader(r)
+	cr.FieldsPerRecord = -1
+	return cr, f.Close, nil
+}
+
+// createCSVFile 按 o 的设置创建 filename 并写入表头，format 的后缀名为 .gz 时压缩
+func (o *options) createCSVFile(filename, format string, header []string) (*csvFileWriter, error) {
+	f, err := o.openFile(filename, fsutil.FsCWTFlags)
+	if err != nil {
+		return nil, err
+	}
+	w := &csvFileWriter{name: filename, f: f}
+	var out io.Writer = f
+	if _, gzipped := o.formatExt(format); gzipped {
+		if w.gz, err = gzip.NewWriterLevel(f, o.gzipLevel); err != nil {
+			f.Close()
+			return nil, err
+		}
+		out = w.gz
+	}
+	w.Writer = csv.NewWriter(out)
+	if err = w.Write(header); err != nil {
+		w.Close()
+		return nil, err
+	}
+	return w, nil
+}
+
+// Close 写入缓冲的记录并关闭文件
+func (w *csvFileWriter) Close() error {
+	w.Flush()
+	err := w.Error()
+	if w.gz != nil {
+		if gerr := w.gz.Close(); err == nil {
+			err = gerr
+		}
+	}
+	if cerr := w.f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return fmt.Errorf("write %s: %w", w.name, err)
+	}
+	return nil
+}
+
+// shardPlaceholder 是 WriteShardedCSVFiles 文件名中分片序号的占位符
+const shardPlaceholder = "{shard}"
+
+// WriteShardedCSVFiles 将切片 data 按每 rowsPerShard 个元素拆分，并发写入多个带表头的 CSV 文件，按分片顺序返回写入的文件
+// pathTemplate 中的 {shard} 替换为从 1 开始的分片序号，时间戳等占位符对所有分片只展开一次；各分片的列相同，空切片写入一个只有表头的文件
+// 某个分片写入失败时删除已写入的分片并返回错误；配合 WithPartialResults 则保留已写入的分片并同时返回它们
+func WriteShardedCSVFiles(pathTemplate string, data any, rowsPerShard int, opts ...Option) ([]string, error) {
+	if rowsPerShard <= 0 {
+		return nil, errors.New("rowsPerShard must be positive")
+	}
+	if !strings.Contains(pathTemplate, shardPlaceholder) {
+		return nil, fmt.Errorf("pathTemplate %q has no %s placeholder", pathTemplate, shardPlaceholder)
+	}
+	rv := reflect.ValueOf(data)
+	for rv.Kind() == reflect.Ptr {
+		rv = rv.Elem()
+	}
+	if rv.Kind() != reflect.Slice && rv.Kind() != reflect.Array {
+		return nil, fmt.Errorf("data must be a slice, got %T", data)
+	}
+
+	o := newOptions(opts)
+	if o.marshal == nil {
+		o.marshal = o.marshalStableCSV()
+	}
+	base, err := timestampFileName(pathTemplate)
+	if err != nil {
+		return nil, err
+	}
+	shards := max((rv.Len()+rowsPerShard-1)/rowsPerShard, 1)
+	paths := make([]string, shards)
+	errs := make([]error, shards)
+
+	var wg sync.WaitGroup
+	sem := make(chan struct{}, min(shards, runtime.GOMAXPROCS(0)))
+	for i := range shards {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func() {
+			defer wg.Done()
+			defer func() { <-sem }()
+			name := strings.ReplaceAll(base, shardPlaceholder, strconv.Itoa(i+1))
+			shard := rv.Slice(i*rowsPerShard, min((i+1)*rowsPerShard, rv.Len())).Interface()
+			if _, errs[i] = o.writeFile(osFS{o}, name, shard); errs[i] == nil {
+				paths[i] = name
+			} else {
+				errs[i] = fmt.Errorf("%s: %w", name, errs[i])
+			}
+		}()
+	}
+	wg.Wait()
+
+	err = errors.Join(errs...)
+	if err == nil {
+		return paths, nil
+	}
+	written := slices.DeleteFunc(paths, func(p string) bool { return p == "" })
+	if o.partialResults {
+		return written, err
+	}
+	for _, p := range written {
+		os.Remove(p)
+	}
+	return nil, err
+}
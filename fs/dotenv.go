@@ -0,0 +1,488 @@
+package fs
+
+import (
+	"encoding"
+	"errors"
+	"fmt"
+	"maps"
+	"reflect"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// envTag 是 .env 和 .properties 文件中结构体字段的标签名，例如 `env:"DATABASE_URL,omitempty"`
+const envTag = "env"
+
+// MarshalDotenv 将 map[string]T 或结构体序列化为 .env 文件，每行一个 KEY=VALUE，按键名排序
+// 包含空白、引号、#、\ 或换行的值以双引号包围并转义；结构体字段按 env 标签命名，标签为 "-" 时跳过，omitempty 跳过零值
+func MarshalDotenv(v any) ([]byte, error) {
+	kv, err := keyValues(v)
+	if err != nil {
+		return nil, err
+	}
+	var b strings.Builder
+	for _, key := range slices.Sorted(maps.Keys(kv)) {
+		if key == "" || strings.ContainsAny(key, "= \t\r\n#\"'") {
+			return nil, fmt.Errorf("invalid dotenv key %q", key)
+		}
+		b.WriteString(key)
+		b.WriteByte('=')
+		b.WriteString(quoteDotenv(kv[key]))
+		b.WriteByte('\n')
+	}
+	return []byte(b.String()), nil
+}
+
+// quoteDotenv 在 value 包含特殊字符时以双引号包围并转义
+func quoteDotenv(value string) string {
+	if !strings.ContainsAny(value, " \t\r\n\"'#\\") {
+		return value
+	}
+	r := strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`, "\r", `\r`, "\t", `\t`)
+	return `"` + r.Replace(value) + `"`
+}
+
+// UnmarshalDotenv 解析 .env 文件到 out，out 为指向 map[string]string、结构体或 any 的指针
+// 支持 # 注释、export 前缀、单引号（原样保留）和双引号（支持 \n 等转义，可跨行）包围的值，未加引号的值中空白后的 # 开始注释
+func UnmarshalDotenv(data []byte, out any) error {
+	kv, err := parseDotenv(string(data))
+	if err != nil {
+		return err
+	}
+	return assignKeyValues(kv, out)
+}
+
+// parseDotenv 解析 .env 文件的内容，错误中包含行号
+func parseDotenv(s string) (map[string]string, error) {
+	kv := make(map[string]string)
+	s = strings.TrimPrefix(strings.ReplaceAll(s, "\r\n", "\n"), "\ufeff")
+	for n := 1; s != ""; n++ {
+		raw, rest, found := strings.Cut(s, "\n")
+		s = rest
+		line := strings.TrimLeft(raw, " \t")
+		if trimmed := strings.TrimSpace(line); trimmed == "" || strings.HasPrefix(trimmed, "#") {
+			continue
+		}
+		if after, ok := strings.CutPrefix(line, "export"); ok && after != "" && (after[0] == ' ' || after[0] == '\t') {
+			line = strings.TrimLeft(after, " \t")
+		}
+
+		key, value, ok := strings.Cut(line, "=")
+		key = strings.TrimSpace(key)
+		if !ok || key == "" {
+			return nil, fmt.Errorf("line %d: expected KEY=VALUE", n)
+		}
+		value = strings.TrimLeft(value, " \t")
+		if value == "" || (value[0] != '"' && value[0] != '\'') {
+			value = stripDotenvComment(value)
+			kv[key] = strings.TrimSpace(value)
+			continue
+		}
+
+		// 引号中的值可以跨行，从当前行的剩余部分开始解析
+		start := n
+		quoted := value
+		if found {
+			quoted += "\n" + rest
+		}
+		unquoted, size, err := unquoteDotenv(quoted)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", start, err)
+		}
+		n += strings.Count(quoted[:size], "\n")
+		tail, remaining, _ := strings.Cut(quoted[size:], "\n")
+		if tail = strings.TrimSpace(tail); tail != "" && !strings.HasPrefix(tail, "#") {
+			return nil, fmt.Errorf("line %d: unexpected %q after quoted value", n, tail)
+		}
+		kv[key] = unquoted
+		s = remaining
+	}
+	return kv, nil
+}
+
+// stripDotenvComment 去掉未加引号的值中位于开头或空白之后的 # 注释
+func stripDotenvComment(value string) string {
+	for i := 0; i < len(value); i++ {
+		if value[i] == '#' && (i == 0 || value[i-1] == ' ' || value[i-1] == '\t') {
+			return value[:i]
+		}
+	}
+	return value
+}
+
+// unquoteDotenv 解析以引号开始的 s，返回引号中的值及包括引号在内消耗的字节数
+func unquoteDotenv(s string) (string, int, error) {
+	q := s[0]
+	var b strings.Builder
+	for i := 1; i < len(s); i++ {
+		c := s[i]
+		if c == q {
+			return b.String(), i + 1, nil
+		}
+		if q == '"' && c == '\\' && i+1 < len(s) {
+			i++
+			switch s[i] {
+			case 'n':
+				b.WriteByte('\n')
+			case 'r':
+				b.WriteByte('\r')
+			case 't':
+				b.WriteByte('\t')
+			case '"', '\\':
+				b.WriteByte(s[i])
+			default:
+				b.WriteByte('\\')
+				b.WriteByte(s[i])
+			}
+			continue
+		}
+		b.WriteByte(c)
+	}
+	return "", 0, errors.New("unterminated quoted value")
+}
+
+// MarshalProperties 将 map[string]T 或结构体序列化为 Java .properties 文件，每行一个 key=value，按键名排序
+// 键中的空白、=、:、#、! 和值中的换行、前导空白等按 .properties 的规则以 \ 转义；结构体字段的命名规则与 MarshalDotenv 相同
+func MarshalProperties(v any) ([]byte, error) {
+	kv, err := keyValues(v)
+	if err != nil {
+		return nil, err
+	}
+	var b strings.Builder
+	for _, key := range slices.Sorted(maps.Keys(kv)) {
+		if key == "" {
+			return nil, errors.New("invalid properties key \"\"")
+		}
+		b.WriteString(escapeProperty(key, true))
+		b.WriteByte('=')
+		b.WriteString(escapeProperty(kv[key], false))
+		b.WriteByte('\n')
+	}
+	return []byte(b.String()), nil
+}
+
+// escapeProperty 按 .properties 的规则转义键或值
+func escapeProperty(s string, isKey bool) string {
+	var b strings.Builder
+	for i, r := range s {
+		switch r {
+		case '\\':
+			b.WriteString(`\\`)
+		case '\n':
+			b.WriteString(`\n`)
+		case '\r':
+			b.WriteString(`\r`)
+		case '\t':
+			b.WriteString(`\t`)
+		case '\f':
+			b.WriteString(`\f`)
+		case ' ':
+			// 值中只有前导空白需要转义
+			if isKey || i == 0 {
+				b.WriteByte('\\')
+			}
+			b.WriteRune(r)
+		case '=', ':', '#', '!':
+			if isKey || i == 0 {
+				b.WriteByte('\\')
+			}
+			b.WriteRune(r)
+		default:
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
+
+// UnmarshalProperties 解析 Java .properties 文件到 out，out 的要求与 UnmarshalDotenv 相同
+// 支持 # 和 ! 注释、=、: 或空白分隔的键值、以 \ 结尾的续行以及 \n、\uXXXX 等转义，文件按 UTF-8 读取
+func UnmarshalProperties(data []byte, out any) error {
+	return assignKeyValues(parseProperties(string(data)), out)
+}
+
+// parseProperties 解析 .properties 文件的内容
+func parseProperties(s string) map[string]string {
+	kv := make(map[string]string)
+	s = strings.TrimPrefix(strings.ReplaceAll(s, "\r\n", "\n"), "\ufeff")
+	lines := strings.Split(s, "\n")
+	for i := 0; i < len(lines); i++ {
+		line := strings.TrimLeft(lines[i], " \t\f")
+		if line == "" || line[0] == '#' || line[0] == '!' {
+			continue
+		}
+		// 以奇数个 \ 结尾的行与下一行相连，下一行的前导空白被忽略
+		for hasContinuation(line) && i+1 < len(lines) {
+			i++
+			line = line[:len(line)-1] + strings.TrimLeft(lines[i], " \t\f")
+		}
+		if hasContinuation(line) {
+			line = line[:len(line)-1]
+		}
+
+		end := len(line)
+		for j := 0; j < len(line); j++ {
+			if line[j] == '\\' {
+				j++
+				continue
+			}
+			if strings.IndexByte("=: \t\f", line[j]) >= 0 {
+				end = j
+				break
+			}
+		}
+		key, value := line[:end], strings.TrimLeft(line[end:], " \t\f")
+		if value != "" && (value[0] == '=' || value[0] == ':') {
+			value = strings.TrimLeft(value[1:], " \t\f")
+		}
+		kv[unescapeProperty(key)] = unescapeProperty(value)
+	}
+	return kv
+}
+
+// hasContinuation 判断 line 是否以奇数个 \ 结尾
+func hasContinuation(line string) bool {
+	n := len(line) - len(strings.TrimRight(line, `\`))
+	return n%2 == 1
+}
+
+// unescapeProperty 还原 .properties 中的转义字符，无效的 \u 转义原样保留
+func unescapeProperty(s string) string {
+	if !strings.Contains(s, `\`) {
+		return s
+	}
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] != '\\' || i+1 == len(s) {
+			b.WriteByte(s[i])
+			continue
+		}
+		i++
+		switch c := s[i]; c {
+		case 'n':
+			b.WriteByte('\n')
+		case 'r':
+			b.WriteByte('\r')
+		case 't':
+			b.WriteByte('\t')
+		case 'f':
+			b.WriteByte('\f')
+		case 'u':
+			if i+5 <= len(s) {
+				if r, err := strconv.ParseUint(s[i+1:i+5], 16, 32); err == nil {
+					b.WriteRune(rune(r))
+					i += 4
+					continue
+				}
+			}
+			b.WriteString(`\u`)
+		default:
+			b.WriteByte(c)
+		}
+	}
+	return b.String()
+}
+
+// keyField 是结构体中与一个键对应的字段
+type keyField struct {
+	name      string
+	index     []int
+	omitEmpty bool
+}
+
+// keyFields 返回结构体类型 t 中按 tagName 标签命名的字段，没有标签时使用字段名，没有标签的匿名结构体字段会被展开
+func keyFields(t reflect.Type, tagName string) []keyField {
+	var fields []keyField
+	for i := 0; i < t.NumField(); i++ {
+		sf := t.Field(i)
+		tag, hasTag := sf.Tag.Lookup(tagName)
+		if tag == "-" {
+			continue
+		}
+		if sf.Anonymous && !hasTag && sf.Type.Kind() == reflect.Struct {
+			for _, f := range keyFields(sf.Type, tagName) {
+				f.index = append([]int{i}, f.index...)
+				fields = append(fields, f)
+			}
+			continue
+		}
+		if !sf.IsExported() {
+			continue
+		}
+		name, opts, _ := strings.Cut(tag, ",")
+		if name = strings.TrimSpace(name); name == "" {
+			name = sf.Name
+		}
+		fields = append(fields, keyField{name: name, index: []int{i}, omitEmpty: slices.Contains(strings.Split(opts, ","), "omitempty")})
+	}
+	return fields
+}
+
+// keyValues 将 map[string]T 或结构体转换为键值对
+func keyValues(v any) (map[string]string, error) {
+	rv := reflect.ValueOf(v)
+	for rv.Kind() == reflect.Ptr || rv.Kind() == reflect.Interface {
+		if rv.IsNil() {
+			return nil, errors.New("cannot marshal a nil value")
+		}
+		rv = rv.Elem()
+	}
+
+	kv := make(map[string]string)
+	switch {
+	case rv.Kind() == reflect.Map && rv.Type().Key().Kind() == reflect.String:
+		for iter := rv.MapRange(); iter.Next(); {
+			value, err := formatEnvValue(iter.Value())
+			if err != nil {
+				return nil, fmt.Errorf("key %s: %w", iter.Key().String(), err)
+			}
+			kv[iter.Key().String()] = value
+		}
+	case rv.Kind() == reflect.Struct:
+		for _, f := range keyFields(rv.Type(), envTag) {
+			field := rv.FieldByIndex(f.index)
+			if f.omitEmpty && field.IsZero() {
+				continue
+			}
+			value, err := formatEnvValue(field)
+			if err != nil {
+				return nil, fmt.Errorf("field %s: %w", f.name, err)
+			}
+			kv[f.name] = value
+		}
+	default:
+		return nil, fmt.Errorf("cannot marshal %T, want a map with string keys or a struct", v)
+	}
+	return kv, nil
+}
+
+var durationType = reflect.TypeOf(time.Duration(0))
+
+// formatEnvValue 将基本类型、time.Duration 或实现了 encoding.TextMarshaler 的值格式化为字符串
+func formatEnvValue(v reflect.Value) (string, error) {
+	for v.Kind() == reflect.Interface || v.Kind() == reflect.Ptr {
+		if v.IsNil() {
+			return "", nil
+		}
+		if m, ok := v.Interface().(encoding.TextMarshaler); ok {
+			text, err := m.MarshalText()
+			return string(text), err
+		}
+		v = v.Elem()
+	}
+	if m, ok := v.Interface().(encoding.TextMarshaler); ok {
+		text, err := m.MarshalText()
+		return string(text), err
+	}
+	if v.Type() == durationType {
+		return time.Duration(v.Int()).String(), nil
+	}
+	switch v.Kind() {
+	case reflect.String:
+		return v.String(), nil
+	case reflect.Bool:
+		return strconv.FormatBool(v.Bool()), nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return strconv.FormatInt(v.Int(), 10), nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return strconv.FormatUint(v.Uint(), 10), nil
+	case reflect.Float32, reflect.Float64:
+		return strconv.FormatFloat(v.Float(), 'g', -1, v.Type().Bits()), nil
+	}
+	return "", fmt.Errorf("unsupported type %s", v.Type())
+}
+
+// assignKeyValues 将键值对写入 out 指向的 map[string]string、结构体或 any
+// 结构体中没有对应键的字段保持不变，没有对应字段的键被忽略
+func assignKeyValues(kv map[string]string, out any) error {
+	rv := reflect.ValueOf(out)
+	if rv.Kind() != reflect.Ptr || rv.IsNil() {
+		return fmt.Errorf("out must be a non-nil pointer, got %T", out)
+	}
+	target := rv.Elem()
+
+	switch {
+	case target.Kind() == reflect.Interface && target.NumMethod() == 0:
+		target.Set(reflect.ValueOf(kv))
+	case target.Kind() == reflect.Map && target.Type().Key().Kind() == reflect.String && target.Type().Elem().Kind() == reflect.String:
+		if target.IsNil() {
+			target.Set(reflect.MakeMapWithSize(target.Type(), len(kv)))
+		}
+		for key, value := range kv {
+			target.SetMapIndex(reflect.ValueOf(key).Convert(target.Type().Key()), reflect.ValueOf(value).Convert(target.Type().Elem()))
+		}
+	case target.Kind() == reflect.Struct:
+		return assignFields(target, kv, envTag)
+	default:
+		return fmt.Errorf("cannot unmarshal into %T, want a pointer to map[string]string or a struct", out)
+	}
+	return nil
+}
+
+// assignFields 将 kv 中的值赋给结构体 target 中按 tagName 标签命名的字段，没有对应键的字段保持不变
+func assignFields(target reflect.Value, kv map[string]string, tagName string) error {
+	for _, f := range keyFields(target.Type(), tagName) {
+		value, ok := kv[f.name]
+		if !ok {
+			continue
+		}
+		if err := parseEnvValue(target.FieldByIndex(f.index), value); err != nil {
+			return fmt.Errorf("key %s: %w", f.name, err)
+		}
+	}
+	return nil
+}
+
+// parseEnvValue 将 value 解析后赋给基本类型、time.Duration 或实现了 encoding.TextUnmarshaler 的字段
+func parseEnvValue(field reflect.Value, value string) error {
+	if field.Kind() == reflect.Ptr {
+		if field.IsNil() {
+			field.Set(reflect.New(field.Type().Elem()))
+		}
+		field = field.Elem()
+	}
+	if u, ok := field.Addr().Interface().(encoding.TextUnmarshaler); ok {
+		return u.UnmarshalText([]byte(value))
+	}
+	if field.Type() == durationType {
+		d, err := time.ParseDuration(value)
+		if err != nil {
+			return err
+		}
+		field.SetInt(int64(d))
+		return nil
+	}
+
+	switch field.Kind() {
+	case reflect.String:
+		field.SetString(value)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(value)
+		if err != nil {
+			return err
+		}
+		field.SetBool(b)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		n, err := strconv.ParseInt(value, 10, field.Type().Bits())
+		if err != nil {
+			return err
+		}
+		field.SetInt(n)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		n, err := strconv.ParseUint(value, 10, field.Type().Bits())
+		if err != nil {
+			return err
+		}
+		field.SetUint(n)
+	case reflect.Float32, reflect.Float64:
+		f, err := strconv.ParseFloat(value, field.Type().Bits())
+		if err != nil {
+			return err
+		}
+		field.SetFloat(f)
+	default:
+		return fmt.Errorf("unsupported type %s", field.Type())
+	}
+	return nil
+}
@@ -0,0 +1,99 @@
+package fs
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// ErrDecryptFailed 表示无法解密文件，密钥错误、文件被篡改或格式不对时都会返回它
+var ErrDecryptFailed = errors.New("decrypt failed")
+
+const (
+	// encExt 是加密文件的后缀名，格式按它之前的后缀名选择
+	encExt = ".enc"
+	// encVersion 是加密文件格式的版本，写在文件的第一个字节
+	encVersion byte = 1
+)
+
+// WriteEncryptedFile 与 WriteFile 相同，但将序列化（及压缩）后的数据以 AES-256-GCM 加密后写入，key 须为 32 字节
+// 文件内容为版本号、随机 nonce 和密文；path 以 .enc 结尾时按它之前的后缀名选择格式，例如 data.json.gz.enc
+func WriteEncryptedFile(path string, data any, key []byte, opts ...Option) error {
+	_, err := WriteEncryptedFilePath(path, data, key, opts...)
+	return err
+}
+
+// WriteEncryptedFilePath 与 WriteEncryptedFile 相同，但返回替换时间戳后的文件名
+func WriteEncryptedFilePath(path string, data any, key []byte, opts ...Option) (string, error) {
+	gcm, err := newGCM(key)
+	if err != nil {
+		return "", err
+	}
+	o := newOptions(opts)
+	bs, err := o.marshalData(strings.TrimSuffix(path, encExt), data)
+	if err != nil {
+		return "", err
+	}
+
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err = rand.Read(nonce); err != nil {
+		return "", fmt.Errorf("generate nonce: %w", err)
+	}
+	header := append([]byte{encVersion}, nonce...)
+	sealed := gcm.Seal(header, nonce, bs, header[:1])
+
+	var filename string
+	err = o.lockWrite(path, func() error {
+		if filename, err = timestampFileName(path); err != nil {
+			return err
+		}
+		return o.writeNamed(filename, sealed)
+	})
+	return filename, err
+}
+
+// ReadEncryptedFile 读取 WriteEncryptedFile 写入的最新文件，解密后按 .enc 之前的后缀名解码到 out
+// 密钥错误或文件被篡改时返回 ErrDecryptFailed
+func ReadEncryptedFile(path string, out any, key []byte, opts ...Option) error {
+	gcm, err := newGCM(key)
+	if err != nil {
+		return err
+	}
+	o := newOptions(opts)
+	filename, err := GetLatestFileByName(path, o.listOpts...)
+	if err != nil {
+		return fmt.Errorf("get latest file: %w", err)
+	}
+	data, err := o.readAll(osFS{}, filename)
+	if err != nil {
+		return err
+	}
+
+	n := 1 + gcm.NonceSize()
+	if len(data) < n+gcm.Overhead() {
+		return fmt.Errorf("%s: %w: file too short", filename, ErrDecryptFailed)
+	}
+	if data[0] != encVersion {
+		return fmt.Errorf("%s: %w: unknown version %d", filename, ErrDecryptFailed, data[0])
+	}
+	plain, err := gcm.Open(nil, data[1:n], data[n:], data[:1])
+	if err != nil {
+		return fmt.Errorf("%s: %w", filename, ErrDecryptFailed)
+	}
+	return o.decodeBytes(strings.TrimSuffix(filename, encExt), plain, out)
+}
+
+// newGCM 以 key 创建 AES-256-GCM
+func newGCM(key []byte) (cipher.AEAD, error) {
+	if len(key) != 32 {
+		return nil, fmt.Errorf("key must be 32 bytes for AES-256, got %d", len(key))
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
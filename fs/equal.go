@@ -0,0 +1,158 @@
+package fs
+
+import (
+	"fmt"
+	"reflect"
+	"slices"
+	"strconv"
+	"time"
+)
+
+// FilesEqual 按后缀名解码与 pathA、pathB 匹配的最新文件，比较两者的内容是否相同，不相同时返回第一处差异的描述
+// 比较不受 JSON 键的顺序、CSV 列的顺序和空白的影响；数字按数值比较，时间按 RFC 3339 字符串比较，
+// 因此不同格式的文件（如 a.json 和 a.yaml）解码为相同的结构时也视为相同
+// CSV 和 NDJSON 文件按行解码为对象的列表；XML 没有通用的结构，不支持比较
+func FilesEqual(pathA, pathB string) (bool, string, error) {
+	a, err := readGeneric(pathA)
+	if err != nil {
+		return false, "", err
+	}
+	b, err := readGeneric(pathB)
+	if err != nil {
+		return false, "", err
+	}
+	if diff := firstDiff("$", a, b); diff != "" {
+		return false, diff, nil
+	}
+	return true, "", nil
+}
+
+// readGeneric 将与 path 匹配的最新文件解码为由 map[string]any、[]any 和标量组成的通用结构
+func readGeneric(path string) (any, error) {
+	filename, err := GetLatestFileByName(path)
+	if err != nil {
+		return nil, fmt.Errorf("get latest file: %w", err)
+	}
+	var v any
+	switch ext, _ := formatExt(filename); {
+	case ext == ".csv" || isNDJSON(ext):
+		// 表格格式只能解码到切片，CSV 的单元格按 csv 包的规则推断类型
+		var rows []any
+		err = ReadFile(filename, &rows)
+		v = rows
+	case ext == ".xml":
+		return nil, fmt.Errorf("%w: cannot compare %s", ErrUnsupportedFormat, ext)
+	default:
+		err = ReadFile(filename, &v)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", filename, err)
+	}
+	return normalizeValue(reflect.ValueOf(v)), nil
+}
+
+// normalizeValue 将 v 转换为可比较的通用结构：键为字符串的 map 转为 map[string]any，切片和数组转为 []any，
+// 数字转为 float64，time.Time 转为 RFC 3339 字符串
+func normalizeValue(v reflect.Value) any {
+	for v.Kind() == reflect.Interface || v.Kind() == reflect.Ptr {
+		if v.IsNil() {
+			return nil
+		}
+		v = v.Elem()
+	}
+	if t, ok := v.Interface().(time.Time); ok {
+		return t.Format(time.RFC3339Nano)
+	}
+	switch v.Kind() {
+	case reflect.Map:
+		m := make(map[string]any, v.Len())
+		for iter := v.MapRange(); iter.Next(); {
+			m[fmt.Sprint(normalizeValue(iter.Key()))] = normalizeValue(iter.Value())
+		}
+		return m
+	case reflect.Slice, reflect.Array:
+		if v.Kind() == reflect.Slice && v.Type().Elem().Kind() == reflect.Uint8 {
+			return string(v.Bytes())
+		}
+		s := make([]any, v.Len())
+		for i := range s {
+			s[i] = normalizeValue(v.Index(i))
+		}
+		return s
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return float64(v.Int())
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return float64(v.Uint())
+	case reflect.Float32, reflect.Float64:
+		return v.Float()
+	}
+	return v.Interface()
+}
+
+// firstDiff 按键名顺序比较 normalizeValue 得到的 a 和 b，返回以 path 表示位置的第一处差异，相同时返回空字符串
+func firstDiff(path string, a, b any) string {
+	switch a := a.(type) {
+	case map[string]any:
+		b, ok := b.(map[string]any)
+		if !ok {
+			break
+		}
+		keys := make([]string, 0, len(a)+len(b))
+		for k := range a {
+			keys = append(keys, k)
+		}
+		for k := range b {
+			if _, ok := a[k]; !ok {
+				keys = append(keys, k)
+			}
+		}
+		slices.Sort(keys)
+		for _, k := range keys {
+			va, inA := a[k]
+			vb, inB := b[k]
+			switch {
+			case !inA:
+				return fmt.Sprintf("%s.%s: missing in A, B has %s", path, k, describeValue(vb))
+			case !inB:
+				return fmt.Sprintf("%s.%s: missing in B, A has %s", path, k, describeValue(va))
+			}
+			if diff := firstDiff(path+"."+k, va, vb); diff != "" {
+				return diff
+			}
+		}
+		return ""
+	case []any:
+		b, ok := b.([]any)
+		if !ok {
+			break
+		}
+		for i := range min(len(a), len(b)) {
+			if diff := firstDiff(path+"["+strconv.Itoa(i)+"]", a[i], b[i]); diff != "" {
+				return diff
+			}
+		}
+		if len(a) != len(b) {
+			return fmt.Sprintf("%s: A has %d elements, B has %d", path, len(a), len(b))
+		}
+		return ""
+	}
+	if reflect.DeepEqual(a, b) {
+		return ""
+	}
+	return fmt.Sprintf("%s: A has %s, B has %s", path, describeValue(a), describeValue(b))
+}
+
+// describeValue 返回差异描述中值的写法，字符串带引号
+func describeValue(v any) string {
+	switch v := v.(type) {
+	case nil:
+		return "null"
+	case string:
+		return strconv.Quote(v)
+	case map[string]any:
+		return fmt.Sprintf("an object with %d keys", len(v))
+	case []any:
+		return fmt.Sprintf("a list of %d elements", len(v))
+	}
+	return fmt.Sprint(v)
+}
@@ -0,0 +1,37 @@
+package fs
+
+import (
+	"errors"
+	"os"
+)
+
+var (
+	// ErrNoMatch 表示没有与模式匹配的文件，errors.Is(err, os.ErrNotExist) 对它同样成立
+	ErrNoMatch error = noMatchError{}
+	// ErrUnsupportedFormat 表示无法根据后缀名选择序列化方式
+	ErrUnsupportedFormat = errors.New("unsupported file format")
+	// ErrHeaderMismatch 表示 CSV 文件的表头与预期的列不一致
+	ErrHeaderMismatch = errors.New("header mismatch")
+	// ErrPatternTooBroad 表示模式过于宽泛，RotateFiles 拒绝执行
+	ErrPatternTooBroad = errors.New("pattern is too broad")
+	// ErrChecksumMismatch 表示文件内容与校验文件中的摘要不一致
+	ErrChecksumMismatch = errors.New("checksum mismatch")
+	// ErrChecksumMissing 表示要求校验时校验文件不存在
+	ErrChecksumMissing = errors.New("checksum file missing")
+	// ErrFileTooLarge 表示文件超过了 WithMaxSize 或 DefaultMaxSize 允许的大小
+	ErrFileTooLarge = errors.New("file too large")
+	// ErrPathTraversal 表示 SafeJoin 拼接的路径超出了目录
+	ErrPathTraversal = errors.New("path escapes directory")
+	// ErrInsufficientSpace 表示目标文件系统的可用空间少于 WithMinFreeSpace 的要求
+	ErrInsufficientSpace = errors.New("insufficient disk space")
+)
+
+type noMatchError struct{}
+
+func (noMatchError) Error() string {
+	return "no matching files found"
+}
+
+func (noMatchError) Is(target error) bool {
+	return target == os.ErrNotExist
+}
@@ -0,0 +1,121 @@
+package fs
+
+import (
+	"context"
+	iofs "io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"time"
+)
+
+// FileEntry 描述一个与模式匹配的文件
+type FileEntry struct {
+	Path    string
+	Info    os.FileInfo
+	Size    int64
+	ModTime time.Time
+	// Timestamp 是文件名中 20060102_150405 格式的时间戳，HasTimestamp 为 false 时为零值
+	Timestamp    time.Time
+	HasTimestamp bool
+}
+
+// nameTime 返回文件名中的时间戳，没有时返回修改时间
+func (e FileEntry) nameTime() time.Time {
+	if e.HasTimestamp {
+		return e.Timestamp
+	}
+	return e.ModTime
+}
+
+// newFileEntry 以 path 及其文件信息 info 构造 FileEntry，并解析文件名中的时间戳
+func newFileEntry(path string, info iofs.FileInfo) FileEntry {
+	entry := FileEntry{Path: path, Info: info, Size: info.Size(), ModTime: info.ModTime()}
+	entry.Timestamp, entry.HasTimestamp = ParseTimestampFromName(path)
+	return entry
+}
+
+// StatEntry 返回 path 的 FileEntry，包含文件信息及文件名中解析出的时间戳，path 不作为模式匹配
+func StatEntry(path string) (FileEntry, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return FileEntry{}, err
+	}
+	return newFileEntry(path, info), nil
+}
+
+// firstEntry 返回 entries 中按 less 排在最前的文件，相同时取先出现者
+func firstEntry(entries []FileEntry, less func(a, b FileEntry) bool) (FileEntry, error) {
+	if len(entries) == 0 {
+		return FileEntry{}, ErrNoMatch
+	}
+	first := entries[0]
+	for _, entry := range entries[1:] {
+		if less(entry, first) {
+			first = entry
+		}
+	}
+	return first, nil
+}
+
+// GetFileBy 获取与 pattern 匹配的文件中按 less 排在最前的文件，例如 less 为“a 比 b 新”时返回最新的文件
+// less 相同的文件中取文件名自然顺序（见 NaturalLess）最小者
+// opts 可在选择前过滤文件，例如 WithMinSize、WithMinAge，没有满足条件的文件时返回 ErrNoMatch
+func GetFileBy(pattern string, less func(a, b FileEntry) bool, opts ...ListOption) (string, error) {
+	return getFileBy(context.Background(), osFS{}, pattern, less, opts)
+}
+
+// getFileBy 获取 fsys 中与 pattern 匹配的文件中按 less 排在最前的文件
+func getFileBy(ctx context.Context, fsys iofs.FS, pattern string, less func(a, b FileEntry) bool, opts []ListOption) (string, error) {
+	entry, err := getEntryBy(ctx, fsys, pattern, less, opts)
+	return entry.Path, err
+}
+
+// getEntryBy 与 getFileBy 相同，但返回文件的 FileEntry
+func getEntryBy(ctx context.Context, fsys iofs.FS, pattern string, less func(a, b FileEntry) bool, opts []ListOption) (FileEntry, error) {
+	entries, err := listFiles(ctx, fsys, pattern, opts)
+	if err != nil {
+		return FileEntry{}, err
+	}
+	return firstEntry(entries, less)
+}
+
+// newerByName 比较文件名中的时间戳（没有时使用修改时间），时间相同时文件名自然顺序较大者更新
+func newerByName(a, b FileEntry) bool {
+	ta, tb := a.nameTime(), b.nameTime()
+	return ta.After(tb) || (ta.Equal(tb) && NaturalLess(b.Path, a.Path))
+}
+
+// newerByModTime 比较修改时间，同一时钟周期内写入的文件修改时间相同，此时文件名自然顺序较大者更新
+func newerByModTime(a, b FileEntry) bool {
+	ta, tb := a.ModTime, b.ModTime
+	return ta.After(tb) || (ta.Equal(tb) && NaturalLess(b.Path, a.Path))
+}
+
+// GetOldestFileByModTime 获取修改时间最早的文件
+func GetOldestFileByModTime(pattern string, opts ...ListOption) (string, error) {
+	return GetFileBy(pattern, func(a, b FileEntry) bool {
+		return a.ModTime.Before(b.ModTime)
+	}, opts...)
+}
+
+// GetLargestFile 获取最大的文件
+func GetLargestFile(pattern string, opts ...ListOption) (string, error) {
+	return GetFileBy(pattern, func(a, b FileEntry) bool {
+		return a.Size > b.Size
+	}, opts...)
+}
+
+// GetLatestFileMatching 获取与 pattern 匹配且文件名（不含目录）与 re 匹配的最新文件，规则与 GetLatestFileByName 相同
+func GetLatestFileMatching(pattern string, re *regexp.Regexp, opts ...ListOption) (string, error) {
+	entries, err := ListFiles(pattern, opts...)
+	if err != nil {
+		return "", err
+	}
+	entries = slices.DeleteFunc(entries, func(e FileEntry) bool {
+		return !re.MatchString(filepath.Base(e.Path))
+	})
+	entry, err := firstEntry(entries, newerByName)
+	return entry.Path, err
+}
@@ -0,0 +1,241 @@
+package fs
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+
+	"github.com/0xuLiang/lancet/csv"
+	"gopkg.in/yaml.v3"
+)
+
+// JSONOptions 是 .json 文件的格式选项，零值与 encoding/json 的默认行为相同
+type JSONOptions struct {
+	// Indent 非空时以该缩进输出，例如 "  "，默认输出紧凑格式
+	Indent string
+	// DisallowUnknownFields 为 true 时解码到结构体遇到没有对应字段的键会报错
+	DisallowUnknownFields bool
+}
+
+// YAMLOptions 是 .yaml、.yml 文件的格式选项，零值与 yaml 包的默认行为相同
+type YAMLOptions struct {
+	// Indent 是输出时每一级缩进的空格数，为 0 时使用 yaml 包的默认值 4
+	Indent int
+	// KnownFields 为 true 时解码到结构体遇到没有对应字段的键会报错
+	KnownFields bool
+}
+
+var (
+	formatDefaultsMu sync.RWMutex
+	// formatDefaults 以 formatKey 为键保存 SetFormatOptions 注册的选项
+	formatDefaults = map[string]any{}
+)
+
+// SetFormatOptions 注册后缀名 ext 对应格式的默认选项，此后所有按后缀名选择格式的读写都会使用，可并发调用
+//   - .csv：csv.Option 或 []csv.Option，例如 csv.Options 或 csv.WithIntBase(csv.IntBaseAuto)
+//   - .json：JSONOptions
+//   - .yaml、.yml：YAMLOptions，两个后缀名共用一份设置
+//
+// opts 为 nil 时删除已注册的选项。单次调用可通过 WithCSVOptions、WithJSONOptions、WithYAMLOptions 覆盖：
+// CSV 的选项追加在注册的选项之后，JSON、YAML 的选项整体替换注册的选项
+// ext 不支持或 opts 的类型与格式不符时 panic
+func SetFormatOptions(ext string, opts any) {
+	key := formatKey(ext)
+	switch v := opts.(type) {
+	case nil:
+		formatDefaultsMu.Lock()
+		delete(formatDefaults, key)
+		formatDefaultsMu.Unlock()
+		return
+	case csv.Option:
+		if key == ".csv" {
+			opts = []csv.Option{v}
+		}
+	}
+
+	var ok bool
+	switch key {
+	case ".csv":
+		_, ok = opts.([]csv.Option)
+	case ".json":
+		_, ok = opts.(JSONOptions)
+	case ".yaml":
+		_, ok = opts.(YAMLOptions)
+	default:
+		panic(fmt.Sprintf("fs: SetFormatOptions does not support %s", ext))
+	}
+	if !ok {
+		panic(fmt.Sprintf("fs: SetFormatOptions(%q): unexpected options type %T", ext, opts))
+	}
+
+	formatDefaultsMu.Lock()
+	formatDefaults[key] = opts
+	formatDefaultsMu.Unlock()
+}
+
+// formatKey 返回 ext 在 formatDefaults 中的键，补齐开头的 . 并将 .yml 视为 .yaml
+func formatKey(ext string) string {
+	if ext != "" && !strings.HasPrefix(ext, ".") {
+		ext = "." + ext
+	}
+	if ext == ".yml" {
+		return ".yaml"
+	}
+	return ext
+}
+
+// formatDefault 返回 SetFormatOptions 为 ext 注册的选项
+func formatDefault(ext string) (any, bool) {
+	formatDefaultsMu.RLock()
+	defer formatDefaultsMu.RUnlock()
+	opts, ok := formatDefaults[formatKey(ext)]
+	return opts, ok
+}
+
+// WithCSVOptions 为本次调用追加 CSV 的编解码选项，在 SetFormatOptions 注册的选项之后生效
+func WithCSVOptions(opts ...csv.Option) Option {
+	return func(o *options) {
+		o.csvOpts = append(o.csvOpts, opts...)
+	}
+}
+
+// WithJSONOptions 为本次调用设置 JSON 的格式选项，代替 SetFormatOptions 注册的选项
+func WithJSONOptions(opts JSONOptions) Option {
+	return func(o *options) {
+		o.jsonOpts = &opts
+	}
+}
+
+// WithYAMLOptions 为本次调用设置 YAML 的格式选项，代替 SetFormatOptions 注册的选项
+func WithYAMLOptions(opts YAMLOptions) Option {
+	return func(o *options) {
+		o.yamlOpts = &opts
+	}
+}
+
+// csvOptions 返回注册的 CSV 选项及本次调用追加的选项
+func (o *options) csvOptions() []csv.Option {
+	var opts []csv.Option
+	if v, ok := formatDefault(".csv"); ok {
+		opts = append(opts, v.([]csv.Option)...)
+	}
+	return append(opts, o.csvOpts...)
+}
+
+// jsonOptions 返回本次调用或注册的 JSON 选项
+func (o *options) jsonOptions() JSONOptions {
+	if o.jsonOpts != nil {
+		return *o.jsonOpts
+	}
+	v, _ := formatDefault(".json")
+	opts, _ := v.(JSONOptions)
+	return opts
+}
+
+// yamlOptions 返回本次调用或注册的 YAML 选项
+func (o *options) yamlOptions() YAMLOptions {
+	if o.yamlOpts != nil {
+		return *o.yamlOpts
+	}
+	v, _ := formatDefault(".yaml")
+	opts, _ := v.(YAMLOptions)
+	return opts
+}
+
+// marshalCSV 按 csvOptions 序列化 CSV，extra 追加在最后
+func (o *options) marshalCSV(extra ...csv.Option) marshal {
+	opts := append(o.csvOptions(), extra...)
+	if len(opts) == 0 {
+		return csv.Marshal
+	}
+	return func(v any) ([]byte, error) {
+		return csv.MarshalWithOptions(v, opts...)
+	}
+}
+
+// marshalStableCSV 以固定列的方式序列化 CSV，表头只取决于类型而与数据无关
+func (o *options) marshalStableCSV() marshal {
+	return o.marshalCSV(csv.WithStableColumns())
+}
+
+// unmarshalCSV 按 csvOptions 解析 CSV
+func (o *options) unmarshalCSV() unmarshal {
+	opts := o.csvOptions()
+	if len(opts) == 0 {
+		return csv.Unmarshal
+	}
+	return func(data []byte, v any) error {
+		return csv.UnmarshalWithOptions(data, v, opts...)
+	}
+}
+
+// marshalJSON 按 jsonOptions 序列化 JSON
+func (o *options) marshalJSON() marshal {
+	opts := o.jsonOptions()
+	if opts.Indent == "" {
+		return json.Marshal
+	}
+	return func(v any) ([]byte, error) {
+		return json.MarshalIndent(v, "", opts.Indent)
+	}
+}
+
+// unmarshalJSON 按 jsonOptions 解析 JSON
+func (o *options) unmarshalJSON() unmarshal {
+	opts := o.jsonOptions()
+	if !opts.DisallowUnknownFields {
+		return json.Unmarshal
+	}
+	return func(data []byte, v any) error {
+		dec := json.NewDecoder(bytes.NewReader(data))
+		dec.DisallowUnknownFields()
+		if err := dec.Decode(v); err != nil {
+			return err
+		}
+		if dec.More() {
+			return errors.New("invalid character after top-level value")
+		}
+		return nil
+	}
+}
+
+// marshalYAML 按 yamlOptions 序列化 YAML
+func (o *options) marshalYAML() marshal {
+	opts := o.yamlOptions()
+	if opts.Indent == 0 {
+		return yaml.Marshal
+	}
+	return func(v any) ([]byte, error) {
+		var buf bytes.Buffer
+		enc := yaml.NewEncoder(&buf)
+		enc.SetIndent(opts.Indent)
+		if err := enc.Encode(v); err != nil {
+			return nil, err
+		}
+		if err := enc.Close(); err != nil {
+			return nil, err
+		}
+		return buf.Bytes(), nil
+	}
+}
+
+// unmarshalYAML 按 yamlOptions 解析 YAML
+func (o *options) unmarshalYAML() unmarshal {
+	opts := o.yamlOptions()
+	if !opts.KnownFields {
+		return yaml.Unmarshal
+	}
+	return func(data []byte, v any) error {
+		dec := yaml.NewDecoder(bytes.NewReader(data))
+		dec.KnownFields(true)
+		// 与 yaml.Unmarshal 相同，空文档不是错误
+		if err := dec.Decode(v); err != nil && err != io.EOF {
+			return err
+		}
+		return nil
+	}
+}
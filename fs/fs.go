@@ -1,79 +1,342 @@
 package fs
 
 import (
-	"encoding/json"
+	"bytes"
+	"context"
+	"encoding/xml"
 	"errors"
 	"fmt"
+	iofs "io/fs"
+	"math/rand/v2"
 	"os"
 	"path/filepath"
+	"regexp"
+	"slices"
 	"sort"
 	"strings"
 	"time"
 
 	"github.com/0xuLiang/lancet/csv"
 	"github.com/gookit/goutil/fsutil"
-	"gopkg.in/yaml.v3"
+	"github.com/pelletier/go-toml/v2"
 )
 
 // ReadJsonFile 从最新的 JSON 文件中读取数据
-func ReadJsonFile(path string, out any) error {
-	return ReadFile(path, out, json.Unmarshal)
+func ReadJsonFile(path string, out any, opts ...Option) error {
+	return ReadFileWith(path, out, append(opts, WithUnmarshal(newOptions(opts).unmarshalJSON()))...)
 }
 
-// ReadCSVFile 从最新的 CSV 文件中读取数据
-func ReadCSVFile(path string, out any) error {
-	return ReadFile(path, out, csv.Unmarshal)
+// ReadCSVFile 从最新的 CSV 文件中读取数据，文件以流的方式解码，不会一次性读入内存；gzip 压缩的文件会自动解压
+func ReadCSVFile(path string, out any, opts ...Option) error {
+	o := newOptions(opts)
+	return o.retry(o.ctx, func() error {
+		filename, err := getFileBy(o.ctx, osFS{}, path, newerByName, o.listOpts)
+		if err != nil {
+			return fmt.Errorf("get latest file: %w", err)
+		}
+		if err = o.readCSVFile(osFS{}, filename, out); err != nil {
+			return o.fileError("read", filename, err)
+		}
+		return nil
+	})
+}
+
+// readCSVFile 以流的方式解码 fsys 中的 CSV 文件 filename 到 out
+func (o *options) readCSVFile(fsys iofs.FS, filename string, out any) error {
+	f, err := fsys.Open(filename)
+	if err != nil {
+		return fmt.Errorf("read file: %w", err)
+	}
+	defer f.Close()
+
+	r, err := gunzipReader(o.fileReader(f))
+	if err != nil {
+		return fmt.Errorf("read file: %w", err)
+	}
+	if r, err = o.charsetReader(r); err != nil {
+		return fmt.Errorf("read file: %w", err)
+	}
+	if err = csv.UnmarshalFrom(r, out, o.csvOptions()...); err != nil {
+		return fmt.Errorf("unmarshal data: %w", err)
+	}
+	return nil
+}
+
+// ReadNDJSONFile 从最新的 NDJSON 文件中逐行读取数据到 out 指向的切片，文件以流的方式解码
+func ReadNDJSONFile(path string, out any, opts ...Option) error {
+	o := newOptions(opts)
+	return o.retry(o.ctx, func() error {
+		filename, err := getFileBy(o.ctx, osFS{}, path, newerByName, o.listOpts)
+		if err != nil {
+			return fmt.Errorf("get latest file: %w", err)
+		}
+		if err = o.readNDJSONFile(osFS{}, filename, out); err != nil {
+			return o.fileError("read", filename, err)
+		}
+		return nil
+	})
 }
 
 // ReadYAMLFile 从最新的 YAML 文件中读取数据
-func ReadYAMLFile(path string, out any) error {
-	return ReadFile(path, out, yaml.Unmarshal)
+func ReadYAMLFile(path string, out any, opts ...Option) error {
+	return ReadFileWith(path, out, append(opts, WithUnmarshal(newOptions(opts).unmarshalYAML()))...)
 }
 
 // WriteJsonFile 将 data 写入到 JSON 文件中，如果 path 中包含 *，则会替换为当前时间戳（格式为 20060102_150405）
-func WriteJsonFile(path string, data any) error {
-	return WriteFile(path, data, json.Marshal)
+func WriteJsonFile(path string, data any, opts ...Option) error {
+	_, err := WriteJsonFilePath(path, data, opts...)
+	return err
+}
+
+// WriteJsonFilePath 与 WriteJsonFile 相同，但返回替换时间戳后的文件名
+func WriteJsonFilePath(path string, data any, opts ...Option) (string, error) {
+	return WriteFilePath(path, data, append([]Option{WithMarshal(newOptions(opts).marshalJSON())}, opts...)...)
+}
+
+// WriteNDJSONFile 将切片 data 写入到 NDJSON 文件中，每个元素占一行，如果 path 中包含 *，则会替换为当前时间戳（格式为 20060102_150405）
+func WriteNDJSONFile(path string, data any, opts ...Option) error {
+	_, err := WriteNDJSONFilePath(path, data, opts...)
+	return err
+}
+
+// WriteNDJSONFilePath 与 WriteNDJSONFile 相同，但返回替换时间戳后的文件名
+func WriteNDJSONFilePath(path string, data any, opts ...Option) (string, error) {
+	return WriteFilePath(path, data, append([]Option{WithMarshal(MarshalNDJSON)}, opts...)...)
 }
 
 // WriteCSVFile 将 data 写入到 CSV 文件中，如果 path 中包含 *，则会替换为当前时间戳（格式为 20060102_150405）
-func WriteCSVFile(path string, data any) error {
-	return WriteFile(path, data, csv.Marshal)
+// 若已存在与 path 匹配的文件，则始终输出 omitempty 列，保证多个文件的表头一致
+func WriteCSVFile(path string, data any, opts ...Option) error {
+	_, err := WriteCSVFilePath(path, data, opts...)
+	return err
+}
+
+// WriteCSVFilePath 与 WriteCSVFile 相同，但返回替换时间戳后的文件名
+func WriteCSVFilePath(path string, data any, opts ...Option) (string, error) {
+	o := newOptions(opts)
+	if _, err := GetLatestFileByName(path); err == nil {
+		return WriteFilePath(path, data, append([]Option{WithMarshal(o.marshalStableCSV())}, opts...)...)
+	}
+	return WriteFilePath(path, data, append([]Option{WithMarshal(o.marshalCSV())}, opts...)...)
 }
 
 // WriteYAMLFile 将 data 写入到 YAML 文件中，如果 path 中包含 *，则会替换为当前时间戳（格式为 20060102_150405）
-func WriteYAMLFile(path string, data any) error {
-	return WriteFile(path, data, yaml.Marshal)
+func WriteYAMLFile(path string, data any, opts ...Option) error {
+	_, err := WriteYAMLFilePath(path, data, opts...)
+	return err
+}
+
+// WriteYAMLFilePath 与 WriteYAMLFile 相同，但返回替换时间戳后的文件名
+func WriteYAMLFilePath(path string, data any, opts ...Option) (string, error) {
+	return WriteFilePath(path, data, append([]Option{WithMarshal(newOptions(opts).marshalYAML())}, opts...)...)
+}
+
+// ReadTOMLFile 从最新的 TOML 文件中读取数据
+func ReadTOMLFile(path string, out any, opts ...Option) error {
+	return ReadFileWith(path, out, append(opts, WithUnmarshal(toml.Unmarshal))...)
+}
+
+// WriteTOMLFile 将 data 写入到 TOML 文件中，如果 path 中包含 *，则会替换为当前时间戳（格式为 20060102_150405）
+func WriteTOMLFile(path string, data any, opts ...Option) error {
+	_, err := WriteTOMLFilePath(path, data, opts...)
+	return err
+}
+
+// WriteTOMLFilePath 与 WriteTOMLFile 相同，但返回替换时间戳后的文件名
+func WriteTOMLFilePath(path string, data any, opts ...Option) (string, error) {
+	return WriteFilePath(path, data, append([]Option{WithMarshal(toml.Marshal)}, opts...)...)
+}
+
+// ReadXMLFile 从最新的 XML 文件中读取数据
+func ReadXMLFile(path string, out any, opts ...Option) error {
+	return ReadFileWith(path, out, append(opts, WithUnmarshal(xml.Unmarshal))...)
+}
+
+// WriteXMLFile 将 data 写入到 XML 文件中，文件以 XML 声明开头，如果 path 中包含 *，则会替换为当前时间戳（格式为 20060102_150405）
+// 可通过 WithXMLIndent 输出缩进格式
+func WriteXMLFile(path string, data any, opts ...Option) error {
+	_, err := WriteXMLFilePath(path, data, opts...)
+	return err
+}
+
+// WriteXMLFilePath 与 WriteXMLFile 相同，但返回替换时间戳后的文件名
+func WriteXMLFilePath(path string, data any, opts ...Option) (string, error) {
+	o := newOptions(opts)
+	return WriteFilePath(path, data, append([]Option{WithMarshal(marshalXML(o.xmlIndent))}, opts...)...)
+}
+
+// marshalXML 返回以 XML 声明开头的序列化函数，indent 非空时输出缩进格式
+func marshalXML(indent string) marshal {
+	return func(v any) ([]byte, error) {
+		var bs []byte
+		var err error
+		if indent == "" {
+			bs, err = xml.Marshal(v)
+		} else {
+			bs, err = xml.MarshalIndent(v, "", indent)
+		}
+		if err != nil {
+			return nil, err
+		}
+		return append([]byte(xml.Header), append(bs, '\n')...), nil
+	}
 }
 
+// stdioPath 作为 ReadFile 的 path 时表示标准输入，作为 WriteFile 的 path 时表示标准输出
+const stdioPath = "-"
+
 type unmarshal func([]byte, any) error
 type marshal func(any) ([]byte, error)
 
+// ReadINIFile 从最新的 INI 文件中读取数据，解码规则见 UnmarshalINI
+func ReadINIFile(path string, out any, opts ...Option) error {
+	return ReadFileWith(path, out, append(opts, WithUnmarshal(UnmarshalINI))...)
+}
+
+// WriteINIFile 将 data 写入到 INI 文件中，如果 path 中包含 *，则会替换为当前时间戳（格式为 20060102_150405）
+func WriteINIFile(path string, data any, opts ...Option) error {
+	_, err := WriteINIFilePath(path, data, opts...)
+	return err
+}
+
+// WriteINIFilePath 与 WriteINIFile 相同，但返回替换时间戳后的文件名
+func WriteINIFilePath(path string, data any, opts ...Option) (string, error) {
+	return WriteFilePath(path, data, append([]Option{WithMarshal(MarshalINI)}, opts...)...)
+}
+
 // ReadFile 从最新的文件中读取数据，没有指定 unmarshal 时，会根据后缀名自动选择对应类型的 unmarshal
+// 其余行为与 ReadFileWith 相同，需要其他选项时使用 ReadFileWith
 func ReadFile(path string, out any, unmarshal ...unmarshal) error {
-	filename, err := GetLatestFileByName(path)
+	var opts []Option
+	if len(unmarshal) > 0 {
+		opts = append(opts, WithUnmarshal(unmarshal[0]))
+	}
+	return ReadFileWith(path, out, opts...)
+}
+
+// ReadFileWith 与 ReadFile 相同，但接受函数式选项，没有通过 WithUnmarshal 指定时，会根据后缀名自动选择对应类型的 unmarshal
+// 读取失败时返回的错误包含选择的文件名和格式，例如 read file report_20240102.csv (csv): ...，选择的文件也可以通过 ResolvePath 得到
+// 以 gzip 文件头开始的内容会先解压，后缀名 .gz 会被剥离，例如 data.json.gz 按 JSON 解析
+//
+// path 为 "-" 时从标准输入读取全部内容，此时没有后缀名，须通过 WithFormat 或 WithUnmarshal 指定格式，也不会查找最新的文件
+func ReadFileWith(path string, out any, opts ...Option) error {
+	return ReadFileCtx(context.Background(), path, out, opts...)
+}
+
+// ReadFileCtx 与 ReadFileWith 相同，但 ctx 结束后停止查找文件、读取文件信息和读取内容，返回的错误包含 ctx.Err()
+// 读取在块之间检查 ctx，已阻塞在文件系统上的单次读取无法中断；WithRetry 的等待也会随 ctx 结束
+func ReadFileCtx(ctx context.Context, path string, out any, opts ...Option) error {
+	opts = append(opts[:len(opts):len(opts)], withContext(ctx))
+	if path == stdioPath {
+		return newOptions(opts).readStdin(out)
+	}
+	return ReadFileFS(osFS{}, path, out, opts...)
+}
+
+// readStdin 从标准输入读取数据并反序列化到 out
+func (o *options) readStdin(out any) error {
+	ext, _ := o.formatExt(stdioPath)
+	if o.unmarshal == nil && isNDJSON(ext) {
+		if err := decodeNDJSON(os.Stdin, out); err != nil {
+			return fmt.Errorf("unmarshal data: %w", err)
+		}
+		return nil
+	}
+
+	data, err := o.readLimited(os.Stdin, "stdin")
 	if err != nil {
-		return fmt.Errorf("get latest file: %w", err)
+		return err
+	}
+	return o.unmarshalData(ext, data, out)
+}
+
+// readFile 按 o 的设置读取 fsys 中的 filename 并反序列化到 out
+func (o *options) readFile(fsys iofs.FS, filename string, out any) error {
+	if err := o.ctx.Err(); err != nil {
+		return err
+	}
+	ext, _ := o.formatExt(filename)
+	if o.unmarshal == nil && isNDJSON(ext) {
+		return o.readNDJSONFile(fsys, filename, out)
 	}
 
-	data, err := os.ReadFile(filename)
+	data, err := o.readAll(fsys, filename)
 	if err != nil {
-		return fmt.Errorf("read file: %w", err)
+		return err
+	}
+	return o.unmarshalData(ext, data, out)
+}
+
+// decodeBytes 按 name 的后缀名解码已读入内存的文件内容 data，包括 NDJSON
+func (o *options) decodeBytes(name string, data []byte, out any) error {
+	ext, _ := o.formatExt(name)
+	if o.unmarshal == nil && isNDJSON(ext) {
+		r, err := gunzipReader(bytes.NewReader(data))
+		if err != nil {
+			return fmt.Errorf("read file: %w", err)
+		}
+		if r, err = o.charsetReader(r); err != nil {
+			return fmt.Errorf("read file: %w", err)
+		}
+		if err = decodeNDJSON(r, out); err != nil {
+			return fmt.Errorf("unmarshal data: %w", err)
+		}
+		return nil
 	}
+	return o.unmarshalData(ext, data, out)
+}
 
-	if len(unmarshal) == 0 {
-		switch ext := filepath.Ext(filename); ext {
-		case ".csv":
-			unmarshal = append(unmarshal, csv.Unmarshal)
-		case ".json":
-			unmarshal = append(unmarshal, json.Unmarshal)
-		case ".yaml", ".yml":
-			unmarshal = append(unmarshal, yaml.Unmarshal)
-		default:
-			return fmt.Errorf("unsupported file format: %s", ext)
+// unmarshalFor 返回后缀名 ext 对应的 unmarshal，CSV、JSON、YAML 按 SetFormatOptions 和本次调用的选项配置，不支持时返回 nil
+func (o *options) unmarshalFor(ext string) unmarshal {
+	switch ext {
+	case ".csv":
+		return o.unmarshalCSV()
+	case ".json":
+		return o.unmarshalJSON()
+	case ".ndjson", ".jsonl":
+		return UnmarshalNDJSON
+	case ".yaml", ".yml":
+		return o.unmarshalYAML()
+	case ".toml":
+		return toml.Unmarshal
+	case ".xml":
+		return xml.Unmarshal
+	case ".env":
+		return UnmarshalDotenv
+	case ".properties":
+		return UnmarshalProperties
+	case ".ini":
+		return UnmarshalINI
+	}
+	return nil
+}
+
+// unmarshalData 解压 data 后以 o.unmarshal 或 ext 对应的格式反序列化到 out
+func (o *options) unmarshalData(ext string, data []byte, out any) error {
+	data, err := o.gunzipBytes(data)
+	if err != nil {
+		return fmt.Errorf("decompress file: %w", err)
+	}
+	if data, err = o.decodeCharset(data); err != nil {
+		return err
+	}
+
+	unmarshal := o.unmarshal
+	if unmarshal == nil {
+		unmarshal = o.unmarshalFor(ext)
+	}
+	if unmarshal == nil && o.sniffing {
+		sniffed, err := sniffFormat(data)
+		if err != nil {
+			return fmt.Errorf("%w: %s: %v", ErrUnsupportedFormat, ext, err)
 		}
+		unmarshal = o.unmarshalFor(sniffed)
+	}
+	if unmarshal == nil {
+		return fmt.Errorf("%w: %s", ErrUnsupportedFormat, ext)
 	}
 
-	if err = unmarshal[0](data, out); err != nil {
+	if err = unmarshal(data, out); err != nil {
 		return fmt.Errorf("unmarshal data: %w", err)
 	}
 
@@ -81,79 +344,442 @@ func ReadFile(path string, out any, unmarshal ...unmarshal) error {
 }
 
 // WriteFile 将 data 写入到文件中，如果 path 中包含 *，则会替换为当前时间戳（格式为 20060102_150405）
+// 没有指定 marshal 时，会根据后缀名自动选择对应类型的 marshal；其余行为与 WriteFileWith 相同，需要其他选项时使用 WriteFileWith
 func WriteFile(path string, data any, marshal ...marshal) error {
-	if len(marshal) == 0 {
-		switch ext := filepath.Ext(path); ext {
+	var opts []Option
+	if len(marshal) > 0 {
+		opts = append(opts, WithMarshal(marshal[0]))
+	}
+	return WriteFileWith(path, data, opts...)
+}
+
+// WriteFileWith 与 WriteFile 相同，但接受函数式选项，没有通过 WithMarshal 指定时，会根据后缀名自动选择对应类型的 marshal；
+// 后缀名为 .gz 时以 gzip 压缩写入，并按剥离 .gz 后的后缀名选择 marshal，压缩级别可通过 WithGzipLevel 调整
+// path 中的 {hash} 替换为最终写入的字节（压缩后）的 SHA-256 的前 12 个十六进制字符，可用 VerifyContentAddressed 校验
+// 写入失败时返回的错误包含文件名和格式，例如 write file report_20240102.csv (csv): ...
+//
+// path 为 "-" 时写入标准输出，须通过 WithFormat 或 WithMarshal 指定格式，不替换时间戳，也不支持 WithAtomic 等文件选项
+func WriteFileWith(path string, data any, opts ...Option) error {
+	return WriteFileCtx(context.Background(), path, data, opts...)
+}
+
+// WriteFileCtx 与 WriteFileWith 相同，但 ctx 结束后不再开始写入，等待 WithLock 的文件锁时也会随 ctx 结束
+// 已经开始的写入会完成，配合 WithAtomic 时不会留下写了一半的文件
+func WriteFileCtx(ctx context.Context, path string, data any, opts ...Option) error {
+	_, err := WriteFilePath(path, data, append(opts[:len(opts):len(opts)], withContext(ctx))...)
+	return err
+}
+
+// WriteFilePath 与 WriteFileWith 相同，但返回替换时间戳后的文件名，便于记录或上传实际写入的文件
+func WriteFilePath(path string, data any, opts ...Option) (string, error) {
+	o := newOptions(opts)
+	if path == stdioPath {
+		_, bs, err := o.renderFile(osFS{}, path, data)
+		if err != nil {
+			return "", err
+		}
+		if _, err = os.Stdout.Write(bs); err != nil {
+			return "", fmt.Errorf("write stdout: %w", err)
+		}
+		return stdioPath, nil
+	}
+	var filename string
+	err := o.lockWrite(path, func() (err error) {
+		filename, err = o.writeFile(osFS{o}, path, data)
+		return err
+	})
+	return filename, err
+}
+
+// RenderFile 与 WriteFilePath 相同地替换时间戳、按后缀名选择 marshal 并按需压缩，但不写入磁盘，返回文件名及将要写入的内容
+// 用于测试或命令行的 --dry-run；WriteFile 先调用同样的逻辑再保存，两者的结果始终一致
+func RenderFile(path string, data any, opts ...Option) (filename string, contents []byte, err error) {
+	return newOptions(opts).renderFile(osFS{}, path, data)
+}
+
+// renderFile 按 o 的设置序列化 data，返回替换时间戳后的文件名及序列化的内容，文件是否存在按 fsys 判断
+func (o *options) renderFile(fsys WritableFS, path string, data any) (string, []byte, error) {
+	if err := o.ctx.Err(); err != nil {
+		return "", nil, err
+	}
+	bs, err := o.marshalData(path, data)
+	if err != nil {
+		return "", nil, err
+	}
+	filename, err := expandFileName(expandHash(path, bs), existsIn(fsys), o.sanitizeNames)
+	if err != nil {
+		return "", nil, err
+	}
+	return filename, bs, nil
+}
+
+// writeFile 按 o 的设置序列化 data 并写入 fsys，返回替换时间戳后的文件名
+func (o *options) writeFile(fsys WritableFS, path string, data any) (string, error) {
+	filename, bs, err := o.renderFile(fsys, path, data)
+	if err != nil {
+		return "", o.fileError("write", path, err)
+	}
+	if err = o.ctx.Err(); err != nil {
+		return "", o.fileError("write", filename, err)
+	}
+	if err = fsys.WriteFile(filename, bs, o.fileMode); err != nil {
+		return filename, o.fileError("write", filename, err)
+	}
+	return filename, nil
+}
+
+// fileError 以操作、文件名和格式包装 err，例如 read file report_20240102.csv (csv): unmarshal data: ...
+func (o *options) fileError(op, filename string, err error) error {
+	format := "unknown format"
+	if ext, gzipped := o.formatExt(filename); ext != "" {
+		format = strings.ToLower(strings.TrimPrefix(ext, "."))
+		if gzipped {
+			format += ", gzip"
+		}
+	}
+	return fmt.Errorf("%s file %s (%s): %w", op, filename, format, err)
+}
+
+// marshalData 以 o.marshal 或 path 的后缀名对应的格式序列化 data，后缀名为 .gz 时压缩
+func (o *options) marshalData(path string, data any) ([]byte, error) {
+	ext, gzipped := o.formatExt(path)
+	marshal := o.marshal
+	if marshal == nil {
+		switch ext {
 		case ".csv":
-			marshal = append(marshal, csv.Marshal)
+			marshal = o.marshalCSV()
 		case ".json":
-			marshal = append(marshal, json.Marshal)
+			marshal = o.marshalJSON()
+		case ".ndjson", ".jsonl":
+			marshal = MarshalNDJSON
 		case ".yaml", ".yml":
-			marshal = append(marshal, yaml.Marshal)
+			marshal = o.marshalYAML()
+		case ".toml":
+			marshal = toml.Marshal
+		case ".xml":
+			marshal = marshalXML(o.xmlIndent)
+		case ".env":
+			marshal = MarshalDotenv
+		case ".properties":
+			marshal = MarshalProperties
+		case ".ini":
+			marshal = MarshalINI
 		default:
-			return fmt.Errorf("unsupported file format: %s", ext)
+			return nil, fmt.Errorf("%w: %s", ErrUnsupportedFormat, ext)
 		}
 	}
 
-	bs, err := marshal[0](data)
+	bs, err := marshal(data)
 	if err != nil {
+		return nil, err
+	}
+	if bs, err = o.encodeCharset(bs); err != nil {
+		return nil, err
+	}
+	if gzipped {
+		if bs, err = gzipBytes(bs, o.gzipLevel); err != nil {
+			return nil, fmt.Errorf("compress data: %w", err)
+		}
+	}
+	return bs, nil
+}
+
+// SaveFile 将 data（[]byte、string 或 io.Reader）写入文件，如果 path 中包含 *，则会替换为当前时间戳
+// optFns 设置打开文件的 flag 和新建文件的权限，见 WithOpenOptions；需要其他选项时使用 SaveFileWith
+func SaveFile(path string, data any, optFns ...fsutil.OpenOptionFunc) error {
+	var opts []Option
+	if len(optFns) > 0 {
+		opts = append(opts, WithOpenOptions(optFns...))
+	}
+	return SaveFileWith(path, data, opts...)
+}
+
+// SaveFileWith 与 SaveFile 相同，但接受函数式选项
+// 默认创建缺失的父目录，可通过 WithDirMode 调整权限或 WithCreateDirs(false) 关闭；WithAtomic 可避免读者看到写了一半的文件
+func SaveFileWith(path string, data any, opts ...Option) error {
+	_, err := SaveFilePath(path, data, opts...)
+	return err
+}
+
+// SaveFilePath 与 SaveFileWith 相同，但返回替换时间戳后的文件名
+func SaveFilePath(path string, data any, opts ...Option) (string, error) {
+	return newOptions(opts).saveFile(path, data)
+}
+
+// saveFile 按 o 的设置将 data 写入文件，返回替换时间戳后的文件名
+func (o *options) saveFile(path string, data any) (filename string, err error) {
+	err = o.lockWrite(path, func() error {
+		if filename, err = timestampFileName(path); err != nil {
+			return err
+		}
+		return o.writeNamed(filename, data)
+	})
+	return filename, err
+}
+
+// writeNamed 按 o 的设置将 data 写入已替换时间戳的 filename
+func (o *options) writeNamed(filename string, data any) (err error) {
+	if err = o.checkFreeSpace(filename, data); err != nil {
 		return err
 	}
 
-	return SaveFile(path, bs)
+	if o.atomic {
+		if err := o.writeAtomic(filename, data); err != nil {
+			return err
+		}
+	} else {
+		flag := fsutil.FsCWTFlags
+		if o.openFlag != 0 {
+			flag = o.openFlag
+		}
+		f, err := o.openFile(filename, flag)
+		if err != nil {
+			return err
+		}
+		if err = o.writeOSFile(f, data); err != nil {
+			return err
+		}
+		if err = o.syncParentDir(filename); err != nil {
+			return err
+		}
+	}
+
+	return o.afterWrite(filename)
 }
 
-func SaveFile(path string, data any, optFns ...fsutil.OpenOptionFunc) error {
-	return fsutil.SaveFile(TimestampFileName(path), data, optFns...)
+// afterWrite 在 filename 写入完成后按 o 生成校验文件并更新最新文件的链接
+func (o *options) afterWrite(filename string) error {
+	if o.checksum != 0 {
+		if err := o.writeChecksum(filename); err != nil {
+			return err
+		}
+	}
+	if o.latest != "" {
+		return o.updateLatest(filename)
+	}
+	return nil
 }
 
-// TimestampFileName 将 path 中的 * 替换为当前时间戳（格式为 20060102_150405）
-func TimestampFileName(path string) string {
-	timestamp := time.Now().Format("20060102_150405")
-	return strings.Replace(path, "*", timestamp, -1)
+// writeAtomic 先写入同一目录下的临时文件，再重命名为 filename，设置了 WithSync 时在重命名前 fsync 临时文件、之后 fsync 目录
+func (o *options) writeAtomic(filename string, data any) error {
+	tmp := filepath.Join(filepath.Dir(filename), fmt.Sprintf(".%s.tmp%d", filepath.Base(filename), rand.Uint32()))
+	f, err := o.openFile(tmp, os.O_CREATE|os.O_EXCL|os.O_WRONLY)
+	if err != nil {
+		return err
+	}
+	if err = o.writeOSFile(f, data); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	if err = os.Rename(tmp, filename); err != nil {
+		os.Remove(tmp)
+		return fmt.Errorf("rename file: %w", err)
+	}
+	return o.syncParentDir(filename)
 }
 
-// GetLatestFileByName 获取最新的文件，基于文件名中的时戳
-func GetLatestFileByName(path string) (string, error) {
-	matches, err := filepath.Glob(path)
+// WriteFileRotating 与 WriteFile 相同，但以原子方式写入，随后只保留与 path 匹配的最新的 keep 个文件
+// 返回写入的文件名及被删除的文件，keep 必须大于 0，模式的保护规则见 RotateFiles
+func WriteFileRotating(path string, data any, keep int, opts ...Option) (written string, removed []string, err error) {
+	if keep < 1 {
+		return "", nil, errors.New("keep must be at least 1")
+	}
+	o := newOptions(opts)
+	o.atomic = true
+	if written, err = o.writeFile(osFS{o}, path, data); err != nil {
+		return "", nil, err
+	}
+	removed, err = RotateFiles(path, keep)
+	return written, removed, err
+}
+
+// AppendCSVFile 将 data 追加到 CSV 文件末尾，如果 path 中包含 *，则会替换为当前时间戳（格式为 20060102_150405）
+// 文件不存在或为空时先写入表头；已有内容时校验其表头与 data 的列一致，不一致则报错。omitempty 列始终输出
+func AppendCSVFile(path string, data any, opts ...Option) error {
+	o := newOptions(opts)
+	filename, err := timestampFileName(path)
 	if err != nil {
-		return "", err
+		return err
 	}
 
-	if len(matches) == 0 {
-		return "", errors.New("no matching files found")
+	header, err := csv.Header(data)
+	if err != nil {
+		return err
 	}
 
-	sort.Slice(matches, func(i, j int) bool {
-		return matches[i] > matches[j]
-	})
+	f, err := o.openFile(filename, os.O_CREATE|os.O_RDWR|os.O_APPEND)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
 
-	return matches[0], nil
+	info, err := f.Stat()
+	if err != nil {
+		return fmt.Errorf("stat file: %w", err)
+	}
+	encOpts := o.csvOptions()
+	if info.Size() > 0 {
+		existing, err := csv.NewDecoder(f, o.csvOptions()...).Header()
+		if err != nil {
+			return fmt.Errorf("read header: %w", err)
+		}
+		if !slices.Equal(existing, header) {
+			return fmt.Errorf("%w: header %q of %s does not match columns %q", ErrHeaderMismatch, existing, filename, header)
+		}
+		encOpts = append(encOpts, csv.WithNoHeader())
+	}
+
+	enc := csv.NewEncoder(f, data, encOpts...)
+	if err = enc.Encode(data); err != nil {
+		return fmt.Errorf("marshal data: %w", err)
+	}
+	if err = enc.Flush(); err != nil {
+		return err
+	}
+	return f.Close()
 }
 
-// GetLatestFileByModTime 获取最新的文件，基于文件的修改时间
-func GetLatestFileByModTime(path string) (string, error) {
-	matches, err := filepath.Glob(path)
+// createParentDirs 在开启 createDirs 时创建 filename 缺失的父目录，并按 o 设置属主
+func (o *options) createParentDirs(filename string) error {
+	if !o.createDirs {
+		return nil
+	}
+	created, err := mkdirAll(filepath.Dir(filename), o.dirMode)
 	if err != nil {
-		return "", err
+		return fmt.Errorf("create parent directories: %w", err)
+	}
+	for _, dir := range created {
+		if err = o.chown(dir); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// openFile 以 flag 打开 filename，并按 o 创建父目录、设置权限和属主
+func (o *options) openFile(filename string, flag int) (*os.File, error) {
+	if err := o.createParentDirs(filename); err != nil {
+		return nil, err
 	}
 
-	if len(matches) == 0 {
-		return "", errors.New("no matching files found")
+	f, err := os.OpenFile(filename, flag, o.fileMode)
+	if err != nil {
+		return nil, fmt.Errorf("open file: %w", err)
+	}
+	if o.fileModeSet {
+		if err = f.Chmod(o.fileMode); err != nil {
+			f.Close()
+			return nil, fmt.Errorf("change file mode: %w", err)
+		}
+	}
+	if err = o.chown(filename); err != nil {
+		f.Close()
+		return nil, err
 	}
+	return f, nil
+}
 
-	var latestFile string
-	var latestTime time.Time
-	for _, file := range matches {
-		fileInfo, err := os.Stat(file)
-		if err != nil {
-			return "", err
+// mkdirAll 与 os.MkdirAll 相同，但返回新创建的目录，由外向内排列
+func mkdirAll(dir string, mode os.FileMode) ([]string, error) {
+	var missing []string
+	for d := dir; ; d = filepath.Dir(d) {
+		if _, err := os.Stat(d); err == nil {
+			break
+		} else if !errors.Is(err, os.ErrNotExist) {
+			return nil, err
+		}
+		missing = append(missing, d)
+		if filepath.Dir(d) == d {
+			break
 		}
-		if latestFile == "" || fileInfo.ModTime().After(latestTime) {
-			latestFile = file
-			latestTime = fileInfo.ModTime()
+	}
+
+	var created []string
+	for i := len(missing) - 1; i >= 0; i-- {
+		if err := os.Mkdir(missing[i], mode); err != nil {
+			// 其他进程可能同时创建了该目录
+			if info, statErr := os.Stat(missing[i]); statErr == nil && info.IsDir() {
+				continue
+			}
+			return created, err
+		}
+		created = append(created, missing[i])
+	}
+	return created, nil
+}
+
+// timestampLayout 是 TimestampFileName 写入文件名的时间戳格式
+const timestampLayout = "20060102_150405"
+
+// timestampPattern 匹配文件名中的 timestampLayout 时间戳
+var timestampPattern = regexp.MustCompile(`\d{8}_\d{6}`)
+
+// TimestampFileName 将 path 中的 * 替换为当前时间戳（格式为 20060102_150405），当前时间可通过 SetClock 设置
+// 同时展开 {ts:layout}、{host} 和 {seq} 等占位符（见 ExpandFileName），{seq} 从 1 开始递增，直到文件名不与已有文件冲突
+// 无法判断文件是否存在时（例如父路径不是目录）返回最后尝试的文件名，写入该文件时会报告实际的错误
+func TimestampFileName(path string) string {
+	filename, _ := timestampFileName(path)
+	return filename
+}
+
+// timestampFileName 与 TimestampFileName 相同，但返回展开 {seq} 时的错误
+func timestampFileName(path string) (string, error) {
+	return expandFileName(path, existsIn(osFS{}), false)
+}
+
+// ParseTimestampFromName 解析文件名中最后一个 20060102_150405 格式的时间戳，按本地时区解析，与 TimestampFileName 一致
+func ParseTimestampFromName(name string) (time.Time, bool) {
+	matches := timestampPattern.FindAllString(filepath.Base(name), -1)
+	for i := len(matches) - 1; i >= 0; i-- {
+		if t, err := time.ParseInLocation(timestampLayout, matches[i], time.Local); err == nil {
+			return t, true
 		}
 	}
+	return time.Time{}, false
+}
+
+// GetLatestFileByName 获取最新的文件，基于文件名中 20060102_150405 格式的时间戳
+// 文件名中没有可解析的时间戳时使用文件的修改时间，时间相同时取自然顺序（见 NaturalLess）较大的文件名
+// opts 可在选择前过滤文件，例如 WithMinSize(1) 跳过空文件，WithMinAge 跳过刚写入的文件
+func GetLatestFileByName(path string, opts ...ListOption) (string, error) {
+	return GetFileBy(path, newerByName, opts...)
+}
+
+// ResolvePath 返回 ReadFile 对 pattern 会选择的文件，即与 pattern 匹配的最新文件（新旧规则同 GetLatestFileByName），
+// 候选文件的过滤同 ReadFile，可通过 WithSelect 设置；pattern 为 "-" 时返回 "-"。供调用方在读取前记录实际使用的文件
+func ResolvePath(pattern string, opts ...Option) (string, error) {
+	if pattern == stdioPath {
+		return stdioPath, nil
+	}
+	o := newOptions(opts)
+	return getFileBy(o.ctx, osFS{}, pattern, newerByName, o.listOpts)
+}
+
+// GetLatestEntry 与 GetLatestFileByName 相同，但返回包含文件信息和时间戳的 FileEntry，调用方无需再次 Stat 或解析文件名
+func GetLatestEntry(pattern string, opts ...ListOption) (FileEntry, error) {
+	return getEntryBy(context.Background(), osFS{}, pattern, newerByName, opts)
+}
+
+// GetLatestFileByNameLexicographic 获取文件名按字符串排序最大的文件，即 GetLatestFileByName 以前的行为
+func GetLatestFileByNameLexicographic(path string) (string, error) {
+	matches, err := filepath.Glob(GlobPattern(path))
+	if err != nil {
+		return "", err
+	}
+	matches = slices.DeleteFunc(matches, newListOptions(nil).excluded(path))
+
+	if len(matches) == 0 {
+		return "", ErrNoMatch
+	}
 
-	return latestFile, nil
+	sort.Slice(matches, func(i, j int) bool {
+		return matches[i] > matches[j]
+	})
+
+	return matches[0], nil
+}
+
+// GetLatestFileByModTime 获取最新的文件，基于文件的修改时间
+func GetLatestFileByModTime(path string, opts ...ListOption) (string, error) {
+	return GetFileBy(path, newerByModTime, opts...)
 }
@@ -1,14 +1,38 @@
 package fs
 
 import (
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"crypto"
+	"crypto/sha256"
 	"encoding/csv"
+	"encoding/hex"
 	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	iofs "io/fs"
+	"net/http"
+	"net/http/httptest"
 	"os"
 	"path"
 	"path/filepath"
+	"regexp"
+	"runtime"
+	"slices"
+	"strconv"
 	"strings"
+	"sync"
+	"syscall"
 	"testing"
+	"testing/fstest"
+	"time"
+	"unicode/utf8"
 
+	lcsv "github.com/0xuLiang/lancet/csv"
+	"github.com/gookit/goutil/fsutil"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -39,6 +63,188 @@ func TestGetLatestFileByName(t *testing.T) {
 	}
 }
 
+func TestGetLatestFileByName_Timestamp(t *testing.T) {
+	dir := t.TempDir()
+	create := func(name string, modTime time.Time) {
+		filename := filepath.Join(dir, name)
+		assert.NoError(t, os.WriteFile(filename, nil, 0o644))
+		assert.NoError(t, os.Chtimes(filename, modTime, modTime))
+	}
+	old := time.Date(2020, 1, 1, 0, 0, 0, 0, time.Local)
+
+	// 按文件名中的时间戳选择，与前缀无关
+	create("a_20240102_090000.csv", old)
+	create("report_20231231_235959.csv", old)
+	// 没有时间戳的文件使用修改时间
+	create("report_9.csv", old)
+	latest, err := GetLatestFileByName(filepath.Join(dir, "*.csv"))
+	assert.NoError(t, err)
+	assert.Equal(t, filepath.Join(dir, "a_20240102_090000.csv"), latest)
+
+	// 旧的行为按字符串排序
+	latest, err = GetLatestFileByNameLexicographic(filepath.Join(dir, "*.csv"))
+	assert.NoError(t, err)
+	assert.Equal(t, filepath.Join(dir, "report_9.csv"), latest)
+
+	// 修改时间晚于所有时间戳的文件胜出
+	create("manual.csv", time.Date(2025, 1, 1, 0, 0, 0, 0, time.Local))
+	latest, err = GetLatestFileByName(filepath.Join(dir, "*.csv"))
+	assert.NoError(t, err)
+	assert.Equal(t, filepath.Join(dir, "manual.csv"), latest)
+
+	// 无效的时间戳按修改时间处理
+	create("bad_20241399_250000.csv", old)
+	latest, err = GetLatestFileByName(filepath.Join(dir, "bad_*.csv"))
+	assert.NoError(t, err)
+	assert.Equal(t, filepath.Join(dir, "bad_20241399_250000.csv"), latest)
+
+	// TimestampFileName 写入的文件可以被选中
+	filename := TimestampFileName(filepath.Join(dir, "a_*.csv"))
+	create(filepath.Base(filename), old)
+	latest, err = GetLatestFileByName(filepath.Join(dir, "*.csv"))
+	assert.NoError(t, err)
+	assert.Equal(t, filename, latest)
+}
+
+func TestNaturalLess(t *testing.T) {
+	// 每组中前者排在后者之前
+	cases := [][2]string{
+		{"file2.txt", "file10.txt"},
+		{"file9.txt", "file10.txt"},
+		{"v1.9", "v1.10"},
+		{"v1.10", "v2.0"},
+		{"a", "a1"},
+		{"file01", "file2"},
+		{"file1", "file01"},
+		{"报告2.csv", "报告10.csv"},
+		{"报告10.csv", "表格1.csv"},
+		{"ä9", "ä10"},
+	}
+	for _, c := range cases {
+		assert.True(t, NaturalLess(c[0], c[1]), "%s < %s", c[0], c[1])
+		assert.False(t, NaturalLess(c[1], c[0]), "%s > %s", c[1], c[0])
+	}
+	assert.False(t, NaturalLess("file1", "file1"))
+}
+
+func TestGetLatestFileByName_NaturalOrder(t *testing.T) {
+	dir := t.TempDir()
+	modTime := time.Date(2020, 1, 1, 0, 0, 0, 0, time.Local)
+	// 修改时间相同时按自然顺序选择
+	for _, name := range []string{"file2.txt", "file9.txt", "file10.txt", "v1.9.txt", "v1.10.txt"} {
+		filename := filepath.Join(dir, name)
+		assert.NoError(t, os.WriteFile(filename, nil, 0o644))
+		assert.NoError(t, os.Chtimes(filename, modTime, modTime))
+	}
+
+	latest, err := GetLatestFileByName(filepath.Join(dir, "file*.txt"))
+	assert.NoError(t, err)
+	assert.Equal(t, filepath.Join(dir, "file10.txt"), latest)
+
+	latest, err = GetLatestFileByModTime(filepath.Join(dir, "v*.txt"))
+	assert.NoError(t, err)
+	assert.Equal(t, filepath.Join(dir, "v1.10.txt"), latest)
+}
+
+func TestGetFileBy(t *testing.T) {
+	dir := t.TempDir()
+	create := func(name string, size int, modTime time.Time) {
+		filename := filepath.Join(dir, name)
+		assert.NoError(t, os.WriteFile(filename, make([]byte, size), 0o644))
+		assert.NoError(t, os.Chtimes(filename, modTime, modTime))
+	}
+	day := func(d int) time.Time { return time.Date(2024, 1, d, 0, 0, 0, 0, time.Local) }
+	create("a.log", 10, day(2))
+	create("b.log", 30, day(1))
+	create("c.log", 20, day(3))
+	create("app_20240105_000000.txt", 0, day(1))
+	create("app_20240104_000000.txt", 0, day(1))
+	create("db_20240106_000000.txt", 0, day(1))
+
+	oldest, err := GetOldestFileByModTime(filepath.Join(dir, "*.log"))
+	assert.NoError(t, err)
+	assert.Equal(t, filepath.Join(dir, "b.log"), oldest)
+
+	largest, err := GetLargestFile(filepath.Join(dir, "*.log"))
+	assert.NoError(t, err)
+	assert.Equal(t, filepath.Join(dir, "b.log"), largest)
+
+	// 只在文件名与正则匹配的文件中选择最新的
+	latest, err := GetLatestFileMatching(filepath.Join(dir, "*.txt"), regexp.MustCompile(`^app_`))
+	assert.NoError(t, err)
+	assert.Equal(t, filepath.Join(dir, "app_20240105_000000.txt"), latest)
+
+	// 自定义比较函数可以使用文件名中的时间戳
+	earliest, err := GetFileBy(filepath.Join(dir, "*.txt"), func(a, b FileEntry) bool {
+		return a.HasTimestamp && a.Timestamp.Before(b.Timestamp)
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, filepath.Join(dir, "app_20240104_000000.txt"), earliest)
+
+	// 没有匹配的文件
+	_, err = GetLatestFileMatching(filepath.Join(dir, "*.txt"), regexp.MustCompile(`^web_`))
+	assert.Error(t, err)
+}
+
+func TestListFiles(t *testing.T) {
+	dir := t.TempDir()
+	create := func(name string, size int, modTime time.Time) {
+		filename := filepath.Join(dir, name)
+		assert.NoError(t, os.WriteFile(filename, make([]byte, size), 0o644))
+		assert.NoError(t, os.Chtimes(filename, modTime, modTime))
+	}
+	day := func(d int) time.Time { return time.Date(2024, 1, d, 0, 0, 0, 0, time.Local) }
+	create("file10.txt", 5, day(1))
+	create("file9.txt", 20, day(3))
+	create("file2.txt", 0, day(2))
+	create(".hidden.txt", 1, day(4))
+	assert.NoError(t, os.Mkdir(filepath.Join(dir, "dir.txt"), 0o755))
+
+	paths := func(entries []FileEntry) []string {
+		var names []string
+		for _, entry := range entries {
+			names = append(names, filepath.Base(entry.Path))
+		}
+		return names
+	}
+	pattern := filepath.Join(dir, "*.txt")
+
+	// 默认按自然顺序升序，并排除隐藏文件
+	entries, err := ListFiles(pattern)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"dir.txt", "file2.txt", "file9.txt", "file10.txt"}, paths(entries))
+	entries, err = ListFiles(pattern, WithExcludePatterns())
+	assert.NoError(t, err)
+	assert.Equal(t, []string{".hidden.txt", "dir.txt", "file2.txt", "file9.txt", "file10.txt"}, paths(entries))
+
+	// 过滤目录、空文件和隐藏文件
+	entries, err = ListFiles(pattern, WithExcludeDirs(), WithExcludeEmpty(), WithExcludeHidden())
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"file9.txt", "file10.txt"}, paths(entries))
+	assert.Equal(t, int64(20), entries[0].Size)
+	assert.Equal(t, day(3), entries[0].ModTime)
+
+	// 按字符串顺序、修改时间和大小排序
+	entries, err = ListFiles(pattern, WithExcludeDirs(), WithExcludeHidden(), WithSortBy(SortByName))
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"file10.txt", "file2.txt", "file9.txt"}, paths(entries))
+
+	entries, err = ListFiles(pattern, WithExcludeDirs(), WithExcludeHidden(), WithSortBy(SortByModTime), WithDescending())
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"file9.txt", "file2.txt", "file10.txt"}, paths(entries))
+
+	entries, err = ListFiles(pattern, WithExcludeDirs(), WithExcludePatterns(), WithSortBy(SortBySize))
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"file2.txt", ".hidden.txt", "file10.txt", "file9.txt"}, paths(entries))
+
+	// 文件名中的时间戳
+	create("app_20240105_000000.log", 0, day(1))
+	entries, err = ListFiles(filepath.Join(dir, "*.log"))
+	assert.NoError(t, err)
+	assert.True(t, entries[0].HasTimestamp)
+	assert.Equal(t, time.Date(2024, 1, 5, 0, 0, 0, 0, time.Local), entries[0].Timestamp)
+}
+
 func TestGetLatestFileByModTime(t *testing.T) {
 	// 创建一个临时目录
 	dir, err := os.MkdirTemp("", "test")
@@ -219,6 +425,126 @@ func TestWriteCSVFile(t *testing.T) {
 	assert.Equal(t, []string{testData[0].Key, testData[0].Value}, records[1]) // 验证数据
 }
 
+type OptionalCSVRecord struct {
+	Key  string `csv:"key"`
+	Note string `csv:"note,omitempty"`
+}
+
+func TestWriteCSVFile_StableColumnsWhenExists(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "data.csv")
+	testData := []OptionalCSVRecord{{Key: "a"}}
+
+	// 文件不存在时，全部为空的 omitempty 列被省略
+	assert.NoError(t, WriteCSVFile(filename, testData))
+	content, err := os.ReadFile(filename)
+	assert.NoError(t, err)
+	assert.Equal(t, "key\na\n", string(content))
+
+	// 文件已存在时，始终输出 omitempty 列
+	assert.NoError(t, WriteCSVFile(filename, testData))
+	content, err = os.ReadFile(filename)
+	assert.NoError(t, err)
+	assert.Equal(t, "key,note\na,\n", string(content))
+}
+
+func TestAppendCSVFile(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "metrics", "data.csv")
+
+	// 文件不存在时写入表头，omitempty 列始终输出
+	assert.NoError(t, AppendCSVFile(filename, []OptionalCSVRecord{{Key: "a"}}))
+	// 文件已存在时只追加数据行
+	assert.NoError(t, AppendCSVFile(filename, OptionalCSVRecord{Key: "b", Note: "x"}))
+	content, err := os.ReadFile(filename)
+	assert.NoError(t, err)
+	assert.Equal(t, "key,note\na,\nb,x\n", string(content))
+
+	// 空文件同样写入表头
+	empty := filepath.Join(filepath.Dir(filename), "empty.csv")
+	assert.NoError(t, os.WriteFile(empty, nil, 0o644))
+	assert.NoError(t, AppendCSVFile(empty, []OptionalCSVRecord{{Key: "a"}}))
+	content, err = os.ReadFile(empty)
+	assert.NoError(t, err)
+	assert.Equal(t, "key,note\na,\n", string(content))
+
+	// 表头与结构体的列不一致时报错，文件保持不变
+	err = AppendCSVFile(filename, []CSVRecord{{Key: "c", Value: "v"}})
+	assert.ErrorContains(t, err, "does not match")
+	content, err = os.ReadFile(filename)
+	assert.NoError(t, err)
+	assert.Equal(t, "key,note\na,\nb,x\n", string(content))
+}
+
+func TestGzipFile(t *testing.T) {
+	dir := t.TempDir()
+	testData := []CSVRecord{{Key: "key", Value: "value"}}
+
+	for _, name := range []string{"data.json.gz", "data.csv.gz", "data.yaml.gz"} {
+		filename := filepath.Join(dir, name)
+		assert.NoError(t, WriteFileWith(filename, testData, WithGzipLevel(gzip.BestCompression)))
+
+		// 写入的内容经过 gzip 压缩
+		content, err := os.ReadFile(filename)
+		assert.NoError(t, err)
+		assert.True(t, bytes.HasPrefix(content, []byte{0x1f, 0x8b}), name)
+
+		// 读取时自动解压并按内层后缀名解析
+		var result []CSVRecord
+		assert.NoError(t, ReadFile(filename, &result), name)
+		assert.Equal(t, testData, result, name)
+	}
+
+	// 流式读取 CSV 同样支持 gzip
+	var result []CSVRecord
+	assert.NoError(t, ReadCSVFile(filepath.Join(dir, "data.csv.gz"), &result))
+	assert.Equal(t, testData, result)
+
+	// 后缀名与内容不符时，根据文件头识别 gzip
+	filename := filepath.Join(dir, "misnamed.json")
+	assert.NoError(t, WriteFile(filepath.Join(dir, "misnamed.json.gz"), testData))
+	assert.NoError(t, os.Rename(filepath.Join(dir, "misnamed.json.gz"), filename))
+	result = nil
+	assert.NoError(t, ReadFile(filename, &result))
+	assert.Equal(t, testData, result)
+
+	// 无效的压缩级别
+	assert.Error(t, WriteFileWith(filepath.Join(dir, "bad.json.gz"), testData, WithGzipLevel(42)))
+}
+
+func TestNDJSONFile(t *testing.T) {
+	dir := t.TempDir()
+	testData := []CSVRecord{{Key: "a", Value: "1"}, {Key: "b", Value: "2"}}
+
+	// 每个元素占一行
+	filename := filepath.Join(dir, "data.ndjson")
+	assert.NoError(t, WriteFile(filename, testData))
+	content, err := os.ReadFile(filename)
+	assert.NoError(t, err)
+	assert.Equal(t, "{\"Key\":\"a\",\"Value\":\"1\"}\n{\"Key\":\"b\",\"Value\":\"2\"}\n", string(content))
+
+	var result []CSVRecord
+	assert.NoError(t, ReadFile(filename, &result))
+	assert.Equal(t, testData, result)
+
+	// .jsonl 与 gzip 压缩同样支持
+	filename = filepath.Join(dir, "data.jsonl.gz")
+	assert.NoError(t, WriteNDJSONFile(filename, testData))
+	result = nil
+	assert.NoError(t, ReadNDJSONFile(filename, &result))
+	assert.Equal(t, testData, result)
+
+	// 空行被跳过，解析失败时报告行号
+	filename = filepath.Join(dir, "bad.jsonl")
+	assert.NoError(t, os.WriteFile(filename, []byte("{\"Key\":\"a\"}\n\n{\"Key\":\"b\"}\n\n{oops}\n"), 0o644))
+	result = nil
+	err = ReadFile(filename, &result)
+	assert.ErrorContains(t, err, "line 5")
+	assert.Equal(t, []CSVRecord{{Key: "a"}, {Key: "b"}}, result)
+
+	// 目标必须是切片指针
+	var single CSVRecord
+	assert.Error(t, UnmarshalNDJSON([]byte("{}"), &single))
+}
+
 func TestReadAndWriteYAMLFile(t *testing.T) {
 	// 创建一个临时的 YAML 文件
 	tempFile, err := os.CreateTemp("", "*.yml")
@@ -252,3 +578,3085 @@ func TestReadAndWriteYAMLFile(t *testing.T) {
 	err = ReadFile("nonexistent.yaml", &result)
 	assert.Error(t, err)
 }
+
+func TestWriteFile_CreateParentDirs(t *testing.T) {
+	dir := t.TempDir()
+	filename := filepath.Join(dir, "out", "reports", "2024", "*.json")
+
+	// 多级不存在的父目录会被自动创建
+	assert.NoError(t, WriteJsonFile(filename, map[string]string{"key": "value"}))
+	info, err := os.Stat(filepath.Join(dir, "out", "reports", "2024"))
+	assert.NoError(t, err)
+	assert.True(t, info.IsDir())
+	assert.Zero(t, info.Mode().Perm()&0o002) // 默认权限 0775，其他用户不可写
+
+	var result map[string]string
+	assert.NoError(t, ReadJsonFile(filename, &result))
+	assert.Equal(t, "value", result["key"])
+}
+
+func TestWriteFile_StrictDirs(t *testing.T) {
+	dir := t.TempDir()
+
+	// 关闭自动创建后，父目录不存在时报错
+	err := WriteFileWith(filepath.Join(dir, "missing", "data.json"), map[string]string{}, WithCreateDirs(false))
+	assert.ErrorIs(t, err, os.ErrNotExist)
+}
+
+func TestWriteFile_ParentIsFile(t *testing.T) {
+	dir := t.TempDir()
+	parent := filepath.Join(dir, "parent")
+	assert.NoError(t, os.WriteFile(parent, []byte("x"), 0o644))
+
+	// 父路径是普通文件时给出明确的错误
+	err := WriteFile(filepath.Join(parent, "child", "data.json"), map[string]string{})
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "create parent directories")
+}
+
+func TestWriteFile_FileAndDirMode(t *testing.T) {
+	dir := t.TempDir()
+	filename := filepath.Join(dir, "secrets", "nested", "config.yaml")
+
+	assert.NoError(t, WriteYAMLFile(filename, map[string]string{"token": "x"}, WithFileMode(0o600), WithDirMode(0o750)))
+
+	info, err := os.Stat(filename)
+	assert.NoError(t, err)
+	assert.Equal(t, os.FileMode(0o600), info.Mode().Perm())
+	for _, d := range []string{filepath.Join(dir, "secrets"), filepath.Join(dir, "secrets", "nested")} {
+		info, err = os.Stat(d)
+		assert.NoError(t, err)
+		assert.Zero(t, info.Mode().Perm()&0o027) // 新建的父目录使用 0750
+	}
+
+	// 已存在的文件也会被修改为指定的权限
+	existing := filepath.Join(dir, "existing.json")
+	assert.NoError(t, os.WriteFile(existing, []byte("{}"), 0o644))
+	assert.NoError(t, WriteJsonFile(existing, map[string]string{}, WithFileMode(0o600)))
+	info, err = os.Stat(existing)
+	assert.NoError(t, err)
+	assert.Equal(t, os.FileMode(0o600), info.Mode().Perm())
+}
+
+func TestWriteFile_Owner(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("Chown 在 Windows 上不可用")
+	}
+	dir := t.TempDir()
+	filename := filepath.Join(dir, "owned", "data.csv")
+
+	// 使用当前用户作为属主，无需特权
+	err := WriteCSVFile(filename, []CSVRecord{{Key: "k", Value: "v"}}, WithOwner(os.Getuid(), os.Getgid()))
+	assert.NoError(t, err)
+	_, err = os.Stat(filename)
+	assert.NoError(t, err)
+}
+
+type TOMLServer struct {
+	Host  string   `toml:"host"`
+	Ports []int    `toml:"ports"`
+	Tags  []string `toml:"tags"`
+}
+
+type TOMLConfig struct {
+	Name    string            `toml:"name"`
+	Debug   bool              `toml:"debug"`
+	Server  TOMLServer        `toml:"server"`
+	Backups []TOMLServer      `toml:"backups"`
+	Labels  map[string]string `toml:"labels"`
+}
+
+func TestReadAndWriteTOMLFile(t *testing.T) {
+	dir := t.TempDir()
+	testData := TOMLConfig{
+		Name:   "app",
+		Debug:  true,
+		Server: TOMLServer{Host: "localhost", Ports: []int{80, 443}, Tags: []string{"a", "b"}},
+		Backups: []TOMLServer{
+			{Host: "b1", Ports: []int{8080}, Tags: []string{"x"}},
+			{Host: "b2", Ports: []int{8081, 8082}, Tags: []string{"c"}},
+		},
+		Labels: map[string]string{"env": "prod"},
+	}
+
+	// 写入后包含嵌套表和表数组
+	filename := filepath.Join(dir, "config.toml")
+	assert.NoError(t, WriteTOMLFile(filename, testData))
+	content, err := os.ReadFile(filename)
+	assert.NoError(t, err)
+	assert.Contains(t, string(content), "[server]")
+	assert.Contains(t, string(content), "[[backups]]")
+
+	var result TOMLConfig
+	assert.NoError(t, ReadTOMLFile(filename, &result))
+	assert.Equal(t, testData, result)
+
+	// ReadFile/WriteFile 根据后缀名自动选择 TOML
+	filename = filepath.Join(dir, "auto_*.toml")
+	assert.NoError(t, WriteFile(filename, testData))
+	result = TOMLConfig{}
+	assert.NoError(t, ReadFile(filename, &result))
+	assert.Equal(t, testData, result)
+}
+
+type XMLItem struct {
+	ID   int    `xml:"id,attr"`
+	Name string `xml:"name"`
+}
+
+type XMLExport struct {
+	XMLName xml.Name  `xml:"export"`
+	Items   []XMLItem `xml:"item"`
+}
+
+func TestReadAndWriteXMLFile(t *testing.T) {
+	dir := t.TempDir()
+	testData := XMLExport{
+		XMLName: xml.Name{Local: "export"},
+		Items:   []XMLItem{{ID: 1, Name: "a"}, {ID: 2, Name: "b"}},
+	}
+
+	// 默认输出紧凑格式，并以 XML 声明开头
+	filename := filepath.Join(dir, "export.xml")
+	assert.NoError(t, WriteXMLFile(filename, testData))
+	content, err := os.ReadFile(filename)
+	assert.NoError(t, err)
+	assert.Equal(t, xml.Header+`<export><item id="1"><name>a</name></item><item id="2"><name>b</name></item></export>`+"\n", string(content))
+
+	var result XMLExport
+	assert.NoError(t, ReadXMLFile(filename, &result))
+	assert.Equal(t, testData, result)
+
+	// WriteFile 根据后缀名自动选择 XML，并支持缩进输出
+	filename = filepath.Join(dir, "export_*.xml")
+	assert.NoError(t, WriteFileWith(filename, testData, WithXMLIndent("  ")))
+	latest, err := GetLatestFileByName(filename)
+	assert.NoError(t, err)
+	content, err = os.ReadFile(latest)
+	assert.NoError(t, err)
+	assert.True(t, strings.HasPrefix(string(content), xml.Header+"<export>\n  <item id=\"1\">\n    <name>a</name>"))
+
+	result = XMLExport{}
+	assert.NoError(t, ReadFile(filename, &result))
+	assert.Equal(t, testData, result)
+}
+
+func TestReadFiles(t *testing.T) {
+	dir := t.TempDir()
+	assert.NoError(t, WriteFile(filepath.Join(dir, "b.csv"), []CSVRecord{{Key: "b", Value: "2"}}))
+	assert.NoError(t, WriteFile(filepath.Join(dir, "a.csv"), []CSVRecord{{Key: "a", Value: "1"}, {Key: "a", Value: "2"}}))
+
+	// 按文件名顺序合并到切片
+	var result []CSVRecord
+	assert.NoError(t, ReadFiles(filepath.Join(dir, "*.csv"), &result))
+	assert.Equal(t, []CSVRecord{{Key: "a", Value: "1"}, {Key: "a", Value: "2"}, {Key: "b", Value: "2"}}, result)
+
+	// 以文件名为键分别解码
+	var byFile map[string][]CSVRecord
+	assert.NoError(t, ReadFiles(filepath.Join(dir, "*.csv"), &byFile))
+	assert.Equal(t, map[string][]CSVRecord{
+		filepath.Join(dir, "a.csv"): {{Key: "a", Value: "1"}, {Key: "a", Value: "2"}},
+		filepath.Join(dir, "b.csv"): {{Key: "b", Value: "2"}},
+	}, byFile)
+
+	// 表头不一致时报告出错的文件
+	assert.NoError(t, WriteFile(filepath.Join(dir, "c.csv"), []OptionalCSVRecord{{Key: "c", Note: "n"}}))
+	result = nil
+	err := ReadFiles(filepath.Join(dir, "*.csv"), &result)
+	assert.ErrorContains(t, err, "c.csv")
+	assert.ErrorContains(t, err, "a.csv")
+
+	// 没有匹配的文件或 out 类型错误
+	assert.Error(t, ReadFiles(filepath.Join(dir, "*.json"), &result))
+	var single CSVRecord
+	assert.Error(t, ReadFiles(filepath.Join(dir, "a.csv"), &single))
+}
+
+func TestRotateFiles(t *testing.T) {
+	dir := t.TempDir()
+	modTime := time.Date(2020, 1, 1, 0, 0, 0, 0, time.Local)
+	names := []string{"report_20240101_000000.csv", "report_20240103_000000.csv", "report_20240102_000000.csv", "report_manual.csv"}
+	for _, name := range names {
+		filename := filepath.Join(dir, name)
+		assert.NoError(t, os.WriteFile(filename, nil, 0o644))
+		assert.NoError(t, os.Chtimes(filename, modTime, modTime))
+	}
+	pattern := filepath.Join(dir, "report_*.csv")
+
+	// 试运行只返回将被删除的文件
+	removed, err := RotateFiles(pattern, 2, WithDryRun())
+	assert.NoError(t, err)
+	assert.Equal(t, []string{filepath.Join(dir, "report_20240101_000000.csv"), filepath.Join(dir, "report_manual.csv")}, removed)
+	entries, err := ListFiles(pattern)
+	assert.NoError(t, err)
+	assert.Len(t, entries, 4)
+
+	// 保留最新的 2 个文件，没有时间戳的文件按修改时间处理
+	removed, err = RotateFiles(pattern, 2)
+	assert.NoError(t, err)
+	assert.Len(t, removed, 2)
+	entries, err = ListFiles(pattern)
+	assert.NoError(t, err)
+	assert.Equal(t, filepath.Join(dir, "report_20240102_000000.csv"), entries[0].Path)
+	assert.Equal(t, filepath.Join(dir, "report_20240103_000000.csv"), entries[1].Path)
+
+	// 文件数量不超过 keep 时不删除
+	removed, err = RotateFiles(pattern, 5)
+	assert.NoError(t, err)
+	assert.Empty(t, removed)
+
+	// 过于宽泛的模式被拒绝
+	_, err = RotateFiles(filepath.Join(dir, "*"), 1)
+	assert.ErrorContains(t, err, "too broad")
+	_, err = RotateFiles(filepath.Join(dir, "*.*"), 1)
+	assert.ErrorContains(t, err, "too broad")
+	removed, err = RotateFiles(filepath.Join(dir, "*"), 1, WithForce(), WithDryRun())
+	assert.NoError(t, err)
+	assert.Len(t, removed, 1)
+
+	_, err = RotateFiles(pattern, -1)
+	assert.Error(t, err)
+}
+
+func TestWriteFileRotating(t *testing.T) {
+	dir := t.TempDir()
+	for _, name := range []string{"report_20240101_000000.json", "report_20240102_000000.json", "report_20240103_000000.json"} {
+		assert.NoError(t, os.WriteFile(filepath.Join(dir, name), []byte("[]"), 0o644))
+	}
+
+	// 写入新文件后只保留最新的 2 个
+	written, removed, err := WriteFileRotating(filepath.Join(dir, "report_*.json"), []CSVRecord{{Key: "a"}}, 2)
+	assert.NoError(t, err)
+	_, hasTimestamp := ParseTimestampFromName(written)
+	assert.True(t, hasTimestamp)
+	assert.Equal(t, dir, filepath.Dir(written))
+	assert.Equal(t, []string{filepath.Join(dir, "report_20240102_000000.json"), filepath.Join(dir, "report_20240101_000000.json")}, removed)
+
+	entries, err := ListFiles(filepath.Join(dir, "*"))
+	assert.NoError(t, err)
+	assert.Len(t, entries, 2)
+	var result []CSVRecord
+	assert.NoError(t, ReadFile(filepath.Join(dir, "report_*.json"), &result))
+	assert.Equal(t, []CSVRecord{{Key: "a"}}, result)
+
+	_, _, err = WriteFileRotating(filepath.Join(dir, "report_*.json"), []CSVRecord{}, 0)
+	assert.Error(t, err)
+}
+
+func TestSaveFile_Atomic(t *testing.T) {
+	dir := t.TempDir()
+	filename := filepath.Join(dir, "data.txt")
+	assert.NoError(t, os.WriteFile(filename, []byte("old content"), 0o644))
+
+	// 原子写入替换原文件，不留下临时文件
+	assert.NoError(t, SaveFileWith(filename, "new", WithAtomic(), WithFileMode(0o600)))
+	content, err := os.ReadFile(filename)
+	assert.NoError(t, err)
+	assert.Equal(t, "new", string(content))
+
+	entries, err := os.ReadDir(dir)
+	assert.NoError(t, err)
+	assert.Len(t, entries, 1)
+	if runtime.GOOS != "windows" {
+		info, err := os.Stat(filename)
+		assert.NoError(t, err)
+		assert.Equal(t, os.FileMode(0o600), info.Mode().Perm())
+	}
+}
+
+func TestWriteFilePath(t *testing.T) {
+	dir := t.TempDir()
+	testData := []CSVRecord{{Key: "key", Value: "value"}}
+
+	// 返回替换时间戳后实际写入的文件
+	writers := map[string]func(string, any, ...Option) (string, error){
+		"data_*.json":    WriteFilePath,
+		"json_*.json":    WriteJsonFilePath,
+		"csv_*.csv":      WriteCSVFilePath,
+		"yaml_*.yaml":    WriteYAMLFilePath,
+		"ndjson_*.jsonl": WriteNDJSONFilePath,
+	}
+	for pattern, write := range writers {
+		written, err := write(filepath.Join(dir, pattern), testData)
+		assert.NoError(t, err, pattern)
+		assert.NotContains(t, written, "*")
+		matched, err := filepath.Match(filepath.Join(dir, pattern), written)
+		assert.NoError(t, err)
+		assert.True(t, matched, written)
+		assert.FileExists(t, written)
+	}
+
+	// TOML 和 XML 的顶层不能是切片
+	written, err := WriteTOMLFilePath(filepath.Join(dir, "toml_*.toml"), TOMLServer{Host: "h"})
+	assert.NoError(t, err)
+	assert.FileExists(t, written)
+	written, err = WriteXMLFilePath(filepath.Join(dir, "xml_*.xml"), XMLExport{})
+	assert.NoError(t, err)
+	assert.FileExists(t, written)
+
+	written, err = SaveFilePath(filepath.Join(dir, "plain.txt"), "text")
+	assert.NoError(t, err)
+	assert.Equal(t, filepath.Join(dir, "plain.txt"), written)
+}
+
+func TestWriteFile_LatestSymlink(t *testing.T) {
+	dir := t.TempDir()
+	latest := filepath.Join(dir, "report_latest.json")
+
+	// 两次写入后符号链接指向第二个文件
+	first := filepath.Join(dir, "report_1.json")
+	second := filepath.Join(dir, "report_2.json")
+	assert.NoError(t, WriteFileWith(first, []CSVRecord{{Key: "1"}}, WithLatestSymlink(latest)))
+	assert.NoError(t, WriteFileWith(second, []CSVRecord{{Key: "2"}}, WithLatestSymlink(latest)))
+
+	if runtime.GOOS != "windows" {
+		target, err := os.Readlink(latest)
+		assert.NoError(t, err)
+		assert.Equal(t, "report_2.json", target)
+	}
+	var result []CSVRecord
+	assert.NoError(t, ReadFile(latest, &result))
+	assert.Equal(t, []CSVRecord{{Key: "2"}}, result)
+
+	// 复制模式写入普通文件
+	copied := filepath.Join(dir, "copy_latest.json")
+	assert.NoError(t, SaveFileWith(first, "[]", WithLatestCopy(copied)))
+	info, err := os.Lstat(copied)
+	assert.NoError(t, err)
+	assert.True(t, info.Mode().IsRegular())
+	content, err := os.ReadFile(copied)
+	assert.NoError(t, err)
+	assert.Equal(t, "[]", string(content))
+
+	// 不留下临时文件
+	entries, err := os.ReadDir(dir)
+	assert.NoError(t, err)
+	assert.Len(t, entries, 4)
+}
+
+func TestSetClock(t *testing.T) {
+	frozen := time.Date(2024, 5, 1, 8, 30, 0, 0, time.Local)
+	SetClock(func() time.Time { return frozen })
+	defer SetClock(nil)
+
+	// 固定时间后文件名可预测
+	dir := t.TempDir()
+	written, err := WriteFilePath(filepath.Join(dir, "x_*.csv"), []CSVRecord{{Key: "a"}})
+	assert.NoError(t, err)
+	assert.Equal(t, filepath.Join(dir, "x_20240501_083000.csv"), written)
+
+	// ParseTimestampFromName 是 TimestampFileName 的逆操作
+	ts, ok := ParseTimestampFromName(written)
+	assert.True(t, ok)
+	assert.True(t, frozen.Equal(ts))
+	_, ok = ParseTimestampFromName("x_latest.csv")
+	assert.False(t, ok)
+
+	// 恢复后使用当前时间
+	SetClock(nil)
+	ts, ok = ParseTimestampFromName(TimestampFileName("x_*.csv"))
+	assert.True(t, ok)
+	assert.WithinDuration(t, time.Now(), ts, 2*time.Second)
+}
+
+func TestExpandFileName(t *testing.T) {
+	ts := time.Date(2024, 5, 1, 8, 30, 0, 0, time.UTC)
+
+	assert.Equal(t, "report_20240501_083000.csv", ExpandFileName("report_*.csv", ts, "", 0))
+	assert.Equal(t, "report_20240501_083000.csv", ExpandFileName("report_{ts}.csv", ts, "", 0))
+	assert.Equal(t, "report_2024-05-01.csv", ExpandFileName("report_{ts:2006-01-02}.csv", ts, "", 0))
+	assert.Equal(t, "web1/report_3.csv", ExpandFileName("{host}/report_{seq}.csv", ts, "web1", 3))
+	// 未知的占位符保持不变
+	assert.Equal(t, "report_{other}.csv", ExpandFileName("report_{other}.csv", ts, "", 0))
+
+	assert.Equal(t, "*/report_*_*.csv", GlobPattern("{host}/report_{ts:2006-01-02}_{seq}.csv"))
+}
+
+func TestTimestampFileName_Placeholders(t *testing.T) {
+	SetClock(func() time.Time { return time.Date(2024, 5, 1, 8, 30, 0, 0, time.Local) })
+	defer SetClock(nil)
+	dir := t.TempDir()
+
+	// 按日期命名，{seq} 在文件名冲突时递增
+	path := filepath.Join(dir, "report_{ts:2006-01-02}_{seq}.csv")
+	for i := 1; i <= 3; i++ {
+		written, err := WriteFilePath(path, []CSVRecord{{Key: strconv.Itoa(i)}})
+		assert.NoError(t, err)
+		assert.Equal(t, filepath.Join(dir, "report_2024-05-01_"+strconv.Itoa(i)+".csv"), written)
+	}
+
+	// 读取时占位符按 * 匹配
+	var result []CSVRecord
+	assert.NoError(t, ReadFile(path, &result))
+	assert.Equal(t, []CSVRecord{{Key: "3"}}, result)
+
+	host, err := os.Hostname()
+	if err == nil {
+		assert.Equal(t, filepath.Join(dir, host+".csv"), TimestampFileName(filepath.Join(dir, "{host}.csv")))
+	}
+}
+
+func TestSentinelErrors(t *testing.T) {
+	dir := t.TempDir()
+	var result []CSVRecord
+
+	// 没有匹配的文件
+	err := ReadFile(filepath.Join(dir, "*.json"), &result)
+	assert.ErrorIs(t, err, ErrNoMatch)
+	assert.ErrorIs(t, err, os.ErrNotExist)
+	_, err = GetLatestFileByModTime(filepath.Join(dir, "*.json"))
+	assert.ErrorIs(t, err, ErrNoMatch)
+	assert.ErrorIs(t, ReadFiles(filepath.Join(dir, "*.json"), &result), ErrNoMatch)
+
+	// 不支持的格式
+	assert.ErrorIs(t, WriteFile(filepath.Join(dir, "data.bin"), result), ErrUnsupportedFormat)
+	assert.NoError(t, os.WriteFile(filepath.Join(dir, "data.bin"), nil, 0o644))
+	assert.ErrorIs(t, ReadFile(filepath.Join(dir, "data.bin"), &result), ErrUnsupportedFormat)
+
+	// 表头不一致
+	assert.NoError(t, AppendCSVFile(filepath.Join(dir, "a.csv"), []CSVRecord{{Key: "a"}}))
+	assert.ErrorIs(t, AppendCSVFile(filepath.Join(dir, "a.csv"), []OptionalCSVRecord{{Key: "a"}}), ErrHeaderMismatch)
+
+	// 模式过于宽泛
+	_, err = RotateFiles(filepath.Join(dir, "*"), 1)
+	assert.ErrorIs(t, err, ErrPatternTooBroad)
+}
+
+func TestReadFileOrDefault(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "state_*.json")
+	defaults := []CSVRecord{{Key: "default"}}
+
+	// 没有匹配的文件时使用默认值
+	var result []CSVRecord
+	assert.NoError(t, ReadFileOrDefault(path, &result, defaults))
+	assert.Equal(t, defaults, result)
+	_, err := GetLatestFileByName(path)
+	assert.ErrorIs(t, err, ErrNoMatch)
+
+	// WithWriteDefault 在首次运行时写入默认值
+	result = nil
+	assert.NoError(t, ReadFileOrDefault(path, &result, &defaults, WithWriteDefault()))
+	assert.Equal(t, defaults, result)
+	result = nil
+	assert.NoError(t, ReadFile(path, &result))
+	assert.Equal(t, defaults, result)
+
+	// 文件存在时读取文件
+	assert.NoError(t, WriteFile(filepath.Join(dir, "state_20990101_000000.json"), []CSVRecord{{Key: "saved"}}))
+	assert.NoError(t, ReadFileOrDefault(path, &result, defaults))
+	assert.Equal(t, []CSVRecord{{Key: "saved"}}, result)
+
+	// 解析失败等错误仍然返回
+	assert.NoError(t, os.WriteFile(filepath.Join(dir, "broken_1.json"), []byte("{"), 0o644))
+	assert.Error(t, ReadFileOrDefault(filepath.Join(dir, "broken_*.json"), &result, defaults))
+
+	// 默认值类型不匹配
+	assert.Error(t, ReadFileOrDefault(filepath.Join(dir, "none_*.json"), &result, "x"))
+
+	// 自定义 fallback
+	var count int
+	assert.NoError(t, ReadFileOr(filepath.Join(dir, "none_*.json"), &count, func() error {
+		count = 42
+		return nil
+	}))
+	assert.Equal(t, 42, count)
+}
+
+// memFS 在内存中记录写入的文件
+type memFS struct {
+	fstest.MapFS
+}
+
+func (m memFS) WriteFile(name string, data []byte, perm iofs.FileMode) error {
+	m.MapFS[name] = &fstest.MapFile{Data: data, Mode: perm}
+	return nil
+}
+
+func TestReadAndWriteFileFS(t *testing.T) {
+	SetClock(func() time.Time { return time.Date(2024, 5, 1, 8, 30, 0, 0, time.Local) })
+	defer SetClock(nil)
+	fsys := memFS{fstest.MapFS{
+		"data/old_20240101_000000.json": {Data: []byte(`[{"Key":"old"}]`)},
+		"data/old_20240102_000000.json": {Data: []byte(`[{"Key":"new"}]`)},
+	}}
+
+	// 从 fs.FS 中选择最新的文件
+	var result []CSVRecord
+	assert.NoError(t, ReadFileFS(fsys, "data/old_*.json", &result))
+	assert.Equal(t, []CSVRecord{{Key: "new"}}, result)
+
+	// 写入内存，{seq} 根据已有文件递增
+	written, err := WriteFileFS(fsys, "data/report_{seq}.csv", []CSVRecord{{Key: "a", Value: "1"}})
+	assert.NoError(t, err)
+	assert.Equal(t, "data/report_1.csv", written)
+	written, err = WriteFileFS(fsys, "data/report_{seq}.csv", []CSVRecord{{Key: "b", Value: "2"}})
+	assert.NoError(t, err)
+	assert.Equal(t, "data/report_2.csv", written)
+	assert.Equal(t, "Key,Value\na,1\n", string(fsys.MapFS["data/report_1.csv"].Data))
+
+	written, err = WriteFileFS(fsys, "data/new_*.json.gz", []CSVRecord{{Key: "c"}})
+	assert.NoError(t, err)
+	assert.Equal(t, "data/new_20240501_083000.json.gz", written)
+	result = nil
+	assert.NoError(t, ReadFileFS(fsys, "data/new_*.json.gz", &result))
+	assert.Equal(t, []CSVRecord{{Key: "c"}}, result)
+
+	// 没有匹配的文件
+	assert.ErrorIs(t, ReadFileFS(fsys, "data/none_*.json", &result), ErrNoMatch)
+}
+
+func TestReadAndWriteStdio(t *testing.T) {
+	dir := t.TempDir()
+	testData := []CSVRecord{{Key: "a", Value: "1"}}
+
+	// 写入标准输出
+	stdout, err := os.Create(filepath.Join(dir, "stdout"))
+	assert.NoError(t, err)
+	orig := os.Stdout
+	os.Stdout = stdout
+	written, err := WriteFilePath("-", testData, WithFormat("csv"))
+	os.Stdout = orig
+	assert.NoError(t, err)
+	assert.Equal(t, "-", written)
+	assert.NoError(t, stdout.Close())
+	content, err := os.ReadFile(stdout.Name())
+	assert.NoError(t, err)
+	assert.Equal(t, "Key,Value\na,1\n", string(content))
+
+	// 从标准输入读取
+	stdin, err := os.Open(stdout.Name())
+	assert.NoError(t, err)
+	defer stdin.Close()
+	origIn := os.Stdin
+	os.Stdin = stdin
+	defer func() { os.Stdin = origIn }()
+	var result []CSVRecord
+	assert.NoError(t, ReadFileWith("-", &result, WithFormat(".csv")))
+	assert.Equal(t, testData, result)
+
+	// 没有指定格式
+	assert.ErrorIs(t, ReadFile("-", &result), ErrUnsupportedFormat)
+	assert.ErrorIs(t, WriteFile("-", testData), ErrUnsupportedFormat)
+
+	// WithFormat 同样可以代替普通文件的后缀名
+	filename := filepath.Join(dir, "data.txt")
+	assert.NoError(t, WriteFileWith(filename, testData, WithFormat("json")))
+	result = nil
+	assert.NoError(t, ReadFileWith(filename, &result, WithFormat("json")))
+	assert.Equal(t, testData, result)
+}
+
+func TestReadFileURL(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/data.json":
+			w.Write([]byte(`[{"Key":"json"}]`))
+		case "/export":
+			w.Header().Set("Content-Type", "text/csv; charset=utf-8")
+			w.Write([]byte("Key,Value\ncsv,1\n"))
+		case "/auth.yaml":
+			if r.Header.Get("Authorization") != "Bearer token" {
+				http.Error(w, strings.Repeat("denied ", 200), http.StatusUnauthorized)
+				return
+			}
+			w.Write([]byte("- key: yaml\n"))
+		case "/slow.json":
+			time.Sleep(200 * time.Millisecond)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+	ctx := context.Background()
+
+	// 按 URL 后缀名选择格式
+	var result []CSVRecord
+	assert.NoError(t, ReadFileURL(ctx, server.URL+"/data.json", &result))
+	assert.Equal(t, []CSVRecord{{Key: "json"}}, result)
+
+	// 按 Content-Type 选择格式
+	result = nil
+	assert.NoError(t, ReadFileURL(ctx, server.URL+"/export", &result))
+	assert.Equal(t, []CSVRecord{{Key: "csv", Value: "1"}}, result)
+
+	// 非 200 的响应包含状态和截断的响应内容
+	err := ReadFileURL(ctx, server.URL+"/auth.yaml", &result)
+	assert.ErrorContains(t, err, "401 Unauthorized")
+	assert.ErrorContains(t, err, "denied")
+	assert.Less(t, len(err.Error()), 700)
+
+	// 自定义 http.Client 附加认证头
+	client := &http.Client{Transport: roundTripFunc(func(r *http.Request) (*http.Response, error) {
+		r.Header.Set("Authorization", "Bearer token")
+		return http.DefaultTransport.RoundTrip(r)
+	})}
+	result = nil
+	assert.NoError(t, ReadFileURL(ctx, server.URL+"/auth.yaml", &result, WithHTTPClient(client)))
+	assert.Equal(t, []CSVRecord{{Key: "yaml"}}, result)
+
+	// 遵循 ctx 的超时
+	timeout, cancel := context.WithTimeout(ctx, 50*time.Millisecond)
+	defer cancel()
+	assert.ErrorIs(t, ReadFileURL(timeout, server.URL+"/slow.json", &result), context.DeadlineExceeded)
+}
+
+type roundTripFunc func(*http.Request) (*http.Response, error)
+
+func (f roundTripFunc) RoundTrip(r *http.Request) (*http.Response, error) {
+	return f(r)
+}
+
+type StreamRecord struct {
+	Key   string `csv:"key"`
+	Count int    `csv:"count"`
+}
+
+func TestStreamCSVFile(t *testing.T) {
+	dir := t.TempDir()
+	filename := filepath.Join(dir, "data.csv.gz")
+	assert.NoError(t, WriteFile(filename, []StreamRecord{{Key: "a", Count: 1}, {Key: "b", Count: 2}, {Key: "c", Count: 3}}))
+
+	// 逐行回调
+	var keys []string
+	assert.NoError(t, StreamCSVFile(filename, func(r StreamRecord) error {
+		keys = append(keys, r.Key)
+		return nil
+	}))
+	assert.Equal(t, []string{"a", "b", "c"}, keys)
+
+	// 返回 csv.ErrStop 时提前结束
+	keys = nil
+	assert.NoError(t, StreamCSVFile(filename, func(r *StreamRecord) error {
+		keys = append(keys, r.Key)
+		if r.Key == "b" {
+			return lcsv.ErrStop
+		}
+		return nil
+	}))
+	assert.Equal(t, []string{"a", "b"}, keys)
+
+	// 回调的错误包含文件名和行号
+	err := StreamCSVFile(filename, func(r StreamRecord) error {
+		if r.Count == 2 {
+			return errors.New("boom")
+		}
+		return nil
+	})
+	assert.EqualError(t, err, filename+": row 2: boom")
+
+	// 解码错误同样包含文件名和行号
+	bad := filepath.Join(dir, "bad.csv")
+	assert.NoError(t, os.WriteFile(bad, []byte("key,count\na,1\nb,x\n"), 0o644))
+	err = StreamCSVFile(bad, func(r StreamRecord) error { return nil })
+	assert.ErrorContains(t, err, bad+": row 2")
+
+	// fn 类型错误
+	assert.Error(t, StreamCSVFile(filename, func(r StreamRecord) {}))
+	assert.Error(t, StreamCSVFile(filename, func(s string) error { return nil }))
+}
+
+func TestStreamWriteCSVFile(t *testing.T) {
+	dir := t.TempDir()
+
+	// 逐行写入直到通道关闭
+	rows := make(chan StreamRecord)
+	go func() {
+		defer close(rows)
+		for i := 1; i <= 3; i++ {
+			rows <- StreamRecord{Key: strconv.Itoa(i), Count: i}
+		}
+	}()
+	filename := filepath.Join(dir, "out.csv")
+	assert.NoError(t, StreamWriteCSVFile(filename, rows))
+	content, err := os.ReadFile(filename)
+	assert.NoError(t, err)
+	assert.Equal(t, "key,count\n1,1\n2,2\n3,3\n", string(content))
+
+	// 没有数据时只写入表头，.gz 后缀压缩写入
+	empty := make(chan *StreamRecord)
+	close(empty)
+	assert.NoError(t, StreamWriteCSVFile(filepath.Join(dir, "empty.csv.gz"), empty))
+	var result []StreamRecord
+	assert.NoError(t, ReadFile(filepath.Join(dir, "empty.csv.gz"), &result))
+	assert.Empty(t, result)
+
+	// 写入出错后仍读完通道，生产者不会阻塞
+	bad := make(chan *StreamRecord)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		defer close(bad)
+		bad <- &StreamRecord{Key: "a"}
+		bad <- nil
+		for i := 0; i < 10; i++ {
+			bad <- &StreamRecord{Key: "b"}
+		}
+	}()
+	assert.Error(t, StreamWriteCSVFile(filepath.Join(dir, "bad.csv"), bad))
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("producer is blocked")
+	}
+}
+
+func TestYAMLDocuments(t *testing.T) {
+	dir := t.TempDir()
+	testData := []CSVRecord{{Key: "a", Value: "1"}, {Key: "b", Value: "2"}}
+
+	// 写入多个文档后逐个读取
+	filename := filepath.Join(dir, "docs.yaml")
+	assert.NoError(t, WriteYAMLDocuments(filename, testData))
+	content, err := os.ReadFile(filename)
+	assert.NoError(t, err)
+	assert.Equal(t, "key: a\nvalue: \"1\"\n---\nkey: b\nvalue: \"2\"\n", string(content))
+
+	var result []CSVRecord
+	assert.NoError(t, ReadYAMLDocuments(filename, &result))
+	assert.Equal(t, testData, result)
+
+	// 空文档被跳过
+	filename = filepath.Join(dir, "k8s.yaml")
+	assert.NoError(t, os.WriteFile(filename, []byte("---\nkey: a\n---\n---\n# comment\n---\nkey: b\n"), 0o644))
+	result = nil
+	assert.NoError(t, ReadYAMLDocuments(filename, &result))
+	assert.Equal(t, []CSVRecord{{Key: "a"}, {Key: "b"}}, result)
+
+	// 解码失败时报告文档序号
+	filename = filepath.Join(dir, "bad.yaml")
+	assert.NoError(t, os.WriteFile(filename, []byte("key: a\n---\nkey: [1, 2]\n"), 0o644))
+	assert.ErrorContains(t, ReadYAMLDocuments(filename, &result), "document 2")
+
+	assert.Error(t, WriteYAMLDocuments(filepath.Join(dir, "x.yaml"), CSVRecord{}))
+}
+
+func TestFileLock(t *testing.T) {
+	dir := t.TempDir()
+	filename := filepath.Join(dir, "state.json")
+
+	// 持有锁时其他写入在超时后返回 ErrLockTimeout
+	locked := make(chan struct{})
+	release := make(chan struct{})
+	done := make(chan error)
+	go func() {
+		done <- WithFileLock(filename, func() error {
+			close(locked)
+			<-release
+			return nil
+		})
+	}()
+	<-locked
+	err := WriteFileWith(filename, []CSVRecord{{Key: "a"}}, WithLock(50*time.Millisecond))
+	assert.ErrorIs(t, err, ErrLockTimeout)
+	close(release)
+	assert.NoError(t, <-done)
+
+	// 锁释放后可以写入
+	assert.NoError(t, WriteFileWith(filename, []CSVRecord{{Key: "a"}}, WithLock(time.Second), WithAtomic()))
+
+	// 多个 goroutine 在锁内读取-修改-写入，没有丢失更新
+	counter := filepath.Join(dir, "counter.txt")
+	assert.NoError(t, os.WriteFile(counter, []byte("0"), 0o644))
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			assert.NoError(t, WithFileLock(counter, func() error {
+				content, err := os.ReadFile(counter)
+				if err != nil {
+					return err
+				}
+				n, _ := strconv.Atoi(string(content))
+				return os.WriteFile(counter, []byte(strconv.Itoa(n+1)), 0o644)
+			}))
+		}()
+	}
+	wg.Wait()
+	content, err := os.ReadFile(counter)
+	assert.NoError(t, err)
+	assert.Equal(t, "10", string(content))
+
+	// fn 的错误原样返回
+	assert.EqualError(t, WithFileLock(counter, func() error { return errors.New("boom") }), "boom")
+}
+
+type CounterState struct {
+	Count int `json:"count"`
+}
+
+func TestUpdateFile(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "state.json")
+	inc := func(s *CounterState) error {
+		s.Count++
+		return nil
+	}
+
+	// 没有文件时默认返回 ErrNoMatch
+	assert.ErrorIs(t, UpdateFile(path, inc), ErrNoMatch)
+
+	// 多个 goroutine 同时更新，没有丢失更新
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			assert.NoError(t, UpdateFile(path, inc, WithAllowMissing()))
+		}()
+	}
+	wg.Wait()
+	var state CounterState
+	assert.NoError(t, ReadFile(path, &state))
+	assert.Equal(t, 20, state.Count)
+
+	// fn 返回错误时不写入
+	assert.EqualError(t, UpdateFile(path, func(s *CounterState) error {
+		s.Count = 100
+		return errors.New("abort")
+	}), "abort")
+	assert.NoError(t, ReadFile(path, &state))
+	assert.Equal(t, 20, state.Count)
+
+	// 带时间戳的模式写回最新的文件
+	assert.NoError(t, WriteFile(filepath.Join(dir, "s_20240101_000000.yaml"), CounterState{Count: 1}))
+	assert.NoError(t, WriteFile(filepath.Join(dir, "s_20240102_000000.yaml"), CounterState{Count: 5}))
+	assert.NoError(t, UpdateFile(filepath.Join(dir, "s_*.yaml"), inc))
+	assert.NoError(t, ReadFile(filepath.Join(dir, "s_20240102_000000.yaml"), &state))
+	assert.Equal(t, 6, state.Count)
+}
+
+func TestChecksum(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "export.json")
+	data := []CSVRecord{{Key: "a", Value: "1"}}
+
+	// 校验文件与 sha256sum 的格式一致
+	assert.NoError(t, WriteFileWith(path, data, WithChecksum(crypto.SHA256)))
+	content, err := os.ReadFile(path)
+	assert.NoError(t, err)
+	sum := sha256.Sum256(content)
+	sidecar, err := os.ReadFile(path + ".sha256")
+	assert.NoError(t, err)
+	assert.Equal(t, hex.EncodeToString(sum[:])+"  export.json\n", string(sidecar))
+
+	var got []CSVRecord
+	assert.NoError(t, ReadFileVerified(path, &got))
+	assert.Equal(t, data, got)
+
+	// 内容被修改后拒绝解码
+	assert.NoError(t, os.WriteFile(path, []byte(`[{"Key":"b","Value":"2"}]`), 0o644))
+	got = nil
+	assert.ErrorIs(t, ReadFileVerified(path, &got), ErrChecksumMismatch)
+	assert.Nil(t, got)
+
+	// 校验文件不存在时默认跳过校验，WithRequireChecksum 时报错
+	assert.NoError(t, os.Remove(path+".sha256"))
+	assert.NoError(t, ReadFileVerified(path, &got))
+	assert.Equal(t, []CSVRecord{{Key: "b", Value: "2"}}, got)
+	assert.ErrorIs(t, ReadFileVerified(path, &got, WithRequireChecksum()), ErrChecksumMissing)
+
+	// 其他算法及带时间戳的文件名
+	name, err := WriteFilePath(filepath.Join(dir, "data_*.csv.gz"), data, WithChecksum(crypto.SHA512))
+	assert.NoError(t, err)
+	assert.FileExists(t, name+".sha512")
+	got = nil
+	assert.NoError(t, ReadFileVerified(filepath.Join(dir, "data_*.csv.gz"), &got, WithChecksum(crypto.SHA512), WithRequireChecksum()))
+	assert.Equal(t, data, got)
+}
+
+func TestWatchFile(t *testing.T) {
+	dir := t.TempDir()
+	pattern := filepath.Join(dir, "config_*.yaml")
+	first := filepath.Join(dir, "config_20240101_000000.yaml")
+	assert.NoError(t, os.WriteFile(first, []byte("a: 1"), 0o644))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	changes := make(chan string, 10)
+	done := make(chan error, 1)
+	go func() {
+		done <- WatchFile(ctx, pattern, 5*time.Millisecond, func(path string) {
+			changes <- path
+		}, WithInitial(), WithDebounce(20*time.Millisecond))
+	}()
+
+	wait := func() string {
+		select {
+		case path := <-changes:
+			return path
+		case <-time.After(2 * time.Second):
+			t.Fatal("timed out waiting for change")
+			return ""
+		}
+	}
+
+	// WithInitial 时立即回调已有的文件
+	assert.Equal(t, first, wait())
+
+	// 新的时间戳文件出现时回调，去抖期间的多次写入只回调一次
+	second := filepath.Join(dir, "config_20240102_000000.yaml")
+	for i := 0; i < 3; i++ {
+		assert.NoError(t, os.WriteFile(second, []byte(strings.Repeat("b", i+1)), 0o644))
+		time.Sleep(2 * time.Millisecond)
+	}
+	assert.Equal(t, second, wait())
+
+	// 同一文件的内容变化也会回调
+	assert.NoError(t, os.WriteFile(second, []byte("changed content"), 0o644))
+	assert.Equal(t, second, wait())
+
+	select {
+	case path := <-changes:
+		t.Fatalf("unexpected change: %s", path)
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	cancel()
+	assert.ErrorIs(t, <-done, context.Canceled)
+
+	// 默认不回调已有的文件
+	ctx, cancel = context.WithTimeout(context.Background(), 30*time.Millisecond)
+	defer cancel()
+	called := false
+	assert.ErrorIs(t, WatchFile(ctx, pattern, 5*time.Millisecond, func(string) { called = true }), context.DeadlineExceeded)
+	assert.False(t, called)
+}
+
+func TestWatchAndReload(t *testing.T) {
+	dir := t.TempDir()
+	pattern := filepath.Join(dir, "app_*.json")
+	assert.NoError(t, WriteFile(filepath.Join(dir, "app_20240101_000000.json"), CounterState{Count: 1}))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	values, errs := WatchAndReload[CounterState](ctx, pattern, 5*time.Millisecond, WithDebounce(10*time.Millisecond))
+
+	next := func() (CounterState, error) {
+		select {
+		case v := <-values:
+			return v, nil
+		case err := <-errs:
+			return CounterState{}, err
+		case <-time.After(2 * time.Second):
+			t.Fatal("timed out waiting for reload")
+			return CounterState{}, nil
+		}
+	}
+
+	// 启动时发送已有的文件
+	v, err := next()
+	assert.NoError(t, err)
+	assert.Equal(t, 1, v.Count)
+
+	// 新文件解码失败时发送错误
+	bad := filepath.Join(dir, "app_20240102_000000.json")
+	assert.NoError(t, os.WriteFile(bad, []byte("{"), 0o644))
+	_, err = next()
+	assert.ErrorContains(t, err, bad)
+
+	// 修复后发送新的值
+	assert.NoError(t, WriteFile(filepath.Join(dir, "app_20240103_000000.json"), CounterState{Count: 3}))
+	v, err = next()
+	assert.NoError(t, err)
+	assert.Equal(t, 3, v.Count)
+
+	// ctx 取消后通道关闭
+	cancel()
+	_, ok := <-values
+	assert.False(t, ok)
+	_, ok = <-errs
+	assert.False(t, ok)
+}
+
+func TestWaitForFile(t *testing.T) {
+	dir := t.TempDir()
+	pattern := filepath.Join(dir, "upstream_*.csv")
+
+	// 超时返回包含模式的 ctx.Err()
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	_, err := WaitForFile(ctx, pattern, 5*time.Millisecond)
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+	assert.ErrorContains(t, err, pattern)
+
+	// 已有文件时立即返回
+	existing := filepath.Join(dir, "upstream_20240101_000000.csv")
+	assert.NoError(t, os.WriteFile(existing, []byte("a\n"), 0o644))
+	path, err := WaitForFile(context.Background(), pattern, time.Hour)
+	assert.NoError(t, err)
+	assert.Equal(t, existing, path)
+
+	// 等待文件出现，WithStableSize 时等到文件不再增长
+	assert.NoError(t, os.Remove(existing))
+	target := filepath.Join(dir, "upstream_20240102_000000.csv")
+	go func() {
+		f, err := os.Create(target)
+		if err != nil {
+			return
+		}
+		defer f.Close()
+		for i := 0; i < 5; i++ {
+			f.WriteString("row\n")
+			time.Sleep(10 * time.Millisecond)
+		}
+	}()
+	ctx, cancel = context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	path, err = WaitForFile(ctx, pattern, 50*time.Millisecond, WithStableSize())
+	assert.NoError(t, err)
+	assert.Equal(t, target, path)
+	info, err := os.Stat(target)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(20), info.Size())
+}
+
+func TestLatestFileFilters(t *testing.T) {
+	dir := t.TempDir()
+	base := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	SetClock(func() time.Time { return base })
+	defer SetClock(nil)
+
+	write := func(name, content string, age time.Duration) string {
+		file := filepath.Join(dir, name)
+		assert.NoError(t, os.WriteFile(file, []byte(content), 0o644))
+		assert.NoError(t, os.Chtimes(file, base.Add(-age), base.Add(-age)))
+		return file
+	}
+	old := write("a.log", "old", time.Hour)
+	good := write("b.log", "good", 10*time.Minute)
+	write("c.log", "", 5*time.Minute)
+	write("d.log", "fresh", time.Second)
+	pattern := filepath.Join(dir, "*.log")
+
+	// 跳过崩溃留下的空文件和刚写入的文件
+	latest, err := GetLatestFileByModTime(pattern, WithMinSize(1), WithMinAge(time.Minute))
+	assert.NoError(t, err)
+	assert.Equal(t, good, latest)
+
+	latest, err = GetLatestFileByName(pattern, WithMinSize(1), WithMinAge(time.Minute))
+	assert.NoError(t, err)
+	assert.Equal(t, good, latest)
+
+	oldest, err := GetOldestFileByModTime(pattern, WithMaxAge(30*time.Minute))
+	assert.NoError(t, err)
+	assert.Equal(t, good, oldest)
+
+	entries, err := ListFiles(pattern, WithMinAge(30*time.Minute))
+	assert.NoError(t, err)
+	assert.Len(t, entries, 1)
+	assert.Equal(t, old, entries[0].Path)
+
+	// 没有满足条件的文件
+	_, err = GetLatestFileByModTime(pattern, WithMinSize(100))
+	assert.ErrorIs(t, err, ErrNoMatch)
+}
+
+func TestRecursiveGlob(t *testing.T) {
+	dir := t.TempDir()
+	files := []string{
+		"out/2024/05/01/report_20240501_000000.csv",
+		"out/2024/05/02/report_20240502_000000.csv",
+		"out/2024/04/30/report_20240430_000000.csv",
+		"out/report_20240101_000000.csv",
+		"out/2024/05/02/other.csv",
+	}
+	for _, f := range files {
+		assert.NoError(t, WriteCSVFile(filepath.Join(dir, f), []CSVRecord{{Key: f, Value: "1"}}))
+	}
+	pattern := filepath.Join(dir, "out", "**", "report_*.csv")
+
+	// ** 匹配任意层目录，包括零层
+	entries, err := ListFiles(pattern)
+	assert.NoError(t, err)
+	assert.Len(t, entries, 4)
+
+	latest, err := GetLatestFileByName(pattern)
+	assert.NoError(t, err)
+	assert.Equal(t, filepath.Join(dir, files[1]), latest)
+
+	var records []CSVRecord
+	assert.NoError(t, ReadFiles(filepath.Join(dir, "out", "**", "*.csv"), &records))
+	assert.Len(t, records, 5)
+
+	// 通配符出现在 ** 之前
+	entries, err = ListFiles(filepath.Join(dir, "o*", "2024", "**", "report_*.csv"))
+	assert.NoError(t, err)
+	assert.Len(t, entries, 3)
+
+	// 指向祖先目录的符号链接只跟随一次
+	if err := os.Symlink(filepath.Join(dir, "out"), filepath.Join(dir, "out", "2024", "loop")); err == nil {
+		entries, err = ListFiles(pattern)
+		assert.NoError(t, err)
+		assert.Len(t, entries, 8)
+	}
+
+	// io/fs 的实现同样支持
+	fsys := fstest.MapFS{
+		"a/b/c/x_20240101_000000.json": {Data: []byte(`{"count":1}`)},
+		"a/x_20240102_000000.json":     {Data: []byte(`{"count":2}`)},
+	}
+	var state CounterState
+	assert.NoError(t, ReadFileFS(fsys, "**/x_*.json", &state))
+	assert.Equal(t, 2, state.Count)
+}
+
+// statErrFS 在 Stat 指定的文件时返回错误，模拟文件在匹配后被删除或没有权限
+type statErrFS struct {
+	fstest.MapFS
+	errs map[string]error
+}
+
+func (f statErrFS) Stat(name string) (iofs.FileInfo, error) {
+	if err, ok := f.errs[name]; ok {
+		return nil, &iofs.PathError{Op: "stat", Path: name, Err: err}
+	}
+	return f.MapFS.Stat(name)
+}
+
+func TestListFilesStatErrors(t *testing.T) {
+	fsys := statErrFS{MapFS: fstest.MapFS{}, errs: map[string]error{}}
+	for i := 0; i < 200; i++ {
+		fsys.MapFS[fmt.Sprintf("data/f%03d.json", i)] = &fstest.MapFile{Data: []byte("{}"), ModTime: time.Unix(int64(i), 0)}
+	}
+
+	// 匹配后被删除的文件被跳过
+	fsys.errs["data/f199.json"] = iofs.ErrNotExist
+	fsys.errs["data/f050.json"] = iofs.ErrNotExist
+	entries, err := listFiles(context.Background(), fsys, "data/*.json", nil)
+	assert.NoError(t, err)
+	assert.Len(t, entries, 198)
+	latest, err := getFileBy(context.Background(), fsys, "data/*.json", newerByModTime, nil)
+	assert.NoError(t, err)
+	assert.Equal(t, "data/f198.json", latest)
+
+	// 其他错误仍然返回，并包含文件名
+	fsys.errs["data/f100.json"] = iofs.ErrPermission
+	_, err = listFiles(context.Background(), fsys, "data/*.json", nil)
+	assert.ErrorIs(t, err, iofs.ErrPermission)
+	assert.ErrorContains(t, err, "data/f100.json")
+}
+
+func BenchmarkGetLatestFileByModTime(b *testing.B) {
+	dir := b.TempDir()
+	for i := 0; i < 50000; i++ {
+		if err := os.WriteFile(filepath.Join(dir, fmt.Sprintf("file%05d.json", i)), nil, 0o644); err != nil {
+			b.Fatal(err)
+		}
+	}
+	pattern := filepath.Join(dir, "*.json")
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := GetLatestFileByModTime(pattern); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func TestCleanupOlderThan(t *testing.T) {
+	dir := t.TempDir()
+	SetClock(func() time.Time { return time.Date(2024, 5, 10, 0, 0, 0, 0, time.Local) })
+	defer SetClock(nil)
+
+	for _, name := range []string{"log_20240501_000000.txt", "log_20240505_000000.txt", "log_20240509_000000.txt", "log_plain.txt", "log_recent.txt"} {
+		assert.NoError(t, os.WriteFile(filepath.Join(dir, name), []byte("x"), 0o644))
+	}
+	// 没有时间戳的文件使用修改时间
+	old := time.Date(2024, 4, 1, 0, 0, 0, 0, time.Local)
+	assert.NoError(t, os.Chtimes(filepath.Join(dir, "log_plain.txt"), old, old))
+	// 目录不受影响
+	assert.NoError(t, os.Mkdir(filepath.Join(dir, "log_20240101_000000.txt"), 0o755))
+	pattern := filepath.Join(dir, "log_*.txt")
+
+	// WithDryRun 只返回将被删除的文件
+	removed, err := CleanupOlderThan(pattern, 7*24*time.Hour, WithDryRun())
+	assert.NoError(t, err)
+	want := []string{filepath.Join(dir, "log_plain.txt"), filepath.Join(dir, "log_20240501_000000.txt")}
+	assert.Equal(t, want, removed)
+	assert.FileExists(t, want[0])
+
+	removed, err = CleanupOlderThan(pattern, 7*24*time.Hour)
+	assert.NoError(t, err)
+	assert.Equal(t, want, removed)
+	assert.NoFileExists(t, want[0])
+	assert.NoFileExists(t, want[1])
+	assert.DirExists(t, filepath.Join(dir, "log_20240101_000000.txt"))
+	assert.FileExists(t, filepath.Join(dir, "log_20240505_000000.txt"))
+
+	_, err = CleanupOlderThan(filepath.Join(dir, "*"), time.Hour)
+	assert.ErrorIs(t, err, ErrPatternTooBroad)
+
+	// 删除失败时继续处理其余文件并汇总错误
+	if runtime.GOOS != "windows" && os.Geteuid() != 0 {
+		locked := filepath.Join(dir, "locked")
+		assert.NoError(t, os.Mkdir(locked, 0o755))
+		for _, name := range []string{"a_20240101_000000.txt", "b_20240101_000000.txt"} {
+			assert.NoError(t, os.WriteFile(filepath.Join(locked, name), nil, 0o644))
+		}
+		assert.NoError(t, os.WriteFile(filepath.Join(dir, "a_20240101_000000.txt"), nil, 0o644))
+		assert.NoError(t, os.Chmod(locked, 0o555))
+		defer os.Chmod(locked, 0o755)
+		removed, err = CleanupOlderThan(filepath.Join(dir, "**", "?_*.txt"), time.Hour)
+		assert.ErrorIs(t, err, iofs.ErrPermission)
+		assert.ErrorContains(t, err, "b_20240101_000000.txt")
+		assert.Equal(t, []string{filepath.Join(dir, "a_20240101_000000.txt")}, removed)
+	}
+}
+
+func TestSplitCSVFile(t *testing.T) {
+	dir := t.TempDir()
+	var data []CSVRecord
+	for i := 1; i <= 5; i++ {
+		data = append(data, CSVRecord{Key: strconv.Itoa(i), Value: "line1\nline2"})
+	}
+	assert.NoError(t, WriteCSVFile(filepath.Join(dir, "big_20240101_000000.csv"), data))
+
+	// 每个分片带有表头，多行字段不会被拆开
+	parts, err := SplitCSVFile(filepath.Join(dir, "big_*.csv"), filepath.Join(dir, "parts", "big_part{part}.csv.gz"), 2)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{
+		filepath.Join(dir, "parts", "big_part1.csv.gz"),
+		filepath.Join(dir, "parts", "big_part2.csv.gz"),
+		filepath.Join(dir, "parts", "big_part3.csv.gz"),
+	}, parts)
+
+	var merged []CSVRecord
+	for i, part := range parts {
+		var records []CSVRecord
+		assert.NoError(t, ReadFile(part, &records))
+		assert.Equal(t, data[i*2:min(i*2+2, len(data))], records)
+		merged = append(merged, records...)
+	}
+	assert.Equal(t, data, merged)
+
+	// 只有表头时创建一个只有表头的文件
+	assert.NoError(t, os.WriteFile(filepath.Join(dir, "empty.csv"), []byte("Key,Value\n"), 0o644))
+	parts, err = SplitCSVFile(filepath.Join(dir, "empty.csv"), filepath.Join(dir, "empty_{part}.csv"), 2)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{filepath.Join(dir, "empty_1.csv")}, parts)
+	content, err := os.ReadFile(parts[0])
+	assert.NoError(t, err)
+	assert.Equal(t, "Key,Value\n", string(content))
+
+	_, err = SplitCSVFile(filepath.Join(dir, "big_*.csv"), filepath.Join(dir, "out.csv"), 2)
+	assert.Error(t, err)
+}
+
+func TestMergeCSVFiles(t *testing.T) {
+	dir := t.TempDir()
+	write := func(name, content string) {
+		assert.NoError(t, os.WriteFile(filepath.Join(dir, name), []byte(content), 0o644))
+	}
+	write("part10.csv", "key,value\n10,\"multi\nline\"\n")
+	write("part2.csv", "key,value\n2,b\n")
+	write("part1.csv", "key,value\n1,a\n")
+
+	// 按自然顺序合并，表头只写一次，dst 不参与合并
+	dst := filepath.Join(dir, "part_all.csv")
+	assert.NoError(t, MergeCSVFiles(filepath.Join(dir, "part*.csv"), dst))
+	assert.NoError(t, MergeCSVFiles(filepath.Join(dir, "part*.csv"), dst))
+	content, err := os.ReadFile(dst)
+	assert.NoError(t, err)
+	assert.Equal(t, "key,value\n1,a\n2,b\n10,\"multi\nline\"\n", string(content))
+
+	// 表头不一致时返回包含文件名的错误，不留下临时文件
+	write("part3.csv", "key,note\n3,c\n")
+	err = MergeCSVFiles(filepath.Join(dir, "part?.csv"), filepath.Join(dir, "merged.csv.gz"))
+	assert.ErrorIs(t, err, ErrHeaderMismatch)
+	assert.ErrorContains(t, err, "part3.csv")
+	matches, _ := filepath.Glob(filepath.Join(dir, ".merged*"))
+	assert.Empty(t, matches)
+	assert.NoFileExists(t, filepath.Join(dir, "merged.csv.gz"))
+
+	// WithUnionHeaders 合并所有列，缺少的列留空
+	assert.NoError(t, MergeCSVFiles(filepath.Join(dir, "part?.csv"), filepath.Join(dir, "merged.csv.gz"), WithUnionHeaders()))
+	var records []OptionalCSVRecord
+	assert.NoError(t, ReadFile(filepath.Join(dir, "merged.csv.gz"), &records))
+	assert.Equal(t, []OptionalCSVRecord{{Key: "1"}, {Key: "2"}, {Key: "3", Note: "c"}}, records)
+
+	assert.ErrorIs(t, MergeCSVFiles(filepath.Join(dir, "none*.csv"), dst), ErrNoMatch)
+}
+
+func TestHeadAndTailCSVFile(t *testing.T) {
+	dir := t.TempDir()
+	var data []StreamRecord
+	for i := 1; i <= 10; i++ {
+		data = append(data, StreamRecord{Key: "k" + strconv.Itoa(i), Count: i})
+	}
+	assert.NoError(t, WriteCSVFile(filepath.Join(dir, "export_20240101_000000.csv.gz"), data))
+	pattern := filepath.Join(dir, "export_*.csv.gz")
+
+	var head []StreamRecord
+	assert.NoError(t, HeadCSVFile(pattern, 3, &head))
+	assert.Equal(t, data[:3], head)
+
+	var tail []*StreamRecord
+	assert.NoError(t, TailCSVFile(pattern, 3, &tail))
+	assert.Len(t, tail, 3)
+	for i, r := range tail {
+		assert.Equal(t, data[7+i], *r)
+	}
+
+	// n 大于行数时返回所有行，原有内容被替换
+	assert.NoError(t, HeadCSVFile(pattern, 20, &head))
+	assert.Equal(t, data, head)
+	assert.NoError(t, TailCSVFile(pattern, 20, &head))
+	assert.Equal(t, data, head)
+	assert.NoError(t, TailCSVFile(pattern, 0, &head))
+	assert.Empty(t, head)
+
+	// 只读取需要的行，后面的错误数据不影响 HeadCSVFile
+	assert.NoError(t, os.WriteFile(filepath.Join(dir, "broken.csv"), []byte("key,count\na,1\nb,x\n"), 0o644))
+	assert.NoError(t, HeadCSVFile(filepath.Join(dir, "broken.csv"), 1, &head))
+	assert.Equal(t, []StreamRecord{{Key: "a", Count: 1}}, head)
+	assert.ErrorContains(t, TailCSVFile(filepath.Join(dir, "broken.csv"), 1, &head), "broken.csv")
+
+	var wrong []int
+	assert.Error(t, HeadCSVFile(pattern, 1, &wrong))
+}
+
+func TestConvertFile(t *testing.T) {
+	dir := t.TempDir()
+	SetClock(func() time.Time { return time.Date(2024, 5, 1, 8, 30, 0, 0, time.Local) })
+	defer SetClock(nil)
+
+	// YAML 转为 JSON
+	assert.NoError(t, os.WriteFile(filepath.Join(dir, "config.yaml"), []byte("name: app\nports:\n  - 80\n  - 443\n"), 0o644))
+	assert.NoError(t, ConvertFile(filepath.Join(dir, "config.yaml"), filepath.Join(dir, "config.json")))
+	content, err := os.ReadFile(filepath.Join(dir, "config.json"))
+	assert.NoError(t, err)
+	assert.JSONEq(t, `{"name":"app","ports":[80,443]}`, string(content))
+
+	// CSV 转为 NDJSON，再转回 CSV 时保持值不变
+	assert.NoError(t, os.WriteFile(filepath.Join(dir, "data.csv"), []byte("key,value\na,1\nb,\"x,y\"\n"), 0o644))
+	name, err := ConvertFilePath(filepath.Join(dir, "data.csv"), filepath.Join(dir, "data_*.ndjson.gz"))
+	assert.NoError(t, err)
+	assert.Equal(t, filepath.Join(dir, "data_20240501_083000.ndjson.gz"), name)
+	var records []map[string]string
+	assert.NoError(t, ReadFile(name, &records))
+	assert.Equal(t, []map[string]string{{"key": "a", "value": "1"}, {"key": "b", "value": "x,y"}}, records)
+
+	assert.NoError(t, ConvertFile(filepath.Join(dir, "data_*.ndjson.gz"), filepath.Join(dir, "copy.csv")))
+	content, err = os.ReadFile(filepath.Join(dir, "copy.csv"))
+	assert.NoError(t, err)
+	assert.Equal(t, "key,value\na,1\nb,\"x,y\"\n", string(content))
+
+	// CSV 保持源文件的列顺序
+	assert.NoError(t, os.WriteFile(filepath.Join(dir, "cols.csv"), []byte("z,a\n1,2\n"), 0o644))
+	assert.NoError(t, ConvertFile(filepath.Join(dir, "cols.csv"), filepath.Join(dir, "cols.toml.csv")))
+	content, err = os.ReadFile(filepath.Join(dir, "cols.toml.csv"))
+	assert.NoError(t, err)
+	assert.Equal(t, "z,a\n1,2\n", string(content))
+
+	// 无法表示的结构返回说明原因的错误
+	err = ConvertFile(filepath.Join(dir, "config.yaml"), filepath.Join(dir, "config.csv"))
+	assert.ErrorContains(t, err, "CSV needs a list of records, got an object")
+	assert.NoError(t, os.WriteFile(filepath.Join(dir, "nested.json"), []byte(`[{"a":{"b":1}}]`), 0o644))
+	err = ConvertFile(filepath.Join(dir, "nested.json"), filepath.Join(dir, "nested.csv"))
+	assert.ErrorContains(t, err, `record 1 field "a": an object cannot be written to a CSV cell`)
+	err = ConvertFile(filepath.Join(dir, "nested.json"), filepath.Join(dir, "nested.toml"))
+	assert.ErrorContains(t, err, "TOML needs a table at the top level, got a list")
+	assert.ErrorIs(t, ConvertFile(filepath.Join(dir, "nested.json"), filepath.Join(dir, "nested.xml")), ErrUnsupportedFormat)
+}
+
+func TestDedupeCSVFile(t *testing.T) {
+	dir := t.TempDir()
+	src := filepath.Join(dir, "export_20240101_000000.csv")
+	assert.NoError(t, os.WriteFile(src, []byte("id,Name,value\n1,a,x\n2,b,y\n1,a,z\n3,b,y\n2,b,y\n"), 0o644))
+	pattern := filepath.Join(dir, "export_*.csv")
+
+	// 默认保留每组的第一行
+	dst := filepath.Join(dir, "first.csv")
+	kept, dropped, err := DedupeCSVFile(pattern, dst, []string{"id", "Name"})
+	assert.NoError(t, err)
+	assert.Equal(t, 3, kept)
+	assert.Equal(t, 2, dropped)
+	content, err := os.ReadFile(dst)
+	assert.NoError(t, err)
+	assert.Equal(t, "id,Name,value\n1,a,x\n2,b,y\n3,b,y\n", string(content))
+
+	// WithKeepLast 保留最后一行，保留的行维持原来的顺序
+	dst = filepath.Join(dir, "last.csv.gz")
+	kept, dropped, err = DedupeCSVFile(pattern, dst, []string{"Name"}, WithKeepLast())
+	assert.NoError(t, err)
+	assert.Equal(t, 2, kept)
+	assert.Equal(t, 3, dropped)
+	var rows []map[string]string
+	assert.NoError(t, ConvertFile(dst, filepath.Join(dir, "last.json")))
+	assert.NoError(t, ReadFile(filepath.Join(dir, "last.json"), &rows))
+	assert.Equal(t, []map[string]string{{"id": "1", "Name": "a", "value": "z"}, {"id": "2", "Name": "b", "value": "y"}}, rows)
+
+	// 没有键列时按整行去重
+	kept, dropped, err = DedupeCSVFile(pattern, filepath.Join(dir, "rows.csv"), nil)
+	assert.NoError(t, err)
+	assert.Equal(t, 4, kept)
+	assert.Equal(t, 1, dropped)
+
+	// 键列按名称区分大小写匹配，缺少时不写入任何内容
+	_, _, err = DedupeCSVFile(pattern, filepath.Join(dir, "missing.csv"), []string{"name"})
+	assert.ErrorContains(t, err, `key column "name" not found`)
+	assert.NoFileExists(t, filepath.Join(dir, "missing.csv"))
+}
+
+func TestDiffCSVFiles(t *testing.T) {
+	dir := t.TempDir()
+	a := filepath.Join(dir, "export_20240101_000000.csv")
+	b := filepath.Join(dir, "export_20240102_000000.csv")
+	assert.NoError(t, os.WriteFile(a, []byte("id,region,name,price\n1,eu,apple,1\n2,eu,pear,2\n3,us,plum,3\n"), 0o644))
+	assert.NoError(t, os.WriteFile(b, []byte("id,region,name,price\n2,eu,pear,2.5\n1,eu,apple,1\n4,us,kiwi,4\n"), 0o644))
+
+	d, err := DiffCSVFiles(a, b, []string{"id", "region"})
+	assert.NoError(t, err)
+	assert.False(t, d.Empty())
+	assert.Equal(t, [][]string{{"3", "us", "plum", "3"}}, d.Removed)
+	assert.Equal(t, [][]string{{"4", "us", "kiwi", "4"}}, d.Added)
+	assert.Equal(t, []RowChange{{
+		Key:     []string{"2", "eu"},
+		Row:     []string{"2", "eu", "pear", "2.5"},
+		Changes: []CellChange{{Column: "price", Old: "2", New: "2.5"}},
+	}}, d.Changed)
+
+	// 保存差异报告
+	report := filepath.Join(dir, "diff.csv")
+	assert.NoError(t, d.WriteCSV(report))
+	content, err := os.ReadFile(report)
+	assert.NoError(t, err)
+	assert.Equal(t, "change,id,region,name,price,column,old,new\n"+
+		"removed,3,us,plum,3,,,\n"+
+		"added,4,us,kiwi,4,,,\n"+
+		"changed,2,eu,pear,2.5,price,2,2.5\n", string(content))
+
+	// 相同的文件没有差异
+	d, err = DiffCSVFiles(a, a, []string{"id"})
+	assert.NoError(t, err)
+	assert.True(t, d.Empty())
+
+	// 表头不一致、缺少键列或键重复时报错
+	other := filepath.Join(dir, "other.csv")
+	assert.NoError(t, os.WriteFile(other, []byte("id,name\n1,a\n1,b\n"), 0o644))
+	_, err = DiffCSVFiles(a, other, []string{"id"})
+	assert.ErrorIs(t, err, ErrHeaderMismatch)
+	_, err = DiffCSVFiles(a, b, []string{"ID"})
+	assert.ErrorContains(t, err, `key column "ID" not found`)
+	_, err = DiffCSVFiles(other, other, []string{"id"})
+	assert.ErrorContains(t, err, "duplicate key")
+}
+
+func TestEncryptedFile(t *testing.T) {
+	dir := t.TempDir()
+	key := bytes.Repeat([]byte{7}, 32)
+	data := []CSVRecord{{Key: "ssn", Value: "123-45-6789"}}
+
+	// 按 .enc 之前的后缀名选择格式，密文中不含明文
+	path := filepath.Join(dir, "pii.csv.gz.enc")
+	assert.NoError(t, WriteEncryptedFile(path, data, key))
+	raw, err := os.ReadFile(path)
+	assert.NoError(t, err)
+	assert.Equal(t, byte(1), raw[0])
+	assert.NotContains(t, string(raw), "123-45-6789")
+
+	var got []CSVRecord
+	assert.NoError(t, ReadEncryptedFile(path, &got, key))
+	assert.Equal(t, data, got)
+
+	// 每次写入使用不同的 nonce
+	name, err := WriteEncryptedFilePath(filepath.Join(dir, "pii_*.json.enc"), data, key)
+	assert.NoError(t, err)
+	other, err := os.ReadFile(name)
+	assert.NoError(t, err)
+	assert.NoError(t, WriteEncryptedFile(name, data, key))
+	again, err := os.ReadFile(name)
+	assert.NoError(t, err)
+	assert.NotEqual(t, other, again)
+	got = nil
+	assert.NoError(t, ReadEncryptedFile(filepath.Join(dir, "pii_*.json.enc"), &got, key))
+	assert.Equal(t, data, got)
+
+	// 密钥错误或内容被篡改
+	wrong := bytes.Repeat([]byte{8}, 32)
+	assert.ErrorIs(t, ReadEncryptedFile(path, &got, wrong), ErrDecryptFailed)
+	raw[len(raw)-1] ^= 1
+	assert.NoError(t, os.WriteFile(path, raw, 0o644))
+	assert.ErrorIs(t, ReadEncryptedFile(path, &got, key), ErrDecryptFailed)
+	assert.NoError(t, os.WriteFile(path, []byte("short"), 0o644))
+	assert.ErrorIs(t, ReadEncryptedFile(path, &got, key), ErrDecryptFailed)
+
+	assert.Error(t, WriteEncryptedFile(path, data, key[:16]))
+}
+
+func TestMaxSize(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "big.json")
+	assert.NoError(t, WriteFile(path, []CSVRecord{{Key: strings.Repeat("x", 100)}}))
+
+	// 超过限制时不读取文件，错误中包含实际大小
+	var got []CSVRecord
+	err := ReadFileWith(path, &got, WithMaxSize(50))
+	assert.ErrorIs(t, err, ErrFileTooLarge)
+	assert.ErrorContains(t, err, "limit is 50")
+	assert.Nil(t, got)
+	assert.NoError(t, ReadFileWith(path, &got, WithMaxSize(1000)))
+	assert.NoError(t, ReadFileWith(path, &got, WithMaxSize(0)))
+
+	// 默认值可以调整
+	old := DefaultMaxSize
+	DefaultMaxSize = 10
+	defer func() { DefaultMaxSize = old }()
+	assert.ErrorIs(t, ReadFile(path, &got), ErrFileTooLarge)
+	assert.ErrorIs(t, ReadFileVerified(path, &got), ErrFileTooLarge)
+
+	// 压缩文件按解压后的大小检查
+	assert.NoError(t, WriteFile(filepath.Join(dir, "zeros.json.gz"), strings.Repeat("0", 10000)))
+	err = ReadFileWith(filepath.Join(dir, "zeros.json.gz"), new(string), WithMaxSize(1000))
+	assert.ErrorIs(t, err, ErrFileTooLarge)
+	assert.ErrorContains(t, err, "decompressed data")
+	assert.NoError(t, ReadFileWith(filepath.Join(dir, "zeros.json.gz"), new(string), WithMaxSize(20000)))
+	// 逐行读取的函数不受限制
+	assert.NoError(t, WriteFile(filepath.Join(dir, "big.ndjson"), []CSVRecord{{Key: strings.Repeat("x", 100)}}))
+	assert.NoError(t, ReadFile(filepath.Join(dir, "big.ndjson"), &got))
+	assert.NoError(t, WriteCSVFile(filepath.Join(dir, "big.csv"), []StreamRecord{{Key: strings.Repeat("x", 100)}}))
+	assert.NoError(t, StreamCSVFile(filepath.Join(dir, "big.csv"), func(StreamRecord) error { return nil }))
+
+	// URL 按 Content-Length 和读取的内容检查
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`[{"Key":"a","Value":"b"}]`))
+	}))
+	defer srv.Close()
+	assert.ErrorIs(t, ReadFileURL(context.Background(), srv.URL+"/data.json", &got), ErrFileTooLarge)
+	assert.NoError(t, ReadFileURL(context.Background(), srv.URL+"/data.json", &got, WithMaxSize(-1)))
+}
+
+// flakyFS 在前 failures 次 Open 时返回 err，模拟 NFS 上的暂时性错误
+type flakyFS struct {
+	fstest.MapFS
+	failures int
+	err      error
+	opens    int
+}
+
+func (f *flakyFS) Open(name string) (iofs.File, error) {
+	if f.opens++; f.opens <= f.failures {
+		return nil, &iofs.PathError{Op: "open", Path: name, Err: f.err}
+	}
+	return f.MapFS.Open(name)
+}
+
+func TestWithRetry(t *testing.T) {
+	fsys := &flakyFS{MapFS: fstest.MapFS{"data.json": {Data: []byte(`{"count":3}`)}}, failures: 2, err: syscall.EIO}
+
+	// 暂时性错误会重试
+	var state CounterState
+	assert.NoError(t, ReadFileFS(fsys, "data.json", &state, WithRetry(3, time.Millisecond)))
+	assert.Equal(t, 3, state.Count)
+
+	// 没有 WithRetry 时不重试
+	fsys.opens = 0
+	assert.ErrorIs(t, ReadFileFS(fsys, "data.json", &state), syscall.EIO)
+
+	// 次数用完时包装最后的错误并注明次数
+	fsys.opens, fsys.failures = 0, 10
+	err := ReadFileFS(fsys, "data.json", &state, WithRetry(3, time.Millisecond))
+	assert.ErrorIs(t, err, syscall.EIO)
+	assert.ErrorContains(t, err, "after 3 attempts")
+	assert.Equal(t, 3, fsys.opens)
+
+	// 解码错误不重试
+	fsys.MapFS["bad.json"] = &fstest.MapFile{Data: []byte("{")}
+	fsys.opens, fsys.failures = 0, 0
+	err = ReadFileFS(fsys, "bad.json", &state, WithRetry(3, time.Millisecond))
+	assert.Error(t, err)
+	assert.NotContains(t, err.Error(), "attempts")
+	assert.Equal(t, 1, fsys.opens)
+
+	// WithRetryMissing 时等待轮转后短暂缺失的文件
+	dir := t.TempDir()
+	path := filepath.Join(dir, "rotated.csv")
+	go func() {
+		time.Sleep(20 * time.Millisecond)
+		WriteCSVFile(path, []CSVRecord{{Key: "a", Value: "1"}})
+	}()
+	var records []CSVRecord
+	assert.ErrorIs(t, ReadCSVFile(path, &records, WithRetry(2, time.Millisecond)), ErrNoMatch)
+	assert.NoError(t, ReadCSVFile(path, &records, WithRetry(20, 5*time.Millisecond), WithRetryMissing()))
+	assert.Equal(t, []CSVRecord{{Key: "a", Value: "1"}}, records)
+}
+
+func TestWithSniffing(t *testing.T) {
+	dir := t.TempDir()
+	write := func(name, content string) string {
+		path := filepath.Join(dir, name)
+		assert.NoError(t, os.WriteFile(path, []byte(content), 0o644))
+		return path
+	}
+
+	// 默认不根据内容判断格式
+	jsonPath := write("json.dat", `[{"key":"a","count":1}]`)
+	var records []StreamRecord
+	assert.ErrorIs(t, ReadFile(jsonPath, &records), ErrUnsupportedFormat)
+
+	want := []StreamRecord{{Key: "a", Count: 1}}
+	for name, content := range map[string]string{
+		"json.dat":   `[{"key":"a","count":1}]`,
+		"yaml.dat":   "# export\n- key: a\n  count: 1\n",
+		"csv.dat":    "key,count\na,1\n",
+		"csv.gz.dat": "",
+	} {
+		path := write(name, content)
+		if name == "csv.gz.dat" {
+			assert.NoError(t, WriteFileWith(path, want, WithFormat("csv.gz")))
+		}
+		records = nil
+		assert.NoError(t, ReadFileWith(path, &records, WithSniffing(true)), name)
+		assert.Equal(t, want, records, name)
+	}
+
+	records = nil
+	assert.NoError(t, ReadFileWith(write("ndjson.dat", "{\"key\":\"a\",\"count\":1}\n{\"key\":\"a\",\"count\":1}\n"), &records, WithSniffing(true)))
+	assert.Equal(t, append(want, want...), records)
+
+	var state CounterState
+	assert.NoError(t, ReadFileWith(write("config", "---\ncount: 2\n"), &state, WithSniffing(true)))
+	assert.Equal(t, 2, state.Count)
+	assert.NoError(t, ReadFileWith(write("config.bin", "count: 3\n"), &state, WithSniffing(true)))
+	assert.Equal(t, 3, state.Count)
+
+	// 无法判断或有歧义时返回 ErrUnsupportedFormat 并列出尝试过的格式
+	err := ReadFileWith(write("plain.dat", "hello world\n"), &state, WithSniffing(true))
+	assert.ErrorIs(t, err, ErrUnsupportedFormat)
+	assert.ErrorContains(t, err, "tried JSON, NDJSON, XML, YAML and CSV")
+	err = ReadFileWith(write("both.dat", "a: 1, 2\nb: 3, 4\n"), &state, WithSniffing(true))
+	assert.ErrorIs(t, err, ErrUnsupportedFormat)
+	assert.ErrorContains(t, err, "ambiguous")
+}
+
+func TestWithCharset(t *testing.T) {
+	dir := t.TempDir()
+	data := []CSVRecord{{Key: "城市", Value: "北京"}}
+
+	// 写入 GBK 编码的文件，再以 GBK 读取
+	path := filepath.Join(dir, "gbk.csv")
+	assert.NoError(t, WriteFileWith(path, data, WithCharset("gbk")))
+	raw, err := os.ReadFile(path)
+	assert.NoError(t, err)
+	assert.False(t, utf8.Valid(raw))
+	assert.Contains(t, string(raw), "\xb3\xc7\xca\xd0")
+
+	var got []CSVRecord
+	assert.NoError(t, ReadFileWith(path, &got, WithCharset("gbk")))
+	assert.Equal(t, data, got)
+	got = nil
+	assert.NoError(t, ReadCSVFile(path, &got, WithCharset("GBK")))
+	assert.Equal(t, data, got)
+	var streamed []CSVRecord
+	assert.NoError(t, StreamCSVFile(path, func(r CSVRecord) error {
+		streamed = append(streamed, r)
+		return nil
+	}, WithCharset("gbk")))
+	assert.Equal(t, data, streamed)
+
+	// 自动判断：合法的 UTF-8 不转换，否则使用备用字符集
+	got = nil
+	assert.NoError(t, ReadFileWith(path, &got, WithCharsetDetect("gb18030")))
+	assert.Equal(t, data, got)
+	utf8Path := filepath.Join(dir, "utf8.yaml")
+	assert.NoError(t, WriteFile(utf8Path, data))
+	got = nil
+	assert.NoError(t, ReadFileWith(utf8Path, &got, WithCharsetDetect("gbk")))
+	assert.Equal(t, data, got)
+
+	// Latin-1 的 NDJSON 以流的方式转换
+	latin := filepath.Join(dir, "latin1.ndjson")
+	assert.NoError(t, os.WriteFile(latin, []byte("{\"Key\":\"caf\xe9\",\"Value\":\"\"}\n"), 0o644))
+	got = nil
+	assert.NoError(t, ReadFileWith(latin, &got, WithCharset("latin1")))
+	assert.Equal(t, []CSVRecord{{Key: "café"}}, got)
+
+	// 无法用目标字符集表示的字符、未知的字符集
+	assert.Error(t, WriteFileWith(filepath.Join(dir, "bad.json"), []CSVRecord{{Key: "😀"}}, WithCharset("gbk")))
+	assert.ErrorContains(t, ReadFileWith(path, &got, WithCharset("no-such-charset")), "unknown charset")
+}
+
+func TestReadDirFiles(t *testing.T) {
+	dir := t.TempDir()
+	assert.NoError(t, WriteFile(filepath.Join(dir, "tenants", "acme.yaml"), CounterState{Count: 1}))
+	assert.NoError(t, WriteFile(filepath.Join(dir, "tenants", "globex.v2.yaml.gz"), CounterState{Count: 2}))
+	assert.NoError(t, os.Mkdir(filepath.Join(dir, "tenants", "archive.yaml"), 0o755))
+
+	// 以不含后缀名的文件名为键，目录被跳过
+	tenants, err := ReadDirFiles[CounterState](filepath.Join(dir, "tenants", "*.yaml*"))
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]CounterState{"acme": {Count: 1}, "globex.v2": {Count: 2}}, tenants)
+
+	// 解码失败时返回所有失败的文件名
+	assert.NoError(t, os.WriteFile(filepath.Join(dir, "tenants", "bad.yaml"), []byte("count: [1"), 0o644))
+	assert.NoError(t, os.WriteFile(filepath.Join(dir, "tenants", "worse.yaml"), []byte("count: {"), 0o644))
+	tenants, err = ReadDirFiles[CounterState](filepath.Join(dir, "tenants", "*.yaml*"))
+	assert.Nil(t, tenants)
+	assert.ErrorContains(t, err, "bad.yaml")
+	assert.ErrorContains(t, err, "worse.yaml")
+
+	// WithPartialResults 同时返回其余文件的结果
+	tenants, err = ReadDirFiles[CounterState](filepath.Join(dir, "tenants", "*.yaml*"), WithPartialResults())
+	assert.Error(t, err)
+	assert.Equal(t, map[string]CounterState{"acme": {Count: 1}, "globex.v2": {Count: 2}}, tenants)
+
+	// 去掉后缀名后重名
+	assert.NoError(t, WriteFile(filepath.Join(dir, "tenants", "acme.json"), CounterState{Count: 3}))
+	_, err = ReadDirFiles[CounterState](filepath.Join(dir, "tenants", "acme.*"))
+	assert.ErrorContains(t, err, `key "acme" is already used`)
+
+	_, err = ReadDirFiles[CounterState](filepath.Join(dir, "none", "*.yaml"))
+	assert.ErrorIs(t, err, ErrNoMatch)
+}
+
+func TestWriteShardedCSVFiles(t *testing.T) {
+	dir := t.TempDir()
+	SetClock(func() time.Time { return time.Date(2024, 5, 1, 8, 30, 0, 0, time.Local) })
+	defer SetClock(nil)
+
+	var data []OptionalCSVRecord
+	for i := 1; i <= 7; i++ {
+		data = append(data, OptionalCSVRecord{Key: strconv.Itoa(i)})
+	}
+	data[6].Note = "last"
+
+	// 时间戳对所有分片只展开一次，各分片的列相同
+	paths, err := WriteShardedCSVFiles(filepath.Join(dir, "out_*_{shard}.csv"), data, 3)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{
+		filepath.Join(dir, "out_20240501_083000_1.csv"),
+		filepath.Join(dir, "out_20240501_083000_2.csv"),
+		filepath.Join(dir, "out_20240501_083000_3.csv"),
+	}, paths)
+	var all []OptionalCSVRecord
+	assert.NoError(t, ReadFiles(filepath.Join(dir, "out_*.csv"), &all))
+	assert.Equal(t, data, all)
+
+	// 空切片写入一个只有表头的文件
+	paths, err = WriteShardedCSVFiles(filepath.Join(dir, "empty_{shard}.csv"), []OptionalCSVRecord{}, 3)
+	assert.NoError(t, err)
+	assert.Len(t, paths, 1)
+	content, err := os.ReadFile(paths[0])
+	assert.NoError(t, err)
+	assert.Equal(t, "key,note\n", string(content))
+
+	// 某个分片失败时删除已写入的分片
+	assert.NoError(t, os.MkdirAll(filepath.Join(dir, "fail_2.csv"), 0o755))
+	paths, err = WriteShardedCSVFiles(filepath.Join(dir, "fail_{shard}.csv"), data, 3)
+	assert.ErrorContains(t, err, "fail_2.csv")
+	assert.Nil(t, paths)
+	assert.NoFileExists(t, filepath.Join(dir, "fail_1.csv"))
+	assert.NoFileExists(t, filepath.Join(dir, "fail_3.csv"))
+
+	// WithPartialResults 保留已写入的分片
+	paths, err = WriteShardedCSVFiles(filepath.Join(dir, "fail_{shard}.csv"), data, 3, WithPartialResults())
+	assert.Error(t, err)
+	assert.Equal(t, []string{filepath.Join(dir, "fail_1.csv"), filepath.Join(dir, "fail_3.csv")}, paths)
+
+	_, err = WriteShardedCSVFiles(filepath.Join(dir, "out.csv"), data, 3)
+	assert.Error(t, err)
+}
+
+func TestWithProgress(t *testing.T) {
+	dir := t.TempDir()
+	type call struct{ done, total int64 }
+	var calls []call
+	record := WithProgress(func(done, total int64) {
+		calls = append(calls, call{done, total})
+	})
+
+	data := make([]CSVRecord, 0, 1000)
+	for i := range 1000 {
+		data = append(data, CSVRecord{Key: strconv.Itoa(i), Value: strings.Repeat("x", 100)})
+	}
+	filename := filepath.Join(dir, "data.json")
+	assert.NoError(t, WriteFileWith(filename, data, record))
+	info, err := os.Stat(filename)
+	assert.NoError(t, err)
+	// 写入时 total 为数据长度，最后一次回调为写入的字节数
+	assert.NotEmpty(t, calls)
+	assert.LessOrEqual(t, len(calls), 2)
+	assert.Equal(t, call{info.Size(), info.Size()}, calls[len(calls)-1])
+
+	// 读取时 total 为文件大小
+	calls = nil
+	var got []CSVRecord
+	assert.NoError(t, ReadFileWith(filename, &got, record))
+	assert.Equal(t, data, got)
+	assert.LessOrEqual(t, len(calls), 2)
+	assert.Equal(t, call{info.Size(), info.Size()}, calls[len(calls)-1])
+
+	// StreamCSVFile 同样报告进度
+	csvFile := filepath.Join(dir, "data.csv")
+	assert.NoError(t, WriteFile(csvFile, data))
+	info, err = os.Stat(csvFile)
+	assert.NoError(t, err)
+	calls = nil
+	rows := 0
+	assert.NoError(t, StreamCSVFile(csvFile, func(CSVRecord) error {
+		rows++
+		return nil
+	}, record))
+	assert.Equal(t, 1000, rows)
+	assert.Equal(t, call{info.Size(), info.Size()}, calls[len(calls)-1])
+}
+
+func TestWithSync(t *testing.T) {
+	dir := t.TempDir()
+	var synced []string
+	syncFile = func(f syncer) error {
+		synced = append(synced, f.Name())
+		return f.Sync()
+	}
+	defer func() { syncFile = func(f syncer) error { return f.Sync() } }()
+
+	// 原子写入时先 fsync 临时文件，重命名后再 fsync 目录
+	filename := filepath.Join(dir, "checkpoint.json")
+	assert.NoError(t, SaveFileWith(filename, []byte(`{"count":1}`), WithAtomic(), WithSync()))
+	assert.Len(t, synced, 2)
+	assert.True(t, strings.HasPrefix(filepath.Base(synced[0]), ".checkpoint.json.tmp"))
+	assert.Equal(t, dir, synced[1])
+	content, err := os.ReadFile(filename)
+	assert.NoError(t, err)
+	assert.Equal(t, `{"count":1}`, string(content))
+
+	// 非原子写入时 fsync 目标文件和目录
+	synced = nil
+	assert.NoError(t, WriteFileWith(filename, CounterState{Count: 2}, WithSync()))
+	assert.Equal(t, []string{filename, dir}, synced)
+
+	// 未开启时不调用 fsync
+	synced = nil
+	assert.NoError(t, SaveFileWith(filename, strings.NewReader("x"), WithAtomic()))
+	assert.Empty(t, synced)
+
+	// fsync 失败时返回错误，原子写入不替换目标文件
+	syncFile = func(syncer) error { return syscall.EIO }
+	err = SaveFileWith(filename, "y", WithAtomic(), WithSync())
+	assert.ErrorIs(t, err, syscall.EIO)
+	content, err = os.ReadFile(filename)
+	assert.NoError(t, err)
+	assert.Equal(t, "x", string(content))
+}
+
+func BenchmarkSaveFileSync(b *testing.B) {
+	data := bytes.Repeat([]byte("x"), 4096)
+	for _, bc := range []struct {
+		name string
+		opts []Option
+	}{
+		{"Atomic", []Option{WithAtomic()}},
+		{"AtomicSync", []Option{WithAtomic(), WithSync()}},
+	} {
+		b.Run(bc.name, func(b *testing.B) {
+			filename := filepath.Join(b.TempDir(), "checkpoint.bin")
+			for i := 0; i < b.N; i++ {
+				if err := SaveFileWith(filename, data, bc.opts...); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+func TestReadFirstFile(t *testing.T) {
+	dir := t.TempDir()
+	local := filepath.Join(dir, "app.yaml")
+	home := filepath.Join(dir, "home", ".app.yaml")
+	etc := filepath.Join(dir, "etc", "app_*.yaml")
+	paths := []string{local, home, etc}
+
+	// 全部不存在时返回包含所有路径的 ErrNoMatch
+	var state CounterState
+	_, err := ReadFirstFile(paths, &state)
+	assert.ErrorIs(t, err, ErrNoMatch)
+	assert.ErrorContains(t, err, local)
+	assert.ErrorContains(t, err, etc)
+
+	// 路径可以是模式，选择最新的文件
+	assert.NoError(t, SaveFile(filepath.Join(dir, "etc", "app_1.yaml"), "count: 1\n"))
+	assert.NoError(t, SaveFile(filepath.Join(dir, "etc", "app_2.yaml"), "count: 2\n"))
+	got, err := ReadFirstFile(paths, &state)
+	assert.NoError(t, err)
+	assert.Equal(t, filepath.Join(dir, "etc", "app_2.yaml"), got)
+	assert.Equal(t, 2, state.Count)
+
+	// 靠前的路径优先
+	assert.NoError(t, SaveFile(home, "count: 3\n"))
+	got, err = ReadFirstFile(paths, &state)
+	assert.NoError(t, err)
+	assert.Equal(t, home, got)
+	assert.Equal(t, 3, state.Count)
+
+	// 解析失败时默认停止查找
+	assert.NoError(t, SaveFile(local, "count: [\n"))
+	got, err = ReadFirstFile(paths, &state)
+	assert.Error(t, err)
+	assert.NotErrorIs(t, err, ErrNoMatch)
+	assert.Equal(t, local, got)
+
+	// WithSkipInvalid 继续尝试下一个路径
+	state = CounterState{}
+	got, err = ReadFirstFile(paths, &state, WithSkipInvalid())
+	assert.NoError(t, err)
+	assert.Equal(t, home, got)
+	assert.Equal(t, 3, state.Count)
+
+	// 全部解析失败时错误中包含各文件的错误
+	_, err = ReadFirstFile([]string{local}, &state, WithSkipInvalid())
+	assert.ErrorIs(t, err, ErrNoMatch)
+	assert.ErrorContains(t, err, local+":")
+}
+
+func TestCopyFileAndMoveFile(t *testing.T) {
+	dir := t.TempDir()
+	SetClock(func() time.Time { return time.Date(2024, 5, 1, 8, 30, 0, 0, time.Local) })
+	defer SetClock(nil)
+
+	src := filepath.Join(dir, "src", "report.json")
+	assert.NoError(t, SaveFileWith(src, `{"count":1}`, WithFileMode(0o600)))
+
+	// 替换时间戳、创建父目录并保留权限
+	dst, err := CopyFile(filepath.Join(dir, "src", "*.json"), filepath.Join(dir, "backup", "report_*.json"))
+	assert.NoError(t, err)
+	assert.Equal(t, filepath.Join(dir, "backup", "report_20240501_083000.json"), dst)
+	info, err := os.Stat(dst)
+	assert.NoError(t, err)
+	assert.Equal(t, os.FileMode(0o600), info.Mode().Perm())
+	var state CounterState
+	assert.NoError(t, ReadFile(dst, &state))
+	assert.Equal(t, 1, state.Count)
+	assert.FileExists(t, src)
+
+	// WithFileMode 覆盖源文件的权限
+	dst, err = CopyFile(src, filepath.Join(dir, "backup", "copy.json"), WithFileMode(0o644))
+	assert.NoError(t, err)
+	info, err = os.Stat(dst)
+	assert.NoError(t, err)
+	assert.Equal(t, os.FileMode(0o644), info.Mode().Perm())
+
+	_, err = CopyFile(filepath.Join(dir, "missing.json"), filepath.Join(dir, "out.json"))
+	assert.ErrorIs(t, err, ErrNoMatch)
+
+	// 同一文件系统内直接重命名
+	dst, err = MoveFile(src, filepath.Join(dir, "archive", "report_*.json"))
+	assert.NoError(t, err)
+	assert.Equal(t, filepath.Join(dir, "archive", "report_20240501_083000.json"), dst)
+	assert.NoFileExists(t, src)
+	assert.FileExists(t, dst)
+
+}
+
+func TestZipFiles(t *testing.T) {
+	dir := t.TempDir()
+	for i, name := range []string{"report_20240501_000000.json", "report_20240502_000000.json", "report_20240503_000000.json.gz"} {
+		assert.NoError(t, WriteFile(filepath.Join(dir, "data", name), CounterState{Count: i + 1}))
+	}
+	old := time.Date(2024, 5, 1, 0, 0, 0, 0, time.Local)
+	assert.NoError(t, os.Chtimes(filepath.Join(dir, "data", "report_20240501_000000.json"), old, old))
+
+	// 只保留文件名，dstZip 本身不参与打包
+	dst := filepath.Join(dir, "data", "report_202405.zip")
+	files, err := ZipFiles(filepath.Join(dir, "data", "report_*"), dst)
+	assert.NoError(t, err)
+	assert.Len(t, files, 3)
+	zr, err := zip.OpenReader(dst)
+	assert.NoError(t, err)
+	var names []string
+	for _, f := range zr.File {
+		names = append(names, f.Name)
+	}
+	assert.Equal(t, []string{"report_20240501_000000.json", "report_20240502_000000.json", "report_20240503_000000.json.gz"}, names)
+	assert.True(t, zr.File[0].Modified.Equal(old))
+	assert.NoError(t, zr.Close())
+
+	// 按条目名选择最新的条目并解码，gzip 压缩的条目会自动解压
+	var state CounterState
+	assert.NoError(t, ReadFileFromZip(filepath.Join(dir, "data", "*.zip"), "report_*.json*", &state))
+	assert.Equal(t, 3, state.Count)
+	assert.NoError(t, ReadFileFromZip(dst, "report_20240502_*.json", &state))
+	assert.Equal(t, 2, state.Count)
+	err = ReadFileFromZip(dst, "*.yaml", &state)
+	assert.ErrorIs(t, err, ErrNoMatch)
+	assert.ErrorContains(t, err, dst)
+
+	// CSV 和 NDJSON 条目以流的方式解码，不受一次读入的大小限制
+	rows := []CSVRecord{{Key: strings.Repeat("x", 200), Value: "1"}}
+	tables := filepath.Join(dir, "tables")
+	for _, name := range []string{"rows.csv", "rows.ndjson", "rows.json"} {
+		assert.NoError(t, WriteFile(filepath.Join(tables, name), rows))
+	}
+	tablesZip := filepath.Join(dir, "tables.zip")
+	_, err = ZipFiles(filepath.Join(tables, "rows.*"), tablesZip)
+	assert.NoError(t, err)
+	for _, name := range []string{"rows.csv", "rows.ndjson"} {
+		var got []CSVRecord
+		assert.NoError(t, ReadFileFromZip(tablesZip, name, &got, WithMaxSize(50)), name)
+		assert.Equal(t, rows, got, name)
+	}
+	var got []CSVRecord
+	assert.ErrorIs(t, ReadFileFromZip(tablesZip, "rows.json", &got, WithMaxSize(50)), ErrFileTooLarge)
+
+	// 不同目录下的文件重名时返回错误且不创建压缩包
+	assert.NoError(t, WriteFile(filepath.Join(dir, "other", "report_20240501_000000.json"), CounterState{}))
+	_, err = ZipFiles(filepath.Join(dir, "*", "report_*.json"), filepath.Join(dir, "all.zip"))
+	assert.ErrorContains(t, err, "duplicate zip entry report_20240501_000000.json")
+	assert.NoFileExists(t, filepath.Join(dir, "all.zip"))
+
+	// 没有匹配的文件时返回 ErrNoMatch
+	_, err = ZipFiles(filepath.Join(dir, "missing_*"), filepath.Join(dir, "missing.zip"))
+	assert.ErrorIs(t, err, ErrNoMatch)
+}
+
+func TestStatEntryAndGetLatestEntry(t *testing.T) {
+	dir := t.TempDir()
+	stamped := filepath.Join(dir, "report_20240501_083000.json")
+	plain := filepath.Join(dir, "report.json")
+	assert.NoError(t, os.WriteFile(stamped, []byte("{}"), 0o644))
+	assert.NoError(t, os.WriteFile(plain, []byte("[]\n"), 0o644))
+	old := time.Date(2024, 4, 1, 0, 0, 0, 0, time.Local)
+	assert.NoError(t, os.Chtimes(plain, old, old))
+
+	entry, err := StatEntry(stamped)
+	assert.NoError(t, err)
+	assert.Equal(t, stamped, entry.Path)
+	assert.True(t, entry.HasTimestamp)
+	assert.Equal(t, time.Date(2024, 5, 1, 8, 30, 0, 0, time.Local), entry.Timestamp)
+	assert.Equal(t, int64(2), entry.Size)
+	assert.Equal(t, entry.Info.ModTime(), entry.ModTime)
+
+	entry, err = StatEntry(plain)
+	assert.NoError(t, err)
+	assert.False(t, entry.HasTimestamp)
+	assert.True(t, entry.ModTime.Equal(old))
+
+	// StatEntry 不按模式匹配
+	_, err = StatEntry(filepath.Join(dir, "*.json"))
+	assert.ErrorIs(t, err, os.ErrNotExist)
+
+	// GetLatestEntry 与 GetLatestFileByName 选择相同的文件
+	entry, err = GetLatestEntry(filepath.Join(dir, "report*.json"))
+	assert.NoError(t, err)
+	assert.Equal(t, stamped, entry.Path)
+	assert.True(t, entry.HasTimestamp)
+	entry, err = GetLatestEntry(filepath.Join(dir, "report*.json"), WithMinSize(3))
+	assert.NoError(t, err)
+	assert.Equal(t, plain, entry.Path)
+	assert.Equal(t, int64(3), entry.Info.Size())
+
+	_, err = GetLatestEntry(filepath.Join(dir, "*.yaml"))
+	assert.ErrorIs(t, err, ErrNoMatch)
+}
+
+func TestWithStableFor(t *testing.T) {
+	dir := t.TempDir()
+	done := filepath.Join(dir, "data_20240501_000000.json")
+	writing := filepath.Join(dir, "data_20240502_000000.json")
+	assert.NoError(t, WriteFile(done, CounterState{Count: 1}))
+	old := time.Now().Add(-time.Hour)
+	assert.NoError(t, os.Chtimes(done, old, old))
+	assert.NoError(t, os.WriteFile(writing, []byte(`{"count":`), 0o644))
+	pattern := filepath.Join(dir, "data_*.json")
+
+	// 等待期间仍在写入的文件被跳过
+	go func() {
+		time.Sleep(20 * time.Millisecond)
+		f, err := os.OpenFile(writing, os.O_APPEND|os.O_WRONLY, 0)
+		if err == nil {
+			f.WriteString("2}")
+			f.Close()
+		}
+	}()
+	start := time.Now()
+	got, err := GetLatestFileByName(pattern, WithStableFor(100*time.Millisecond))
+	assert.NoError(t, err)
+	assert.Equal(t, done, got)
+	assert.GreaterOrEqual(t, time.Since(start), 100*time.Millisecond)
+
+	// 写入完成后不再变化的文件被选中
+	var state CounterState
+	assert.NoError(t, ReadFileWith(pattern, &state, WithSelect(WithStableFor(50*time.Millisecond))))
+	assert.Equal(t, 2, state.Count)
+
+	// 所有文件都早于 d 时不等待
+	assert.NoError(t, os.Chtimes(writing, old, old))
+	start = time.Now()
+	got, err = GetLatestFileByName(pattern, WithStableFor(time.Second))
+	assert.NoError(t, err)
+	assert.Equal(t, writing, got)
+	assert.Less(t, time.Since(start), time.Second)
+}
+
+type DotenvConfig struct {
+	DatabaseURL string        `env:"DATABASE_URL"`
+	Port        int           `env:"PORT"`
+	Debug       bool          `env:"DEBUG,omitempty"`
+	Timeout     time.Duration `env:"TIMEOUT"`
+	Secret      string        `env:"-"`
+	Name        string
+}
+
+func TestDotenvFile(t *testing.T) {
+	dir := t.TempDir()
+	filename := filepath.Join(dir, ".env")
+	content := "# 部署配置\n" +
+		"export DATABASE_URL=postgres://u:p@host/db?sslmode=disable\n" +
+		"PORT = 8080 # 端口\n" +
+		"TIMEOUT='5s'\n" +
+		"Name=\"multi\nline\"\n" +
+		"HASH=a#b\n" +
+		"EMPTY=\n" +
+		"QUOTED=\"say \\\"hi\\\"\\tnow\" # 注释\n"
+	assert.NoError(t, os.WriteFile(filename, []byte(content), 0o644))
+
+	var kv map[string]string
+	assert.NoError(t, ReadFile(filename, &kv))
+	assert.Equal(t, map[string]string{
+		"DATABASE_URL": "postgres://u:p@host/db?sslmode=disable",
+		"PORT":         "8080",
+		"TIMEOUT":      "5s",
+		"Name":         "multi\nline",
+		"HASH":         "a#b",
+		"EMPTY":        "",
+		"QUOTED":       "say \"hi\"\tnow",
+	}, kv)
+
+	// 解码到结构体，没有标签的字段使用字段名，"-" 被忽略
+	var cfg DotenvConfig
+	assert.NoError(t, ReadFile(filename, &cfg))
+	assert.Equal(t, DotenvConfig{DatabaseURL: "postgres://u:p@host/db?sslmode=disable", Port: 8080, Timeout: 5 * time.Second, Name: "multi\nline"}, cfg)
+
+	// 写入时按键名排序，特殊字符的值加引号，往返后保持不变
+	tricky := map[string]string{
+		"EQUALS":   "a=b=c",
+		"SPACES":   "  padded value ",
+		"NEWLINES": "line1\nline2\r\n",
+		"QUOTES":   `it's "quoted" \ back`,
+		"COMMENT":  "value #not a comment",
+		"PLAIN":    "plain",
+	}
+	out := filepath.Join(dir, "tricky.env")
+	assert.NoError(t, WriteFile(out, tricky))
+	data, err := os.ReadFile(out)
+	assert.NoError(t, err)
+	assert.Equal(t, "COMMENT=\"value #not a comment\"\n"+
+		"EQUALS=a=b=c\n"+
+		"NEWLINES=\"line1\\nline2\\r\\n\"\n"+
+		"PLAIN=plain\n"+
+		"QUOTES=\"it's \\\"quoted\\\" \\\\ back\"\n"+
+		"SPACES=\"  padded value \"\n", string(data))
+	kv = nil
+	assert.NoError(t, ReadFile(out, &kv))
+	assert.Equal(t, tricky, kv)
+
+	// 结构体往返，omitempty 跳过零值
+	assert.NoError(t, WriteFile(out, cfg))
+	data, err = os.ReadFile(out)
+	assert.NoError(t, err)
+	assert.NotContains(t, string(data), "DEBUG")
+	var back DotenvConfig
+	assert.NoError(t, ReadFile(out, &back))
+	assert.Equal(t, cfg, back)
+
+	// 错误中包含行号
+	assert.NoError(t, os.WriteFile(out, []byte("A=1\nB\n"), 0o644))
+	assert.ErrorContains(t, ReadFile(out, &kv), "line 2")
+	assert.NoError(t, os.WriteFile(out, []byte("A=1\nB=\"open\n"), 0o644))
+	assert.ErrorContains(t, ReadFile(out, &kv), "line 2: unterminated")
+	assert.NoError(t, os.WriteFile(out, []byte("PORT=abc\n"), 0o644))
+	assert.ErrorContains(t, ReadFile(out, &cfg), "key PORT")
+	assert.ErrorContains(t, WriteFile(out, map[string]string{"BAD KEY": "x"}), "invalid dotenv key")
+}
+
+func TestPropertiesFile(t *testing.T) {
+	dir := t.TempDir()
+	filename := filepath.Join(dir, "app.properties")
+	content := "# comment\n" +
+		"! also a comment\n" +
+		"db.url = jdbc:mysql://host:3306/db\n" +
+		"name: Duke\n" +
+		"greeting Hello World\n" +
+		"path=c:\\\\temp\\\\dir\n" +
+		"long = first \\\n    second\n" +
+		"key\\ with\\ spaces=v\n" +
+		"unicode=caf\\u00e9\n"
+	assert.NoError(t, os.WriteFile(filename, []byte(content), 0o644))
+
+	var kv map[string]string
+	assert.NoError(t, ReadFile(filename, &kv))
+	assert.Equal(t, map[string]string{
+		"db.url":          "jdbc:mysql://host:3306/db",
+		"name":            "Duke",
+		"greeting":        "Hello World",
+		"path":            `c:\temp\dir`,
+		"long":            "first second",
+		"key with spaces": "v",
+		"unicode":         "café",
+	}, kv)
+
+	// 往返后保持不变
+	tricky := map[string]string{
+		"a=b":     "x=y:z",
+		"lead":    "  leading spaces",
+		"newline": "one\ntwo",
+		"hash#":   "#not comment",
+		"slash":   `back\slash\`,
+		"empty":   "",
+	}
+	out := filepath.Join(dir, "out.properties")
+	assert.NoError(t, WriteFile(out, tricky))
+	data, err := os.ReadFile(out)
+	assert.NoError(t, err)
+	assert.Equal(t, "a\\=b=x=y:z\nempty=\nhash\\#=\\#not comment\nlead=\\  leading spaces\nnewline=one\\ntwo\nslash=back\\\\slash\\\\\n", string(data))
+	kv = nil
+	assert.NoError(t, ReadFile(out, &kv))
+	assert.Equal(t, tricky, kv)
+}
+
+type INIConfig struct {
+	AppName  string `ini:"app_name"`
+	Database struct {
+		Host string `ini:"host"`
+		Port int    `ini:"port"`
+	} `ini:"database"`
+	Cache *struct {
+		TTL time.Duration `ini:"ttl"`
+	} `ini:"cache"`
+	Extra map[string]string `ini:"extra"`
+}
+
+func TestINIFile(t *testing.T) {
+	dir := t.TempDir()
+	filename := filepath.Join(dir, "legacy.ini")
+	content := "; 旧服务的配置\n" +
+		"app_name = billing\n" +
+		"\n" +
+		"[database]\n" +
+		"host = db.local ; 行内注释\n" +
+		"port: 5432\n" +
+		"unknown = ignored\n" +
+		"\n" +
+		"# 另一种注释\n" +
+		"[extra]\n" +
+		"motd = \"hello; world\"\n" +
+		"path = /var/#data\n" +
+		"\n" +
+		"[cache]\n" +
+		"ttl = 1m30s\n"
+	assert.NoError(t, os.WriteFile(filename, []byte(content), 0o644))
+
+	// 解码到 map 时保留所有节和键
+	var sections map[string]map[string]string
+	assert.NoError(t, ReadFile(filename, &sections))
+	assert.Equal(t, map[string]map[string]string{
+		"":         {"app_name": "billing"},
+		"database": {"host": "db.local", "port": "5432", "unknown": "ignored"},
+		"extra":    {"motd": "hello; world", "path": "/var/#data"},
+		"cache":    {"ttl": "1m30s"},
+	}, sections)
+
+	// 解码到结构体时按标签选择节，未知的键被忽略
+	var cfg INIConfig
+	assert.NoError(t, ReadINIFile(filename, &cfg))
+	assert.Equal(t, "billing", cfg.AppName)
+	assert.Equal(t, "db.local", cfg.Database.Host)
+	assert.Equal(t, 5432, cfg.Database.Port)
+	assert.Equal(t, 90*time.Second, cfg.Cache.TTL)
+	assert.Equal(t, map[string]string{"motd": "hello; world", "path": "/var/#data"}, cfg.Extra)
+
+	// 写入时全局键在前，节按字段顺序排列
+	out := filepath.Join(dir, "out_*.ini")
+	written, err := WriteINIFilePath(out, cfg)
+	assert.NoError(t, err)
+	data, err := os.ReadFile(written)
+	assert.NoError(t, err)
+	assert.Equal(t, "app_name = billing\n"+
+		"\n[database]\nhost = db.local\nport = 5432\n"+
+		"\n[cache]\nttl = 1m30s\n"+
+		"\n[extra]\nmotd = \"hello; world\"\npath = \"/var/#data\"\n", string(data))
+	var back INIConfig
+	assert.NoError(t, ReadFile(written, &back))
+	assert.Equal(t, cfg, back)
+
+	// map 中的节按名称排序，往返后保持不变
+	sections["database"]["padded"] = "  spaced  "
+	assert.NoError(t, WriteFile(filepath.Join(dir, "map.ini"), sections))
+	var mapBack map[string]map[string]string
+	assert.NoError(t, ReadFile(filepath.Join(dir, "map.ini"), &mapBack))
+	assert.Equal(t, sections, mapBack)
+
+	// 错误中包含行号，值不能包含换行
+	assert.NoError(t, os.WriteFile(filename, []byte("[ok]\na = 1\n[broken\n"), 0o644))
+	assert.ErrorContains(t, ReadFile(filename, &sections), "line 3")
+	assert.NoError(t, os.WriteFile(filename, []byte("[database]\nport = abc\n"), 0o644))
+	assert.ErrorContains(t, ReadFile(filename, &cfg), "section database: key port")
+	assert.ErrorContains(t, WriteFile(filepath.Join(dir, "bad.ini"), map[string]map[string]string{"s": {"k": "a\nb"}}), "newlines")
+}
+
+func TestRenderFile(t *testing.T) {
+	dir := t.TempDir()
+	SetClock(func() time.Time { return time.Date(2024, 5, 1, 8, 30, 0, 0, time.Local) })
+	defer SetClock(nil)
+	data := []CSVRecord{{Key: "a", Value: "1"}}
+
+	// 替换时间戳并按后缀名序列化，不写入磁盘
+	filename, contents, err := RenderFile(filepath.Join(dir, "out_*.json"), data)
+	assert.NoError(t, err)
+	assert.Equal(t, filepath.Join(dir, "out_20240501_083000.json"), filename)
+	assert.JSONEq(t, `[{"Key":"a","Value":"1"}]`, string(contents))
+	assert.NoFileExists(t, filename)
+
+	// 与 WriteFile 写入的内容一致，包括 gzip 压缩
+	for _, path := range []string{"out_*.csv", "out_*.yaml.gz", "out_{seq}.json"} {
+		filename, contents, err = RenderFile(filepath.Join(dir, path), data)
+		assert.NoError(t, err)
+		written, err := WriteFilePath(filepath.Join(dir, path), data)
+		assert.NoError(t, err)
+		assert.Equal(t, filename, written)
+		saved, err := os.ReadFile(written)
+		assert.NoError(t, err)
+		assert.Equal(t, contents, saved)
+	}
+
+	// {seq} 跳过已存在的文件
+	filename, _, err = RenderFile(filepath.Join(dir, "out_{seq}.json"), data)
+	assert.NoError(t, err)
+	assert.Equal(t, filepath.Join(dir, "out_2.json"), filename)
+
+	_, _, err = RenderFile(filepath.Join(dir, "out.unknown"), data)
+	assert.ErrorIs(t, err, ErrUnsupportedFormat)
+}
+
+func TestContextCancellation(t *testing.T) {
+	dir := t.TempDir()
+	filename := filepath.Join(dir, "data.json")
+	assert.NoError(t, WriteFile(filename, CounterState{Count: 1}))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	var state CounterState
+	assert.NoError(t, ReadFileCtx(ctx, filename, &state))
+	assert.Equal(t, 1, state.Count)
+
+	// ctx 结束后不再读取和写入
+	cancel()
+	assert.ErrorIs(t, ReadFileCtx(ctx, filename, &state), context.Canceled)
+	assert.ErrorIs(t, ReadFilesCtx(ctx, filepath.Join(dir, "*.json"), &[]CounterState{}), context.Canceled)
+	assert.ErrorIs(t, WriteFileCtx(ctx, filepath.Join(dir, "new.json"), state), context.Canceled)
+	assert.NoFileExists(t, filepath.Join(dir, "new.json"))
+
+	// 文件信息较多时在读取之间检查 ctx
+	for i := range parallelStatMin * 2 {
+		assert.NoError(t, os.WriteFile(filepath.Join(dir, fmt.Sprintf("f%03d.txt", i)), nil, 0o644))
+	}
+	_, err := listFiles(ctx, osFS{}, filepath.Join(dir, "*.txt"), nil)
+	assert.ErrorIs(t, err, context.Canceled)
+
+	// WithRetry 的等待随 ctx 结束
+	ctx, cancel = context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	err = ReadFileCtx(ctx, filepath.Join(dir, "missing.json"), &state, WithRetry(10, time.Second), WithRetryMissing())
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+	assert.Less(t, time.Since(start), time.Second)
+
+	// WithStableFor 的等待随 ctx 结束
+	ctx, cancel = context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	start = time.Now()
+	err = ReadFileCtx(ctx, filename, &state, WithSelect(WithStableFor(time.Minute)))
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+	assert.Less(t, time.Since(start), time.Second)
+
+	// 等待文件锁时随 ctx 结束
+	unlock, err := lockFile(context.Background(), filename, 0)
+	assert.NoError(t, err)
+	defer unlock()
+	ctx, cancel = context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	err = WriteFileCtx(ctx, filename, state, WithLock(-1))
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+}
+
+func TestContentAddressedFileName(t *testing.T) {
+	dir := t.TempDir()
+	data := []CSVRecord{{Key: "a", Value: "1"}}
+
+	written, err := WriteFilePath(filepath.Join(dir, "app.{hash}.json"), data)
+	assert.NoError(t, err)
+	content, err := os.ReadFile(written)
+	assert.NoError(t, err)
+	sum := sha256.Sum256(content)
+	assert.Equal(t, filepath.Join(dir, "app."+hex.EncodeToString(sum[:])[:12]+".json"), written)
+
+	// 相同的内容得到相同的文件名，与其他占位符一起使用
+	again, err := WriteFilePath(filepath.Join(dir, "app.{hash}.json"), data)
+	assert.NoError(t, err)
+	assert.Equal(t, written, again)
+	filename, _, err := RenderFile(filepath.Join(dir, "{ts:2006}-{hash}.json.gz"), data)
+	assert.NoError(t, err)
+	assert.Regexp(t, `\d{4}-[0-9a-f]{12}\.json\.gz$`, filename)
+
+	// 模板可直接用于查找和校验
+	ok, err := VerifyContentAddressed(filepath.Join(dir, "app.{hash}.json"))
+	assert.NoError(t, err)
+	assert.True(t, ok)
+	assert.NoError(t, os.WriteFile(written, []byte("[]"), 0o644))
+	ok, err = VerifyContentAddressed(written)
+	assert.NoError(t, err)
+	assert.False(t, ok)
+
+	assert.NoError(t, WriteFile(filepath.Join(dir, "plain.json"), data))
+	_, err = VerifyContentAddressed(filepath.Join(dir, "plain.json"))
+	assert.ErrorContains(t, err, "no content hash")
+	_, err = VerifyContentAddressed(filepath.Join(dir, "missing.{hash}.json"))
+	assert.ErrorIs(t, err, ErrNoMatch)
+
+	// ExpandFileName 保留 {hash}，GlobPattern 将其替换为 *
+	assert.Equal(t, "app.{hash}.json", ExpandFileName("app.{hash}.json", time.Now(), "", 0))
+	assert.Equal(t, "app.*.json", GlobPattern("app.{hash}.json"))
+}
+
+func TestDefaultExcludePatterns(t *testing.T) {
+	dir := t.TempDir()
+	real := filepath.Join(dir, "report_20240101_000000.csv")
+	assert.NoError(t, WriteFile(real, []CSVRecord{{Key: "a", Value: "1"}}))
+	// 比真实文件更新的干扰文件
+	for _, name := range []string{
+		".#report_20240102_000000.csv",
+		".report_20240103_000000.csv.swp",
+		"report_20240104_000000.csv~",
+		"report_20240105_000000.csv.swp",
+		"report_20240106_000000.csv.tmp",
+		"report_20240107_000000.csv.partial",
+	} {
+		assert.NoError(t, os.WriteFile(filepath.Join(dir, name), []byte("garbage"), 0o644))
+	}
+
+	for _, pattern := range []string{"report_*", "*", "*report_*"} {
+		pattern = filepath.Join(dir, pattern)
+		got, err := GetLatestFileByName(pattern)
+		assert.NoError(t, err)
+		assert.Equal(t, real, got)
+		got, err = GetLatestFileByModTime(pattern)
+		assert.NoError(t, err)
+		assert.Equal(t, real, got)
+		got, err = GetLatestFileByNameLexicographic(pattern)
+		assert.NoError(t, err)
+		assert.Equal(t, real, got)
+		entries, err := ListFiles(pattern)
+		assert.NoError(t, err)
+		assert.Len(t, entries, 1)
+		var records []CSVRecord
+		assert.NoError(t, ReadFiles(pattern, &records))
+		assert.Equal(t, []CSVRecord{{Key: "a", Value: "1"}}, records)
+	}
+
+	// 模式本身指向这类文件时不排除
+	got, err := GetLatestFileByName(filepath.Join(dir, "*.partial"))
+	assert.NoError(t, err)
+	assert.Equal(t, filepath.Join(dir, "report_20240107_000000.csv.partial"), got)
+	got, err = GetLatestFileByName(filepath.Join(dir, ".#report_*"))
+	assert.NoError(t, err)
+	assert.Equal(t, filepath.Join(dir, ".#report_20240102_000000.csv"), got)
+
+	// 排除列表可以替换
+	got, err = GetLatestFileByName(filepath.Join(dir, "report_*"), WithExcludePatterns("*.partial"))
+	assert.NoError(t, err)
+	assert.Equal(t, filepath.Join(dir, "report_20240106_000000.csv.tmp"), got)
+	var records []CSVRecord
+	err = ReadFiles(filepath.Join(dir, "report_*.csv*"), &records, WithSelect(WithExcludePatterns()))
+	assert.Error(t, err)
+}
+
+func TestReadFileAs(t *testing.T) {
+	dir := t.TempDir()
+	state := CounterState{Count: 3}
+	assert.NoError(t, WriteJsonFile(filepath.Join(dir, "state.json"), state))
+	assert.NoError(t, WriteYAMLFile(filepath.Join(dir, "state.yaml"), state))
+	records := []CSVRecord{{Key: "a", Value: "1"}, {Key: "b", Value: "2"}}
+	assert.NoError(t, WriteCSVFile(filepath.Join(dir, "records.csv"), records))
+
+	got, err := ReadFileAs[CounterState](filepath.Join(dir, "state.json"))
+	assert.NoError(t, err)
+	assert.Equal(t, state, got)
+	got, err = ReadJsonFileAs[CounterState](filepath.Join(dir, "state.json"))
+	assert.NoError(t, err)
+	assert.Equal(t, state, got)
+	got, err = ReadYAMLFileAs[CounterState](filepath.Join(dir, "state.yaml"))
+	assert.NoError(t, err)
+	assert.Equal(t, state, got)
+	rows, err := ReadCSVFileAs[CSVRecord](filepath.Join(dir, "records.csv"))
+	assert.NoError(t, err)
+	assert.Equal(t, records, rows)
+
+	// 出错时返回零值
+	assert.NoError(t, os.WriteFile(filepath.Join(dir, "bad.json"), []byte(`{"count":`), 0o644))
+	got, err = ReadFileAs[CounterState](filepath.Join(dir, "bad.json"))
+	assert.Error(t, err)
+	assert.Zero(t, got)
+	_, err = ReadFileAs[CounterState](filepath.Join(dir, "missing_*.json"))
+	assert.ErrorIs(t, err, ErrNoMatch)
+}
+
+func TestFilesEqual(t *testing.T) {
+	dir := t.TempDir()
+	write := func(name, content string) string {
+		path := filepath.Join(dir, name)
+		assert.NoError(t, os.WriteFile(path, []byte(content), 0o644))
+		return path
+	}
+
+	// 键的顺序、空白和末尾换行不影响结果
+	a := write("a.json", `{"name":"svc","ports":[80,443],"tls":{"enabled":true}}`)
+	b := write("b.json", "{\n  \"tls\": {\"enabled\": true},\n  \"ports\": [80, 443],\n  \"name\": \"svc\"\n}\n")
+	equal, diff, err := FilesEqual(a, b)
+	assert.NoError(t, err)
+	assert.True(t, equal)
+	assert.Empty(t, diff)
+
+	// 不同格式解码为相同的结构
+	y := write("a.yaml", "name: svc\nports:\n  - 80\n  - 443\ntls:\n  enabled: true\n")
+	equal, _, err = FilesEqual(a, y)
+	assert.NoError(t, err)
+	assert.True(t, equal)
+
+	// CSV 列的顺序不影响结果，也可以与 NDJSON 比较
+	c1 := write("a.csv", "key,value\na,1\nb,2\n")
+	c2 := write("b.csv", "value,key\n1,a\n2,b\n")
+	nd := write("a.ndjson", "{\"key\":\"a\",\"value\":1}\n{\"key\":\"b\",\"value\":2}\n")
+	equal, _, err = FilesEqual(c1, c2)
+	assert.NoError(t, err)
+	assert.True(t, equal)
+	equal, _, err = FilesEqual(c1, nd)
+	assert.NoError(t, err)
+	assert.True(t, equal)
+
+	// 返回第一处差异
+	d := write("d.json", `{"name":"svc","ports":[80,8443],"tls":{"enabled":true}}`)
+	equal, diff, err = FilesEqual(a, d)
+	assert.NoError(t, err)
+	assert.False(t, equal)
+	assert.Equal(t, "$.ports[1]: A has 443, B has 8443", diff)
+	e := write("e.json", `{"name":"svc","ports":[80,443]}`)
+	_, diff, _ = FilesEqual(a, e)
+	assert.Equal(t, "$.tls: missing in B, A has an object with 1 keys", diff)
+	f := write("f.json", `{"name":"svc","ports":[80],"tls":{"enabled":true}}`)
+	_, diff, _ = FilesEqual(a, f)
+	assert.Equal(t, "$.ports: A has 2 elements, B has 1", diff)
+	_, diff, _ = FilesEqual(c1, write("c.csv", "key,value\na,1\nb,x\n"))
+	assert.Equal(t, `$[1].value: A has 2, B has "x"`, diff)
+
+	// 文件不存在或格式不支持时返回错误
+	_, _, err = FilesEqual(a, filepath.Join(dir, "missing_*.json"))
+	assert.ErrorIs(t, err, ErrNoMatch)
+	_, _, err = FilesEqual(a, write("a.xml", "<a/>"))
+	assert.ErrorIs(t, err, ErrUnsupportedFormat)
+}
+
+func TestAppendJSONArrayFile(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "events.json")
+
+	// 文件不存在时创建
+	assert.NoError(t, AppendJSONArrayFile(path, CSVRecord{Key: "a", Value: "1"}))
+	bs, err := os.ReadFile(path)
+	assert.NoError(t, err)
+	assert.Equal(t, `[{"Key":"a","Value":"1"}]`+"\n", string(bs))
+	assert.NoError(t, AppendJSONArrayFile(path, CSVRecord{Key: "b", Value: "2"}))
+	bs, _ = os.ReadFile(path)
+	assert.Equal(t, `[{"Key":"a","Value":"1"},{"Key":"b","Value":"2"}]`+"\n", string(bs))
+
+	// 保留带缩进的格式
+	pretty := filepath.Join(dir, "pretty.json")
+	assert.NoError(t, os.WriteFile(pretty, []byte("[\n    {\n        \"count\": 1\n    }\n]\n"), 0o644))
+	assert.NoError(t, AppendJSONArrayFile(pretty, CounterState{Count: 2}))
+	bs, _ = os.ReadFile(pretty)
+	assert.Equal(t, "[\n    {\n        \"count\": 1\n    },\n    {\n        \"count\": 2\n    }\n]\n", string(bs))
+
+	// 空文件和空数组
+	empty := filepath.Join(dir, "empty.json")
+	assert.NoError(t, os.WriteFile(empty, []byte("  \n"), 0o644))
+	assert.NoError(t, AppendJSONArrayFile(empty, 1))
+	bs, _ = os.ReadFile(empty)
+	assert.Equal(t, "[1]\n", string(bs))
+	emptyArray := filepath.Join(dir, "empty_array.json")
+	assert.NoError(t, os.WriteFile(emptyArray, []byte("[ ]"), 0o644))
+	assert.NoError(t, AppendJSONArrayFile(emptyArray, 1))
+	bs, _ = os.ReadFile(emptyArray)
+	assert.Equal(t, "[1 ]", string(bs))
+
+	// 不是数组时报错且不修改文件
+	object := filepath.Join(dir, "object.json")
+	assert.NoError(t, os.WriteFile(object, []byte(`{"a":1}`), 0o644))
+	assert.Error(t, AppendJSONArrayFile(object, 1))
+	bs, _ = os.ReadFile(object)
+	assert.Equal(t, `{"a":1}`, string(bs))
+	assert.ErrorIs(t, AppendJSONArrayFile(filepath.Join(dir, "events.json.gz"), 1), ErrUnsupportedFormat)
+
+	// 并发追加时不丢失元素
+	concurrent := filepath.Join(dir, "concurrent.json")
+	var wg sync.WaitGroup
+	for i := range 20 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			assert.NoError(t, AppendJSONArrayFile(concurrent, i))
+		}()
+	}
+	wg.Wait()
+	var got []int
+	assert.NoError(t, ReadFile(concurrent, &got))
+	slices.Sort(got)
+	want := make([]int, 20)
+	for i := range want {
+		want[i] = i
+	}
+	assert.Equal(t, want, got)
+}
+
+func TestAppendNDJSONFile(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "events.ndjson")
+
+	// 文件不存在时创建，切片的每个元素占一行
+	assert.NoError(t, AppendNDJSONFile(path, []CounterState{{Count: 1}, {Count: 2}}))
+	assert.NoError(t, AppendNDJSONFile(path, CounterState{Count: 3}, WithSync()))
+	bs, err := os.ReadFile(path)
+	assert.NoError(t, err)
+	assert.Equal(t, "{\"count\":1}\n{\"count\":2}\n{\"count\":3}\n", string(bs))
+
+	var got []CounterState
+	assert.NoError(t, ReadFile(path, &got))
+	assert.Equal(t, []CounterState{{Count: 1}, {Count: 2}, {Count: 3}}, got)
+
+	assert.ErrorIs(t, AppendNDJSONFile(filepath.Join(dir, "events.ndjson.gz"), 1), ErrUnsupportedFormat)
+	assert.Error(t, AppendNDJSONFile(path, func() {}))
+}
+
+func TestStreamNDJSONFile(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "events_*.ndjson")
+	assert.NoError(t, os.WriteFile(filepath.Join(dir, "events_20240101_000000.ndjson"), []byte("{\"count\":1}\n\n{\"count\":2}\n{\"count\":3}"), 0o644))
+
+	var sum int
+	assert.NoError(t, StreamNDJSONFile(path, func(s CounterState) error {
+		sum += s.Count
+		return nil
+	}))
+	assert.Equal(t, 6, sum)
+
+	// 指针参数和非结构体类型
+	var counts []int
+	assert.NoError(t, StreamNDJSONFile(path, func(m *map[string]int) error {
+		counts = append(counts, (*m)["count"])
+		return nil
+	}))
+	assert.Equal(t, []int{1, 2, 3}, counts)
+
+	// csv.ErrStop 提前结束
+	var n int
+	assert.NoError(t, StreamNDJSONFile(path, func(s CounterState) error {
+		n++
+		return lcsv.ErrStop
+	}))
+	assert.Equal(t, 1, n)
+
+	// 错误包含文件名和行号
+	err := StreamNDJSONFile(path, func(s CounterState) error {
+		if s.Count == 2 {
+			return errors.New("boom")
+		}
+		return nil
+	})
+	assert.ErrorContains(t, err, "events_20240101_000000.ndjson: line 3: boom")
+	assert.NoError(t, os.WriteFile(filepath.Join(dir, "events_20240102_000000.ndjson"), []byte("{\"count\":1}\n{\"count\":\n"), 0o644))
+	err = StreamNDJSONFile(path, func(s CounterState) error { return nil })
+	assert.ErrorContains(t, err, "events_20240102_000000.ndjson: line 2:")
+
+	assert.Error(t, StreamNDJSONFile(path, func(s CounterState) {}))
+	assert.ErrorIs(t, StreamNDJSONFile(filepath.Join(dir, "missing_*.ndjson"), func(s CounterState) error { return nil }), ErrNoMatch)
+}
+
+func TestSanitizeFileName(t *testing.T) {
+	tests := map[string]string{
+		"acme":              "acme",
+		"../../etc/passwd":  "_.._etc_passwd",
+		`a\b/c`:             "a_b_c",
+		"a:b*c?d<e>f|g\"h":  "a_b_c_d_e_f_g_h",
+		"{host}":            "_host_",
+		"  big \t\n corp  ": "big corp",
+		"bell\x07\x00name":  "bellname",
+		"trailing...":       "trailing",
+		"..":                "_",
+		".":                 "_",
+		"":                  "_",
+		" \t ":              "_",
+		"CON":               "_CON",
+		"com1.txt":          "_com1.txt",
+		"console":           "console",
+		"数据 报表":             "数据 报表",
+	}
+	for in, want := range tests {
+		assert.Equal(t, want, SanitizeFileName(in), in)
+	}
+
+	// 清理后的片段拼接的模式不会超出目录
+	dir := t.TempDir()
+	path, err := WriteFilePath(filepath.Join(dir, "export_"+SanitizeFileName("../evil")+"_*.csv"), []CSVRecord{{Key: "a", Value: "1"}})
+	assert.NoError(t, err)
+	assert.Equal(t, dir, filepath.Dir(path))
+}
+
+func TestSafeJoin(t *testing.T) {
+	dir := filepath.Join("data", "exports")
+	for name, want := range map[string]string{
+		"a.csv":                        filepath.Join(dir, "a.csv"),
+		"sub/a.csv":                    filepath.Join(dir, "sub", "a.csv"),
+		"sub/../a.csv":                 filepath.Join(dir, "a.csv"),
+		"./a.csv":                      filepath.Join(dir, "a.csv"),
+		"..a.csv":                      filepath.Join(dir, "..a.csv"),
+		"sub/../../x/../exports/a.csv": filepath.Join(dir, "a.csv"),
+	} {
+		got, err := SafeJoin(dir, name)
+		assert.NoError(t, err, name)
+		assert.Equal(t, want, got, name)
+	}
+	for _, name := range []string{"../a.csv", "sub/../../a.csv", "..", ".", "", "/etc/passwd"} {
+		_, err := SafeJoin(dir, name)
+		assert.ErrorIs(t, err, ErrPathTraversal, name)
+	}
+}
+
+func TestWithSanitizeNames(t *testing.T) {
+	dir := t.TempDir()
+	SetClock(func() time.Time { return time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC) })
+	defer SetClock(nil)
+
+	// 未清理时 {ts:layout} 中的 / 产生子目录
+	filename, _, err := RenderFile(filepath.Join(dir, "report_{ts:2006/01/02}.json"), 1)
+	assert.NoError(t, err)
+	assert.Equal(t, filepath.Join(dir, "report_2024", "01", "02.json"), filename)
+	filename, _, err = RenderFile(filepath.Join(dir, "report_{ts:2006/01/02}.json"), 1, WithSanitizeNames())
+	assert.NoError(t, err)
+	assert.Equal(t, filepath.Join(dir, "report_2024_01_02.json"), filename)
+	filename, _, err = RenderFile(filepath.Join(dir, "sub", "{host}_{ts}.json"), 1, WithSanitizeNames())
+	assert.NoError(t, err)
+	assert.Equal(t, filepath.Join(dir, "sub", SanitizeFileName(hostname())+"_20240102_030405.json"), filename)
+}
+
+func TestSetFormatOptions(t *testing.T) {
+	dir := t.TempDir()
+	records := []CSVRecord{{Key: "a", Value: "1"}}
+	state := CounterState{Count: 1}
+	render := func(name string, data any, opts ...Option) string {
+		_, bs, err := RenderFile(filepath.Join(dir, name), data, opts...)
+		assert.NoError(t, err)
+		return string(bs)
+	}
+
+	// 内置默认值
+	assert.Equal(t, "Key,Value\na,1\n", render("a.csv", records))
+	assert.Equal(t, `{"count":1}`, render("a.json", state))
+
+	SetFormatOptions(".csv", lcsv.WithDelimiter(';'))
+	SetFormatOptions("json", JSONOptions{Indent: "  "})
+	SetFormatOptions(".yml", YAMLOptions{Indent: 2, KnownFields: true})
+	t.Cleanup(func() {
+		SetFormatOptions(".csv", nil)
+		SetFormatOptions(".json", nil)
+		SetFormatOptions(".yaml", nil)
+	})
+
+	// 注册的默认值代替内置默认值
+	assert.Equal(t, "Key;Value\na;1\n", render("a.csv", records))
+	assert.Equal(t, "{\n  \"count\": 1\n}", render("a.json", state))
+	assert.Equal(t, "outer:\n  count: 1\n", render("a.yaml", map[string]CounterState{"outer": state}))
+
+	// 单次调用的选项优先
+	assert.Equal(t, "Key|Value\na|1\n", render("a.csv", records, WithCSVOptions(lcsv.WithDelimiter('|'))))
+	assert.Equal(t, `{"count":1}`, render("a.json", state, WithJSONOptions(JSONOptions{})))
+
+	// 读取同样使用注册的选项
+	csvPath := filepath.Join(dir, "records.csv")
+	assert.NoError(t, WriteCSVFile(csvPath, records))
+	var got []CSVRecord
+	assert.NoError(t, ReadFile(csvPath, &got))
+	assert.Equal(t, records, got)
+	got = nil
+	assert.NoError(t, ReadCSVFile(csvPath, &got))
+	assert.Equal(t, records, got)
+	bs, err := os.ReadFile(csvPath)
+	assert.NoError(t, err)
+	assert.Equal(t, "Key;Value\na;1\n", string(bs))
+
+	yamlPath := filepath.Join(dir, "config.yml")
+	assert.NoError(t, os.WriteFile(yamlPath, []byte("count: 1\nextra: true\n"), 0o644))
+	var s CounterState
+	assert.Error(t, ReadFile(yamlPath, &s))
+	assert.NoError(t, ReadYAMLFile(yamlPath, &s, WithYAMLOptions(YAMLOptions{})))
+	assert.Equal(t, state, s)
+
+	assert.Panics(t, func() { SetFormatOptions(".json", YAMLOptions{}) })
+	assert.Panics(t, func() { SetFormatOptions(".toml", JSONOptions{}) })
+}
+
+func TestErrorsIncludeFileAndFormat(t *testing.T) {
+	dir := t.TempDir()
+	pattern := filepath.Join(dir, "report_*.csv")
+	assert.NoError(t, os.WriteFile(filepath.Join(dir, "report_20240101_000000.csv"), []byte("Key,Value\na,1\n"), 0o644))
+	latest := filepath.Join(dir, "report_20240102_000000.csv")
+	assert.NoError(t, os.WriteFile(latest, []byte("Key,Value\n\"a,1\n"), 0o644))
+
+	// ResolvePath 返回 ReadFile 选择的文件
+	got, err := ResolvePath(pattern)
+	assert.NoError(t, err)
+	assert.Equal(t, latest, got)
+	got, err = ResolvePath(stdioPath)
+	assert.NoError(t, err)
+	assert.Equal(t, stdioPath, got)
+	_, err = ResolvePath(filepath.Join(dir, "missing_*.csv"))
+	assert.ErrorIs(t, err, ErrNoMatch)
+
+	var records []CSVRecord
+	err = ReadFile(pattern, &records)
+	assert.ErrorContains(t, err, "read file "+latest+" (csv): unmarshal data: ")
+	err = ReadCSVFile(pattern, &records)
+	assert.ErrorContains(t, err, "read file "+latest+" (csv): unmarshal data: ")
+
+	ndjson := filepath.Join(dir, "events.ndjson.gz")
+	assert.NoError(t, os.WriteFile(ndjson, []byte("{"), 0o644))
+	err = ReadFile(ndjson, &records)
+	assert.ErrorContains(t, err, "read file "+ndjson+" (ndjson, gzip): ")
+
+	// 写入错误同样包含文件名和格式，错误链保持不变
+	err = WriteFile(filepath.Join(dir, "out.unknown"), records)
+	assert.ErrorIs(t, err, ErrUnsupportedFormat)
+	assert.ErrorContains(t, err, "write file "+filepath.Join(dir, "out.unknown")+" (unknown): ")
+	err = WriteFile(filepath.Join(dir, "out.json"), func() {})
+	assert.ErrorContains(t, err, "write file "+filepath.Join(dir, "out.json")+" (json): ")
+	assert.NoError(t, os.Mkdir(filepath.Join(dir, "taken.json"), 0o755))
+	err = WriteFileWith(filepath.Join(dir, "taken.json"), 1, WithCreateDirs(false))
+	assert.ErrorContains(t, err, "write file "+filepath.Join(dir, "taken.json")+" (json): ")
+}
+
+func TestWithMinFreeSpace(t *testing.T) {
+	dir := t.TempDir()
+	var free int64 = 1000
+	diskSpace = func(string) (int64, bool, error) { return free, true, nil }
+	defer func() { diskSpace = availableSpace }()
+
+	// 空间足够时正常写入
+	path := filepath.Join(dir, "a.txt")
+	assert.NoError(t, SaveFileWith(path, []byte("hello"), WithMinFreeSpace(900)))
+
+	// 空间不足时不创建文件，目录尚未创建时同样检查
+	for _, name := range []string{"b.txt", filepath.Join("sub", "b.txt")} {
+		err := SaveFileWith(filepath.Join(dir, name), make([]byte, 200), WithMinFreeSpace(900))
+		assert.ErrorIs(t, err, ErrInsufficientSpace)
+		assert.NoFileExists(t, filepath.Join(dir, name))
+	}
+	err := WriteFileWith(filepath.Join(dir, "c.json"), []int{1}, WithMinFreeSpace(2000), WithAtomic())
+	assert.ErrorIs(t, err, ErrInsufficientSpace)
+	assert.ErrorContains(t, err, "write file "+filepath.Join(dir, "c.json")+" (json): ")
+
+	// 不足时先清理再检查一次
+	for i := 1; i <= 3; i++ {
+		assert.NoError(t, os.WriteFile(filepath.Join(dir, fmt.Sprintf("log_2024010%d_000000.txt", i)), []byte("x"), 0o644))
+	}
+	cleaned := 0
+	cleanup := func() error {
+		cleaned++
+		removed, err := RotateFiles(filepath.Join(dir, "log_*.txt"), 1)
+		free += int64(len(removed)) * 500
+		return err
+	}
+	assert.NoError(t, SaveFileWith(filepath.Join(dir, "d.txt"), []byte("data"), WithMinFreeSpace(1500), WithLowSpaceCleanup(cleanup)))
+	assert.Equal(t, 1, cleaned)
+	assert.FileExists(t, filepath.Join(dir, "d.txt"))
+	assert.NoFileExists(t, filepath.Join(dir, "log_20240101_000000.txt"))
+
+	// 清理后仍然不足
+	err = SaveFileWith(filepath.Join(dir, "e.txt"), []byte("data"), WithMinFreeSpace(5000), WithLowSpaceCleanup(cleanup))
+	assert.ErrorIs(t, err, ErrInsufficientSpace)
+	assert.Equal(t, 2, cleaned)
+	err = SaveFileWith(filepath.Join(dir, "e.txt"), []byte("data"), WithMinFreeSpace(5000), WithLowSpaceCleanup(func() error { return errors.New("boom") }))
+	assert.ErrorContains(t, err, "free disk space: boom")
+
+	// 平台不支持时不检查
+	diskSpace = func(string) (int64, bool, error) { return 0, false, nil }
+	assert.NoError(t, SaveFileWith(filepath.Join(dir, "f.txt"), []byte("data"), WithMinFreeSpace(5000)))
+
+	// 实际查询当前平台的可用空间
+	avail, ok, err := availableSpace(dir)
+	assert.NoError(t, err)
+	if ok {
+		assert.Greater(t, avail, int64(0))
+	}
+}
+
+func TestLegacySignatures(t *testing.T) {
+	dir := t.TempDir()
+
+	// ReadFile、WriteFile 仍可直接传入序列化函数
+	filename := filepath.Join(dir, "data.txt")
+	assert.NoError(t, WriteFile(filename, map[string]int{"count": 1}, json.Marshal))
+	var got map[string]int
+	assert.NoError(t, ReadFile(filename, &got, json.Unmarshal))
+	assert.Equal(t, map[string]int{"count": 1}, got)
+
+	// SaveFile 仍接受 fsutil 的选项，并创建缺失的父目录
+	log := filepath.Join(dir, "a", "b", "log.txt")
+	assert.NoError(t, SaveFile(log, "a\n"))
+	assert.NoError(t, SaveFile(log, "b\n", fsutil.WithFlag(fsutil.FsCWAFlags)))
+	data, err := os.ReadFile(log)
+	assert.NoError(t, err)
+	assert.Equal(t, "a\nb\n", string(data))
+
+	// WithOpenOptions 将 fsutil 的选项与其他选项一起传入
+	assert.NoError(t, SaveFileWith(log, "c\n", WithOpenOptions(fsutil.WithFlag(fsutil.FsCWAFlags)), WithSync()))
+	data, err = os.ReadFile(log)
+	assert.NoError(t, err)
+	assert.Equal(t, "a\nb\nc\n", string(data))
+
+	// SaveFile 与 WriteFile 创建父目录的默认权限相同
+	assert.NoError(t, SaveFile(filepath.Join(dir, "c", "x.txt"), "x"))
+	assert.NoError(t, WriteFile(filepath.Join(dir, "d", "x.json"), 1))
+	c, err := os.Stat(filepath.Join(dir, "c"))
+	assert.NoError(t, err)
+	d, err := os.Stat(filepath.Join(dir, "d"))
+	assert.NoError(t, err)
+	assert.Equal(t, c.Mode().Perm(), d.Mode().Perm())
+}
+
+func TestWatchFile_DebounceClock(t *testing.T) {
+	dir := t.TempDir()
+	var mu sync.Mutex
+	current := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	watchNow = func() time.Time {
+		mu.Lock()
+		defer mu.Unlock()
+		return current
+	}
+	defer func() { watchNow = time.Now }()
+
+	first := filepath.Join(dir, "config_20240101_000000.yaml")
+	assert.NoError(t, os.WriteFile(first, []byte("a: 1"), 0o644))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	changes := make(chan string, 10)
+	go WatchFile(ctx, filepath.Join(dir, "config_*.yaml"), 5*time.Millisecond, func(path string) {
+		changes <- path
+	}, WithInitial(), WithDebounce(time.Hour))
+	select {
+	case path := <-changes:
+		assert.Equal(t, first, path)
+	case <-time.After(2 * time.Second):
+		t.Fatal("timed out waiting for initial file")
+	}
+
+	// 去抖按 watchNow 计算，时钟不前进时不回调
+	filename := filepath.Join(dir, "config_20240102_000000.yaml")
+	assert.NoError(t, os.WriteFile(filename, []byte("a: 2"), 0o644))
+	select {
+	case path := <-changes:
+		t.Fatalf("unexpected change %s", path)
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	mu.Lock()
+	current = current.Add(time.Hour)
+	mu.Unlock()
+	select {
+	case path := <-changes:
+		assert.Equal(t, filename, path)
+	case <-time.After(2 * time.Second):
+		t.Fatal("timed out waiting for change")
+	}
+}
+
+func TestWatchFile_FrozenClock(t *testing.T) {
+	dir := t.TempDir()
+	// SetClock 冻结时间时，默认的去抖仍按真实时间结束
+	SetClock(func() time.Time { return time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC) })
+	defer SetClock(nil)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	changes := make(chan string, 10)
+	first := filepath.Join(dir, "config_20240101_000000.yaml")
+	assert.NoError(t, os.WriteFile(first, []byte("a: 1"), 0o644))
+	go WatchFile(ctx, filepath.Join(dir, "config_*.yaml"), 5*time.Millisecond, func(path string) {
+		changes <- path
+	}, WithInitial())
+	select {
+	case path := <-changes:
+		assert.Equal(t, first, path)
+	case <-time.After(2 * time.Second):
+		t.Fatal("timed out waiting for initial file")
+	}
+
+	filename := filepath.Join(dir, "config_20240102_000000.yaml")
+	assert.NoError(t, os.WriteFile(filename, []byte("a: 2"), 0o644))
+	select {
+	case path := <-changes:
+		assert.Equal(t, filename, path)
+	case <-time.After(2 * time.Second):
+		t.Fatal("timed out waiting for change")
+	}
+}
+
+func TestUpdateFile_DoesNotWriteCallerOptions(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "counter.json")
+	// opts 有多余的容量时，UpdateFile 追加的内部选项不能写入调用方的底层数组
+	opts := make([]Option, 1, 4)
+	opts[0] = WithAllowMissing()
+	assert.NoError(t, UpdateFile(filename, func(n *int) error { *n++; return nil }, opts...))
+	assert.Nil(t, opts[:cap(opts)][1])
+	assert.Nil(t, opts[:cap(opts)][2])
+}
+
+func TestExpandFileName_SeqErrors(t *testing.T) {
+	dir := t.TempDir()
+	regular := filepath.Join(dir, "regular")
+	assert.NoError(t, os.WriteFile(regular, nil, 0o644))
+
+	// 父路径是普通文件时 Stat 报错，返回错误而不是无休止地递增 {seq}
+	done := make(chan error, 1)
+	go func() { done <- WriteFile(filepath.Join(regular, "out_{seq}.json"), 1) }()
+	select {
+	case err := <-done:
+		assert.Error(t, err)
+	case <-time.After(5 * time.Second):
+		t.Fatal("WriteFile did not return")
+	}
+
+	// 所有序号都被占用时返回错误
+	_, err := expandFileName("out_{seq}.json", func(string) (bool, error) { return true, nil }, false)
+	assert.ErrorContains(t, err, "sequence numbers are taken")
+}
+
+func TestWithLock_PatternLock(t *testing.T) {
+	dir := t.TempDir()
+	pattern := filepath.Join(dir, "report_*.json")
+	for _, day := range []int{1, 2, 3} {
+		SetClock(func() time.Time { return time.Date(2024, 1, day, 0, 0, 0, 0, time.Local) })
+		assert.NoError(t, WriteFileWith(pattern, day, WithLock(time.Second)))
+	}
+	SetClock(nil)
+
+	// 同一模式的写入共用一个锁文件，不为每个带时间戳的文件留下锁文件
+	locks, err := filepath.Glob(filepath.Join(dir, "*.lock"))
+	assert.NoError(t, err)
+	assert.Equal(t, []string{lockFileName(lockPath(pattern))}, locks)
+	files, err := ListFiles(pattern)
+	assert.NoError(t, err)
+	assert.Len(t, files, 3)
+
+	// 持有模式的锁时，展开为其他文件名的写入同样需要等待
+	unlock, err := lockFile(context.Background(), lockPath(pattern), -1)
+	assert.NoError(t, err)
+	err = WriteFileWith(pattern, 4, WithLock(20*time.Millisecond))
+	assert.ErrorIs(t, err, ErrLockTimeout)
+	assert.NoError(t, unlock())
+}
@@ -0,0 +1,121 @@
+package fs
+
+import (
+	iofs "io/fs"
+	"path"
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+// globStar 是匹配任意层目录（包括零层）的路径段
+const globStar = "**"
+
+// glob 与 io/fs.Glob 相同，但支持 ** 路径段，例如 out/**/report_*.csv 匹配 out 下任意深度目录中的文件
+// 末尾的 ** 匹配其下的所有文件和目录。指向目录的符号链接最多跟随一次，避免循环
+func glob(fsys iofs.FS, pattern string) ([]string, error) {
+	segments := strings.Split(filepath.ToSlash(pattern), "/")
+	i := slices.Index(segments, globStar)
+	if i < 0 {
+		return iofs.Glob(fsys, pattern)
+	}
+
+	join := path.Join
+	if _, ok := fsys.(osFS); ok {
+		join = filepath.Join
+	}
+	prefix := strings.Join(segments[:i], "/")
+	rest := strings.Join(segments[i+1:], "/")
+	if rest == "" {
+		rest = "*"
+	}
+	if i > 0 && prefix == "" {
+		// 以 / 开头的绝对路径
+		prefix = "/"
+	}
+
+	var roots []string
+	switch {
+	case prefix == "":
+		roots = []string{"."}
+	case hasMeta(prefix):
+		var err error
+		if roots, err = glob(fsys, prefix); err != nil {
+			return nil, err
+		}
+	default:
+		roots = []string{join(prefix)}
+	}
+
+	var matches []string
+	seen := map[string]bool{}
+	followed := map[string]bool{}
+	for _, root := range roots {
+		err := walkDirs(fsys, root, followed, func(dir string) error {
+			found, err := glob(fsys, join(dir, rest))
+			if err != nil {
+				return err
+			}
+			for _, m := range found {
+				if !seen[m] {
+					seen[m] = true
+					matches = append(matches, m)
+				}
+			}
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	return matches, nil
+}
+
+// hasMeta 判断 pattern 是否包含通配符
+func hasMeta(pattern string) bool {
+	return strings.ContainsAny(pattern, `*?[\`)
+}
+
+// walkDirs 对 dir 及其下的每个目录调用 fn，dir 不是目录时不调用
+// 指向目录的符号链接按目标的真实路径记录在 followed 中，同一目标只跟随一次
+func walkDirs(fsys iofs.FS, dir string, followed map[string]bool, fn func(dir string) error) error {
+	entries, err := iofs.ReadDir(fsys, dir)
+	if err != nil {
+		// 不存在或不是目录时没有可匹配的文件，与 Glob 忽略 I/O 错误的行为一致
+		return nil
+	}
+	if err = fn(dir); err != nil {
+		return err
+	}
+
+	join := path.Join
+	_, isOS := fsys.(osFS)
+	if isOS {
+		join = filepath.Join
+	}
+	for _, entry := range entries {
+		sub := join(dir, entry.Name())
+		if entry.Type()&iofs.ModeSymlink != 0 {
+			info, err := iofs.Stat(fsys, sub)
+			if err != nil || !info.IsDir() {
+				continue
+			}
+			real := sub
+			if isOS {
+				if real, err = filepath.EvalSymlinks(sub); err != nil {
+					continue
+				}
+			}
+			if followed[real] {
+				continue
+			}
+			followed[real] = true
+		} else if !entry.IsDir() {
+			continue
+		}
+		if err = walkDirs(fsys, sub, followed, fn); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,68 @@
+package fs
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"io"
+	"path/filepath"
+	"strings"
+)
+
+// gzipExt 是 gzip 压缩文件的后缀名
+const gzipExt = ".gz"
+
+// gzipMagic 是 gzip 数据的文件头
+var gzipMagic = []byte{0x1f, 0x8b}
+
+// formatExt 返回决定文件格式的后缀名，会剥离末尾的 .gz，例如 data.json.gz 返回 .json 和 true
+func formatExt(name string) (ext string, gzipped bool) {
+	ext = filepath.Ext(name)
+	if strings.EqualFold(ext, gzipExt) {
+		return filepath.Ext(strings.TrimSuffix(name, ext)), true
+	}
+	return ext, false
+}
+
+// gunzipBytes 在 data 以 gzip 文件头开始时解压，否则原样返回，因此后缀名与内容不符时也能正确读取
+// 解压后的内容同样受 o.maxSize 限制，防止很小的压缩文件展开后耗尽内存
+func (o *options) gunzipBytes(data []byte) ([]byte, error) {
+	if !bytes.HasPrefix(data, gzipMagic) {
+		return data, nil
+	}
+	zr, err := gzip.NewReader(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	defer zr.Close()
+	return o.readLimited(zr, "decompressed data")
+}
+
+// gzipBytes 以 level 压缩 data
+func gzipBytes(data []byte, level int) ([]byte, error) {
+	var buf bytes.Buffer
+	zw, err := gzip.NewWriterLevel(&buf, level)
+	if err != nil {
+		return nil, err
+	}
+	if _, err = zw.Write(data); err != nil {
+		return nil, err
+	}
+	if err = zw.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// gunzipReader 在 r 以 gzip 文件头开始时返回解压后的 Reader，否则返回原内容
+func gunzipReader(r io.Reader) (io.Reader, error) {
+	br := bufio.NewReader(r)
+	head, err := br.Peek(len(gzipMagic))
+	if err != nil && err != io.EOF {
+		return nil, err
+	}
+	if !bytes.Equal(head, gzipMagic) {
+		return br, nil
+	}
+	return gzip.NewReader(br)
+}
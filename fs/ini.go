@@ -0,0 +1,321 @@
+package fs
+
+import (
+	"encoding"
+	"errors"
+	"fmt"
+	"maps"
+	"reflect"
+	"slices"
+	"strings"
+)
+
+// iniTag 是 INI 文件中节和键对应的结构体字段的标签名，例如 `ini:"database"`
+const iniTag = "ini"
+
+var textUnmarshalerType = reflect.TypeOf((*encoding.TextUnmarshaler)(nil)).Elem()
+
+// iniSection 是按写入顺序排列的一个节，name 为空时表示第一个节之前的全局键
+type iniSection struct {
+	name string
+	keys []string
+	kv   map[string]string
+}
+
+// MarshalINI 将 map[string]map[string]string 或结构体序列化为 INI 文件
+// 结构体中类型为结构体或 map[string]string 的字段各对应一个节，节名为 ini 标签或字段名，其他字段作为全局键写在所有节之前；
+// 节和键按字段顺序写入，map 中的节和键按名称排序。首尾有空白、包含 ; 或 # 的值以双引号包围，值不能包含换行
+func MarshalINI(v any) ([]byte, error) {
+	sections, err := iniSections(v)
+	if err != nil {
+		return nil, err
+	}
+
+	var b strings.Builder
+	for _, section := range sections {
+		if section.name != "" {
+			if b.Len() > 0 {
+				b.WriteByte('\n')
+			}
+			if strings.ContainsAny(section.name, "[]\r\n") {
+				return nil, fmt.Errorf("invalid INI section name %q", section.name)
+			}
+			fmt.Fprintf(&b, "[%s]\n", section.name)
+		}
+		for _, key := range section.keys {
+			if key == "" || strings.ContainsAny(key, "=:;#[\r\n") || key != strings.TrimSpace(key) {
+				return nil, fmt.Errorf("invalid INI key %q in section %q", key, section.name)
+			}
+			value := section.kv[key]
+			if strings.ContainsAny(value, "\r\n") {
+				return nil, fmt.Errorf("section %q key %s: INI values cannot contain newlines", section.name, key)
+			}
+			if value != strings.TrimSpace(value) || strings.ContainsAny(value, ";#") || strings.HasPrefix(value, `"`) || strings.HasPrefix(value, "'") {
+				value = `"` + value + `"`
+			}
+			fmt.Fprintf(&b, "%s = %s\n", key, value)
+		}
+	}
+	return []byte(b.String()), nil
+}
+
+// iniSections 将 v 转换为按写入顺序排列的节，全局键所在的节排在最前
+func iniSections(v any) ([]iniSection, error) {
+	rv := reflect.ValueOf(v)
+	for rv.Kind() == reflect.Ptr || rv.Kind() == reflect.Interface {
+		if rv.IsNil() {
+			return nil, errors.New("cannot marshal a nil value")
+		}
+		rv = rv.Elem()
+	}
+
+	global := iniSection{kv: make(map[string]string)}
+	var sections []iniSection
+	switch {
+	case rv.Kind() == reflect.Map && rv.Type().Key().Kind() == reflect.String:
+		names := make([]string, 0, rv.Len())
+		for iter := rv.MapRange(); iter.Next(); {
+			names = append(names, iter.Key().String())
+		}
+		slices.Sort(names)
+		for _, name := range names {
+			value := rv.MapIndex(reflect.ValueOf(name).Convert(rv.Type().Key()))
+			if value.Kind() == reflect.Interface {
+				value = value.Elem()
+			}
+			if value.Kind() != reflect.Map || value.Type().Key().Kind() != reflect.String {
+				return nil, fmt.Errorf("section %s: want a map with string keys, got %s", name, value.Kind())
+			}
+			section, err := iniMapSection(name, value)
+			if err != nil {
+				return nil, err
+			}
+			if name == "" {
+				global = section
+				continue
+			}
+			sections = append(sections, section)
+		}
+	case rv.Kind() == reflect.Struct:
+		for _, f := range keyFields(rv.Type(), iniTag) {
+			field := rv.FieldByIndex(f.index)
+			switch {
+			case isINISection(field.Type()):
+				if field.Kind() == reflect.Ptr {
+					if field.IsNil() {
+						continue
+					}
+					field = field.Elem()
+				}
+				section, err := iniStructSection(f.name, field)
+				if err != nil {
+					return nil, err
+				}
+				sections = append(sections, section)
+			case isINIMap(field.Type()):
+				section, err := iniMapSection(f.name, field)
+				if err != nil {
+					return nil, err
+				}
+				sections = append(sections, section)
+			default:
+				if f.omitEmpty && field.IsZero() {
+					continue
+				}
+				value, err := formatEnvValue(field)
+				if err != nil {
+					return nil, fmt.Errorf("field %s: %w", f.name, err)
+				}
+				global.keys = append(global.keys, f.name)
+				global.kv[f.name] = value
+			}
+		}
+	default:
+		return nil, fmt.Errorf("cannot marshal %T, want map[string]map[string]string or a struct", v)
+	}
+	return append([]iniSection{global}, sections...), nil
+}
+
+// iniStructSection 将结构体 rv 按字段顺序转换为名为 name 的节
+func iniStructSection(name string, rv reflect.Value) (iniSection, error) {
+	section := iniSection{name: name, kv: make(map[string]string)}
+	for _, f := range keyFields(rv.Type(), iniTag) {
+		field := rv.FieldByIndex(f.index)
+		if f.omitEmpty && field.IsZero() {
+			continue
+		}
+		value, err := formatEnvValue(field)
+		if err != nil {
+			return section, fmt.Errorf("section %s field %s: %w", name, f.name, err)
+		}
+		section.keys = append(section.keys, f.name)
+		section.kv[f.name] = value
+	}
+	return section, nil
+}
+
+// iniMapSection 将键为字符串的 map rv 转换为名为 name 的节，键按名称排序
+func iniMapSection(name string, rv reflect.Value) (iniSection, error) {
+	section := iniSection{name: name, kv: make(map[string]string)}
+	for iter := rv.MapRange(); iter.Next(); {
+		value, err := formatEnvValue(iter.Value())
+		if err != nil {
+			return section, fmt.Errorf("section %s key %s: %w", name, iter.Key().String(), err)
+		}
+		section.kv[iter.Key().String()] = value
+	}
+	section.keys = slices.Sorted(maps.Keys(section.kv))
+	return section, nil
+}
+
+// isINISection 判断字段类型 t 是否对应一个以结构体表示的节，实现了 encoding.TextUnmarshaler 的结构体（如 time.Time）除外
+func isINISection(t reflect.Type) bool {
+	if t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	return t.Kind() == reflect.Struct && !reflect.PointerTo(t).Implements(textUnmarshalerType)
+}
+
+// isINIMap 判断字段类型 t 是否为以 map[string]string 表示的节
+func isINIMap(t reflect.Type) bool {
+	return t.Kind() == reflect.Map && t.Key().Kind() == reflect.String && t.Elem().Kind() == reflect.String
+}
+
+// UnmarshalINI 解析 INI 文件到 out，out 为指向 map[string]map[string]string、结构体或 any 的指针
+// 第一个节之前的键属于名为 "" 的节；以 ; 或 # 开始的行以及值中空白之后的 ; 或 # 为注释，双引号或单引号包围的值原样保留；
+// 节名和键名区分大小写，同名的节会合并，重复的键取最后一个值
+//
+// 解码到 map 时保留所有节和键；解码到结构体时，结构体或 map[string]string 类型的字段按 ini 标签或字段名对应一个节，
+// 其他字段对应全局键，没有对应字段的节和键被忽略，需要保留未知的键时可将该节声明为 map[string]string
+func UnmarshalINI(data []byte, out any) error {
+	sections, err := parseINI(string(data))
+	if err != nil {
+		return err
+	}
+
+	rv := reflect.ValueOf(out)
+	if rv.Kind() != reflect.Ptr || rv.IsNil() {
+		return fmt.Errorf("out must be a non-nil pointer, got %T", out)
+	}
+	target := rv.Elem()
+	switch {
+	case target.Kind() == reflect.Interface && target.NumMethod() == 0:
+		target.Set(reflect.ValueOf(sections))
+	case target.Type() == reflect.TypeOf(sections):
+		existing := target.Interface().(map[string]map[string]string)
+		if existing == nil {
+			target.Set(reflect.ValueOf(sections))
+			return nil
+		}
+		for name, kv := range sections {
+			if existing[name] == nil {
+				existing[name] = kv
+				continue
+			}
+			maps.Copy(existing[name], kv)
+		}
+	case target.Kind() == reflect.Struct:
+		return assignINISections(target, sections)
+	default:
+		return fmt.Errorf("cannot unmarshal into %T, want a pointer to map[string]map[string]string or a struct", out)
+	}
+	return nil
+}
+
+// assignINISections 将 sections 赋给结构体 target 中对应的节和全局键
+func assignINISections(target reflect.Value, sections map[string]map[string]string) error {
+	for _, f := range keyFields(target.Type(), iniTag) {
+		field := target.FieldByIndex(f.index)
+		switch {
+		case isINISection(field.Type()):
+			kv, ok := sections[f.name]
+			if !ok {
+				continue
+			}
+			if field.Kind() == reflect.Ptr {
+				if field.IsNil() {
+					field.Set(reflect.New(field.Type().Elem()))
+				}
+				field = field.Elem()
+			}
+			if err := assignFields(field, kv, iniTag); err != nil {
+				return fmt.Errorf("section %s: %w", f.name, err)
+			}
+		case isINIMap(field.Type()):
+			kv, ok := sections[f.name]
+			if !ok {
+				continue
+			}
+			if field.IsNil() {
+				field.Set(reflect.MakeMapWithSize(field.Type(), len(kv)))
+			}
+			for key, value := range kv {
+				field.SetMapIndex(reflect.ValueOf(key).Convert(field.Type().Key()), reflect.ValueOf(value).Convert(field.Type().Elem()))
+			}
+		default:
+			value, ok := sections[""][f.name]
+			if !ok {
+				continue
+			}
+			if err := parseEnvValue(field, value); err != nil {
+				return fmt.Errorf("key %s: %w", f.name, err)
+			}
+		}
+	}
+	return nil
+}
+
+// parseINI 解析 INI 文件的内容，返回各节的键值，错误中包含行号
+func parseINI(s string) (map[string]map[string]string, error) {
+	sections := map[string]map[string]string{}
+	current := ""
+	s = strings.TrimPrefix(strings.ReplaceAll(s, "\r\n", "\n"), "\ufeff")
+	for n, line := range strings.Split(s, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || line[0] == ';' || line[0] == '#' {
+			continue
+		}
+		if line[0] == '[' {
+			end := strings.IndexByte(line, ']')
+			if end < 0 {
+				return nil, fmt.Errorf("line %d: unterminated section header", n+1)
+			}
+			if rest := strings.TrimSpace(line[end+1:]); rest != "" && rest[0] != ';' && rest[0] != '#' {
+				return nil, fmt.Errorf("line %d: unexpected %q after section header", n+1, rest)
+			}
+			current = strings.TrimSpace(line[1:end])
+			if sections[current] == nil {
+				sections[current] = map[string]string{}
+			}
+			continue
+		}
+
+		sep := strings.IndexAny(line, "=:")
+		if sep <= 0 {
+			return nil, fmt.Errorf("line %d: expected key = value", n+1)
+		}
+		if sections[current] == nil {
+			sections[current] = map[string]string{}
+		}
+		sections[current][strings.TrimSpace(line[:sep])] = iniValue(strings.TrimSpace(line[sep+1:]))
+	}
+	return sections, nil
+}
+
+// iniValue 去掉值两端的引号或值中的行内注释
+func iniValue(value string) string {
+	if value != "" && (value[0] == '"' || value[0] == '\'') {
+		if end := strings.LastIndexByte(value, value[0]); end > 0 {
+			rest := strings.TrimSpace(value[end+1:])
+			if rest == "" || rest[0] == ';' || rest[0] == '#' {
+				return value[1:end]
+			}
+		}
+	}
+	for i := 1; i < len(value); i++ {
+		if (value[i] == ';' || value[i] == '#') && (value[i-1] == ' ' || value[i-1] == '\t') {
+			return strings.TrimSpace(value[:i])
+		}
+	}
+	return value
+}
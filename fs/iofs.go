@@ -0,0 +1,81 @@
+package fs
+
+import (
+	"errors"
+	"fmt"
+	iofs "io/fs"
+	"os"
+	"path/filepath"
+)
+
+// WritableFS 是 WriteFileFS 写入文件的目标，测试中可以用内存实现捕获写入的内容
+type WritableFS interface {
+	WriteFile(name string, data []byte, perm iofs.FileMode) error
+}
+
+// osFS 以 os 包实现 io/fs.FS 和 WritableFS，与 os.DirFS 不同，它接受绝对路径等任意 os 路径
+type osFS struct {
+	o *options
+}
+
+func (osFS) Open(name string) (iofs.File, error) {
+	return os.Open(name)
+}
+
+func (osFS) Glob(pattern string) ([]string, error) {
+	return filepath.Glob(pattern)
+}
+
+func (osFS) Stat(name string) (iofs.FileInfo, error) {
+	return os.Stat(name)
+}
+
+func (osFS) ReadFile(name string) ([]byte, error) {
+	return os.ReadFile(name)
+}
+
+// WriteFile 按创建 osFS 时的选项写入文件，perm 由 WithFileMode 决定
+func (f osFS) WriteFile(name string, data []byte, _ iofs.FileMode) error {
+	return f.o.writeNamed(name, data)
+}
+
+// ReadFileFS 与 ReadFile 相同，但从 fsys 中选择并读取最新的文件，例如 embed.FS 或 fstest.MapFS
+// path 使用 io/fs 的路径格式，以 / 分隔且不以 / 开头
+func ReadFileFS(fsys iofs.FS, path string, out any, opts ...Option) error {
+	o := newOptions(opts)
+	return o.retry(o.ctx, func() error {
+		filename, err := getFileBy(o.ctx, fsys, path, newerByName, o.listOpts)
+		if err != nil {
+			return fmt.Errorf("get latest file: %w", err)
+		}
+		if err = o.readFile(fsys, filename, out); err != nil {
+			return o.fileError("read", filename, err)
+		}
+		return nil
+	})
+}
+
+// WriteFileFS 与 WriteFilePath 相同，但序列化后通过 fsys 写入，返回替换时间戳后的文件名
+// 创建目录、原子写入、属主等只对磁盘文件有效的选项不起作用
+func WriteFileFS(fsys WritableFS, path string, data any, opts ...Option) (string, error) {
+	return newOptions(opts).writeFile(fsys, path, data)
+}
+
+// existsIn 返回判断 fsys 中是否已有某个文件的函数，fsys 不可读时视为文件都不存在
+// 文件不存在以外的错误（例如父路径不是目录、没有权限）原样返回
+func existsIn(fsys WritableFS) func(string) (bool, error) {
+	rfs, ok := fsys.(iofs.FS)
+	if !ok {
+		return func(string) (bool, error) { return false, nil }
+	}
+	return func(name string) (bool, error) {
+		_, err := iofs.Stat(rfs, name)
+		switch {
+		case err == nil:
+			return true, nil
+		case errors.Is(err, iofs.ErrNotExist):
+			return false, nil
+		}
+		return false, err
+	}
+}
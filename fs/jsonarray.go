@@ -0,0 +1,142 @@
+package fs
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+)
+
+// jsonArrayHeadSize 是判断数组缩进时从文件开头读取的字节数
+const jsonArrayHeadSize = 4096
+
+// AppendJSONArrayFile 在文件锁内将 item 追加到 JSON 数组文件的末尾，如果 path 中包含 *，则会替换为当前时间戳（格式为 20060102_150405）
+// 文件不存在或为空时写入 [item]；文件是带缩进的格式时，item 按第一个元素的缩进输出
+// 追加时只读取文件开头和结尾，将 item 插入到末尾的 ] 之前，不会读入整个数组；写入不是原子的，进程在写入途中退出可能留下不完整的数组
+// 锁与 UpdateFile 相同，等待时间可通过 WithLock 设置；不支持 gzip 压缩的文件
+func AppendJSONArrayFile(path string, item any, opts ...Option) error {
+	if _, gzipped := formatExt(path); gzipped {
+		return fmt.Errorf("%w: cannot append to a gzip file", ErrUnsupportedFormat)
+	}
+	o := newOptions(opts)
+	return WithFileLock(lockPath(path), func() error {
+		filename, err := timestampFileName(path)
+		if err != nil {
+			return err
+		}
+		return o.appendJSONArray(filename, item)
+	}, opts...)
+}
+
+// appendJSONArray 将 item 插入到 filename 中 JSON 数组末尾的 ] 之前
+func (o *options) appendJSONArray(filename string, item any) error {
+	f, err := o.openFile(filename, os.O_CREATE|os.O_RDWR)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	info, err := f.Stat()
+	if err != nil {
+		return fmt.Errorf("stat file: %w", err)
+	}
+	head := make([]byte, min(info.Size(), jsonArrayHeadSize))
+	if _, err = f.ReadAt(head, 0); err != nil && err != io.EOF {
+		return fmt.Errorf("read file: %w", err)
+	}
+
+	// 空文件
+	if info.Size() == int64(len(head)) && len(bytes.TrimSpace(head)) == 0 {
+		data, err := json.Marshal([]any{item})
+		if err != nil {
+			return fmt.Errorf("marshal data: %w", err)
+		}
+		if err = f.Truncate(0); err != nil {
+			return fmt.Errorf("truncate file: %w", err)
+		}
+		return o.writeOSFile(f, append(data, '\n'))
+	}
+
+	start := bytes.IndexFunc(head, notSpace)
+	if start < 0 || head[start] != '[' {
+		return fmt.Errorf("%s: not a JSON array", filename)
+	}
+	end, c, err := lastNonSpace(f, info.Size())
+	if err != nil {
+		return err
+	}
+	if c != ']' {
+		return fmt.Errorf("%s: not a JSON array, missing the closing ]", filename)
+	}
+	// last 是 ] 之前的最后一个非空白字符，为 [ 时数组为空
+	last, c, err := lastNonSpace(f, end)
+	if err != nil {
+		return err
+	}
+
+	var insert []byte
+	indent := jsonArrayIndent(head[start+1:])
+	switch {
+	case c == '[':
+		if insert, err = json.Marshal(item); err != nil {
+			return fmt.Errorf("marshal data: %w", err)
+		}
+	case indent != "":
+		data, err := json.MarshalIndent(item, indent, indent)
+		if err != nil {
+			return fmt.Errorf("marshal data: %w", err)
+		}
+		insert = append([]byte(",\n"+indent), data...)
+	default:
+		data, err := json.Marshal(item)
+		if err != nil {
+			return fmt.Errorf("marshal data: %w", err)
+		}
+		insert = append([]byte{','}, data...)
+	}
+
+	// 在 last 之后插入 item，原有的换行、] 和结尾的空白保持不变
+	tail := make([]byte, info.Size()-last-1)
+	if _, err = f.ReadAt(tail, last+1); err != nil && err != io.EOF {
+		return fmt.Errorf("read file: %w", err)
+	}
+	if _, err = f.Seek(last+1, io.SeekStart); err != nil {
+		return fmt.Errorf("seek file: %w", err)
+	}
+	return o.writeOSFile(f, append(insert, tail...))
+}
+
+// jsonArrayIndent 返回数组第一个元素所在行的缩进，data 为 [ 之后的内容；第一个元素与 [ 在同一行时返回空字符串
+func jsonArrayIndent(data []byte) string {
+	first := bytes.IndexFunc(data, notSpace)
+	if first < 0 {
+		return ""
+	}
+	nl := bytes.LastIndexByte(data[:first], '\n')
+	if nl < 0 {
+		return ""
+	}
+	return string(bytes.TrimLeft(data[nl+1:first], "\r"))
+}
+
+// lastNonSpace 从 end 向前逐块读取 f，返回 end 之前最后一个非空白字符及其偏移，没有时返回 -1 和 0
+func lastNonSpace(f *os.File, end int64) (int64, byte, error) {
+	buf := make([]byte, jsonArrayHeadSize)
+	for end > 0 {
+		n := min(end, int64(len(buf)))
+		if _, err := f.ReadAt(buf[:n], end-n); err != nil && err != io.EOF {
+			return 0, 0, fmt.Errorf("read file: %w", err)
+		}
+		if i := bytes.LastIndexFunc(buf[:n], notSpace); i >= 0 {
+			return end - n + int64(i), buf[i], nil
+		}
+		end -= n
+	}
+	return -1, 0, nil
+}
+
+// notSpace 判断 r 是否不是 JSON 的空白字符
+func notSpace(r rune) bool {
+	return r != ' ' && r != '\t' && r != '\r' && r != '\n'
+}
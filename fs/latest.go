@@ -0,0 +1,60 @@
+package fs
+
+import (
+	"fmt"
+	"math/rand/v2"
+	"os"
+	"path/filepath"
+
+	"github.com/gookit/goutil/fsutil"
+)
+
+// WithLatestSymlink 写入成功后将符号链接 name 原子地指向写入的文件，例如 report_latest.json
+// 不支持符号链接的平台上会改为复制文件。name 最好不与 path 的模式匹配，否则会被 ReadFile、RotateFiles 等当作普通文件
+func WithLatestSymlink(name string) Option {
+	return func(o *options) {
+		o.latest = name
+		o.latestCopy = false
+	}
+}
+
+// WithLatestCopy 与 WithLatestSymlink 相同，但始终将写入的文件复制为 name
+func WithLatestCopy(name string) Option {
+	return func(o *options) {
+		o.latest = name
+		o.latestCopy = true
+	}
+}
+
+// updateLatest 将 o.latest 更新为指向 filename 的符号链接或其副本，先创建临时文件再重命名
+func (o *options) updateLatest(filename string) error {
+	dir := filepath.Dir(o.latest)
+	tmp := filepath.Join(dir, fmt.Sprintf(".%s.tmp%d", filepath.Base(o.latest), rand.Uint32()))
+
+	if !o.latestCopy {
+		target, err := filepath.Rel(dir, filename)
+		if err != nil {
+			target, err = filepath.Abs(filename)
+		}
+		if err == nil {
+			if err = os.Symlink(target, tmp); err == nil {
+				if err = os.Rename(tmp, o.latest); err != nil {
+					os.Remove(tmp)
+					return fmt.Errorf("update latest symlink: %w", err)
+				}
+				return nil
+			}
+		}
+		// 不支持符号链接时改为复制
+	}
+
+	if err := fsutil.CopyFile(filename, tmp); err != nil {
+		os.Remove(tmp)
+		return fmt.Errorf("copy latest file: %w", err)
+	}
+	if err := os.Rename(tmp, o.latest); err != nil {
+		os.Remove(tmp)
+		return fmt.Errorf("copy latest file: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,61 @@
+package fs
+
+import (
+	"fmt"
+	"io"
+	iofs "io/fs"
+)
+
+// DefaultMaxSize 是 ReadFile 等一次读入整个文件的函数默认允许的最大字节数，可按需调大，不大于 0 时不限制
+// StreamCSVFile、NDJSON 等逐行读取的函数不受限制
+var DefaultMaxSize int64 = 1 << 30
+
+// WithMaxSize 设置一次读入整个文件时允许的最大字节数，超过时返回 ErrFileTooLarge，n 不大于 0 时不限制，默认为 DefaultMaxSize
+func WithMaxSize(n int64) Option {
+	return func(o *options) {
+		o.maxSize = n
+	}
+}
+
+// readAll 读取 fsys 中的整个 name，先按文件大小检查 o.maxSize，读取时再用 LimitReader 限制，防止文件在此期间变大
+func (o *options) readAll(fsys iofs.FS, name string) ([]byte, error) {
+	if o.maxSize > 0 {
+		info, err := iofs.Stat(fsys, name)
+		if err != nil {
+			return nil, fmt.Errorf("read file: %w", err)
+		}
+		if info.Size() > o.maxSize {
+			return nil, fmt.Errorf("%s: %w: %d bytes, limit is %d", name, ErrFileTooLarge, info.Size(), o.maxSize)
+		}
+	}
+
+	f, err := fsys.Open(name)
+	if err != nil {
+		return nil, fmt.Errorf("read file: %w", err)
+	}
+	defer f.Close()
+	data, err := o.readLimited(o.fileReader(f), name)
+	if err != nil {
+		return nil, err
+	}
+	return data, nil
+}
+
+// readLimited 读取 r 的全部内容，超过 o.maxSize 时返回 ErrFileTooLarge，name 用于错误信息
+func (o *options) readLimited(r io.Reader, name string) ([]byte, error) {
+	if o.maxSize <= 0 {
+		data, err := io.ReadAll(r)
+		if err != nil {
+			return nil, fmt.Errorf("read %s: %w", name, err)
+		}
+		return data, nil
+	}
+	data, err := io.ReadAll(io.LimitReader(r, o.maxSize+1))
+	if err != nil {
+		return nil, fmt.Errorf("read %s: %w", name, err)
+	}
+	if int64(len(data)) > o.maxSize {
+		return nil, fmt.Errorf("%s: %w: more than %d bytes", name, ErrFileTooLarge, o.maxSize)
+	}
+	return data, nil
+}
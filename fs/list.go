@@ -0,0 +1,334 @@
+package fs
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"fmt"
+	iofs "io/fs"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// SortKey 指定 ListFiles 的排序依据
+type SortKey int
+
+const (
+	// SortByNaturalName 按文件名的自然顺序排序（见 NaturalLess），为默认值
+	SortByNaturalName SortKey = iota
+	// SortByName 按文件名的字符串顺序排序
+	SortByName
+	// SortByModTime 按修改时间排序
+	SortByModTime
+	// SortBySize 按文件大小排序
+	SortBySize
+	// SortByTimestamp 按文件名中的时间戳排序，没有时间戳的文件使用修改时间
+	SortByTimestamp
+)
+
+// ListOption 配置 ListFiles 的排序与过滤
+type ListOption func(*listOptions)
+
+// DefaultExcludePatterns 是默认排除的文件名模式：隐藏文件（包括 Emacs 的 .# 锁文件）、编辑器的备份和交换文件以及未完成的临时文件
+// 模式按 filepath.Match 匹配文件名（不含目录），可通过 WithExcludePatterns 替换
+var DefaultExcludePatterns = []string{".*", "*~", "*.swp", "*.tmp", "*.partial"}
+
+type listOptions struct {
+	sortBy        SortKey
+	descending    bool
+	excludeDirs   bool
+	excludeEmpty  bool
+	excludeHidden bool
+	minSize       int64
+	minAge        time.Duration
+	maxAge        time.Duration
+	stableFor     time.Duration
+	exclude       []string
+}
+
+// newListOptions 按 opts 创建 listOptions，排除模式默认为 DefaultExcludePatterns
+func newListOptions(opts []ListOption) *listOptions {
+	o := &listOptions{exclude: DefaultExcludePatterns}
+	for _, opt := range opts {
+		if opt != nil {
+			opt(o)
+		}
+	}
+	return o
+}
+
+// WithSortBy 设置排序依据，排序依据相同的文件保持文件名的自然顺序
+func WithSortBy(key SortKey) ListOption {
+	return func(o *listOptions) {
+		o.sortBy = key
+	}
+}
+
+// WithDescending 按降序排列，例如与 SortByModTime 一起使用时最新的文件在前
+func WithDescending() ListOption {
+	return func(o *listOptions) {
+		o.descending = true
+	}
+}
+
+// WithExcludeDirs 排除目录
+func WithExcludeDirs() ListOption {
+	return func(o *listOptions) {
+		o.excludeDirs = true
+	}
+}
+
+// WithExcludeEmpty 排除空文件，目录不受影响
+func WithExcludeEmpty() ListOption {
+	return func(o *listOptions) {
+		o.excludeEmpty = true
+	}
+}
+
+// WithExcludePatterns 以 patterns 替换默认的排除模式 DefaultExcludePatterns，不传参数时不排除任何文件
+func WithExcludePatterns(patterns ...string) ListOption {
+	return func(o *listOptions) {
+		o.exclude = patterns
+	}
+}
+
+// WithExcludeHidden 排除以 . 开头的文件和目录，即使模式本身以 . 开头或通过 WithExcludePatterns 替换了默认的排除模式
+func WithExcludeHidden() ListOption {
+	return func(o *listOptions) {
+		o.excludeHidden = true
+	}
+}
+
+// WithMinSize 排除小于 n 字节的文件，例如崩溃的写入者留下的空文件，目录不受影响
+func WithMinSize(n int64) ListOption {
+	return func(o *listOptions) {
+		o.minSize = n
+	}
+}
+
+// WithMinAge 排除修改时间距今不足 d 的文件，避免读到仍在写入的文件
+func WithMinAge(d time.Duration) ListOption {
+	return func(o *listOptions) {
+		o.minAge = d
+	}
+}
+
+// WithMaxAge 排除修改时间距今超过 d 的文件
+func WithMaxAge(d time.Duration) ListOption {
+	return func(o *listOptions) {
+		o.maxAge = d
+	}
+}
+
+// WithStableFor 只保留正在写入的可能性已排除的文件：修改时间早于 d 的文件直接保留，
+// 较新的文件等待 d 后重新读取信息，大小和修改时间都没有变化才保留，否则视为仍在写入而跳过，选择下一个候选文件
+// 无论较新的文件有多少，最多只等待一次 d；用于读取不受控制、不以原子方式写入的生产者的文件
+func WithStableFor(d time.Duration) ListOption {
+	return func(o *listOptions) {
+		o.stableFor = d
+	}
+}
+
+// ListFiles 返回与 pattern 匹配的所有文件及其元数据，默认按文件名的自然顺序升序排列
+// pattern 中的占位符按 GlobPattern 转换为 *，** 路径段匹配任意层目录
+// 文件较多时并发读取文件信息，匹配后被删除的文件会被跳过
+func ListFiles(pattern string, opts ...ListOption) ([]FileEntry, error) {
+	return listFiles(context.Background(), osFS{}, pattern, opts)
+}
+
+// listFiles 返回 fsys 中与 pattern 匹配的所有文件及其元数据，ctx 结束后停止读取文件信息并返回 ctx.Err()
+func listFiles(ctx context.Context, fsys iofs.FS, pattern string, opts []ListOption) ([]FileEntry, error) {
+	o := newListOptions(opts)
+
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	matches, err := glob(fsys, GlobPattern(pattern))
+	if err != nil {
+		return nil, err
+	}
+	slices.SortFunc(matches, naturalCompare)
+	matches = slices.DeleteFunc(matches, o.excluded(pattern))
+	infos, err := statFiles(ctx, fsys, matches)
+	if err != nil {
+		return nil, err
+	}
+
+	entries := make([]FileEntry, 0, len(matches))
+	for i, file := range matches {
+		info := infos[i]
+		// 在匹配和读取信息之间被删除的文件
+		if info == nil {
+			continue
+		}
+		if (o.excludeDirs && info.IsDir()) || (o.excludeEmpty && !info.IsDir() && info.Size() == 0) {
+			continue
+		}
+		if !o.keep(info) {
+			continue
+		}
+		entries = append(entries, newFileEntry(file, info))
+	}
+	if entries, err = o.stable(ctx, fsys, entries); err != nil {
+		return nil, err
+	}
+
+	slices.SortStableFunc(entries, func(a, b FileEntry) int {
+		c := o.compare(a, b)
+		if o.descending {
+			return -c
+		}
+		return c
+	})
+	return entries, nil
+}
+
+// compare 按排序依据比较 a 和 b
+func (o *listOptions) compare(a, b FileEntry) int {
+	switch o.sortBy {
+	case SortByName:
+		return strings.Compare(a.Path, b.Path)
+	case SortByModTime:
+		return a.ModTime.Compare(b.ModTime)
+	case SortBySize:
+		return cmp.Compare(a.Size, b.Size)
+	case SortByTimestamp:
+		return a.nameTime().Compare(b.nameTime())
+	default:
+		return naturalCompare(a.Path, b.Path)
+	}
+}
+
+// excluded 返回判断与 pattern 匹配的文件是否应被排除的函数
+// 模式的文件名部分本身与某个排除模式匹配时（例如 .env 或 *.tmp），该排除模式不起作用，以便显式地读取这类文件
+func (o *listOptions) excluded(pattern string) func(file string) bool {
+	base := filepath.Base(GlobPattern(pattern))
+	var active []string
+	for _, p := range o.exclude {
+		if ok, _ := filepath.Match(p, base); !ok {
+			active = append(active, p)
+		}
+	}
+	return func(file string) bool {
+		name := filepath.Base(file)
+		if o.excludeHidden && strings.HasPrefix(name, ".") {
+			return true
+		}
+		for _, p := range active {
+			if ok, _ := filepath.Match(p, name); ok {
+				return true
+			}
+		}
+		return false
+	}
+}
+
+// keep 判断 info 是否满足大小和修改时间的过滤条件，文件的年龄按 SetClock 设置的时钟计算
+func (o *listOptions) keep(info iofs.FileInfo) bool {
+	if o.minSize > 0 && !info.IsDir() && info.Size() < o.minSize {
+		return false
+	}
+	age := now().Sub(info.ModTime())
+	if o.minAge > 0 && age < o.minAge {
+		return false
+	}
+	return o.maxAge <= 0 || age <= o.maxAge
+}
+
+// stable 按 WithStableFor 过滤 entries，较新的文件在等待 stableFor 后大小或修改时间有变化时被移除
+func (o *listOptions) stable(ctx context.Context, fsys iofs.FS, entries []FileEntry) ([]FileEntry, error) {
+	if o.stableFor <= 0 {
+		return entries, nil
+	}
+	var recent []string
+	for _, entry := range entries {
+		if !entry.Info.IsDir() && now().Sub(entry.ModTime) < o.stableFor {
+			recent = append(recent, entry.Path)
+		}
+	}
+	if len(recent) == 0 {
+		return entries, nil
+	}
+
+	if err := sleepCtx(ctx, o.stableFor); err != nil {
+		return nil, err
+	}
+	infos, err := statFiles(ctx, fsys, recent)
+	if err != nil {
+		return nil, err
+	}
+	rechecked := make(map[string]iofs.FileInfo, len(recent))
+	for i, file := range recent {
+		rechecked[file] = infos[i]
+	}
+	return slices.DeleteFunc(entries, func(entry FileEntry) bool {
+		info, ok := rechecked[entry.Path]
+		// 等待期间被删除的文件 info 为 nil
+		return ok && (info == nil || info.Size() != entry.Size || !info.ModTime().Equal(entry.ModTime))
+	}), nil
+}
+
+const (
+	// parallelStatMin 是并发读取文件信息的最少文件数，文件较少时逐个读取更快
+	parallelStatMin = 64
+	// statWorkers 是并发读取文件信息的 goroutine 数
+	statWorkers = 16
+)
+
+// statFiles 读取 files 中每个文件的信息，文件较多时并发读取
+// 已被删除的文件对应的结果为 nil，其他错误（例如没有权限）中包含文件名；ctx 结束后不再读取并返回 ctx.Err()
+func statFiles(ctx context.Context, fsys iofs.FS, files []string) ([]iofs.FileInfo, error) {
+	infos := make([]iofs.FileInfo, len(files))
+	errs := make([]error, len(files))
+	stat := func(i int) {
+		if ctx.Err() != nil {
+			return
+		}
+		info, err := iofs.Stat(fsys, files[i])
+		switch {
+		case err == nil:
+			infos[i] = info
+		case errors.Is(err, iofs.ErrNotExist):
+		default:
+			var pathErr *iofs.PathError
+			if !errors.As(err, &pathErr) {
+				err = fmt.Errorf("stat %s: %w", files[i], err)
+			}
+			errs[i] = err
+		}
+	}
+
+	if len(files) < parallelStatMin {
+		for i := range files {
+			stat(i)
+		}
+	} else {
+		var next atomic.Int64
+		var wg sync.WaitGroup
+		for range statWorkers {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				for i := int(next.Add(1) - 1); i < len(files); i = int(next.Add(1) - 1) {
+					stat(i)
+				}
+			}()
+		}
+		wg.Wait()
+	}
+
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	// 与逐个读取时一样返回第一个出错的文件
+	for _, err := range errs {
+		if err != nil {
+			return nil, err
+		}
+	}
+	return infos, nil
+}
@@ -0,0 +1,100 @@
+package fs
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"time"
+)
+
+// ErrLockTimeout 表示在超时时间内没有获得文件锁
+var ErrLockTimeout = errors.New("lock timeout")
+
+// lockPollInterval 是等待文件锁时的重试间隔
+const lockPollInterval = 10 * time.Millisecond
+
+// lockFileName 返回 name 对应的锁文件，锁加在单独的文件上，原子写入替换 name 后锁仍然有效
+func lockFileName(name string) string {
+	return name + ".lock"
+}
+
+// lockFile 获取 name 的建议性排他锁，timeout 为负数时一直等待（直到 ctx 结束），为 0 时只尝试一次
+// 返回的函数释放锁，锁文件会被保留，删除它会让其他进程锁住不同的文件
+func lockFile(ctx context.Context, name string, timeout time.Duration) (unlock func() error, err error) {
+	f, err := os.OpenFile(lockFileName(name), os.O_CREATE|os.O_RDWR, 0o644)
+	if err != nil {
+		return nil, fmt.Errorf("open lock file: %w", err)
+	}
+
+	deadline := time.Now().Add(timeout)
+	for {
+		locked, err := tryLock(f)
+		if err != nil {
+			f.Close()
+			return nil, fmt.Errorf("lock file: %w", err)
+		}
+		if locked {
+			break
+		}
+		if timeout >= 0 && !time.Now().Before(deadline) {
+			f.Close()
+			return nil, fmt.Errorf("%w: %s", ErrLockTimeout, name)
+		}
+		if err = sleepCtx(ctx, lockPollInterval); err != nil {
+			f.Close()
+			return nil, fmt.Errorf("lock file: %w", err)
+		}
+	}
+
+	return func() error {
+		err := unlockFile(f)
+		if cerr := f.Close(); err == nil {
+			err = cerr
+		}
+		return err
+	}, nil
+}
+
+// lockWrite 在开启 WithLock 时持有 pattern 对应的锁（见 lockPath）执行 fn，fn 中再展开时间戳等占位符，
+// 因此展开为不同文件名的写入同样互斥，也不会为每个写入的文件留下单独的锁文件；未开启时直接执行 fn
+func (o *options) lockWrite(pattern string, fn func() error) (err error) {
+	if !o.lock {
+		return fn()
+	}
+	lock := lockPath(pattern)
+	// 锁文件与目标文件在同一目录下
+	if err = o.createParentDirs(lock); err != nil {
+		return err
+	}
+	unlock, err := lockFile(o.ctx, lock, o.lockTimeout)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if uerr := unlock(); err == nil {
+			err = uerr
+		}
+	}()
+	return fn()
+}
+
+// WithFileLock 在持有 path 的建议性文件锁时执行 fn，用于“读取-修改-写入”等需要互斥的操作
+// 默认一直等待锁，可通过 WithLock 设置超时，超时返回 ErrLockTimeout；锁加在 path + ".lock" 文件上
+func WithFileLock(path string, fn func() error, opts ...Option) (err error) {
+	o := newOptions(opts)
+	timeout := time.Duration(-1)
+	if o.lock {
+		timeout = o.lockTimeout
+	}
+	unlock, err := lockFile(o.ctx, path, timeout)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if uerr := unlock(); err == nil {
+			err = uerr
+		}
+	}()
+	return fn()
+}
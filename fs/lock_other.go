@@ -0,0 +1,20 @@
+//go:build !unix && !windows
+
+package fs
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"runtime"
+)
+
+// tryLock 在不支持文件锁的平台上返回 errors.ErrUnsupported
+func tryLock(*os.File) (bool, error) {
+	return false, fmt.Errorf("file lock on %s: %w", runtime.GOOS, errors.ErrUnsupported)
+}
+
+// unlockFile 在不支持文件锁的平台上不需要释放
+func unlockFile(*os.File) error {
+	return nil
+}
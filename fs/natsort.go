@@ -0,0 +1,68 @@
+package fs
+
+import "strings"
+
+// NaturalLess 按自然顺序比较两个字符串：连续的数字按数值比较，其余部分按字符串比较，
+// 因此 file2 排在 file10 之前，v1.9 排在 v1.10 之前
+func NaturalLess(a, b string) bool {
+	return naturalCompare(a, b) < 0
+}
+
+// naturalCompare 按自然顺序比较 a 和 b，返回 -1、0 或 1
+func naturalCompare(a, b string) int {
+	for a != "" && b != "" {
+		ca, ra := nextChunk(a)
+		cb, rb := nextChunk(b)
+		if c := compareChunk(ca, cb); c != 0 {
+			return c
+		}
+		a, b = ra, rb
+	}
+	switch {
+	case a == b:
+		return 0
+	case a == "":
+		return -1
+	default:
+		return 1
+	}
+}
+
+// nextChunk 切出 s 开头的一段连续数字或连续非数字
+func nextChunk(s string) (chunk, rest string) {
+	digit := isDigit(s[0])
+	i := 1
+	for i < len(s) && isDigit(s[i]) == digit {
+		i++
+	}
+	return s[:i], s[i:]
+}
+
+// compareChunk 比较两段字符，两段都是数字时按数值比较，数值相同时前导零少的在前
+func compareChunk(a, b string) int {
+	if !isDigit(a[0]) || !isDigit(b[0]) {
+		return strings.Compare(a, b)
+	}
+	ta, tb := strings.TrimLeft(a, "0"), strings.TrimLeft(b, "0")
+	if len(ta) != len(tb) {
+		if len(ta) < len(tb) {
+			return -1
+		}
+		return 1
+	}
+	if c := strings.Compare(ta, tb); c != 0 {
+		return c
+	}
+	if len(a) != len(b) {
+		if len(a) < len(b) {
+			return -1
+		}
+		return 1
+	}
+	return 0
+}
+
+// isDigit 判断是否为 ASCII 数字，UTF-8 中多字节字符的每个字节都不会被误判
+func isDigit(c byte) bool {
+	return '0' <= c && c <= '9'
+}
@@ -0,0 +1,183 @@
+package fs
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	iofs "io/fs"
+	"os"
+	"reflect"
+
+	"github.com/0xuLiang/lancet/csv"
+)
+
+// isNDJSON 判断后缀名是否为按行分隔的 JSON
+func isNDJSON(ext string) bool {
+	return ext == ".ndjson" || ext == ".jsonl"
+}
+
+// MarshalNDJSON 将切片或数组序列化为 NDJSON，每个元素占一行；其他类型的值序列化为单行
+func MarshalNDJSON(v any) ([]byte, error) {
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+
+	rv := reflect.ValueOf(v)
+	for rv.Kind() == reflect.Ptr && !rv.IsNil() {
+		rv = rv.Elem()
+	}
+	if rv.Kind() != reflect.Slice && rv.Kind() != reflect.Array {
+		if err := enc.Encode(v); err != nil {
+			return nil, err
+		}
+		return buf.Bytes(), nil
+	}
+
+	for i := 0; i < rv.Len(); i++ {
+		if err := enc.Encode(rv.Index(i).Interface()); err != nil {
+			return nil, fmt.Errorf("element %d: %w", i, err)
+		}
+	}
+	return buf.Bytes(), nil
+}
+
+// UnmarshalNDJSON 将 NDJSON 逐行解析并追加到 out 指向的切片中，空行会被跳过
+func UnmarshalNDJSON(data []byte, out any) error {
+	return decodeNDJSON(bytes.NewReader(data), out)
+}
+
+// decodeNDJSON 从 r 中逐行读取 NDJSON 并追加到 out 指向的切片中，解析失败时报告行号
+func decodeNDJSON(r io.Reader, out any) error {
+	rv := reflect.ValueOf(out)
+	if rv.Kind() != reflect.Ptr || rv.IsNil() || rv.Elem().Kind() != reflect.Slice {
+		return errors.New("out must be a non-nil pointer to a slice")
+	}
+	slice := rv.Elem()
+	elemType := slice.Type().Elem()
+
+	return eachNDJSONLine(r, func(line []byte) error {
+		elem := reflect.New(elemType)
+		if err := json.Unmarshal(line, elem.Interface()); err != nil {
+			return err
+		}
+		slice.Set(reflect.Append(slice, elem.Elem()))
+		return nil
+	})
+}
+
+// eachNDJSONLine 对 r 中的每个非空行调用 fn，读取失败或 fn 返回错误时停止，错误中包含行号
+func eachNDJSONLine(r io.Reader, fn func(line []byte) error) error {
+	br := bufio.NewReader(r)
+	for n := 1; ; n++ {
+		line, err := br.ReadBytes('\n')
+		if err != nil && err != io.EOF {
+			return fmt.Errorf("line %d: %w", n, err)
+		}
+		if line = bytes.TrimSpace(line); len(line) > 0 {
+			if ferr := fn(line); ferr != nil {
+				return fmt.Errorf("line %d: %w", n, ferr)
+			}
+		}
+		if err == io.EOF {
+			return nil
+		}
+	}
+}
+
+// readNDJSONFile 以流的方式解析 NDJSON 文件，gzip 压缩的文件会自动解压，字符集按 WithCharset 转换
+func (o *options) readNDJSONFile(fsys iofs.FS, filename string, out any) error {
+	f, err := fsys.Open(filename)
+	if err != nil {
+		return fmt.Errorf("read file: %w", err)
+	}
+	defer f.Close()
+
+	r, err := gunzipReader(o.fileReader(f))
+	if err != nil {
+		return fmt.Errorf("read file: %w", err)
+	}
+	if r, err = o.charsetReader(r); err != nil {
+		return fmt.Errorf("read file: %w", err)
+	}
+	if err = decodeNDJSON(r, out); err != nil {
+		return fmt.Errorf("unmarshal data: %w", err)
+	}
+	return nil
+}
+
+// AppendNDJSONFile 将 data 追加到 NDJSON 文件末尾，切片或数组的每个元素占一行，其他类型的值占一行
+// 如果 path 中包含 *，则会替换为当前时间戳（格式为 20060102_150405）；文件不存在时创建
+// 所有行在一次写入中追加，不读取也不重写已有内容，配合 WithSync 在返回前将数据刷入磁盘；不支持 gzip 压缩的文件
+func AppendNDJSONFile(path string, data any, opts ...Option) error {
+	if _, gzipped := formatExt(path); gzipped {
+		return fmt.Errorf("%w: cannot append to a gzip file", ErrUnsupportedFormat)
+	}
+	bs, err := MarshalNDJSON(data)
+	if err != nil {
+		return fmt.Errorf("marshal data: %w", err)
+	}
+
+	o := newOptions(opts)
+	filename, err := timestampFileName(path)
+	if err != nil {
+		return err
+	}
+	f, err := o.openFile(filename, os.O_CREATE|os.O_WRONLY|os.O_APPEND)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	return o.writeOSFile(f, bs)
+}
+
+// StreamNDJSONFile 从最新的 NDJSON 文件中逐行解码，并对每一行调用 fn，内存占用与文件大小无关，可通过 WithProgress 报告读取进度
+// fn 的类型为 func(T) error 或 func(*T) error；空行会被跳过，fn 返回 csv.ErrStop 时提前结束且不返回错误
+// 返回的错误包含文件名和行号，gzip 压缩的文件会自动解压
+func StreamNDJSONFile(path string, fn any, opts ...Option) error {
+	fv, in, elemType, err := rowFunc(fn)
+	if err != nil {
+		return err
+	}
+
+	o := newOptions(opts)
+	filename, err := GetLatestFileByName(path, o.listOpts...)
+	if err != nil {
+		return fmt.Errorf("get latest file: %w", err)
+	}
+	f, err := os.Open(filename)
+	if err != nil {
+		return fmt.Errorf("read file: %w", err)
+	}
+	defer f.Close()
+	r, err := gunzipReader(o.fileReader(f))
+	if err != nil {
+		return fmt.Errorf("read file: %w", err)
+	}
+	if r, err = o.charsetReader(r); err != nil {
+		return fmt.Errorf("read file: %w", err)
+	}
+
+	err = eachNDJSONLine(r, func(line []byte) error {
+		elem := reflect.New(elemType)
+		if err := json.Unmarshal(line, elem.Interface()); err != nil {
+			return err
+		}
+		arg := elem
+		if in.Kind() != reflect.Ptr {
+			arg = elem.Elem()
+		}
+		if out := fv.Call([]reflect.Value{arg})[0]; !out.IsNil() {
+			return out.Interface().(error)
+		}
+		return nil
+	})
+	if errors.Is(err, csv.ErrStop) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("%s: %w", filename, err)
+	}
+	return nil
+}
@@ -0,0 +1,297 @@
+package fs
+
+import (
+	"compress/gzip"
+	"context"
+	"crypto"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/0xuLiang/lancet/csv"
+	"github.com/gookit/goutil/fsutil"
+)
+
+// defaultDirMode 自动创建父目录时使用的默认权限，与 fsutil.DefaultDirPerm 相同
+const defaultDirMode os.FileMode = 0o775
+
+// Option 配置 ReadFileWith、WriteFileWith、SaveFileWith 等函数的行为
+type Option func(*options)
+
+type options struct {
+	marshal   marshal
+	unmarshal unmarshal
+	// createDirs 为 true 时写入前自动创建缺失的父目录
+	createDirs bool
+	dirMode    os.FileMode
+	fileMode   os.FileMode
+	// fileModeSet 表示显式指定了文件权限，已存在的文件也会被修改为该权限
+	fileModeSet bool
+	// uid、gid 为 -1 时不修改属主
+	uid, gid int
+	// gzipLevel 写入 .gz 文件时使用的压缩级别
+	gzipLevel int
+	// xmlIndent 非空时以该缩进输出 XML
+	xmlIndent string
+	// atomic 为 true 时先写入临时文件再重命名
+	atomic bool
+	// latest 非空时写入后将其更新为指向写入文件的符号链接，latestCopy 为 true 时改为复制
+	latest     string
+	latestCopy bool
+	// writeDefault 为 true 时 ReadFileOr 在没有匹配文件时写入默认值
+	writeDefault bool
+	// format 非空时代替文件的后缀名决定格式，例如 ".json"
+	format string
+	// httpClient 是 ReadFileURL 使用的客户端，为 nil 时使用 http.DefaultClient
+	httpClient *http.Client
+	// lock 为 true 时写入前获取文件锁，最多等待 lockTimeout
+	lock        bool
+	lockTimeout time.Duration
+	// allowMissing 为 true 时 UpdateFile 在没有匹配文件时从零值开始
+	allowMissing bool
+	// checksum 不为 0 时写入后生成校验文件，requireChecksum 为 true 时读取要求校验文件存在
+	checksum        crypto.Hash
+	requireChecksum bool
+	// unionHeaders 为 true 时 MergeCSVFiles 合并所有文件的列
+	unionHeaders bool
+	// keepLast 为 true 时 DedupeCSVFile 保留每组的最后一行
+	keepLast bool
+	// maxSize 是一次读入整个文件时允许的最大字节数，不大于 0 时不限制
+	maxSize int64
+	// retryAttempts 大于 1 时读取遇到暂时性错误会重试，见 WithRetry
+	retryAttempts int
+	retryBackoff  time.Duration
+	retryMissing  bool
+	// sniffing 为 true 时后缀名无法识别则根据内容判断格式
+	sniffing bool
+	// charset 是读写文件使用的字符集，charsetDetect 为 true 时读取时自动判断，无法判断时使用 charsetFallback
+	charset         string
+	charsetDetect   bool
+	charsetFallback string
+	// partialResults 为 true 时 ReadDirFiles、WriteShardedCSVFiles 在部分文件失败时仍返回其余文件的结果
+	partialResults bool
+	// progress 不为 nil 时读写文件时报告进度
+	progress func(done, total int64)
+	// sync 为 true 时写入后 fsync 文件及其所在目录
+	sync bool
+	// skipInvalid 为 true 时 ReadFirstFile 跳过解析失败的文件
+	skipInvalid bool
+	// listOpts 是 ReadFile 等选择最新文件时使用的过滤条件
+	listOpts []ListOption
+	// ctx 是 ReadFileCtx 等函数传入的 context，结束后停止查找、读取和写入
+	ctx context.Context
+	// sanitizeNames 为 true 时写入的文件名中占位符的值经过 SanitizeFileName
+	sanitizeNames bool
+	// csvOpts、jsonOpts、yamlOpts 是本次调用的格式选项，见 SetFormatOptions
+	csvOpts  []csv.Option
+	jsonOpts *JSONOptions
+	yamlOpts *YAMLOptions
+	// minFreeSpace 大于 0 时写入前检查可用空间，不足时先调用 lowSpaceCleanup（不为 nil 时）再检查一次
+	minFreeSpace    int64
+	lowSpaceCleanup func() error
+	// openFlag 不为 0 时代替 fsutil.FsCWTFlags 打开非原子写入的文件，见 WithOpenOptions
+	openFlag int
+}
+
+// newOptions 在默认配置上依次应用 opts
+func newOptions(opts []Option) *options {
+	o := &options{
+		createDirs: true,
+		dirMode:    defaultDirMode,
+		fileMode:   fsutil.DefaultFilePerm,
+		uid:        -1,
+		gid:        -1,
+		gzipLevel:  gzip.DefaultCompression,
+		maxSize:    DefaultMaxSize,
+		ctx:        context.Background(),
+	}
+	for _, opt := range opts {
+		if opt != nil {
+			opt(o)
+		}
+	}
+	return o
+}
+
+// WithMarshal 指定 WriteFileWith 使用的序列化函数，不指定时根据后缀名自动选择
+func WithMarshal(m func(any) ([]byte, error)) Option {
+	return func(o *options) {
+		o.marshal = m
+	}
+}
+
+// WithUnmarshal 指定 ReadFileWith 使用的反序列化函数，不指定时根据后缀名自动选择
+func WithUnmarshal(u func([]byte, any) error) Option {
+	return func(o *options) {
+		o.unmarshal = u
+	}
+}
+
+// WithCreateDirs 设置写入前是否自动创建缺失的父目录，默认创建；关闭后父目录不存在时直接报错
+func WithCreateDirs(create bool) Option {
+	return func(o *options) {
+		o.createDirs = create
+	}
+}
+
+// WithDirMode 设置自动创建父目录时使用的权限，默认为 fsutil.DefaultDirPerm（0775）
+func WithDirMode(mode os.FileMode) Option {
+	return func(o *options) {
+		o.dirMode = mode
+	}
+}
+
+// WithFileMode 设置写入文件的权限，例如 0600；已存在的文件也会被修改为该权限
+func WithFileMode(mode os.FileMode) Option {
+	return func(o *options) {
+		o.fileMode = mode
+		o.fileModeSet = true
+	}
+}
+
+// WithOwner 设置写入文件及新建父目录的属主，uid 或 gid 为 -1 时保持不变
+func WithOwner(uid, gid int) Option {
+	return func(o *options) {
+		o.uid = uid
+		o.gid = gid
+	}
+}
+
+// WithGzipLevel 设置写入 .gz 文件时的压缩级别，取值同 compress/gzip，默认为 gzip.DefaultCompression
+func WithGzipLevel(level int) Option {
+	return func(o *options) {
+		o.gzipLevel = level
+	}
+}
+
+// WithXMLIndent 设置写入 XML 时每一级的缩进，例如 "  "，默认输出紧凑格式
+func WithXMLIndent(indent string) Option {
+	return func(o *options) {
+		o.xmlIndent = indent
+	}
+}
+
+// WithAtomic 先写入同一目录下的临时文件再重命名为目标文件，读者不会看到写了一半的文件
+func WithAtomic() Option {
+	return func(o *options) {
+		o.atomic = true
+	}
+}
+
+// WithWriteDefault 使 ReadFileOr 和 ReadFileOrDefault 在没有匹配的文件时将默认值写入 path
+func WithWriteDefault() Option {
+	return func(o *options) {
+		o.writeDefault = true
+	}
+}
+
+// WithFormat 指定文件格式，代替后缀名选择 marshal 和 unmarshal，例如 "json"、".yaml" 或 "csv.gz"
+// 读写标准输入输出（path 为 "-"）时没有后缀名，须通过它或 WithMarshal、WithUnmarshal 指定格式
+func WithFormat(format string) Option {
+	return func(o *options) {
+		if format != "" && !strings.HasPrefix(format, ".") {
+			format = "." + format
+		}
+		o.format = format
+	}
+}
+
+// WithHTTPClient 指定 ReadFileURL 使用的 http.Client，例如附加认证头的客户端
+func WithHTTPClient(client *http.Client) Option {
+	return func(o *options) {
+		o.httpClient = client
+	}
+}
+
+// WithLock 在写入前获取目标文件的建议性锁（unix 上为 flock，Windows 上为 LockFileEx），避免多个进程交错写入
+// 最多等待 timeout，超时返回 ErrLockTimeout；timeout 为负数时一直等待。锁与 UpdateFile 相同，加在 path 中的通配符和占位符
+// 替换为 _ 后再加 ".lock" 的文件上，同一模式的所有写入共用一把锁，不会为每个带时间戳的文件留下锁文件
+func WithLock(timeout time.Duration) Option {
+	return func(o *options) {
+		o.lock = true
+		o.lockTimeout = timeout
+	}
+}
+
+// WithAllowMissing 使 UpdateFile 在没有匹配的文件时从零值开始，而不是返回 ErrNoMatch
+func WithAllowMissing() Option {
+	return func(o *options) {
+		o.allowMissing = true
+	}
+}
+
+// WithUnionHeaders 使 MergeCSVFiles 在各文件表头不一致时合并所有列，按首次出现的顺序排列，文件中缺少的列留空
+func WithUnionHeaders() Option {
+	return func(o *options) {
+		o.unionHeaders = true
+	}
+}
+
+// WithKeepLast 使 DedupeCSVFile 保留每组重复行中的最后一行，默认保留第一行
+func WithKeepLast() Option {
+	return func(o *options) {
+		o.keepLast = true
+	}
+}
+
+// WithPartialResults 使 ReadDirFiles 在部分文件解码失败时仍返回其余文件的结果，同时返回包含失败文件名的错误
+// 对 WriteShardedCSVFiles 则保留已写入的分片并返回它们
+func WithPartialResults() Option {
+	return func(o *options) {
+		o.partialResults = true
+	}
+}
+
+// WithSkipInvalid 使 ReadFirstFile 在文件解析失败时继续尝试下一个路径，而不是返回错误
+func WithSkipInvalid() Option {
+	return func(o *options) {
+		o.skipInvalid = true
+	}
+}
+
+// WithSelect 使 ReadFileWith、ReadFileFS 等按 opts 过滤候选文件后再选择最新的文件，例如 WithSelect(WithStableFor(time.Second)) 跳过仍在写入的文件
+func WithSelect(opts ...ListOption) Option {
+	return func(o *options) {
+		o.listOpts = append(o.listOpts, opts...)
+	}
+}
+
+// WithSanitizeNames 使 WriteFile 等展开文件名中的 {host} 和 {ts:layout} 时，先以 SanitizeFileName 清理占位符的值，
+// 避免本机名或时间格式中的 / 等字符产生意外的子目录；模板本身的其他部分不受影响，由数据拼接的片段应自行调用 SanitizeFileName
+func WithSanitizeNames() Option {
+	return func(o *options) {
+		o.sanitizeNames = true
+	}
+}
+
+// WithOpenOptions 将 SaveFile 接受的 fsutil.OpenOptionFunc 转换为 Option，与其他选项一起使用，
+// 例如 SaveFileWith(path, data, WithOpenOptions(fsutil.WithFlag(fsutil.FsCWAFlags)), WithAtomic())
+// Perm 只作用于新建的文件，与旧版相同；Flag 只在非原子写入时生效
+func WithOpenOptions(optFns ...fsutil.OpenOptionFunc) Option {
+	return func(o *options) {
+		opt := fsutil.NewOpenOption(optFns...)
+		o.fileMode = opt.Perm
+		o.openFlag = opt.Flag
+	}
+}
+
+// formatExt 返回决定 name 格式的后缀名，WithFormat 指定的格式优先
+func (o *options) formatExt(name string) (ext string, gzipped bool) {
+	if o.format != "" {
+		return formatExt(o.format)
+	}
+	return formatExt(name)
+}
+
+// chown 按 WithOwner 的设置修改 name 的属主
+func (o *options) chown(name string) error {
+	if o.uid == -1 && o.gid == -1 {
+		return nil
+	}
+	if err := os.Chown(name, o.uid, o.gid); err != nil {
+		return fmt.Errorf("change owner: %w", err)
+	}
+	return nil
+}
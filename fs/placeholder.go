@@ -0,0 +1,153 @@
+package fs
+
+import (
+	"crypto"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// placeholderPattern 匹配文件名中的 {ts}、{ts:layout}、{host}、{seq} 和 {hash} 占位符
+var placeholderPattern = regexp.MustCompile(`\{(ts(?::[^}]*)?|host|seq|hash)\}`)
+
+// hashPlaceholder 在 WriteFile 等写入序列化数据的函数中替换为内容的哈希
+const hashPlaceholder = "{hash}"
+
+// contentHashLen 是 {hash} 展开后的十六进制字符数
+const contentHashLen = 12
+
+// ExpandFileName 展开 path 中的占位符，结果只取决于参数：
+//   - * 和 {ts} 替换为 t 的 20060102_150405 格式
+//   - {ts:layout} 替换为 t 的 layout 格式，例如 {ts:2006-01-02}
+//   - {host} 替换为 host
+//   - {seq} 替换为 seq
+//
+// {hash} 取决于写入的内容，由 WriteFile 等函数在序列化后替换，这里保持不变；其他花括号内容同样保持不变
+func ExpandFileName(path string, t time.Time, host string, seq int) string {
+	return expandPlaceholders(path, t, host, seq, false)
+}
+
+// expandPlaceholders 与 ExpandFileName 相同，sanitize 为 true 时 {host} 和 {ts:layout} 的值先经过 SanitizeFileName
+func expandPlaceholders(path string, t time.Time, host string, seq int, sanitize bool) string {
+	clean := func(s string) string {
+		if sanitize {
+			return SanitizeFileName(s)
+		}
+		return s
+	}
+	path = strings.ReplaceAll(path, "*", t.Format(timestampLayout))
+	return placeholderPattern.ReplaceAllStringFunc(path, func(m string) string {
+		name := m[1 : len(m)-1]
+		switch {
+		case name == "host":
+			return clean(host)
+		case name == "seq":
+			return strconv.Itoa(seq)
+		case name == "hash":
+			return m
+		case name == "ts":
+			return t.Format(timestampLayout)
+		default:
+			return clean(t.Format(strings.TrimPrefix(name, "ts:")))
+		}
+	})
+}
+
+// GlobPattern 将 path 中的占位符替换为 *，得到匹配所有展开结果的 glob 模式，供 GetLatestFileByName、ListFiles 等使用
+func GlobPattern(path string) string {
+	return placeholderPattern.ReplaceAllString(path, "*")
+}
+
+// hasSeq 判断 path 中是否有 {seq} 占位符
+func hasSeq(path string) bool {
+	return strings.Contains(path, "{seq}")
+}
+
+// hostname 返回本机名，获取失败时返回 localhost
+func hostname() string {
+	host, err := os.Hostname()
+	if err != nil || host == "" {
+		return "localhost"
+	}
+	return host
+}
+
+// maxSeq 是 {seq} 最多尝试的序号，避免已有文件过多时无休止地递增
+const maxSeq = 100000
+
+// expandFileName 以当前时间和本机名展开 path，有 {seq} 时从 1 开始递增，直到 exists 报告文件名不与已有文件冲突
+// exists 出错或超过 maxSeq 时返回错误及最后尝试的文件名；sanitize 的含义同 expandPlaceholders
+func expandFileName(path string, exists func(string) (bool, error), sanitize bool) (string, error) {
+	t, host := now(), ""
+	if strings.Contains(path, "{host}") {
+		host = hostname()
+	}
+	if !hasSeq(path) {
+		return expandPlaceholders(path, t, host, 0, sanitize), nil
+	}
+	var filename string
+	for seq := 1; seq <= maxSeq; seq++ {
+		filename = expandPlaceholders(path, t, host, seq, sanitize)
+		found, err := exists(filename)
+		if err != nil {
+			return filename, fmt.Errorf("expand {seq}: %w", err)
+		}
+		if !found {
+			return filename, nil
+		}
+	}
+	return filename, fmt.Errorf("expand {seq}: %s: all %d sequence numbers are taken", path, maxSeq)
+}
+
+// contentHash 返回 data 的 SHA-256 的前 12 个十六进制字符
+func contentHash(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])[:contentHashLen]
+}
+
+// expandHash 将 path 中的 {hash} 替换为 data 的内容哈希
+func expandHash(path string, data []byte) string {
+	if !strings.Contains(path, hashPlaceholder) {
+		return path
+	}
+	return strings.ReplaceAll(path, hashPlaceholder, contentHash(data))
+}
+
+// VerifyContentAddressed 重新计算与 path 匹配的最新文件（path 可以是含 {hash} 的写入模板）的 SHA-256，
+// 判断文件名中是否有与之相符的 12 位十六进制哈希，即文件写入后内容是否未被修改
+// 哈希按磁盘上的字节计算，与 {hash} 的展开方式相同；文件名中没有 12 位十六进制串时返回错误
+func VerifyContentAddressed(path string) (bool, error) {
+	filename, err := GetLatestFileByName(path)
+	if err != nil {
+		return false, fmt.Errorf("get latest file: %w", err)
+	}
+	// 文件名中以非十六进制字符分隔的 12 位十六进制串
+	matches := slices.DeleteFunc(strings.FieldsFunc(filepath.Base(filename), func(r rune) bool {
+		return !strings.ContainsRune("0123456789abcdefABCDEF", r)
+	}), func(s string) bool {
+		return len(s) != contentHashLen
+	})
+	if len(matches) == 0 {
+		return false, fmt.Errorf("%s: no content hash in file name", filename)
+	}
+
+	f, err := os.Open(filename)
+	if err != nil {
+		return false, fmt.Errorf("read file: %w", err)
+	}
+	defer f.Close()
+	sum, err := checksumOf(crypto.SHA256, f)
+	if err != nil {
+		return false, fmt.Errorf("%s: %w", filename, err)
+	}
+	return slices.ContainsFunc(matches, func(m string) bool {
+		return strings.EqualFold(m, sum[:contentHashLen])
+	}), nil
+}
@@ -0,0 +1,80 @@
+package fs
+
+import (
+	"bytes"
+	"io"
+	iofs "io/fs"
+	"strings"
+	"time"
+)
+
+// progressInterval 是两次进度回调之间的最短间隔，避免回调拖慢小文件的读写
+const progressInterval = 100 * time.Millisecond
+
+// WithProgress 在读写文件时报告进度，done 为已读取或写入的字节数，total 为总字节数，未知时为 -1
+// 读取时 total 为文件大小（gzip 压缩的文件按压缩后的大小计算），写入 []byte 或 string 时为其长度，写入 io.Reader 时为 -1
+// 回调每秒最多约 10 次，完成时总会以最终的字节数回调一次
+func WithProgress(fn func(done, total int64)) Option {
+	return func(o *options) {
+		o.progress = fn
+	}
+}
+
+// progressReader 统计经过的字节数并按 progressInterval 回调
+type progressReader struct {
+	r        io.Reader
+	fn       func(done, total int64)
+	done     int64
+	total    int64
+	last     time.Time
+	finished bool
+}
+
+func (p *progressReader) Read(b []byte) (int, error) {
+	n, err := p.r.Read(b)
+	p.done += int64(n)
+	switch {
+	case err == io.EOF:
+		if !p.finished {
+			p.finished = true
+			p.fn(p.done, p.total)
+		}
+	case n > 0 && time.Since(p.last) >= progressInterval:
+		p.last = time.Now()
+		p.fn(p.done, p.total)
+	}
+	return n, err
+}
+
+// progressReader 在设置了 WithProgress 时包装 r，total 为总字节数，未知时为 -1
+func (o *options) progressReader(r io.Reader, total int64) io.Reader {
+	if o.progress == nil {
+		return r
+	}
+	return &progressReader{r: r, fn: o.progress, total: total, last: time.Now()}
+}
+
+// statSize 返回 f 的大小，无法获取时为 -1
+func statSize(f interface{ Stat() (iofs.FileInfo, error) }) int64 {
+	info, err := f.Stat()
+	if err != nil {
+		return -1
+	}
+	return info.Size()
+}
+
+// progressData 在设置了 WithProgress 时将要写入的 data（[]byte、string 或 io.Reader）包装为统计进度的 io.Reader
+func (o *options) progressData(data any) any {
+	if o.progress == nil {
+		return data
+	}
+	switch v := data.(type) {
+	case []byte:
+		return o.progressReader(bytes.NewReader(v), int64(len(v)))
+	case string:
+		return o.progressReader(strings.NewReader(v), int64(len(v)))
+	case io.Reader:
+		return o.progressReader(v, -1)
+	}
+	return data
+}
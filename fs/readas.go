@@ -0,0 +1,40 @@
+package fs
+
+// ReadFileAs 与 ReadFile 相同，但以返回值的形式返回解码的 T，出错时返回 T 的零值
+func ReadFileAs[T any](path string, opts ...Option) (T, error) {
+	var v T
+	if err := ReadFileWith(path, &v, opts...); err != nil {
+		var zero T
+		return zero, err
+	}
+	return v, nil
+}
+
+// ReadCSVFileAs 与 ReadCSVFile 相同，但以返回值的形式返回解码的行
+func ReadCSVFileAs[T any](path string, opts ...Option) ([]T, error) {
+	var rows []T
+	if err := ReadCSVFile(path, &rows, opts...); err != nil {
+		return nil, err
+	}
+	return rows, nil
+}
+
+// ReadJsonFileAs 与 ReadJsonFile 相同，但以返回值的形式返回解码的 T，出错时返回 T 的零值
+func ReadJsonFileAs[T any](path string, opts ...Option) (T, error) {
+	var v T
+	if err := ReadJsonFile(path, &v, opts...); err != nil {
+		var zero T
+		return zero, err
+	}
+	return v, nil
+}
+
+// ReadYAMLFileAs 与 ReadYAMLFile 相同，但以返回值的形式返回解码的 T，出错时返回 T 的零值
+func ReadYAMLFileAs[T any](path string, opts ...Option) (T, error) {
+	var v T
+	if err := ReadYAMLFile(path, &v, opts...); err != nil {
+		var zero T
+		return zero, err
+	}
+	return v, nil
+}
@@ -0,0 +1,148 @@
+package fs
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"slices"
+	"strings"
+
+	"github.com/0xuLiang/lancet/csv"
+)
+
+// ReadFiles 读取所有与 pattern 匹配的文件，按文件名的自然顺序（见 NaturalLess）逐个反序列化
+// out 为切片指针时，各文件的元素依次追加到切片中；out 为 *map[string]T 时，每个文件解码为一个 T，以文件名为键
+// 多个 CSV 文件的表头必须一致，否则返回包含不一致文件名的错误
+func ReadFiles(pattern string, out any, opts ...Option) error {
+	return ReadFilesCtx(context.Background(), pattern, out, opts...)
+}
+
+// ReadFilesCtx 与 ReadFiles 相同，但在读取每个文件前检查 ctx，ctx 结束后返回 ctx.Err()，out 中保留已读取的文件
+func ReadFilesCtx(ctx context.Context, pattern string, out any, opts ...Option) error {
+	rv := reflect.ValueOf(out)
+	if rv.Kind() != reflect.Ptr || rv.IsNil() {
+		return errors.New("out must be a non-nil pointer to a slice or a map[string]T")
+	}
+	target := rv.Elem()
+	switch {
+	case target.Kind() == reflect.Slice:
+	case target.Kind() == reflect.Map && target.Type().Key().Kind() == reflect.String:
+		if target.IsNil() {
+			target.Set(reflect.MakeMap(target.Type()))
+		}
+	default:
+		return errors.New("out must be a non-nil pointer to a slice or a map[string]T")
+	}
+
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	matches, err := glob(osFS{}, GlobPattern(pattern))
+	if err != nil {
+		return err
+	}
+	o := newOptions(append(opts[:len(opts):len(opts)], withContext(ctx)))
+	matches = slices.DeleteFunc(matches, newListOptions(o.listOpts).excluded(pattern))
+	if len(matches) == 0 {
+		return ErrNoMatch
+	}
+	slices.SortFunc(matches, naturalCompare)
+
+	var firstCSV string
+	var header []string
+	for _, filename := range matches {
+		if err = ctx.Err(); err != nil {
+			return err
+		}
+		if ext, _ := o.formatExt(filename); ext == ".csv" {
+			h, err := readCSVHeader(filename)
+			if err != nil {
+				return fmt.Errorf("%s: read header: %w", filename, err)
+			}
+			if firstCSV == "" {
+				firstCSV, header = filename, h
+			} else if !slices.Equal(h, header) {
+				return fmt.Errorf("%s: %w: header %q does not match header %q of %s", filename, ErrHeaderMismatch, h, header, firstCSV)
+			}
+		}
+
+		var elem reflect.Value
+		if target.Kind() == reflect.Slice {
+			elem = reflect.New(target.Type())
+		} else {
+			elem = reflect.New(target.Type().Elem())
+		}
+		if err = o.readFile(osFS{}, filename, elem.Interface()); err != nil {
+			return fmt.Errorf("%s: %w", filename, err)
+		}
+
+		if target.Kind() == reflect.Slice {
+			target.Set(reflect.AppendSlice(target, elem.Elem()))
+		} else {
+			target.SetMapIndex(reflect.ValueOf(filename).Convert(target.Type().Key()), elem.Elem())
+		}
+	}
+
+	return nil
+}
+
+// ReadDirFiles 读取所有与 pattern 匹配的文件，每个文件按后缀名解码为一个 T，以不含后缀名（包括 .gz）的文件名为键
+// 例如 tenants/*.yaml 中的 acme.yaml 对应键 acme；去掉后缀名后重名的文件会报错，目录会被跳过
+// 解码失败时返回包含所有失败文件名的错误；配合 WithPartialResults 则同时返回其余文件的结果
+func ReadDirFiles[T any](pattern string, opts ...Option) (map[string]T, error) {
+	entries, err := ListFiles(pattern, WithExcludeDirs())
+	if err != nil {
+		return nil, err
+	}
+	if len(entries) == 0 {
+		return nil, ErrNoMatch
+	}
+
+	o := newOptions(opts)
+	result := make(map[string]T, len(entries))
+	sources := make(map[string]string, len(entries))
+	var errs []error
+	for _, entry := range entries {
+		key := trimExt(filepath.Base(entry.Path))
+		if prev, ok := sources[key]; ok {
+			errs = append(errs, fmt.Errorf("%s: key %q is already used by %s", entry.Path, key, prev))
+			continue
+		}
+		var v T
+		if err := o.readFile(osFS{}, entry.Path, &v); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", entry.Path, err))
+			continue
+		}
+		result[key] = v
+		sources[key] = entry.Path
+	}
+
+	if err = errors.Join(errs...); err != nil && !o.partialResults {
+		return nil, err
+	}
+	return result, err
+}
+
+// trimExt 去掉文件名的 .gz 及格式后缀名
+func trimExt(name string) string {
+	name = strings.TrimSuffix(name, gzipExt)
+	return strings.TrimSuffix(name, filepath.Ext(name))
+}
+
+// readCSVHeader 读取 CSV 文件的表头，gzip 压缩的文件会自动解压
+func readCSVHeader(filename string) ([]string, error) {
+	f, err := os.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	r, err := gunzipReader(f)
+	if err != nil {
+		return nil, err
+	}
+	return csv.NewDecoder(r).Header()
+}
@@ -0,0 +1,75 @@
+package fs
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+// ReadFileOr 与 ReadFile 相同，但没有与 path 匹配的文件时调用 fallback 填充 out
+// 文件无法读取或解析失败等其他错误仍会返回；配合 WithWriteDefault 可在首次运行时将 out 写入 path
+func ReadFileOr(path string, out any, fallback func() error, opts ...Option) error {
+	err := ReadFileWith(path, out, opts...)
+	if !errors.Is(err, ErrNoMatch) {
+		return err
+	}
+	if err = fallback(); err != nil {
+		return err
+	}
+	if newOptions(opts).writeDefault {
+		if err = WriteFileWith(path, out, opts...); err != nil {
+			return fmt.Errorf("write default: %w", err)
+		}
+	}
+	return nil
+}
+
+// ReadFileOrDefault 与 ReadFileOr 相同，没有匹配的文件时将 defaultValue 赋给 out
+// defaultValue 的类型须与 out 指向的类型相同，也可以是指向该类型的指针
+func ReadFileOrDefault(path string, out any, defaultValue any, opts ...Option) error {
+	return ReadFileOr(path, out, func() error {
+		rv := reflect.ValueOf(out)
+		if rv.Kind() != reflect.Ptr || rv.IsNil() {
+			return errors.New("out must be a non-nil pointer")
+		}
+		dv := reflect.ValueOf(defaultValue)
+		if dv.Kind() == reflect.Ptr && !dv.IsNil() && dv.Elem().Type() == rv.Elem().Type() {
+			dv = dv.Elem()
+		}
+		if !dv.IsValid() || !dv.Type().AssignableTo(rv.Elem().Type()) {
+			return fmt.Errorf("default value %T is not assignable to %s", defaultValue, rv.Elem().Type())
+		}
+		rv.Elem().Set(dv)
+		return nil
+	}, opts...)
+}
+
+// ReadFirstFile 按顺序尝试 paths 中的每个路径（可以是模式），读取第一个存在的文件并返回其文件名，例如依次查找 ./app.yaml、~/.app.yaml、/etc/app.yaml
+// 全部不存在时返回包含所有路径的 ErrNoMatch；已存在的文件解析失败时默认停止查找并返回该错误，
+// 配合 WithSkipInvalid 则将 out 重置为零值后继续尝试下一个路径
+func ReadFirstFile(paths []string, out any, opts ...Option) (string, error) {
+	o := newOptions(opts)
+	var errs []error
+	for _, path := range paths {
+		filename, err := GetLatestFileByName(path, o.listOpts...)
+		if errors.Is(err, ErrNoMatch) {
+			continue
+		} else if err != nil {
+			return "", fmt.Errorf("get latest file: %w", err)
+		}
+
+		if err = o.readFile(osFS{}, filename, out); err == nil {
+			return filename, nil
+		}
+		err = fmt.Errorf("%s: %w", filename, err)
+		if !o.skipInvalid {
+			return filename, err
+		}
+		errs = append(errs, err)
+		if rv := reflect.ValueOf(out); rv.Kind() == reflect.Ptr && !rv.IsNil() {
+			rv.Elem().SetZero()
+		}
+	}
+	return "", errors.Join(append([]error{fmt.Errorf("%w: tried %s", ErrNoMatch, strings.Join(paths, ", "))}, errs...)...)
+}
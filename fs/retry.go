@@ -0,0 +1,65 @@
+package fs
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	iofs "io/fs"
+	"math/rand/v2"
+	"time"
+)
+
+// WithRetry 读取遇到 EIO 等暂时性错误时重新查找并读取文件，最多尝试 attempts 次
+// 第 n 次重试前等待 backoff 的 2^(n-1) 倍，并随机减少至多一半，避免多个读者同时重试
+func WithRetry(attempts int, backoff time.Duration) Option {
+	return func(o *options) {
+		o.retryAttempts = attempts
+		o.retryBackoff = backoff
+	}
+}
+
+// WithRetryMissing 使 WithRetry 把 ErrNoMatch 和文件不存在也视为暂时性错误，例如轮转后文件短暂缺失
+func WithRetryMissing() Option {
+	return func(o *options) {
+		o.retryMissing = true
+	}
+}
+
+// retry 按 WithRetry 的设置调用 fn，直到成功、遇到非暂时性错误、达到次数上限或 ctx 结束
+// 重试过的错误会注明尝试的次数并包装最后一次的错误
+func (o *options) retry(ctx context.Context, fn func() error) error {
+	for attempt := 1; ; attempt++ {
+		err := fn()
+		if err == nil || attempt >= o.retryAttempts || !o.transient(err) {
+			if err != nil && attempt > 1 {
+				err = fmt.Errorf("after %d attempts: %w", attempt, err)
+			}
+			return err
+		}
+
+		delay := o.retryBackoff << (attempt - 1)
+		if delay > 0 {
+			delay -= time.Duration(rand.Int64N(int64(delay/2) + 1))
+		}
+		timer := time.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return fmt.Errorf("after %d attempts: %w", attempt, errors.Join(ctx.Err(), err))
+		case <-timer.C:
+		}
+	}
+}
+
+// transient 判断 err 是否为值得重试的暂时性错误
+func (o *options) transient(err error) bool {
+	if o.retryMissing && errors.Is(err, iofs.ErrNotExist) {
+		return true
+	}
+	for _, target := range transientErrors {
+		if errors.Is(err, target) {
+			return true
+		}
+	}
+	return false
+}
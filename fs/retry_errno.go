@@ -0,0 +1,8 @@
+//go:build !plan9
+
+package fs
+
+import "syscall"
+
+// transientErrors 是 WithRetry 视为暂时性、值得重试的错误
+var transientErrors = []error{syscall.EIO, syscall.ESTALE, syscall.EAGAIN, syscall.EINTR}
@@ -0,0 +1,6 @@
+//go:build plan9
+
+package fs
+
+// transientErrors 在 Plan 9 上为空，错误以字符串表示，没有可供判断的错误码
+var transientErrors []error
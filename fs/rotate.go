@@ -0,0 +1,128 @@
+package fs
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// RotateOption 配置 RotateFiles 和 CleanupOlderThan
+type RotateOption func(*rotateOptions)
+
+type rotateOptions struct {
+	dryRun bool
+	force  bool
+}
+
+// WithDryRun 只返回将被删除的文件，不实际删除
+func WithDryRun() RotateOption {
+	return func(o *rotateOptions) {
+		o.dryRun = true
+	}
+}
+
+// WithForce 关闭对过于宽泛的模式（例如只有 *）的保护
+func WithForce() RotateOption {
+	return func(o *rotateOptions) {
+		o.force = true
+	}
+}
+
+// RotateFiles 只保留与 pattern 匹配的最新的 keep 个文件，删除其余文件并返回被删除的文件
+// 新旧按文件名中的时间戳判断，没有时间戳的文件使用修改时间，目录不受影响
+// 为避免误删，文件名部分只由通配符组成的模式（例如 * 或 *.*）会被拒绝，可通过 WithForce 关闭该保护
+func RotateFiles(pattern string, keep int, opts ...RotateOption) (removed []string, err error) {
+	o := &rotateOptions{}
+	for _, opt := range opts {
+		if opt != nil {
+			opt(o)
+		}
+	}
+
+	if keep < 0 {
+		return nil, errors.New("keep must not be negative")
+	}
+	if !o.force && !hasLiteral(filepath.Base(GlobPattern(pattern))) {
+		return nil, fmt.Errorf("%w: %q, use WithForce to rotate it anyway", ErrPatternTooBroad, pattern)
+	}
+
+	entries, err := ListFiles(pattern, WithExcludeDirs(), WithSortBy(SortByTimestamp), WithDescending())
+	if err != nil {
+		return nil, err
+	}
+	if len(entries) <= keep {
+		return nil, nil
+	}
+
+	for _, entry := range entries[keep:] {
+		if !o.dryRun {
+			if err = os.Remove(entry.Path); err != nil {
+				return removed, err
+			}
+		}
+		removed = append(removed, entry.Path)
+	}
+	return removed, nil
+}
+
+// CleanupOlderThan 删除与 pattern 匹配且早于 maxAge 之前的文件，返回被删除的文件
+// 新旧按文件名中的时间戳判断，没有时间戳的文件使用修改时间，当前时间由 SetClock 决定；目录（包括指向目录的符号链接）不受影响
+// 某个文件删除失败时继续删除其余文件，最后返回所有错误的组合。模式的保护规则和 WithDryRun 同 RotateFiles
+func CleanupOlderThan(pattern string, maxAge time.Duration, opts ...RotateOption) (removed []string, err error) {
+	o := &rotateOptions{}
+	for _, opt := range opts {
+		if opt != nil {
+			opt(o)
+		}
+	}
+
+	if maxAge < 0 {
+		return nil, errors.New("maxAge must not be negative")
+	}
+	if !o.force && !hasLiteral(filepath.Base(GlobPattern(pattern))) {
+		return nil, fmt.Errorf("%w: %q, use WithForce to clean it up anyway", ErrPatternTooBroad, pattern)
+	}
+
+	entries, err := ListFiles(pattern, WithExcludeDirs(), WithSortBy(SortByTimestamp))
+	if err != nil {
+		return nil, err
+	}
+
+	cutoff := now().Add(-maxAge)
+	var errs []error
+	for _, entry := range entries {
+		if !entry.nameTime().Before(cutoff) {
+			continue
+		}
+		if !o.dryRun {
+			if err := os.Remove(entry.Path); err != nil {
+				errs = append(errs, err)
+				continue
+			}
+		}
+		removed = append(removed, entry.Path)
+	}
+	return removed, errors.Join(errs...)
+}
+
+// hasLiteral 判断 glob 模式中是否有通配符和 . 以外的普通字符
+func hasLiteral(pattern string) bool {
+	for i := 0; i < len(pattern); i++ {
+		switch c := pattern[i]; c {
+		case '*', '?', '.':
+		case '[':
+			// 字符类只匹配一个字符，不算作普通字符
+			if end := strings.IndexByte(pattern[i:], ']'); end > 0 {
+				i += end
+			}
+		case '\\':
+			return i+1 < len(pattern)
+		default:
+			return true
+		}
+	}
+	return false
+}
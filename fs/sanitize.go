@@ -0,0 +1,64 @@
+package fs
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+	"unicode"
+)
+
+// windowsReservedNames 是 Windows 保留的设备名，不区分大小写，带后缀名时同样保留
+var windowsReservedNames = map[string]bool{
+	"CON": true, "PRN": true, "AUX": true, "NUL": true,
+	"COM1": true, "COM2": true, "COM3": true, "COM4": true, "COM5": true, "COM6": true, "COM7": true, "COM8": true, "COM9": true,
+	"LPT1": true, "LPT2": true, "LPT3": true, "LPT4": true, "LPT5": true, "LPT6": true, "LPT7": true, "LPT8": true, "LPT9": true,
+}
+
+// SanitizeFileName 将 s 转换为可以安全地作为单个文件名或文件名片段的字符串，用于由用户名、租户名等数据拼接文件名：
+//   - 路径分隔符 / 和 \、Windows 不允许的 < > : " | ? *，以及会被当作占位符的 { } 替换为 _
+//   - 删除控制字符，连续的空白合并为一个空格，去掉首尾的空白和 .，避免产生隐藏文件或 Windows 不允许的文件名
+//   - 结果为空时返回 _，Windows 保留的设备名（如 CON、com1.txt）前加 _
+func SanitizeFileName(s string) string {
+	var b strings.Builder
+	space := false
+	for _, r := range s {
+		switch {
+		case strings.ContainsRune(`/\<>:"|?*{}`, r):
+			r = '_'
+		case unicode.IsSpace(r):
+			space = true
+			continue
+		case unicode.IsControl(r):
+			continue
+		}
+		if space && b.Len() > 0 {
+			b.WriteByte(' ')
+		}
+		space = false
+		b.WriteRune(r)
+	}
+
+	name := strings.Trim(b.String(), ". ")
+	if name == "" {
+		return "_"
+	}
+	base, _, _ := strings.Cut(name, ".")
+	if windowsReservedNames[strings.ToUpper(base)] {
+		return "_" + name
+	}
+	return name
+}
+
+// SafeJoin 拼接 dir 和 name 并清理结果，name 为绝对路径或清理后指向 dir 之外（如包含 ..）时返回 ErrPathTraversal
+// name 可以包含子目录，但必须指向 dir 之下的文件，不能是 dir 本身；SafeJoin 只检查路径，不解析符号链接
+func SafeJoin(dir, name string) (string, error) {
+	if filepath.IsAbs(name) || filepath.VolumeName(name) != "" {
+		return "", fmt.Errorf("%w: %s is an absolute path", ErrPathTraversal, name)
+	}
+	joined := filepath.Join(dir, name)
+	rel, err := filepath.Rel(filepath.Clean(dir), joined)
+	if err != nil || rel == "." || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return "", fmt.Errorf("%w: %s is not inside %s", ErrPathTraversal, name, dir)
+	}
+	return joined, nil
+}
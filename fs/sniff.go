@@ -0,0 +1,113 @@
+package fs
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"regexp"
+	"strings"
+)
+
+// WithSniffing 在后缀名无法识别时根据内容判断格式，例如内容为 JSON 或 CSV 的 export.dat
+// 依次检查 JSON、NDJSON、XML、YAML 和 CSV，只有一种格式符合时才会采用，否则仍返回 ErrUnsupportedFormat
+func WithSniffing(enabled bool) Option {
+	return func(o *options) {
+		o.sniffing = enabled
+	}
+}
+
+// sniffLines 是判断 YAML 和 CSV 时最多检查的行数
+const sniffLines = 20
+
+// yamlKeyLine 匹配 YAML 映射的 key: 行
+var yamlKeyLine = regexp.MustCompile(`^[A-Za-z_][\w.-]*:(\s|$)`)
+
+// sniffFormat 根据内容判断格式并返回对应的后缀名，没有或有多种格式符合时返回列出已尝试格式的错误
+func sniffFormat(data []byte) (string, error) {
+	trimmed := bytes.TrimSpace(bytes.TrimPrefix(data, []byte("\ufeff")))
+	var candidates []string
+	switch {
+	case len(trimmed) == 0:
+	case (trimmed[0] == '{' || trimmed[0] == '[') && json.Valid(trimmed):
+		candidates = append(candidates, ".json")
+	case trimmed[0] == '{' && isNDJSONContent(trimmed):
+		candidates = append(candidates, ".ndjson")
+	case trimmed[0] == '<':
+		candidates = append(candidates, ".xml")
+	case bytes.HasPrefix(trimmed, []byte("---")):
+		candidates = append(candidates, ".yaml")
+	default:
+		if looksLikeYAML(trimmed) {
+			candidates = append(candidates, ".yaml")
+		}
+		if looksLikeCSV(trimmed) {
+			candidates = append(candidates, ".csv")
+		}
+	}
+
+	if len(candidates) == 1 {
+		return candidates[0], nil
+	}
+	tried := "tried JSON, NDJSON, XML, YAML and CSV"
+	if len(candidates) > 1 {
+		return "", fmt.Errorf("content is ambiguous, it looks like %s; %s", strings.Join(candidates, " and "), tried)
+	}
+	return "", fmt.Errorf("content is not recognizable; %s", tried)
+}
+
+// isNDJSONContent 判断每个非空行是否都是合法的 JSON
+func isNDJSONContent(data []byte) bool {
+	sc := bufio.NewScanner(bytes.NewReader(data))
+	sc.Buffer(nil, len(data)+1)
+	for sc.Scan() {
+		if line := bytes.TrimSpace(sc.Bytes()); len(line) > 0 && !json.Valid(line) {
+			return false
+		}
+	}
+	return sc.Err() == nil
+}
+
+// looksLikeYAML 判断前几个非空、非注释行是否都是 key: 行（可以 - 开头）或以缩进、- 开头的行，且至少有一个 key: 行
+func looksLikeYAML(data []byte) bool {
+	keys := 0
+	for i, line := range strings.Split(string(data), "\n") {
+		if i >= sniffLines {
+			break
+		}
+		trimmed := strings.TrimSpace(line)
+		switch {
+		case trimmed == "" || strings.HasPrefix(trimmed, "#"):
+		case yamlKeyLine.MatchString(strings.TrimPrefix(line, "- ")):
+			keys++
+		case strings.HasPrefix(line, " ") || strings.HasPrefix(line, "\t") || strings.HasPrefix(line, "- "):
+		default:
+			return false
+		}
+	}
+	return keys > 0
+}
+
+// looksLikeCSV 判断前几条记录是否至少有两条，且都有相同的（多于一个的）字段数
+func looksLikeCSV(data []byte) bool {
+	r := csv.NewReader(bytes.NewReader(data))
+	r.FieldsPerRecord = -1
+	fields, records := 0, 0
+	for ; records < sniffLines; records++ {
+		record, err := r.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return false
+		}
+		if records == 0 {
+			fields = len(record)
+		} else if len(record) != fields {
+			return false
+		}
+	}
+	return records >= 2 && fields > 1
+}
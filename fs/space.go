@@ -0,0 +1,76 @@
+package fs
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// diskSpace 返回 dir 所在文件系统中当前用户可用的字节数，当前平台不支持时 ok 为 false，测试中可替换
+var diskSpace = availableSpace
+
+// WithMinFreeSpace 使 WriteFileWith、SaveFileWith 等写入磁盘前检查目标文件系统的可用空间，
+// 可用空间少于 bytes 加上要写入的字节数（data 为 io.Reader 时只要求 bytes）时返回 ErrInsufficientSpace，不创建或修改文件
+// 不支持查询可用空间的平台上不检查；检查与写入之间其他进程仍可能占用空间
+func WithMinFreeSpace(bytes int64) Option {
+	return func(o *options) {
+		o.minFreeSpace = bytes
+	}
+}
+
+// WithLowSpaceCleanup 配合 WithMinFreeSpace，可用空间不足时先调用 fn 释放空间再检查一次，仍然不足才返回 ErrInsufficientSpace
+// 例如 func() error { _, err := RotateFiles("out/report_*.csv", 7); return err }
+func WithLowSpaceCleanup(fn func() error) Option {
+	return func(o *options) {
+		o.lowSpaceCleanup = fn
+	}
+}
+
+// checkFreeSpace 按 WithMinFreeSpace 检查 filename 所在文件系统是否有足够的空间写入 data
+func (o *options) checkFreeSpace(filename string, data any) error {
+	if o.minFreeSpace <= 0 {
+		return nil
+	}
+	need := o.minFreeSpace
+	switch v := data.(type) {
+	case []byte:
+		need += int64(len(v))
+	case string:
+		need += int64(len(v))
+	}
+
+	dir := existingDir(filepath.Dir(filename))
+	avail, ok, err := diskSpace(dir)
+	if err != nil {
+		return fmt.Errorf("check free space: %w", err)
+	}
+	if !ok || avail >= need {
+		return nil
+	}
+	if o.lowSpaceCleanup != nil {
+		if err = o.lowSpaceCleanup(); err != nil {
+			return fmt.Errorf("free disk space: %w", err)
+		}
+		if avail, _, err = diskSpace(dir); err != nil {
+			return fmt.Errorf("check free space: %w", err)
+		}
+		if avail >= need {
+			return nil
+		}
+	}
+	return fmt.Errorf("%w: %d bytes available in %s, need %d", ErrInsufficientSpace, avail, dir, need)
+}
+
+// existingDir 返回 dir 或它最近的已存在的上级目录，目录尚未创建时用于查询将来所在的文件系统
+func existingDir(dir string) string {
+	for {
+		if _, err := os.Stat(dir); err == nil {
+			return dir
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return dir
+		}
+		dir = parent
+	}
+}
@@ -0,0 +1,8 @@
+//go:build !(linux || darwin || freebsd || dragonfly || windows)
+
+package fs
+
+// availableSpace 在不支持查询可用空间的平台上返回 false，WithMinFreeSpace 不做检查
+func availableSpace(string) (int64, bool, error) {
+	return 0, false, nil
+}
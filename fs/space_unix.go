@@ -0,0 +1,14 @@
+//go:build linux || darwin || freebsd || dragonfly
+
+package fs
+
+import "golang.org/x/sys/unix"
+
+// availableSpace 通过 statfs 查询 dir 所在文件系统中非特权用户可用的字节数
+func availableSpace(dir string) (int64, bool, error) {
+	var st unix.Statfs_t
+	if err := unix.Statfs(dir, &st); err != nil {
+		return 0, false, err
+	}
+	return int64(st.Bavail) * int64(st.Bsize), true, nil
+}
@@ -0,0 +1,18 @@
+//go:build windows
+
+package fs
+
+import "golang.org/x/sys/windows"
+
+// availableSpace 通过 GetDiskFreeSpaceEx 查询 dir 所在卷中当前用户可用的字节数
+func availableSpace(dir string) (int64, bool, error) {
+	p, err := windows.UTF16PtrFromString(dir)
+	if err != nil {
+		return 0, false, err
+	}
+	var avail, total, free uint64
+	if err = windows.GetDiskFreeSpaceEx(p, &avail, &total, &free); err != nil {
+		return 0, false, err
+	}
+	return int64(avail), true, nil
+}
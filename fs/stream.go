@@ -0,0 +1,237 @@
+package fs
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"reflect"
+
+	"github.com/0xuLiang/lancet/csv"
+	"github.com/gookit/goutil/fsutil"
+)
+
+var errorType = reflect.TypeOf((*error)(nil)).Elem()
+
+// StreamCSVFile 从最新的 CSV 文件中逐行解码，并对每一行调用 fn，内存占用与文件大小无关，可通过 WithProgress 报告读取进度
+// fn 的类型为 func(T) error 或 func(*T) error，T 为结构体；fn 返回 csv.ErrStop 时提前结束且不返回错误
+// 返回的错误包含文件名和行号，gzip 压缩的文件会自动解压
+func StreamCSVFile(path string, fn any, opts ...Option) error {
+	fv, in, elemType, err := rowFunc(fn)
+	if err != nil {
+		return err
+	}
+	if elemType.Kind() != reflect.Struct {
+		return fmt.Errorf("fn must take a struct or a struct pointer, got %s", in)
+	}
+
+	dec, filename, closeFile, err := newOptions(opts).openCSVDecoder(path)
+	if err != nil {
+		return err
+	}
+	defer closeFile()
+
+	for row := 1; ; row++ {
+		elem := reflect.New(elemType)
+		if err = dec.Decode(elem.Interface()); err == io.EOF {
+			return nil
+		} else if err != nil {
+			return fmt.Errorf("%s: %w", filename, err)
+		}
+
+		arg := elem
+		if in.Kind() != reflect.Ptr {
+			arg = elem.Elem()
+		}
+		if out := fv.Call([]reflect.Value{arg})[0]; !out.IsNil() {
+			err = out.Interface().(error)
+			if errors.Is(err, csv.ErrStop) {
+				return nil
+			}
+			return fmt.Errorf("%s: row %d: %w", filename, row, err)
+		}
+	}
+}
+
+// rowFunc 校验 fn 的类型为 func(T) error 或 func(*T) error，返回 fn、参数类型和 T
+func rowFunc(fn any) (fv reflect.Value, in, elemType reflect.Type, err error) {
+	fv = reflect.ValueOf(fn)
+	if fv.Kind() != reflect.Func {
+		return fv, nil, nil, fmt.Errorf("fn must be a func(T) error, got %T", fn)
+	}
+	ft := fv.Type()
+	if ft.NumIn() != 1 || ft.NumOut() != 1 || ft.Out(0) != errorType {
+		return fv, nil, nil, fmt.Errorf("fn must be a func(T) error, got %T", fn)
+	}
+	in = ft.In(0)
+	elemType = in
+	if in.Kind() == reflect.Ptr {
+		elemType = in.Elem()
+	}
+	return fv, in, elemType, nil
+}
+
+// openCSVDecoder 打开与 path 匹配的最新 CSV 文件并返回逐行解码的 Decoder 及文件名，gzip 压缩的文件会自动解压，
+// 字符集按 WithCharset 转换，进度按 WithProgress 报告
+func (o *options) openCSVDecoder(path string) (dec *csv.Decoder, filename string, closeFile func() error, err error) {
+	filename, err = GetLatestFileByName(path, o.listOpts...)
+	if err != nil {
+		return nil, "", nil, fmt.Errorf("get latest file: %w", err)
+	}
+	f, err := os.Open(filename)
+	if err != nil {
+		return nil, "", nil, fmt.Errorf("read file: %w", err)
+	}
+	r, err := gunzipReader(o.fileReader(f))
+	if err != nil {
+		f.Close()
+		return nil, "", nil, fmt.Errorf("read file: %w", err)
+	}
+	if r, err = o.charsetReader(r); err != nil {
+		f.Close()
+		return nil, "", nil, fmt.Errorf("read file: %w", err)
+	}
+	return csv.NewDecoder(r, o.csvOptions()...), filename, f.Close, nil
+}
+
+// HeadCSVFile 解码最新的 CSV 文件的前 n 行数据并写入 out，读到 n 行后即停止，不读取文件的其余部分
+// out 为指向结构体切片或结构体指针切片的指针，原有内容会被替换；表头的处理与 csv 包相同，gzip 压缩的文件会自动解压
+func HeadCSVFile(path string, n int, out any) error {
+	target, elemType, err := csvSliceTarget(out, n)
+	if err != nil {
+		return err
+	}
+	dec, filename, closeFile, err := newOptions(nil).openCSVDecoder(path)
+	if err != nil {
+		return err
+	}
+	defer closeFile()
+
+	rows := reflect.MakeSlice(target.Type(), 0, n)
+	for rows.Len() < n {
+		elem := reflect.New(elemType)
+		if err = dec.Decode(elem.Interface()); err == io.EOF {
+			break
+		} else if err != nil {
+			return fmt.Errorf("%s: %w", filename, err)
+		}
+		rows = reflect.Append(rows, sliceElem(elem, target.Type().Elem()))
+	}
+	target.Set(rows)
+	return nil
+}
+
+// TailCSVFile 解码最新的 CSV 文件的最后 n 行数据并写入 out，用环形缓冲只保留 n 行，内存占用与文件大小无关
+// out 的要求与 HeadCSVFile 相同
+func TailCSVFile(path string, n int, out any) error {
+	target, elemType, err := csvSliceTarget(out, n)
+	if err != nil {
+		return err
+	}
+	dec, filename, closeFile, err := newOptions(nil).openCSVDecoder(path)
+	if err != nil {
+		return err
+	}
+	defer closeFile()
+
+	ring := make([]reflect.Value, n)
+	count := 0
+	for n > 0 {
+		elem := reflect.New(elemType)
+		if err = dec.Decode(elem.Interface()); err == io.EOF {
+			break
+		} else if err != nil {
+			return fmt.Errorf("%s: %w", filename, err)
+		}
+		ring[count%n] = elem
+		count++
+	}
+
+	size := min(count, n)
+	rows := reflect.MakeSlice(target.Type(), 0, size)
+	for i := count - size; i < count; i++ {
+		rows = reflect.Append(rows, sliceElem(ring[i%n], target.Type().Elem()))
+	}
+	target.Set(rows)
+	return nil
+}
+
+// csvSliceTarget 检查 out 是否为指向结构体切片或结构体指针切片的指针，返回切片及结构体类型
+func csvSliceTarget(out any, n int) (reflect.Value, reflect.Type, error) {
+	if n < 0 {
+		return reflect.Value{}, nil, errors.New("n must not be negative")
+	}
+	rv := reflect.ValueOf(out)
+	if rv.Kind() != reflect.Ptr || rv.IsNil() || rv.Elem().Kind() != reflect.Slice {
+		return reflect.Value{}, nil, fmt.Errorf("out must be a non-nil pointer to a slice, got %T", out)
+	}
+	elemType := rv.Elem().Type().Elem()
+	if elemType.Kind() == reflect.Ptr {
+		elemType = elemType.Elem()
+	}
+	if elemType.Kind() != reflect.Struct {
+		return reflect.Value{}, nil, fmt.Errorf("out must point to a slice of structs, got %T", out)
+	}
+	return rv.Elem(), elemType, nil
+}
+
+// sliceElem 将指向结构体的 elem 转换为切片元素类型 typ
+func sliceElem(elem reflect.Value, typ reflect.Type) reflect.Value {
+	if typ.Kind() == reflect.Ptr {
+		return elem
+	}
+	return elem.Elem()
+}
+
+// streamFlushRows 是 StreamWriteCSVFile 在生产者持续有数据时两次刷新之间的最大行数
+const streamFlushRows = 1000
+
+// StreamWriteCSVFile 创建 CSV 文件（path 中的 * 会替换为时间戳），按 T 写入表头后逐行写入 rows 中的记录，直到 rows 被关闭
+// rows 暂时没有数据时或每写入 1000 行会刷新到文件；写入出错后仍会读完 rows，避免生产者阻塞，并在 rows 关闭后返回该错误
+// 后缀名为 .gz 时以 gzip 压缩写入，目录、权限等选项与 SaveFile 相同
+func StreamWriteCSVFile[T any](path string, rows <-chan T, opts ...Option) (err error) {
+	defer func() {
+		// 出错时读完 rows，让生产者可以结束
+		for range rows {
+		}
+	}()
+
+	o := newOptions(opts)
+	filename, err := timestampFileName(path)
+	if err != nil {
+		return err
+	}
+	f, err := o.openFile(filename, fsutil.FsCWTFlags)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if cerr := f.Close(); err == nil {
+			err = cerr
+		}
+	}()
+
+	encOpts := o.csvOptions()
+	if _, gzipped := o.formatExt(path); gzipped {
+		encOpts = append(encOpts, csv.WithCompression(csv.Gzip))
+	}
+	var zero T
+	enc := csv.NewEncoder(f, zero, encOpts...)
+	if err = enc.WriteHeader(); err != nil {
+		return err
+	}
+
+	pending := 0
+	for row := range rows {
+		if err = enc.WriteRecord(row); err != nil {
+			return fmt.Errorf("write row: %w", err)
+		}
+		if pending++; len(rows) == 0 || pending >= streamFlushRows {
+			if err = enc.Flush(); err != nil {
+				return err
+			}
+			pending = 0
+		}
+	}
+	return enc.Close()
+}
@@ -0,0 +1,70 @@
+package fs
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+
+	"github.com/gookit/goutil/fsutil"
+)
+
+// WithSync 使 SaveFileWith、WriteFileWith 等在写入后调用 fsync，保证断电后文件内容和目录项都已落盘
+// 配合 WithAtomic 时的顺序为：写入临时文件、fsync 临时文件、重命名、fsync 父目录，断电后只会看到旧文件或完整的新文件
+// fsync 会显著增加写入耗时（见 BenchmarkSaveFileSync），仅在检查点等必须持久化的文件上开启
+func WithSync() Option {
+	return func(o *options) {
+		o.sync = true
+	}
+}
+
+// syncer 是可以 fsync 的文件，*os.File 实现了该接口
+type syncer interface {
+	Name() string
+	Sync() error
+}
+
+// syncFile 对 f 调用 fsync，测试中可以替换以检查调用顺序
+var syncFile = func(f syncer) error {
+	return f.Sync()
+}
+
+// writeOSFile 将 data 写入 f 并关闭 f，设置了 WithSync 时在关闭前 fsync
+func (o *options) writeOSFile(f *os.File, data any) error {
+	if !o.sync {
+		_, err := fsutil.WriteOSFile(f, o.progressData(data))
+		return err
+	}
+
+	var err error
+	switch v := o.progressData(data).(type) {
+	case []byte:
+		_, err = f.Write(v)
+	case string:
+		_, err = f.WriteString(v)
+	case io.Reader:
+		_, err = io.Copy(f, v)
+	default:
+		err = fmt.Errorf("unsupported data type %T, want []byte, string or io.Reader", data)
+	}
+	if err == nil {
+		if err = syncFile(f); err != nil {
+			err = fmt.Errorf("sync file: %w", err)
+		}
+	}
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
+
+// syncParentDir 在设置了 WithSync 时 fsync filename 所在的目录，使创建或重命名后的目录项落盘
+func (o *options) syncParentDir(filename string) error {
+	if !o.sync {
+		return nil
+	}
+	if err := syncDir(filepath.Dir(filename)); err != nil {
+		return fmt.Errorf("sync dir: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,8 @@
+//go:build !unix && !windows
+
+package fs
+
+// syncDir 在没有目录 fsync 的平台上不做任何事
+func syncDir(string) error {
+	return nil
+}
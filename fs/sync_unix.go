@@ -0,0 +1,15 @@
+//go:build unix
+
+package fs
+
+import "os"
+
+// syncDir fsync 目录 dir
+func syncDir(dir string) error {
+	d, err := os.Open(dir)
+	if err != nil {
+		return err
+	}
+	defer d.Close()
+	return syncFile(d)
+}
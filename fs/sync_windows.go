@@ -0,0 +1,8 @@
+//go:build windows
+
+package fs
+
+// syncDir 在 Windows 上无法对目录调用 fsync，NTFS 会以日志保证重命名等元数据操作的持久性
+func syncDir(string) error {
+	return nil
+}
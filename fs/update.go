@@ -0,0 +1,54 @@
+package fs
+
+import (
+	"errors"
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+// UpdateFile 在文件锁内读取与 path 匹配的最新文件，调用 fn 修改后以原子方式写回该文件，格式按后缀名选择，与 ReadFile/WriteFile 相同
+// 没有匹配的文件时返回 ErrNoMatch；配合 WithAllowMissing 则从 T 的零值开始，并写入替换时间戳后的 path
+// fn 返回错误时不写入。锁的等待时间可通过 WithLock 设置，默认一直等待
+func UpdateFile[T any](path string, fn func(*T) error, opts ...Option) error {
+	o := newOptions(opts)
+	return WithFileLock(lockPath(path), func() error {
+		var v T
+		target, err := GetLatestFileByName(path, o.listOpts...)
+		switch {
+		case err == nil:
+			if err = ReadFileWith(target, &v, opts...); err != nil {
+				return err
+			}
+		case errors.Is(err, ErrNoMatch) && o.allowMissing:
+			target = path
+		default:
+			return err
+		}
+
+		if err = fn(&v); err != nil {
+			return err
+		}
+		// 已经持有锁，写入时不再加锁；Clip 避免 append 写入调用方的底层数组
+		return WriteFileWith(target, &v, append(slices.Clip(opts), WithAtomic(), withoutLock())...)
+	}, opts...)
+}
+
+// lockPath 返回 path 对应的锁路径，文件名中的通配符和占位符替换为 _，使同一模式的所有文件共用一把锁
+func lockPath(path string) string {
+	base := strings.Map(func(r rune) rune {
+		switch r {
+		case '*', '?', '[', ']', '{', '}', ':':
+			return '_'
+		}
+		return r
+	}, filepath.Base(path))
+	return filepath.Join(filepath.Dir(path), base)
+}
+
+// withoutLock 关闭 WithLock
+func withoutLock() Option {
+	return func(o *options) {
+		o.lock = false
+	}
+}
@@ -0,0 +1,93 @@
+package fs
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// maxErrorBody 是错误信息中包含的响应内容的最大字节数
+const maxErrorBody = 512
+
+// contentTypeExts 将 Content-Type 映射为对应格式的后缀名
+var contentTypeExts = map[string]string{
+	"application/json":     ".json",
+	"text/json":            ".json",
+	"application/x-ndjson": ".ndjson",
+	"application/jsonl":    ".ndjson",
+	"text/csv":             ".csv",
+	"application/csv":      ".csv",
+	"application/yaml":     ".yaml",
+	"application/x-yaml":   ".yaml",
+	"text/yaml":            ".yaml",
+	"application/toml":     ".toml",
+	"application/xml":      ".xml",
+	"text/xml":             ".xml",
+}
+
+// ReadFileURL 通过 HTTP GET 获取 rawURL 的内容并反序列化到 out，请求受 ctx 的超时与取消控制
+// 格式依次由 WithUnmarshal、WithFormat、URL 路径的后缀名和响应的 Content-Type 决定；gzip 压缩的内容会自动解压
+// 非 200 的响应返回包含状态和部分响应内容的错误，可通过 WithHTTPClient 使用带认证等配置的 http.Client
+func ReadFileURL(ctx context.Context, rawURL string, out any, opts ...Option) error {
+	o := newOptions(opts)
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return fmt.Errorf("parse url: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u.String(), nil)
+	if err != nil {
+		return err
+	}
+	client := o.httpClient
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, maxErrorBody+1))
+		msg := strings.TrimSpace(string(body))
+		if len(body) > maxErrorBody {
+			msg = strings.TrimSpace(string(body[:maxErrorBody])) + "..."
+		}
+		return fmt.Errorf("GET %s: %s: %s", u.Redacted(), resp.Status, msg)
+	}
+
+	ext, _ := o.formatExt(u.Path)
+	if ext == "" {
+		if mediaType, _, err := mime.ParseMediaType(resp.Header.Get("Content-Type")); err == nil {
+			ext = contentTypeExts[mediaType]
+		}
+	}
+	if o.unmarshal == nil && isNDJSON(ext) {
+		r, err := gunzipReader(resp.Body)
+		if err != nil {
+			return fmt.Errorf("read body: %w", err)
+		}
+		if r, err = o.charsetReader(r); err != nil {
+			return fmt.Errorf("read body: %w", err)
+		}
+		if err = decodeNDJSON(r, out); err != nil {
+			return fmt.Errorf("unmarshal data: %w", err)
+		}
+		return nil
+	}
+
+	if o.maxSize > 0 && resp.ContentLength > o.maxSize {
+		return fmt.Errorf("%s: %w: %d bytes, limit is %d", u.Redacted(), ErrFileTooLarge, resp.ContentLength, o.maxSize)
+	}
+	data, err := o.readLimited(resp.Body, u.Redacted())
+	if err != nil {
+		return err
+	}
+	return o.unmarshalData(ext, data, out)
+}
@@ -0,0 +1,194 @@
+package fs
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	iofs "io/fs"
+	"time"
+)
+
+// watchNow 是 WatchFile 计算去抖时间的时钟，与轮询的 Ticker 一致使用真实时间，不受 SetClock 影响；测试中可以替换
+var watchNow = time.Now
+
+// WatchOption 配置 WatchFile
+type WatchOption func(*watchOptions)
+
+type watchOptions struct {
+	debounce    time.Duration
+	debounceSet bool
+	initial     bool
+	stableSize  bool
+}
+
+// WithDebounce 设置去抖时间，最新文件变化后须保持不变 d 才会回调，连续快速的变化只回调一次，默认等于轮询间隔
+func WithDebounce(d time.Duration) WatchOption {
+	return func(o *watchOptions) {
+		o.debounce = d
+		o.debounceSet = true
+	}
+}
+
+// WithInitial 启动时如果已有匹配的文件，立即以它调用一次回调，默认只在变化时回调
+func WithInitial() WatchOption {
+	return func(o *watchOptions) {
+		o.initial = true
+	}
+}
+
+// WithStableSize 使 WaitForFile 等到文件的大小和修改时间在连续两次轮询中保持不变，避免读到写了一半的文件
+func WithStableSize() WatchOption {
+	return func(o *watchOptions) {
+		o.stableSize = true
+	}
+}
+
+// fileIdentity 用路径、修改时间和大小标识一个文件的版本
+type fileIdentity struct {
+	path    string
+	modTime int64
+	size    int64
+}
+
+// latestIdentity 返回与 pattern 匹配的最新文件（新旧规则同 GetLatestFileByName）的标识，没有文件时返回零值
+func latestIdentity(ctx context.Context, pattern string) (fileIdentity, error) {
+	entries, err := listFiles(ctx, osFS{}, pattern, []ListOption{WithExcludeDirs()})
+	if err != nil || len(entries) == 0 {
+		return fileIdentity{}, err
+	}
+	latest := entries[0]
+	for _, entry := range entries[1:] {
+		if newerByName(entry, latest) {
+			latest = entry
+		}
+	}
+	return fileIdentity{path: latest.Path, modTime: latest.ModTime.UnixNano(), size: latest.Size}, nil
+}
+
+// WatchFile 每隔 interval 轮询与 pattern 匹配的最新文件，文件名或内容（修改时间、大小）变化时以它的路径调用 onChange
+// 最新的文件被删除时不回调。onChange 在 WatchFile 所在的 goroutine 中执行，执行期间暂停轮询
+// ctx 取消时返回 ctx.Err()，模式非法等错误则立即返回
+func WatchFile(ctx context.Context, pattern string, interval time.Duration, onChange func(path string), opts ...WatchOption) error {
+	if interval <= 0 {
+		return errors.New("interval must be positive")
+	}
+	o := &watchOptions{}
+	for _, opt := range opts {
+		if opt != nil {
+			opt(o)
+		}
+	}
+	if !o.debounceSet {
+		o.debounce = interval
+	}
+
+	last, err := latestIdentity(ctx, pattern)
+	if err != nil && !errors.Is(err, iofs.ErrNotExist) {
+		return err
+	}
+	if o.initial && last.path != "" {
+		onChange(last.path)
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	var pending fileIdentity
+	var pendingSince time.Time
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+
+		cur, err := latestIdentity(ctx, pattern)
+		if err != nil {
+			// 文件在列出和读取信息之间被删除，下次轮询再看
+			if errors.Is(err, iofs.ErrNotExist) {
+				continue
+			}
+			return err
+		}
+		if cur == last || cur.path == "" {
+			pending = fileIdentity{}
+			continue
+		}
+		if cur != pending {
+			pending, pendingSince = cur, watchNow()
+		}
+		if watchNow().Sub(pendingSince) >= o.debounce {
+			last, pending = cur, fileIdentity{}
+			onChange(cur.path)
+		}
+	}
+}
+
+// WatchAndReload 在 WatchFile 的基础上按后缀名解码每个新的最新文件，并将结果发送到返回的第一个通道，启动时先发送已有的文件
+// 解码失败时将错误（包含文件名）发送到第二个通道，不发送值，调用方可继续使用上一次成功的结果
+// ctx 取消或监视出错后两个通道都会关闭，监视本身的错误（ctx 取消除外）会先发送到错误通道
+func WatchAndReload[T any](ctx context.Context, pattern string, interval time.Duration, opts ...WatchOption) (<-chan T, <-chan error) {
+	values := make(chan T)
+	errs := make(chan error, 1)
+	go func() {
+		defer close(values)
+		defer close(errs)
+
+		err := WatchFile(ctx, pattern, interval, func(path string) {
+			var v T
+			if err := ReadFileCtx(ctx, path, &v); err != nil {
+				select {
+				case errs <- fmt.Errorf("%s: %w", path, err):
+				case <-ctx.Done():
+				}
+				return
+			}
+			select {
+			case values <- v:
+			case <-ctx.Done():
+			}
+		}, append([]WatchOption{WithInitial()}, opts...)...)
+		if err != nil && ctx.Err() == nil {
+			errs <- err
+		}
+	}()
+	return values, errs
+}
+
+// WaitForFile 每隔 pollInterval 轮询一次，直到出现与 pattern 匹配的文件，返回其中最新的文件（新旧规则同 GetLatestFileByName）
+// 配合 WithStableSize 则还要等到文件在两次轮询之间没有变化。ctx 取消或超时时返回包含模式的 ctx.Err()
+func WaitForFile(ctx context.Context, pattern string, pollInterval time.Duration, opts ...WatchOption) (string, error) {
+	if pollInterval <= 0 {
+		return "", errors.New("poll interval must be positive")
+	}
+	o := &watchOptions{}
+	for _, opt := range opts {
+		if opt != nil {
+			opt(o)
+		}
+	}
+
+	ticker := time.NewTicker(pollInterval)
+	defer ticker.Stop()
+
+	var prev fileIdentity
+	for {
+		cur, err := latestIdentity(ctx, pattern)
+		if ctx.Err() != nil {
+			return "", fmt.Errorf("wait for %s: %w", pattern, ctx.Err())
+		}
+		if err != nil && !errors.Is(err, iofs.ErrNotExist) {
+			return "", err
+		}
+		if cur.path != "" && (!o.stableSize || cur == prev) {
+			return cur.path, nil
+		}
+		prev = cur
+
+		select {
+		case <-ctx.Done():
+			return "", fmt.Errorf("wait for %s: %w", pattern, ctx.Err())
+		case <-ticker.C:
+		}
+	}
+}
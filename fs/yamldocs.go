@@ -0,0 +1,95 @@
+package fs
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"reflect"
+
+	"gopkg.in/yaml.v3"
+)
+
+// ReadYAMLDocuments 从最新的 YAML 文件中读取以 --- 分隔的多个文档，每个文档解码为 out 指向的切片中的一个元素
+// 空文档会被跳过，解码失败时报告从 1 开始的文档序号
+func ReadYAMLDocuments(path string, out any) error {
+	rv := reflect.ValueOf(out)
+	if rv.Kind() != reflect.Ptr || rv.IsNil() || rv.Elem().Kind() != reflect.Slice {
+		return errors.New("out must be a non-nil pointer to a slice")
+	}
+
+	filename, err := GetLatestFileByName(path)
+	if err != nil {
+		return fmt.Errorf("get latest file: %w", err)
+	}
+	f, err := os.Open(filename)
+	if err != nil {
+		return fmt.Errorf("read file: %w", err)
+	}
+	defer f.Close()
+	r, err := gunzipReader(f)
+	if err != nil {
+		return fmt.Errorf("read file: %w", err)
+	}
+
+	slice := rv.Elem()
+	dec := yaml.NewDecoder(r)
+	for doc := 1; ; doc++ {
+		var node yaml.Node
+		if err = dec.Decode(&node); err == io.EOF {
+			return nil
+		} else if err != nil {
+			return fmt.Errorf("document %d: %w", doc, err)
+		}
+		if isEmptyDocument(&node) {
+			continue
+		}
+		elem := reflect.New(slice.Type().Elem())
+		if err = node.Decode(elem.Interface()); err != nil {
+			return fmt.Errorf("document %d: %w", doc, err)
+		}
+		slice.Set(reflect.Append(slice, elem.Elem()))
+	}
+}
+
+// isEmptyDocument 判断文档是否没有内容，例如连续的两个 --- 之间
+func isEmptyDocument(node *yaml.Node) bool {
+	if node.Kind == 0 || (node.Kind == yaml.DocumentNode && len(node.Content) == 0) {
+		return true
+	}
+	if node.Kind == yaml.DocumentNode && len(node.Content) == 1 {
+		content := node.Content[0]
+		return content.Kind == yaml.ScalarNode && content.Tag == "!!null" && content.Value == ""
+	}
+	return false
+}
+
+// WriteYAMLDocuments 将切片 data 的每个元素写为一个 YAML 文档，文档之间以 --- 分隔
+// 如果 path 中包含 *，则会替换为当前时间戳（格式为 20060102_150405）
+func WriteYAMLDocuments(path string, data any, opts ...Option) error {
+	return WriteFileWith(path, data, append([]Option{WithMarshal(marshalYAMLDocuments)}, opts...)...)
+}
+
+// marshalYAMLDocuments 将切片或数组的每个元素序列化为一个 YAML 文档
+func marshalYAMLDocuments(v any) ([]byte, error) {
+	rv := reflect.ValueOf(v)
+	for rv.Kind() == reflect.Ptr && !rv.IsNil() {
+		rv = rv.Elem()
+	}
+	if rv.Kind() != reflect.Slice && rv.Kind() != reflect.Array {
+		return nil, fmt.Errorf("data must be a slice, got %T", v)
+	}
+
+	var buf bytes.Buffer
+	enc := yaml.NewEncoder(&buf)
+	for i := 0; i < rv.Len(); i++ {
+		if err := enc.Encode(rv.Index(i).Interface()); err != nil {
+			return nil, fmt.Errorf("document %d: %w", i+1, err)
+		}
+	}
+	if err := enc.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
@@ -0,0 +1,123 @@
+package fs
+
+import (
+	"archive/zip"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+)
+
+// ZipFiles 按文件名的自然顺序将与 pattern 匹配的所有文件打包为 dstZip（其中的 * 会替换为时间戳），返回被打包的文件
+// 压缩包中的条目只保留文件名并保留修改时间，不同目录下的文件重名时返回错误且不创建压缩包；dstZip 本身不参与打包
+// 各文件逐个流式写入同一目录下的临时文件后再重命名，创建目录、权限等选项与 SaveFile 相同
+func ZipFiles(pattern, dstZip string, opts ...Option) ([]string, error) {
+	dst, err := timestampFileName(dstZip)
+	if err != nil {
+		return nil, err
+	}
+	entries, err := ListFiles(pattern, WithExcludeDirs())
+	if err != nil {
+		return nil, err
+	}
+	var files []string
+	seen := make(map[string]string)
+	for _, entry := range entries {
+		if filepath.Clean(entry.Path) == filepath.Clean(dst) {
+			continue
+		}
+		name := filepath.Base(entry.Path)
+		if prev, ok := seen[name]; ok {
+			return nil, fmt.Errorf("duplicate zip entry %s: %s and %s", name, prev, entry.Path)
+		}
+		seen[name] = entry.Path
+		files = append(files, entry.Path)
+	}
+	if len(files) == 0 {
+		return nil, ErrNoMatch
+	}
+
+	pr, pw := io.Pipe()
+	go func() {
+		pw.CloseWithError(writeZip(pw, files))
+	}()
+	o := newOptions(opts)
+	o.atomic = true
+	err = o.lockWrite(dstZip, func() error {
+		return o.writeNamed(dst, pr)
+	})
+	// 写入失败时让 writeZip 结束
+	pr.CloseWithError(err)
+	if err != nil {
+		return nil, fmt.Errorf("zip files: %w", err)
+	}
+	return files, nil
+}
+
+// writeZip 将 files 逐个写入 w 中的 zip 压缩包
+func writeZip(w io.Writer, files []string) error {
+	zw := zip.NewWriter(w)
+	for _, file := range files {
+		if err := addZipEntry(zw, file); err != nil {
+			return err
+		}
+	}
+	return zw.Close()
+}
+
+// addZipEntry 以 file 的文件名为条目名将其内容写入 zw
+func addZipEntry(zw *zip.Writer, file string) error {
+	f, err := os.Open(file)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		return err
+	}
+	header, err := zip.FileInfoHeader(info)
+	if err != nil {
+		return fmt.Errorf("%s: %w", file, err)
+	}
+	header.Method = zip.Deflate
+	ew, err := zw.CreateHeader(header)
+	if err != nil {
+		return fmt.Errorf("%s: %w", file, err)
+	}
+	if _, err = io.Copy(ew, f); err != nil {
+		return fmt.Errorf("%s: %w", file, err)
+	}
+	return nil
+}
+
+// ReadFileFromZip 在与 zipPath 匹配的最新压缩包中选择与 namePattern 匹配的最新条目，并按其后缀名解码到 out
+// namePattern 使用 io/fs 的路径格式，条目按文件名中的时间戳或自然顺序选择最新的一个，其余与 ReadFileFS 相同
+// CSV 和 NDJSON 条目以流的方式边解压边解码，不会一次性读入内存
+func ReadFileFromZip(zipPath, namePattern string, out any, opts ...Option) error {
+	filename, err := GetLatestFileByName(zipPath)
+	if err != nil {
+		return fmt.Errorf("get latest file: %w", err)
+	}
+	zr, err := zip.OpenReader(filename)
+	if err != nil {
+		return fmt.Errorf("open zip: %w", err)
+	}
+	defer zr.Close()
+
+	o := newOptions(opts)
+	entry, err := getFileBy(o.ctx, zr, namePattern, newerByName, o.listOpts)
+	if err != nil {
+		return fmt.Errorf("%s: get latest file: %w", filename, err)
+	}
+	if ext, _ := o.formatExt(entry); ext == ".csv" && o.unmarshal == nil {
+		err = o.readCSVFile(zr, entry, out)
+	} else {
+		// NDJSON 在 readFile 中同样以流的方式解码，其他格式需要完整的内容
+		err = o.readFile(zr, entry, out)
+	}
+	if err != nil {
+		return fmt.Errorf("%s: %w", filename, o.fileError("read", entry, err))
+	}
+	return nil
+}